@@ -18,10 +18,33 @@ const (
 	defRefreshInterval = "10s"
 	defLogPath         = "grafterm.log"
 	defGraftermDir     = "grafterm"
+	defSnapshotWidth   = "160"
+	defSnapshotHeight  = "50"
 )
 
 var defUserDatasourcePath = []string{defGraftermDir, "datasources.json"}
 
+// Commands.
+const (
+	cmdRun           = "run"
+	cmdImportGrafana = "import-grafana"
+	cmdSnapshot      = "snapshot"
+	cmdQuery         = "query"
+)
+
+// Snapshot formats.
+const (
+	snapshotFormatPlain = "plain"
+	snapshotFormatANSI  = "ansi"
+)
+
+// Query output formats.
+const (
+	queryFormatTable = "table"
+	queryFormatCSV   = "csv"
+	queryFormatJSON  = "json"
+)
+
 // Env vars.
 const (
 	envPrefix          = "GRAFTERM"
@@ -30,37 +53,90 @@ const (
 
 // flag descriptions.
 const (
-	descCfg             = "the path to the configuration file"
-	descRefreshInterval = "the interval to refresh the dashboard"
-	descLogPath         = "the path where the log output will be written"
-	descRelativeDur     = "the relative duration from now to load the graph."
-	descStart           = "the time the dashboard will start in time. Accepts 2 formats, relative time from now based on duration(e.g.: 24h, 15m), or fixed duration in ISO 8601 (e.g.: 2019-05-12T09:35:11+00:00). If set it disables relative duration flag."
-	descEnd             = "the time the dashboard will end in time. Accepts 2 formats, relative time from now based on duration(e.g.: 24h, 15m), or fixed duration in ISO 8601 (e.g.: 2019-05-12T09:35:11+00:00)."
-	descDebug           = "enable debug mode, on debug mode it will print logs to the desired output"
-	descVar             = "repeatable flag that will override the variable defined on the dashboard (in 'key=value' form)"
-	descDSAlias         = "repeatable flag that maps dashboard ID datasources to user defined datasources in the form of 'dashboard=user' (in 'key=value' form)"
-	descLegacyMode      = "use legacy mode for backward compatibility (disables caching, retry logic, and enhanced timeouts)"
-	descDisableCache    = "disable metric caching (overrides default when not in legacy mode)"
-	descDisableRetry    = "disable query retry logic (overrides default when not in legacy mode)"
+	descCfg                   = "the path to the configuration file"
+	descRefreshInterval       = "the interval to refresh the dashboard"
+	descLogPath               = "the path where the log output will be written"
+	descRelativeDur           = "the relative duration from now to load the graph."
+	descStart                 = "the time the dashboard will start in time. Accepts 2 formats, relative time from now based on duration(e.g.: 24h, 15m), or fixed duration in ISO 8601 (e.g.: 2019-05-12T09:35:11+00:00). If set it disables relative duration flag."
+	descEnd                   = "the time the dashboard will end in time. Accepts 2 formats, relative time from now based on duration(e.g.: 24h, 15m), or fixed duration in ISO 8601 (e.g.: 2019-05-12T09:35:11+00:00)."
+	descDebug                 = "enable debug mode, on debug mode it will print logs to the desired output"
+	descVar                   = "repeatable flag that will override the variable defined on the dashboard (in 'key=value' form)"
+	descDSAlias               = "repeatable flag that maps dashboard ID datasources to user defined datasources in the form of 'dashboard=user' (in 'key=value' form)"
+	descLegacyMode            = "use legacy mode for backward compatibility (disables caching, retry logic, and enhanced timeouts)"
+	descDisableCache          = "disable metric caching (overrides default when not in legacy mode)"
+	descDisableRetry          = "disable query retry logic (overrides default when not in legacy mode)"
+	descDisableCircuitBreaker = "disable the per datasource circuit breaker (overrides default when not in legacy mode)"
+	descQueryTimeout          = "the maximum duration of the datasource queries, datasources can override it (ignored in legacy mode)"
+	descMaxConcurrentQueries  = "the maximum number of queries made at the same time to each datasource, datasources can override it (ignored in legacy mode)"
+	descMaxRetries            = "the number of times a failed query is retried, datasources can override it (ignored in legacy mode)"
+	descCacheSize             = "the maximum number of cached single value queries of each datasource, datasources can override it (ignored in legacy mode)"
+	descCacheTTL              = "the time the single value query results of the datasources are cached, datasources can override it (ignored in legacy mode)"
+
+	descCmdRun           = "render the dashboard on the terminal (default)"
+	descCmdImportGrafana = "convert a Grafana dashboard JSON into a grafterm dashboard"
+	descImportInput      = "the path to the Grafana dashboard JSON file, '-' reads from stdin"
+	descImportOutput     = "the path where the grafterm dashboard will be written, by default stdout"
+	descImportDefaultDS  = "the datasource ID used by the panels that use the Grafana default datasource"
+	descCmdSnapshot      = "render the dashboard once as text without a terminal (e.g: CI, tickets...)"
+	descSnapshotWidth    = "the width in characters of the snapshot"
+	descSnapshotHeight   = "the height in lines of the snapshot"
+	descSnapshotFormat   = "the format of the snapshot, plain text or text with ANSI colors"
+	descCmdQuery         = "run a single query against a datasource and print the result"
+	descQueryExpr        = "the query expression, accepts the variables as the widgets (e.g: {{ .job }})"
+	descQueryDatasource  = "the ID of the datasource used to run the query (dashboard, user or aliased datasource)"
+	descQueryRange       = "run a range query using the time range flags instead of an instant query at the end of the time range"
+	descQueryStep        = "the step of the range query, by default is calculated based on the time range"
+	descQueryFormat      = "the output format of the query result"
 )
 
 var descUserDS = fmt.Sprintf("path to a configuration file with user defined datasources, these datasources can override the dashboard datasources with the same ID and also can be used to alias them using datasource alias flags. It fallbacks to %s env var", envUserDatasources)
 
 type flags struct {
-	variables       map[string]string
-	aliases         map[string]string
-	cfg             string
-	userDSPath      string
-	debug           bool
-	version         bool
-	refreshInterval time.Duration
-	logPath         string
-	start           string
-	relativeDur     time.Duration
-	end             string
-	legacyMode      bool
-	disableCache    bool
-	disableRetry    bool
+	cmd                   string
+	variables             map[string]string
+	aliases               map[string]string
+	cfg                   string
+	userDSPath            string
+	debug                 bool
+	version               bool
+	refreshInterval       time.Duration
+	logPath               string
+	start                 string
+	relativeDur           time.Duration
+	end                   string
+	legacyMode            bool
+	disableCache          bool
+	disableRetry          bool
+	disableCircuitBreaker bool
+	queryTimeout          time.Duration
+	maxConcurrentQueries  int
+	maxRetries            int
+	cacheSize             int64
+	cacheTTL              time.Duration
+
+	importGrafana importGrafanaFlags
+	snapshot      snapshotFlags
+	query         queryFlags
+}
+
+type importGrafanaFlags struct {
+	input               string
+	output              string
+	defaultDatasourceID string
+}
+
+type snapshotFlags struct {
+	width  int
+	height int
+	format string
+}
+
+type queryFlags struct {
+	expr         string
+	datasourceID string
+	rangeQuery   bool
+	step         time.Duration
+	format       string
 }
 
 func newFlags() (*flags, error) {
@@ -96,7 +172,38 @@ func newFlags() (*flags, error) {
 	app.Flag("legacy-mode", descLegacyMode).BoolVar(&flags.legacyMode)
 	app.Flag("disable-cache", descDisableCache).BoolVar(&flags.disableCache)
 	app.Flag("disable-retry", descDisableRetry).BoolVar(&flags.disableRetry)
-	app.Parse(os.Args[1:])
+	app.Flag("disable-circuit-breaker", descDisableCircuitBreaker).BoolVar(&flags.disableCircuitBreaker)
+	app.Flag("query-timeout", descQueryTimeout).DurationVar(&flags.queryTimeout)
+	app.Flag("max-concurrent-queries", descMaxConcurrentQueries).IntVar(&flags.maxConcurrentQueries)
+	app.Flag("max-retries", descMaxRetries).IntVar(&flags.maxRetries)
+	app.Flag("cache-size", descCacheSize).Int64Var(&flags.cacheSize)
+	app.Flag("cache-ttl", descCacheTTL).DurationVar(&flags.cacheTTL)
+
+	// Register commands.
+	app.Command(cmdRun, descCmdRun).Default()
+
+	importGrafana := app.Command(cmdImportGrafana, descCmdImportGrafana)
+	importGrafana.Arg("file", descImportInput).Default("-").StringVar(&flags.importGrafana.input)
+	importGrafana.Flag("output", descImportOutput).Short('o').StringVar(&flags.importGrafana.output)
+	importGrafana.Flag("default-datasource", descImportDefaultDS).Default("default").StringVar(&flags.importGrafana.defaultDatasourceID)
+
+	snapshot := app.Command(cmdSnapshot, descCmdSnapshot)
+	snapshot.Flag("width", descSnapshotWidth).Default(defSnapshotWidth).IntVar(&flags.snapshot.width)
+	snapshot.Flag("height", descSnapshotHeight).Default(defSnapshotHeight).IntVar(&flags.snapshot.height)
+	snapshot.Flag("format", descSnapshotFormat).Default(snapshotFormatPlain).EnumVar(&flags.snapshot.format, snapshotFormatPlain, snapshotFormatANSI)
+
+	query := app.Command(cmdQuery, descCmdQuery)
+	query.Arg("expr", descQueryExpr).Required().StringVar(&flags.query.expr)
+	query.Flag("datasource", descQueryDatasource).Required().StringVar(&flags.query.datasourceID)
+	query.Flag("range", descQueryRange).BoolVar(&flags.query.rangeQuery)
+	query.Flag("step", descQueryStep).DurationVar(&flags.query.step)
+	query.Flag("format", descQueryFormat).Short('f').Default(queryFormatTable).EnumVar(&flags.query.format, queryFormatTable, queryFormatCSV, queryFormatJSON)
+
+	cmd, err := app.Parse(os.Args[1:])
+	if err != nil {
+		return nil, err
+	}
+	flags.cmd = cmd
 
 	if err := flags.validate(); err != nil {
 		return nil, err
@@ -106,5 +213,17 @@ func newFlags() (*flags, error) {
 }
 
 func (f *flags) validate() error {
+	if f.cmd == cmdSnapshot && (f.snapshot.width <= 0 || f.snapshot.height <= 0) {
+		return fmt.Errorf("snapshot width and height should be > 0")
+	}
+
+	if f.cmd == cmdQuery && f.query.step < 0 {
+		return fmt.Errorf("query step can't be negative")
+	}
+
+	if f.queryTimeout < 0 || f.maxConcurrentQueries < 0 || f.maxRetries < 0 || f.cacheSize < 0 || f.cacheTTL < 0 {
+		return fmt.Errorf("query timeout, max concurrent queries, max retries, cache size and cache TTL can't be negative")
+	}
+
 	return nil
 }
@@ -0,0 +1,90 @@
+package main
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"os"
+	"strings"
+
+	"github.com/slok/grafterm/internal/service/configuration"
+	"github.com/slok/grafterm/internal/service/configuration/grafana"
+)
+
+// importGrafana converts a Grafana dashboard into a grafterm dashboard and
+// prints the summary of the import.
+func (m *Main) importGrafana() error {
+	flags := m.flags.importGrafana
+
+	var r io.Reader = os.Stdin
+	if flags.input != "-" {
+		f, err := os.Open(flags.input)
+		if err != nil {
+			return err
+		}
+		defer f.Close()
+		r = f
+	}
+
+	importer := grafana.NewImporter(grafana.ImporterConfig{
+		DefaultDatasourceID: flags.defaultDatasourceID,
+	})
+	cfg, report, err := importer.Import(r)
+	if err != nil {
+		return fmt.Errorf("error importing Grafana dashboard: %s", err)
+	}
+
+	bs, err := json.MarshalIndent(cfg, "", "  ")
+	if err != nil {
+		return err
+	}
+	bs = append(bs, '\n')
+
+	// Check the imported dashboard is valid loading it as a user would do.
+	icfg, err := configuration.JSONLoader{}.Load(bytes.NewReader(bs))
+	if err != nil {
+		return err
+	}
+	_, err = icfg.Dashboard()
+	if err != nil {
+		return fmt.Errorf("imported dashboard is not valid: %s", err)
+	}
+
+	if flags.output == "" {
+		_, err = os.Stdout.Write(bs)
+	} else {
+		err = ioutil.WriteFile(flags.output, bs, 0644)
+	}
+	if err != nil {
+		return err
+	}
+
+	printImportReport(os.Stderr, report)
+
+	return nil
+}
+
+// printImportReport prints the summary of the import.
+func printImportReport(w io.Writer, r *grafana.Report) {
+	fmt.Fprintf(w, "Imported %d widgets and %d variables.\n", r.Widgets, r.Variables)
+
+	if len(r.UnsupportedPanels) > 0 {
+		fmt.Fprintf(w, "\nUnsupported panels (%d):\n", len(r.UnsupportedPanels))
+		for _, p := range r.UnsupportedPanels {
+			fmt.Fprintf(w, "  - %q (%s): %s\n", p.Title, p.Type, p.Reason)
+		}
+	}
+
+	if len(r.Warnings) > 0 {
+		fmt.Fprintf(w, "\nWarnings (%d):\n", len(r.Warnings))
+		for _, warn := range r.Warnings {
+			fmt.Fprintf(w, "  - %s\n", warn)
+		}
+	}
+
+	if len(r.Datasources) > 0 {
+		fmt.Fprintf(w, "\nReferenced datasources, configure them as user datasources or map them with --ds-alias: %s\n", strings.Join(r.Datasources, ", "))
+	}
+}
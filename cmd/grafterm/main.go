@@ -36,6 +36,10 @@ func (m *Main) Run() error {
 		return nil
 	}
 
+	if m.flags.cmd == cmdImportGrafana {
+		return m.importGrafana()
+	}
+
 	// If debug mode then use a verbose logger.
 	m.logger = log.Dummy
 	if m.flags.debug {
@@ -50,6 +54,15 @@ func (m *Main) Run() error {
 		})
 	}
 
+	// The context of the app lifecycle, the background work of the
+	// datasources will stop when the app exits.
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+
+	if m.flags.cmd == cmdQuery {
+		return m.query(ctx)
+	}
+
 	// Load Dashboard.
 	cfg, err := loadConfiguration(m.flags.cfg)
 	if err != nil {
@@ -66,7 +79,7 @@ func (m *Main) Run() error {
 		return err
 	}
 
-	gatherer, err := m.createGatherer(ddss, udss)
+	gatherer, err := m.createGatherer(ctx, ddss, udss)
 	if err != nil {
 		return err
 	}
@@ -74,9 +87,21 @@ func (m *Main) Run() error {
 	// Create controller.
 	ctrl := controller.NewController(gatherer)
 
+	appcfg, err := m.appConfig()
+	if err != nil {
+		return err
+	}
+
+	ds, err := cfg.Dashboard()
+	if err != nil {
+		return err
+	}
+
+	if m.flags.cmd == cmdSnapshot {
+		return m.snapshot(ctx, appcfg, ds, ctrl)
+	}
+
 	// Create renderer.
-	ctx, cancel := context.WithCancel(context.Background())
-	defer cancel()
 	renderer, err := termdash.NewTermDashboard(cancel, m.logger)
 	if err != nil {
 		return err
@@ -108,36 +133,6 @@ func (m *Main) Run() error {
 
 	// Run application.
 	{
-		appcfg := view.AppConfig{
-			RefreshInterval:   m.flags.refreshInterval,
-			RelativeTimeRange: m.flags.relativeDur,
-		}
-
-		// Only set fixed time if start set.
-		if m.flags.start != "" {
-			start, err := timeFromFlag(m.flags.start)
-			if err != nil {
-				return fmt.Errorf("error parsing start flag: %s", err)
-			}
-			end, err := timeFromFlag(m.flags.end)
-			if err != nil {
-				return fmt.Errorf("error parsing end flag: %s", err)
-			}
-
-			appcfg.TimeRangeStart = start
-			appcfg.TimeRangeEnd = end
-
-			// Check times are correct.
-			if !appcfg.TimeRangeEnd.IsZero() && appcfg.TimeRangeEnd.Before(appcfg.TimeRangeStart) {
-				return fmt.Errorf("end timestamp can't be before start timestamp")
-			}
-		}
-
-		ds, err := cfg.Dashboard()
-		if err != nil {
-			return err
-		}
-
 		app, err := m.createApp(ctx, appcfg, ds, ctrl, renderer)
 		if err != nil {
 			return err
@@ -160,6 +155,36 @@ func (m *Main) Run() error {
 	return g.Run()
 }
 
+// appConfig returns the app configuration based on the flags.
+func (m *Main) appConfig() (view.AppConfig, error) {
+	appcfg := view.AppConfig{
+		RefreshInterval:   m.flags.refreshInterval,
+		RelativeTimeRange: m.flags.relativeDur,
+	}
+
+	// Only set fixed time if start set.
+	if m.flags.start != "" {
+		start, err := timeFromFlag(m.flags.start)
+		if err != nil {
+			return appcfg, fmt.Errorf("error parsing start flag: %s", err)
+		}
+		end, err := timeFromFlag(m.flags.end)
+		if err != nil {
+			return appcfg, fmt.Errorf("error parsing end flag: %s", err)
+		}
+
+		appcfg.TimeRangeStart = start
+		appcfg.TimeRangeEnd = end
+
+		// Check times are correct.
+		if !appcfg.TimeRangeEnd.IsZero() && appcfg.TimeRangeEnd.Before(appcfg.TimeRangeStart) {
+			return appcfg, fmt.Errorf("end timestamp can't be before start timestamp")
+		}
+	}
+
+	return appcfg, nil
+}
+
 func loadConfiguration(cfgPath string) (configuration.Configuration, error) {
 	// Load dashboard file.
 	f, err := os.Open(cfgPath)
@@ -193,7 +218,7 @@ func (m *Main) loadUserDatasources() ([]model.Datasource, error) {
 	return cfg.Datasources()
 }
 
-func (m *Main) createGatherer(dashboardDss, userDss []model.Datasource) (metric.Gatherer, error) {
+func (m *Main) createGatherer(ctx context.Context, dashboardDss, userDss []model.Datasource) (metric.Gatherer, error) {
 	// Determine enhanced features configuration based on flags
 	var enhancedCfg *metric.EnhancedFeaturesConfig
 	if m.flags.legacyMode {
@@ -212,15 +237,35 @@ func (m *Main) createGatherer(dashboardDss, userDss []model.Datasource) (metric.
 			defaultCfg.EnableRetry = false
 			m.logger.Infof("Query retry logic disabled by flag")
 		}
+		if m.flags.disableCircuitBreaker {
+			defaultCfg.EnableCircuitBreaker = false
+			m.logger.Infof("Datasource circuit breaker disabled by flag")
+		}
+		if m.flags.queryTimeout > 0 {
+			defaultCfg.QueryTimeout = m.flags.queryTimeout
+		}
+		if m.flags.maxConcurrentQueries > 0 {
+			defaultCfg.MaxConcurrentQueries = m.flags.maxConcurrentQueries
+		}
+		if m.flags.maxRetries > 0 {
+			defaultCfg.MaxRetries = m.flags.maxRetries
+		}
+		if m.flags.cacheSize > 0 {
+			defaultCfg.CacheSize = m.flags.cacheSize
+		}
+		if m.flags.cacheTTL > 0 {
+			defaultCfg.CacheTTL = m.flags.cacheTTL
+		}
 		enhancedCfg = &defaultCfg
-		m.logger.Infof("Enhanced features enabled (caching: %v, retry: %v, timeout: %v)",
-			enhancedCfg.EnableCaching, enhancedCfg.EnableRetry, enhancedCfg.QueryTimeout)
+		m.logger.Infof("Enhanced features enabled (caching: %v, retry: %v, timeout: %v, max concurrent queries: %d)",
+			enhancedCfg.EnableCaching, enhancedCfg.EnableRetry, enhancedCfg.QueryTimeout, enhancedCfg.MaxConcurrentQueries)
 	}
 
 	gatherer, err := metricdatasource.NewGatherer(metricdatasource.ConfigGatherer{
 		DashboardDatasources: dashboardDss,
 		UserDatasources:      userDss,
 		Aliases:              m.flags.aliases,
+		Context:              ctx,
 		EnhancedFeatures:     enhancedCfg,
 	})
 	if err != nil {
@@ -228,6 +273,18 @@ func (m *Main) createGatherer(dashboardDss, userDss []model.Datasource) (metric.
 	}
 	gatherer = metricmiddleware.Logger(m.logger, gatherer)
 
+	// Reject the queries of the failing datasources outside the logger so
+	// only the queries that reach the datasources are logged.
+	if enhancedCfg.EnableCircuitBreaker {
+		gatherer = metricmiddleware.CircuitBreaker(metricmiddleware.CircuitBreakerConfig{}, gatherer)
+	}
+
+	// Collapse the identical queries of the widgets that are synced at the
+	// same time before they reach the cache.
+	if enhancedCfg.Enabled {
+		gatherer = metricmiddleware.SingleFlight(m.logger, gatherer)
+	}
+
 	return gatherer, nil
 }
 
@@ -245,6 +302,12 @@ func (m *Main) createApp(ctx context.Context, appCfg view.AppConfig, dashboard m
 		return nil, err
 	}
 	app := view.NewApp(appCfg, syncer, m.logger)
+
+	// Let the user change the time range from the renderer.
+	if tr, ok := renderer.(render.TimeRangeRenderer); ok {
+		tr.OnTimeRangeChange(app.ChangeTimeRange)
+	}
+
 	return app, nil
 }
 
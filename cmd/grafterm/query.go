@@ -0,0 +1,246 @@
+package main
+
+import (
+	"context"
+	"encoding/csv"
+	"encoding/json"
+	"fmt"
+	"io"
+	"math"
+	"os"
+	"sort"
+	"strconv"
+	"strings"
+	"text/tabwriter"
+	"time"
+
+	"github.com/slok/grafterm/internal/model"
+	"github.com/slok/grafterm/internal/service/unit"
+	"github.com/slok/grafterm/internal/view/template"
+)
+
+const (
+	queryTimeout        = 30 * time.Second
+	queryDefRelativeDur = 1 * time.Hour
+	queryDefSteps       = 50
+)
+
+// query runs a single query against a datasource and writes the
+// resulting series on the stdout.
+func (m *Main) query(ctx context.Context) error {
+	flags := m.flags.query
+
+	// The dashboard is optional, is only used to get its datasources.
+	ddss := []model.Datasource{}
+	cfg, err := loadConfiguration(m.flags.cfg)
+	switch {
+	case err == nil:
+		ddss, err = cfg.Datasources()
+		if err != nil {
+			return err
+		}
+	case os.IsNotExist(err):
+		m.logger.Warnf("could not load '%s' dashboard datasources: %s", m.flags.cfg, err)
+	default:
+		return err
+	}
+
+	udss, err := m.loadUserDatasources()
+	if err != nil {
+		return err
+	}
+
+	// Without a dashboard the user datasources would only be reachable
+	// using aliases, so let the user query them directly by their ID.
+	ddss = append(ddss, udss...)
+
+	gatherer, err := m.createGatherer(ctx, ddss, udss)
+	if err != nil {
+		return err
+	}
+
+	start, end, err := m.queryTimeRange()
+	if err != nil {
+		return err
+	}
+
+	// Template the query in the same way the widgets do.
+	data := template.Data(map[string]interface{}{
+		"__start": fmt.Sprintf("%v", start),
+		"__end":   fmt.Sprintf("%v", end),
+	})
+	vars := map[string]interface{}{}
+	for k, v := range m.flags.variables {
+		vars[k] = v
+	}
+	data = data.WithData(vars)
+
+	q := model.Query{
+		Expr:         data.Render(flags.expr),
+		DatasourceID: flags.datasourceID,
+	}
+
+	ctx, cancel := context.WithTimeout(ctx, queryTimeout)
+	defer cancel()
+
+	var series []model.MetricSeries
+	if flags.rangeQuery {
+		step := flags.step
+		if step == 0 {
+			step = unit.NearestDurationFromSteps(end.Sub(start), queryDefSteps)
+		}
+		series, err = gatherer.GatherRange(ctx, q, start, end, step)
+	} else {
+		series, err = gatherer.GatherSingle(ctx, q, end)
+	}
+	if err != nil {
+		return fmt.Errorf("error running query: %s", err)
+	}
+
+	switch flags.format {
+	case queryFormatCSV:
+		return writeQueryCSV(os.Stdout, series)
+	case queryFormatJSON:
+		return writeQueryJSON(os.Stdout, series)
+	default:
+		return writeQueryTable(os.Stdout, series)
+	}
+}
+
+// queryTimeRange returns the time range of the query based on the
+// same time range flags that the dashboards use.
+func (m *Main) queryTimeRange() (start, end time.Time, err error) {
+	appcfg, err := m.appConfig()
+	if err != nil {
+		return start, end, err
+	}
+
+	start, end = appcfg.TimeRangeStart, appcfg.TimeRangeEnd
+	if end.IsZero() {
+		end = time.Now().UTC()
+	}
+	if start.IsZero() {
+		rel := appcfg.RelativeTimeRange
+		if rel == 0 {
+			rel = queryDefRelativeDur
+		}
+		start = end.Add(-1 * rel)
+	}
+
+	return start, end, nil
+}
+
+// queryLabelKeys returns the sorted keys of all the labels of the series.
+func queryLabelKeys(series []model.MetricSeries) []string {
+	keys := []string{}
+	seen := map[string]bool{}
+	for _, s := range series {
+		for k := range s.Labels {
+			if !seen[k] {
+				seen[k] = true
+				keys = append(keys, k)
+			}
+		}
+	}
+	sort.Strings(keys)
+
+	return keys
+}
+
+// queryRows returns the series as rows, one row per metric with the
+// series ID, the labels on the same order as the keys, the timestamp
+// and the value.
+func queryRows(series []model.MetricSeries, keys []string) [][]string {
+	rows := [][]string{}
+	for _, s := range series {
+		for _, mt := range s.Metrics {
+			row := []string{s.ID}
+			for _, k := range keys {
+				row = append(row, s.Labels[k])
+			}
+			row = append(row, mt.TS.Format(time.RFC3339), strconv.FormatFloat(mt.Value, 'f', -1, 64))
+			rows = append(rows, row)
+		}
+	}
+
+	return rows
+}
+
+func writeQueryTable(w io.Writer, series []model.MetricSeries) error {
+	keys := queryLabelKeys(series)
+
+	header := []string{"SERIES"}
+	for _, k := range keys {
+		header = append(header, strings.ToUpper(k))
+	}
+	header = append(header, "TIMESTAMP", "VALUE")
+
+	tw := tabwriter.NewWriter(w, 0, 0, 2, ' ', 0)
+	fmt.Fprintln(tw, strings.Join(header, "\t"))
+	for _, row := range queryRows(series, keys) {
+		fmt.Fprintln(tw, strings.Join(row, "\t"))
+	}
+
+	return tw.Flush()
+}
+
+func writeQueryCSV(w io.Writer, series []model.MetricSeries) error {
+	keys := queryLabelKeys(series)
+
+	header := []string{"series"}
+	header = append(header, keys...)
+	header = append(header, "timestamp", "value")
+
+	cw := csv.NewWriter(w)
+	err := cw.Write(header)
+	if err != nil {
+		return err
+	}
+	err = cw.WriteAll(queryRows(series, keys))
+	if err != nil {
+		return err
+	}
+
+	return cw.Error()
+}
+
+type queryJSONSeries struct {
+	ID      string            `json:"id"`
+	Labels  map[string]string `json:"labels"`
+	Metrics []queryJSONMetric `json:"metrics"`
+}
+
+type queryJSONMetric struct {
+	TS time.Time `json:"timestamp"`
+	// Value is null when the value can't be represented on JSON (NaN and Inf).
+	Value *float64 `json:"value"`
+}
+
+func writeQueryJSON(w io.Writer, series []model.MetricSeries) error {
+	res := []queryJSONSeries{}
+	for _, s := range series {
+		js := queryJSONSeries{
+			ID:      s.ID,
+			Labels:  s.Labels,
+			Metrics: []queryJSONMetric{},
+		}
+		if js.Labels == nil {
+			js.Labels = map[string]string{}
+		}
+
+		for _, mt := range s.Metrics {
+			jm := queryJSONMetric{TS: mt.TS}
+			if !math.IsNaN(mt.Value) && !math.IsInf(mt.Value, 0) {
+				v := mt.Value
+				jm.Value = &v
+			}
+			js.Metrics = append(js.Metrics, jm)
+		}
+
+		res = append(res, js)
+	}
+
+	enc := json.NewEncoder(w)
+	enc.SetIndent("", "  ")
+	return enc.Encode(res)
+}
@@ -0,0 +1,40 @@
+package main
+
+import (
+	"context"
+	"os"
+	"time"
+
+	"github.com/slok/grafterm/internal/controller"
+	"github.com/slok/grafterm/internal/model"
+	"github.com/slok/grafterm/internal/view"
+	"github.com/slok/grafterm/internal/view/render/snapshot"
+)
+
+const snapshotTimeout = 30 * time.Second
+
+// snapshot renders the dashboard once and writes the result on the stdout.
+func (m *Main) snapshot(ctx context.Context, appCfg view.AppConfig, dashboard model.Dashboard, ctrl controller.Controller) error {
+	ctx, cancel := context.WithTimeout(ctx, snapshotTimeout)
+	defer cancel()
+
+	renderer := snapshot.NewRenderer(snapshot.Config{
+		Width:  m.flags.snapshot.width,
+		Height: m.flags.snapshot.height,
+		ANSI:   m.flags.snapshot.format == snapshotFormatANSI,
+		Logger: m.logger,
+	})
+	defer renderer.Close()
+
+	app, err := m.createApp(ctx, appCfg, dashboard, ctrl, renderer)
+	if err != nil {
+		return err
+	}
+
+	err = app.RunOnce(ctx)
+	if err != nil {
+		return err
+	}
+
+	return renderer.Render(os.Stdout)
+}
@@ -3,7 +3,6 @@ module github.com/slok/grafterm
 go 1.21.5
 
 require (
-	github.com/JensRantil/graphite-client v0.0.0-20151206234601-d93bf4b72f5a
 	github.com/alecthomas/kingpin v2.2.6+incompatible
 	github.com/influxdata/influxdb1-client v0.0.0-20190809212627-fc22c7df067e
 	github.com/lucasb-eyer/go-colorful v1.0.1
@@ -2,6 +2,7 @@ package controller
 
 import (
 	"context"
+	"errors"
 	"fmt"
 	"time"
 
@@ -9,15 +10,28 @@ import (
 	"github.com/slok/grafterm/internal/service/metric"
 )
 
+// ErrNoData is the error returned when the query doesn't return any metric.
+var ErrNoData = errors.New("no data")
+
 // Controller is what has the domain logic, the one that
 // can translate from the views to the models.
 type Controller interface {
 	// GetSingleMetric will get one single metric value at a point in time.
 	GetSingleMetric(ctx context.Context, query model.Query, t time.Time) (*model.Metric, error)
+	// GetSingleMetrics will get multiple metric series with one single metric value
+	// each at a point in time.
+	GetSingleMetrics(ctx context.Context, query model.Query, t time.Time) ([]model.MetricSeries, error)
 	// GetSingleInstantMetric will get one single metric value in real time.
 	GetSingleInstantMetric(ctx context.Context, query model.Query) (*model.Metric, error)
 	// GetRangeMetrics will get N metrics based in a time range.
 	GetRangeMetrics(ctx context.Context, query model.Query, start, end time.Time, step time.Duration) ([]model.MetricSeries, error)
+	// GetDiscoveredValues will get the values that the datasource discovers
+	// for the query (e.g the values of a label).
+	GetDiscoveredValues(ctx context.Context, query model.Query) ([]string, error)
+	// GetMetricsMetadata will get the metadata of the metrics used on the query.
+	GetMetricsMetadata(ctx context.Context, query model.Query) ([]model.MetricMetadata, error)
+	// GetDatasourcesHealth will get the health of the datasources.
+	GetDatasourcesHealth() ([]model.DatasourceHealth, error)
 }
 
 type controller struct {
@@ -37,10 +51,18 @@ func (c controller) GetSingleMetric(ctx context.Context, query model.Query, t ti
 		return nil, fmt.Errorf("failed to gather single metric: %w", err)
 	}
 
+	if len(m) == 0 {
+		return nil, ErrNoData
+	}
+
 	if len(m) != 1 {
 		return nil, fmt.Errorf("wrong number of series returned, 1 expected, got: %d", len(m))
 	}
 
+	if len(m[0].Metrics) == 0 {
+		return nil, ErrNoData
+	}
+
 	if len(m[0].Metrics) != 1 {
 		return nil, fmt.Errorf("wrong number of metric in series returned, 1 expected, got: %d", len(m[0].Metrics))
 	}
@@ -48,6 +70,25 @@ func (c controller) GetSingleMetric(ctx context.Context, query model.Query, t ti
 	return &m[0].Metrics[0], nil
 }
 
+func (c controller) GetSingleMetrics(ctx context.Context, query model.Query, t time.Time) ([]model.MetricSeries, error) {
+	ms, err := c.gatherer.GatherSingle(ctx, query, t)
+	if err != nil {
+		return nil, fmt.Errorf("failed to gather single metrics: %w", err)
+	}
+
+	// Only use the latest metric of each series.
+	res := make([]model.MetricSeries, 0, len(ms))
+	for _, m := range ms {
+		if len(m.Metrics) == 0 {
+			continue
+		}
+		m.Metrics = m.Metrics[len(m.Metrics)-1:]
+		res = append(res, m)
+	}
+
+	return res, nil
+}
+
 func (c controller) GetSingleInstantMetric(ctx context.Context, query model.Query) (*model.Metric, error) {
 	return c.GetSingleMetric(ctx, query, time.Now().UTC())
 }
@@ -69,3 +110,40 @@ func (c controller) GetRangeMetrics(ctx context.Context, query model.Query, star
 
 	return s, nil
 }
+
+func (c controller) GetDiscoveredValues(ctx context.Context, query model.Query) ([]string, error) {
+	d, ok := c.gatherer.(metric.Discoverer)
+	if !ok {
+		return nil, fmt.Errorf("gatherer does not support values discovery")
+	}
+
+	vs, err := d.DiscoverValues(ctx, query)
+	if err != nil {
+		return nil, fmt.Errorf("failed to discover values: %w", err)
+	}
+
+	return vs, nil
+}
+
+func (c controller) GetMetricsMetadata(ctx context.Context, query model.Query) ([]model.MetricMetadata, error) {
+	m, ok := c.gatherer.(metric.MetadataGatherer)
+	if !ok {
+		return nil, metric.ErrMetadataNotSupported
+	}
+
+	mds, err := m.GatherMetadata(ctx, query)
+	if err != nil {
+		return nil, fmt.Errorf("failed to gather metrics metadata: %w", err)
+	}
+
+	return mds, nil
+}
+
+func (c controller) GetDatasourcesHealth() ([]model.DatasourceHealth, error) {
+	h, ok := c.gatherer.(metric.HealthReporter)
+	if !ok {
+		return nil, fmt.Errorf("gatherer does not support datasources health")
+	}
+
+	return h.DatasourcesHealth(), nil
+}
@@ -109,6 +109,89 @@ func TestGetSingleMetric(t *testing.T) {
 	}
 }
 
+func TestGetSingleMetrics(t *testing.T) {
+	tests := []struct {
+		name           string
+		query          model.Query
+		serviceMetrics []model.MetricSeries
+		serviceErr     error
+		ts             time.Time
+		expErr         bool
+		expSeries      []model.MetricSeries
+	}{
+		{
+			name:  "Returning multiple metric series the controller should return the latest metric of each series.",
+			query: model.Query{Expr: "test"},
+			serviceMetrics: []model.MetricSeries{
+				model.MetricSeries{
+					ID:      "s1",
+					Metrics: []model.Metric{{Value: 17.9}},
+				},
+				model.MetricSeries{
+					ID:      "s2",
+					Metrics: []model.Metric{{Value: 11.1}, {Value: 28.1}},
+				},
+			},
+			ts: time.Now(),
+			expSeries: []model.MetricSeries{
+				model.MetricSeries{
+					ID:      "s1",
+					Metrics: []model.Metric{{Value: 17.9}},
+				},
+				model.MetricSeries{
+					ID:      "s2",
+					Metrics: []model.Metric{{Value: 28.1}},
+				},
+			},
+		},
+		{
+			name:  "Returning metric series without metrics should ignore these series.",
+			query: model.Query{Expr: "test"},
+			serviceMetrics: []model.MetricSeries{
+				model.MetricSeries{ID: "s1"},
+				model.MetricSeries{
+					ID:      "s2",
+					Metrics: []model.Metric{{Value: 28.1}},
+				},
+			},
+			ts: time.Now(),
+			expSeries: []model.MetricSeries{
+				model.MetricSeries{
+					ID:      "s2",
+					Metrics: []model.Metric{{Value: 28.1}},
+				},
+			},
+		},
+		{
+			name:       "Returning a error from the metrics service should error.",
+			query:      model.Query{Expr: "test"},
+			serviceErr: errors.New("wanted error"),
+			ts:         time.Now(),
+			expErr:     true,
+		},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			assert := assert.New(t)
+
+			// Mocks.
+			mg := &mmetric.Gatherer{}
+			mg.On("GatherSingle", mock.Anything, test.query, test.ts).Once().Return(test.serviceMetrics, test.serviceErr)
+
+			c := controller.NewController(mg)
+			gotSeries, err := c.GetSingleMetrics(context.TODO(), test.query, test.ts)
+
+			if test.expErr {
+				assert.Error(err)
+			} else if assert.NoError(err) {
+				assert.Equal(test.expSeries, gotSeries)
+				mg.AssertExpectations(t)
+			}
+		})
+	}
+}
+
 func TestGetRangeMetrics(t *testing.T) {
 	start := time.Now()
 	end := start.Add(5 * time.Hour)
@@ -198,3 +281,181 @@ func TestGetRangeMetrics(t *testing.T) {
 		})
 	}
 }
+
+// discovererGatherer is a gatherer that can discover values.
+type discovererGatherer struct {
+	*mmetric.Gatherer
+	*mmetric.Discoverer
+}
+
+func TestGetDiscoveredValues(t *testing.T) {
+	tests := []struct {
+		name          string
+		query         model.Query
+		notDiscoverer bool
+		serviceValues []string
+		serviceErr    error
+		expErr        bool
+		expValues     []string
+	}{
+		{
+			name:          "Using a gatherer that can't discover values should return an error.",
+			query:         model.Query{Expr: "test"},
+			notDiscoverer: true,
+			expErr:        true,
+		},
+		{
+			name:       "Receiving and error from the services should return an error.",
+			query:      model.Query{Expr: "test"},
+			serviceErr: errors.New("wanted error"),
+			expErr:     true,
+		},
+		{
+			name:          "Receiving the values from the services should return the values.",
+			query:         model.Query{Expr: "test"},
+			serviceValues: []string{"v1", "v2", "v3"},
+			expValues:     []string{"v1", "v2", "v3"},
+		},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			assert := assert.New(t)
+
+			// Mocks.
+			mg := &mmetric.Gatherer{}
+			md := &mmetric.Discoverer{}
+			md.On("DiscoverValues", mock.Anything, test.query).Once().Return(test.serviceValues, test.serviceErr)
+
+			var c controller.Controller
+			if test.notDiscoverer {
+				c = controller.NewController(mg)
+			} else {
+				c = controller.NewController(discovererGatherer{Gatherer: mg, Discoverer: md})
+			}
+			gotValues, err := c.GetDiscoveredValues(context.TODO(), test.query)
+
+			if test.expErr {
+				assert.Error(err)
+			} else if assert.NoError(err) {
+				assert.Equal(test.expValues, gotValues)
+				md.AssertExpectations(t)
+			}
+		})
+	}
+}
+
+// metadataGatherer is a gatherer that can gather the metrics metadata.
+type metadataGatherer struct {
+	*mmetric.Gatherer
+	*mmetric.MetadataGatherer
+}
+
+func TestGetMetricsMetadata(t *testing.T) {
+	tests := []struct {
+		name                string
+		query               model.Query
+		notMetadataGatherer bool
+		serviceMetadata     []model.MetricMetadata
+		serviceErr          error
+		expErr              bool
+		expMetadata         []model.MetricMetadata
+	}{
+		{
+			name:                "Using a gatherer that can't gather metadata should return an error.",
+			query:               model.Query{Expr: "test"},
+			notMetadataGatherer: true,
+			expErr:              true,
+		},
+		{
+			name:       "Receiving and error from the services should return an error.",
+			query:      model.Query{Expr: "test"},
+			serviceErr: errors.New("wanted error"),
+			expErr:     true,
+		},
+		{
+			name:            "Receiving the metadata from the services should return the metadata.",
+			query:           model.Query{Expr: "test"},
+			serviceMetadata: []model.MetricMetadata{{Name: "test", Type: "gauge"}},
+			expMetadata:     []model.MetricMetadata{{Name: "test", Type: "gauge"}},
+		},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			assert := assert.New(t)
+
+			// Mocks.
+			mg := &mmetric.Gatherer{}
+			mmg := &mmetric.MetadataGatherer{}
+			mmg.On("GatherMetadata", mock.Anything, test.query).Once().Return(test.serviceMetadata, test.serviceErr)
+
+			var c controller.Controller
+			if test.notMetadataGatherer {
+				c = controller.NewController(mg)
+			} else {
+				c = controller.NewController(metadataGatherer{Gatherer: mg, MetadataGatherer: mmg})
+			}
+			gotMetadata, err := c.GetMetricsMetadata(context.TODO(), test.query)
+
+			if test.expErr {
+				assert.Error(err)
+			} else if assert.NoError(err) {
+				assert.Equal(test.expMetadata, gotMetadata)
+				mmg.AssertExpectations(t)
+			}
+		})
+	}
+}
+
+type healthReporter struct {
+	*mmetric.Gatherer
+	*mmetric.HealthReporter
+}
+
+func TestGetDatasourcesHealth(t *testing.T) {
+	tests := []struct {
+		name              string
+		notHealthReporter bool
+		serviceHealth     []model.DatasourceHealth
+		expErr            bool
+		expHealth         []model.DatasourceHealth
+	}{
+		{
+			name:              "Using a gatherer that can't report the health should return an error.",
+			notHealthReporter: true,
+			expErr:            true,
+		},
+		{
+			name:          "Receiving the health from the services should return the health.",
+			serviceHealth: []model.DatasourceHealth{{DatasourceID: "test", State: model.CircuitOpen}},
+			expHealth:     []model.DatasourceHealth{{DatasourceID: "test", State: model.CircuitOpen}},
+		},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			assert := assert.New(t)
+
+			// Mocks.
+			mg := &mmetric.Gatherer{}
+			mhr := &mmetric.HealthReporter{}
+			mhr.On("DatasourcesHealth").Once().Return(test.serviceHealth)
+
+			var c controller.Controller
+			if test.notHealthReporter {
+				c = controller.NewController(mg)
+			} else {
+				c = controller.NewController(healthReporter{Gatherer: mg, HealthReporter: mhr})
+			}
+			gotHealth, err := c.GetDatasourcesHealth()
+
+			if test.expErr {
+				assert.Error(err)
+			} else if assert.NoError(err) {
+				assert.Equal(test.expHealth, gotHealth)
+				mhr.AssertExpectations(t)
+			}
+		})
+	}
+}
@@ -13,6 +13,75 @@ type Controller struct {
 	mock.Mock
 }
 
+// GetDatasourcesHealth provides a mock function with given fields:
+func (_m *Controller) GetDatasourcesHealth() ([]model.DatasourceHealth, error) {
+	ret := _m.Called()
+
+	var r0 []model.DatasourceHealth
+	if rf, ok := ret.Get(0).(func() []model.DatasourceHealth); ok {
+		r0 = rf()
+	} else {
+		if ret.Get(0) != nil {
+			r0 = ret.Get(0).([]model.DatasourceHealth)
+		}
+	}
+
+	var r1 error
+	if rf, ok := ret.Get(1).(func() error); ok {
+		r1 = rf()
+	} else {
+		r1 = ret.Error(1)
+	}
+
+	return r0, r1
+}
+
+// GetDiscoveredValues provides a mock function with given fields: ctx, query
+func (_m *Controller) GetDiscoveredValues(ctx context.Context, query model.Query) ([]string, error) {
+	ret := _m.Called(ctx, query)
+
+	var r0 []string
+	if rf, ok := ret.Get(0).(func(context.Context, model.Query) []string); ok {
+		r0 = rf(ctx, query)
+	} else {
+		if ret.Get(0) != nil {
+			r0 = ret.Get(0).([]string)
+		}
+	}
+
+	var r1 error
+	if rf, ok := ret.Get(1).(func(context.Context, model.Query) error); ok {
+		r1 = rf(ctx, query)
+	} else {
+		r1 = ret.Error(1)
+	}
+
+	return r0, r1
+}
+
+// GetMetricsMetadata provides a mock function with given fields: ctx, query
+func (_m *Controller) GetMetricsMetadata(ctx context.Context, query model.Query) ([]model.MetricMetadata, error) {
+	ret := _m.Called(ctx, query)
+
+	var r0 []model.MetricMetadata
+	if rf, ok := ret.Get(0).(func(context.Context, model.Query) []model.MetricMetadata); ok {
+		r0 = rf(ctx, query)
+	} else {
+		if ret.Get(0) != nil {
+			r0 = ret.Get(0).([]model.MetricMetadata)
+		}
+	}
+
+	var r1 error
+	if rf, ok := ret.Get(1).(func(context.Context, model.Query) error); ok {
+		r1 = rf(ctx, query)
+	} else {
+		r1 = ret.Error(1)
+	}
+
+	return r0, r1
+}
+
 // GetRangeMetrics provides a mock function with given fields: ctx, query, start, end, step
 func (_m *Controller) GetRangeMetrics(ctx context.Context, query model.Query, start time.Time, end time.Time, step time.Duration) ([]model.MetricSeries, error) {
 	ret := _m.Called(ctx, query, start, end, step)
@@ -81,3 +150,26 @@ func (_m *Controller) GetSingleMetric(ctx context.Context, query model.Query, t
 
 	return r0, r1
 }
+
+// GetSingleMetrics provides a mock function with given fields: ctx, query, t
+func (_m *Controller) GetSingleMetrics(ctx context.Context, query model.Query, t time.Time) ([]model.MetricSeries, error) {
+	ret := _m.Called(ctx, query, t)
+
+	var r0 []model.MetricSeries
+	if rf, ok := ret.Get(0).(func(context.Context, model.Query, time.Time) []model.MetricSeries); ok {
+		r0 = rf(ctx, query, t)
+	} else {
+		if ret.Get(0) != nil {
+			r0 = ret.Get(0).([]model.MetricSeries)
+		}
+	}
+
+	var r1 error
+	if rf, ok := ret.Get(1).(func(context.Context, model.Query, time.Time) error); ok {
+		r1 = rf(ctx, query, t)
+	} else {
+		r1 = ret.Error(1)
+	}
+
+	return r0, r1
+}
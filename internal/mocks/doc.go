@@ -11,9 +11,16 @@ package mocks // import "github.com/slok/grafterm/internal/mocks"
 //go:generate mockery -output ./view/render -outpkg render -dir ../view/render -name GaugeWidget
 //go:generate mockery -output ./view/render -outpkg render -dir ../view/render -name SinglestatWidget
 //go:generate mockery -output ./view/render -outpkg render -dir ../view/render -name GraphWidget
+//go:generate mockery -output ./view/render -outpkg render -dir ../view/render -name ValueRepresentationGraphWidget
+//go:generate mockery -output ./view/render -outpkg render -dir ../view/render -name TableWidget
+//go:generate mockery -output ./view/render -outpkg render -dir ../view/render -name VariablesWidget
+//go:generate mockery -output ./view/render -outpkg render -dir ../view/render -name StatusWidget
 
 // Services mocks.
 //go:generate mockery -output ./service/metric -outpkg metric -dir ../service/metric -name Gatherer
+//go:generate mockery -output ./service/metric -outpkg metric -dir ../service/metric -name Discoverer
+//go:generate mockery -output ./service/metric -outpkg metric -dir ../service/metric -name MetadataGatherer
+//go:generate mockery -output ./service/metric -outpkg metric -dir ../service/metric -name HealthReporter
 
 // 3rd party
 //go:generate mockery -output ./github.com/prometheus/client_golang/api/prometheus/v1 -outpkg v1 -dir ./thirdparty/github.com/prometheus/client_golang/api/prometheus/v1 -name API
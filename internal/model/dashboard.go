@@ -3,6 +3,7 @@ package model
 import (
 	"fmt"
 	"regexp"
+	"strings"
 
 	"github.com/slok/grafterm/internal/service/unit"
 )
@@ -31,6 +32,7 @@ type Variable struct {
 type VariableSource struct {
 	Constant *ConstantVariableSource `json:"constant,omitempty"`
 	Interval *IntervalVariableSource `json:"interval,omitempty"`
+	Query    *QueryVariableSource    `json:"query,omitempty"`
 }
 
 // ConstantVariableSource represents the constant variables.
@@ -43,6 +45,41 @@ type IntervalVariableSource struct {
 	Steps int `json:"steps,omitempty"`
 }
 
+// QueryVariableSource represents the variables that get their values
+// from a datasource using a discovery query (e.g label values).
+type QueryVariableSource struct {
+	Query `json:",inline"`
+	// Regex will filter the discovered values, if the regex has a capture
+	// group, the first group will be used as the value.
+	Regex         string         `json:"regex,omitempty"`
+	CompiledRegex *regexp.Regexp `json:"-"`
+	// Sort is the order of the discovered values.
+	Sort VariableSort `json:"sort,omitempty"`
+	// RefreshOnSync will discover the values on every sync instead of
+	// only when the dashboard is loaded.
+	RefreshOnSync bool `json:"refreshOnSync,omitempty"`
+	// Multi will let select multiple values at the same time.
+	Multi bool `json:"multi,omitempty"`
+}
+
+// VariableSort is the order of the values of a variable.
+type VariableSort string
+
+const (
+	// VariableSortNone is the default sort, it will use the order of the datasource.
+	VariableSortNone VariableSort = "none"
+	// VariableSortAlphabetical will sort the values in alphabetical order.
+	VariableSortAlphabetical VariableSort = "alphabetical"
+	// VariableSortAlphabeticalDesc will sort the values in reverse alphabetical order.
+	VariableSortAlphabeticalDesc VariableSort = "alphabetical-desc"
+	// VariableSortNumerical will sort the values in numerical order, the values
+	// that are not numbers will be placed at the end.
+	VariableSortNumerical VariableSort = "numerical"
+	// VariableSortNumericalDesc will sort the values in reverse numerical order, the
+	// values that are not numbers will be placed at the end.
+	VariableSortNumericalDesc VariableSort = "numerical-desc"
+)
+
 // Widget represents a widget.
 type Widget struct {
 	Title        string  `json:"title,omitempty"`
@@ -74,8 +111,9 @@ type GridPos struct {
 	Y int `json:"y,omitempty"`
 	// W represents the width of the widget (same unit as X).
 	W int `json:"w,omitempty"`
-	// TODO(slok): H represents the height of the widget (same unit as Y).
-	// H int `json:"h,omitempty"`
+	// H represents the height of the widget (same unit as Y).
+	// Not setting H or setting to 0 would fallback to one row.
+	H int `json:"h,omitempty"`
 }
 
 // WidgetSource will tell what kind of widget is.
@@ -83,6 +121,7 @@ type WidgetSource struct {
 	Singlestat *SinglestatWidgetSource `json:"singlestat,omitempty"`
 	Gauge      *GaugeWidgetSource      `json:"gauge,omitempty"`
 	Graph      *GraphWidgetSource      `json:"graph,omitempty"`
+	Table      *TableWidgetSource      `json:"table,omitempty"`
 }
 
 // SinglestatWidgetSource represents a simple value widget.
@@ -108,12 +147,106 @@ type GraphWidgetSource struct {
 	Visualization GraphVisualization `json:"visualization,omitempty"`
 }
 
+// TableWidgetSource represents a widget that renders the metric series in a table,
+// one row for each metric series (identified by the label columns) and one value
+// column for each query.
+type TableWidgetSource struct {
+	Queries []TableQuery `json:"queries,omitempty"`
+	// Columns are the label columns of the table, if not set all the
+	// labels of the metric series will be used as columns.
+	Columns []TableColumn `json:"columns,omitempty"`
+	Sort    TableSort     `json:"sort,omitempty"`
+}
+
+// TableQuery is a query of a table widget, each query will be rendered
+// as a value column.
+type TableQuery struct {
+	Query               `json:",inline"`
+	ValueRepresentation `json:",inline"`
+	Thresholds          []Threshold `json:"thresholds,omitempty"`
+}
+
+// TableColumn is a column of a table that will use the value of a
+// metric series label.
+type TableColumn struct {
+	Label string `json:"label,omitempty"`
+	// Title is the name of the column, by default is the label.
+	Title string `json:"title,omitempty"`
+}
+
+// TableSort controls the order of the table rows.
+type TableSort struct {
+	// Column is the title of the column used to sort the table rows,
+	// by default the rows are sorted by the label columns.
+	Column     string `json:"column,omitempty"`
+	Descending bool   `json:"descending,omitempty"`
+}
+
 // Query is the query that will be made to the datasource.
 type Query struct {
 	Expr string `json:"expr,omitempty"`
 	// Legend accepts `text.template` format.
 	Legend       string `json:"legend,omitempty"`
 	DatasourceID string `json:"datasourceID,omitempty"`
+	// Transforms are the transformations applied in order to the series of
+	// the query on the client side, the same for all the datasources.
+	Transforms []Transform `json:"transforms,omitempty"`
+}
+
+// Transform is a transformation of the series of a query, only one kind
+// of transformation can be set.
+type Transform struct {
+	Rate          *RateTransform          `json:"rate,omitempty"`
+	Derivative    *DerivativeTransform    `json:"derivative,omitempty"`
+	MovingAverage *MovingAverageTransform `json:"movingAverage,omitempty"`
+	CumulativeSum *CumulativeSumTransform `json:"cumulativeSum,omitempty"`
+	Scale         *ScaleTransform         `json:"scale,omitempty"`
+	Offset        *OffsetTransform        `json:"offset,omitempty"`
+	TopK          *TopKTransform          `json:"topk,omitempty"`
+	BottomK       *TopKTransform          `json:"bottomk,omitempty"`
+	RenameByRegex *RenameByRegexTransform `json:"renameByRegex,omitempty"`
+}
+
+// RateTransform converts the values of counters to per second rates,
+// handling the counter resets.
+type RateTransform struct{}
+
+// DerivativeTransform converts the values to the per second change.
+type DerivativeTransform struct{}
+
+// MovingAverageTransform converts the values to the average of the
+// window of the latest values.
+type MovingAverageTransform struct {
+	// Window is the number of values used for the average.
+	Window int `json:"window,omitempty"`
+}
+
+// CumulativeSumTransform converts the values to the sum of all the
+// previous values.
+type CumulativeSumTransform struct{}
+
+// ScaleTransform multiplies the values by a factor.
+type ScaleTransform struct {
+	Factor float64 `json:"factor"`
+}
+
+// OffsetTransform adds an offset to the values.
+type OffsetTransform struct {
+	Value float64 `json:"value"`
+}
+
+// TopKTransform keeps the K series with the highest (or lowest) average.
+type TopKTransform struct {
+	K int `json:"k,omitempty"`
+}
+
+// RenameByRegexTransform renames the series IDs that match the regex
+// with the replacement, the replacement can use the regex capture
+// groups (e.g `$1`).
+type RenameByRegexTransform struct {
+	Regex         string         `json:"regex,omitempty"`
+	CompiledRegex *regexp.Regexp `json:"-"`
+	Replacement   string         `json:"replacement,omitempty"`
 }
 
 // Threshold is a color threshold that is composed
@@ -224,6 +357,11 @@ func (v Variable) validate() error {
 		if i.Steps <= 0 {
 			return fmt.Errorf("%s interval variable step should be > 0", v.Name)
 		}
+	case v.VariableSource.Query != nil:
+		err := v.VariableSource.Query.validate()
+		if err != nil {
+			return fmt.Errorf("%s query variable: %s", v.Name, err)
+		}
 	default:
 		return fmt.Errorf("%s variable is empty, it should be of a specific type", v.Name)
 	}
@@ -231,6 +369,42 @@ func (v Variable) validate() error {
 	return nil
 }
 
+func (q *QueryVariableSource) validate() error {
+	err := q.Query.validateSingle()
+	if err != nil {
+		return err
+	}
+
+	err = q.Sort.validate()
+	if err != nil {
+		return err
+	}
+
+	// Compile the regex.
+	if q.Regex != "" {
+		re, err := regexp.Compile(q.Regex)
+		if err != nil {
+			return fmt.Errorf("invalid regex: %s", err)
+		}
+		q.CompiledRegex = re
+	}
+
+	return nil
+}
+
+func (v *VariableSort) validate() error {
+	if *v == "" {
+		*v = VariableSortNone
+	}
+
+	switch *v {
+	case VariableSortNone, VariableSortAlphabetical, VariableSortAlphabeticalDesc, VariableSortNumerical, VariableSortNumericalDesc:
+		return nil
+	default:
+		return fmt.Errorf("sort '%s' is not a valid sort", *v)
+	}
+}
+
 func (w Widget) validate(d Dashboard) error {
 	err := w.GridPos.validate(d.Grid)
 	if err != nil {
@@ -253,6 +427,11 @@ func (w Widget) validate(d Dashboard) error {
 		if err != nil {
 			return fmt.Errorf("error on %s graph widget: %s", w.Title, err)
 		}
+	case w.Table != nil:
+		err := w.Table.validate()
+		if err != nil {
+			return fmt.Errorf("error on %s table widget: %s", w.Title, err)
+		}
 	}
 	return nil
 }
@@ -262,6 +441,10 @@ func (g GridPos) validate(gr Grid) error {
 		return fmt.Errorf("widget grid position should have a width")
 	}
 
+	if g.H < 0 {
+		return fmt.Errorf("widget grid position height can't be negative")
+	}
+
 	if gr.FixedWidgets && g.X <= 0 {
 		return fmt.Errorf("widget grid position in a fixed grid should have am X position")
 	}
@@ -274,7 +457,7 @@ func (g GridPos) validate(gr Grid) error {
 }
 
 func (g GaugeWidgetSource) validate() error {
-	err := g.Query.validate()
+	err := g.Query.validateSingle()
 	if err != nil {
 		return fmt.Errorf("query error on gauge widget: %s", err)
 	}
@@ -292,7 +475,7 @@ func (g GaugeWidgetSource) validate() error {
 }
 
 func (s SinglestatWidgetSource) validate() error {
-	err := s.Query.validate()
+	err := s.Query.validateSingle()
 	if err != nil {
 		return fmt.Errorf("query error on singlestat widget: %s", err)
 	}
@@ -336,6 +519,37 @@ func (g GraphWidgetSource) validate() error {
 	return nil
 }
 
+func (t TableWidgetSource) validate() error {
+	if len(t.Queries) <= 0 {
+		return fmt.Errorf("table must have at least one query")
+	}
+
+	for _, q := range t.Queries {
+		err := q.Query.validateSingle()
+		if err != nil {
+			return err
+		}
+
+		err = q.ValueRepresentation.validate()
+		if err != nil {
+			return err
+		}
+
+		err = validateThresholds(q.Thresholds)
+		if err != nil {
+			return fmt.Errorf("thresholds error on table widget: %s", err)
+		}
+	}
+
+	for _, c := range t.Columns {
+		if c.Label == "" {
+			return fmt.Errorf("a table column should have a label")
+		}
+	}
+
+	return nil
+}
+
 func (q Query) validate() error {
 	if q.Expr == "" {
 		return fmt.Errorf("query must have an expression")
@@ -344,6 +558,58 @@ func (q Query) validate() error {
 	if q.DatasourceID == "" {
 		return fmt.Errorf("query must have have a datosource ID")
 	}
+
+	for _, t := range q.Transforms {
+		err := t.validate()
+		if err != nil {
+			return fmt.Errorf("query transform error: %s", err)
+		}
+	}
+
+	return nil
+}
+
+// validateSingle validates the queries that get single values instead of
+// series over time (e.g singlestat), these don't support the transforms.
+func (q Query) validateSingle() error {
+	if len(q.Transforms) > 0 {
+		return fmt.Errorf("query transforms are only supported on graph queries")
+	}
+
+	return q.validate()
+}
+
+func (t Transform) validate() error {
+	kinds := 0
+	for _, set := range []bool{
+		t.Rate != nil, t.Derivative != nil, t.MovingAverage != nil,
+		t.CumulativeSum != nil, t.Scale != nil, t.Offset != nil,
+		t.TopK != nil, t.BottomK != nil, t.RenameByRegex != nil,
+	} {
+		if set {
+			kinds++
+		}
+	}
+	if kinds != 1 {
+		return fmt.Errorf("a transform should be of one specific type")
+	}
+
+	switch {
+	case t.MovingAverage != nil && t.MovingAverage.Window <= 0:
+		return fmt.Errorf("moving average window should be greater than 0")
+	case t.TopK != nil && t.TopK.K <= 0:
+		return fmt.Errorf("topk k should be greater than 0")
+	case t.BottomK != nil && t.BottomK.K <= 0:
+		return fmt.Errorf("bottomk k should be greater than 0")
+	case t.RenameByRegex != nil:
+		// Compile the regex.
+		re, err := regexp.Compile(t.RenameByRegex.Regex)
+		if err != nil {
+			return fmt.Errorf("invalid rename regex: %s", err)
+		}
+		t.RenameByRegex.CompiledRegex = re
+	}
+
 	return nil
 }
 
@@ -378,6 +644,80 @@ func (v ValueRepresentation) validate() error {
 	return nil
 }
 
+var perSecondRegexp = regexp.MustCompile(`\b(?:rate|irate)\s*\(`)
+
+// metadataUnits are the units that can be inferred from the metrics metadata
+// unit or the metric name suffix.
+var metadataUnits = map[string]string{
+	"seconds":      "seconds",
+	"milliseconds": "milliseconds",
+	"bytes":        "bytes",
+	"ratio":        "ratio",
+}
+
+// WithMetadataDefaults returns the value representation with the unit
+// inferred from the metadata of the metrics used on the query expression
+// when the unit is not set. If the metrics have different units the unit
+// will not be set.
+func (v ValueRepresentation) WithMetadataDefaults(expr string, mds []MetricMetadata) ValueRepresentation {
+	if v.Unit != "" {
+		return v
+	}
+
+	perSecond := perSecondRegexp.MatchString(expr)
+	inferred := ""
+	for _, md := range mds {
+		u := md.inferUnit(perSecond)
+		if u == "" {
+			continue
+		}
+		if inferred != "" && inferred != u {
+			return v
+		}
+		inferred = u
+	}
+
+	v.Unit = inferred
+	return v
+}
+
+// inferUnit infers the unit of the metric based on the type, the unit and
+// the suffix of the name.
+func (m MetricMetadata) inferUnit(perSecond bool) string {
+	name := m.Name
+
+	// The count and the buckets of histograms and summaries don't use the unit
+	// of the metric.
+	if strings.HasSuffix(name, "_count") || strings.HasSuffix(name, "_bucket") {
+		return ""
+	}
+
+	name = strings.TrimSuffix(name, "_sum")
+	counter := m.Type == "counter" || strings.HasSuffix(name, "_total")
+	name = strings.TrimSuffix(name, "_total")
+
+	u := m.Unit
+	if u == "" {
+		if i := strings.LastIndex(name, "_"); i >= 0 {
+			u = name[i+1:]
+		}
+	}
+
+	mu, ok := metadataUnits[u]
+	switch {
+	// A rate of a counter of seconds doesn't have unit (e.g CPU usage).
+	case ok && counter && perSecond && mu == "seconds":
+		return ""
+	case ok:
+		return mu
+	// A rate of a counter without unit are events per second.
+	case counter && perSecond:
+		return "reqps"
+	}
+
+	return ""
+}
+
 func (s SeriesOverride) validate() error {
 	if s.Regex == "" {
 		return fmt.Errorf("a graph override for series should have a regex")
@@ -87,6 +87,19 @@ func getBaseDashboard() model.Dashboard {
 					},
 				}},
 			},
+			model.Widget{
+				Title:   "test-table",
+				GridPos: model.GridPos{W: 10, Y: 10, X: 10},
+				WidgetSource: model.WidgetSource{Table: &model.TableWidgetSource{
+					Queries: []model.TableQuery{
+						model.TableQuery{Query: model.Query{Expr: "query", Legend: "test", DatasourceID: "test"}},
+						model.TableQuery{Query: model.Query{Expr: "query2", Legend: "test2", DatasourceID: "test"}},
+					},
+					Columns: []model.TableColumn{
+						model.TableColumn{Label: "pod", Title: "Pod"},
+					},
+				}},
+			},
 		},
 	}
 }
@@ -162,6 +175,91 @@ func TestValidateDashboard(t *testing.T) {
 			},
 			expErr: true,
 		},
+		{
+			name: "Query variables should have an expression.",
+			dashboard: func() model.Dashboard {
+				d := getBaseDashboard()
+				d.Variables[0] = model.Variable{
+					Name: "test",
+					VariableSource: model.VariableSource{Query: &model.QueryVariableSource{
+						Query: model.Query{DatasourceID: "test"},
+					}},
+				}
+				return d
+			},
+			expErr: true,
+		},
+		{
+			name: "Query variables should have a datasource ID.",
+			dashboard: func() model.Dashboard {
+				d := getBaseDashboard()
+				d.Variables[0] = model.Variable{
+					Name: "test",
+					VariableSource: model.VariableSource{Query: &model.QueryVariableSource{
+						Query: model.Query{Expr: "label_values(job)"},
+					}},
+				}
+				return d
+			},
+			expErr: true,
+		},
+		{
+			name: "Query variables should have a valid regex.",
+			dashboard: func() model.Dashboard {
+				d := getBaseDashboard()
+				d.Variables[0] = model.Variable{
+					Name: "test",
+					VariableSource: model.VariableSource{Query: &model.QueryVariableSource{
+						Query: model.Query{Expr: "label_values(job)", DatasourceID: "test"},
+						Regex: "([a-z",
+					}},
+				}
+				return d
+			},
+			expErr: true,
+		},
+		{
+			name: "Query variables should have a valid sort.",
+			dashboard: func() model.Dashboard {
+				d := getBaseDashboard()
+				d.Variables[0] = model.Variable{
+					Name: "test",
+					VariableSource: model.VariableSource{Query: &model.QueryVariableSource{
+						Query: model.Query{Expr: "label_values(job)", DatasourceID: "test"},
+						Sort:  "wrong",
+					}},
+				}
+				return d
+			},
+			expErr: true,
+		},
+		{
+			name: "Query variables should set the default sort and compile the regex.",
+			dashboard: func() model.Dashboard {
+				d := getBaseDashboard()
+				d.Variables[0] = model.Variable{
+					Name: "test",
+					VariableSource: model.VariableSource{Query: &model.QueryVariableSource{
+						Query: model.Query{Expr: "label_values(job)", DatasourceID: "test"},
+						Regex: "prod-(.*)",
+					}},
+				}
+				return d
+			},
+			expDashboard: func() model.Dashboard {
+				d := getBaseDashboard()
+				d.Variables[0] = model.Variable{
+					Name: "test",
+					VariableSource: model.VariableSource{Query: &model.QueryVariableSource{
+						Query:         model.Query{Expr: "label_values(job)", DatasourceID: "test"},
+						Regex:         "prod-(.*)",
+						CompiledRegex: regexp.MustCompile("prod-(.*)"),
+						Sort:          model.VariableSortNone,
+					}},
+				}
+				return d
+			},
+		},
 
 		// Widgets.
 		{
@@ -199,6 +297,17 @@ func TestValidateDashboard(t *testing.T) {
 			},
 			expErr: true,
 		},
+		{
+			name: "A widget grid position height can't be negative.",
+			dashboard: func() model.Dashboard {
+				d := getBaseDashboard()
+				w := d.Widgets[0]
+				w.GridPos.H = -1
+				d.Widgets[0] = w
+				return d
+			},
+			expErr: true,
+		},
 
 		// Gauge widget.
 		{
@@ -406,6 +515,148 @@ func TestValidateDashboard(t *testing.T) {
 			},
 			expErr: true,
 		},
+
+		// Table widget.
+		{
+			name: "A table widget should have at least one query.",
+			dashboard: func() model.Dashboard {
+				d := getBaseDashboard()
+				w := d.Widgets[3]
+				w.Table.Queries = []model.TableQuery{}
+				d.Widgets[3] = w
+				return d
+			},
+			expErr: true,
+		},
+		{
+			name: "A table widget with a query should have an expression.",
+			dashboard: func() model.Dashboard {
+				d := getBaseDashboard()
+				w := d.Widgets[3]
+				w.Table.Queries[1].Expr = ""
+				d.Widgets[3] = w
+				return d
+			},
+			expErr: true,
+		},
+		{
+			name: "A table widget query should have a valid unit.",
+			dashboard: func() model.Dashboard {
+				d := getBaseDashboard()
+				w := d.Widgets[3]
+				w.Table.Queries[0].Unit = "unknown"
+				d.Widgets[3] = w
+				return d
+			},
+			expErr: true,
+		},
+		{
+			name: "A table widget query thresholds can't have same start values.",
+			dashboard: func() model.Dashboard {
+				d := getBaseDashboard()
+				w := d.Widgets[3]
+				w.Table.Queries[0].Thresholds = []model.Threshold{
+					model.Threshold{Color: "#FFFFFF", StartValue: 5},
+					model.Threshold{Color: "#FFF000", StartValue: 5},
+				}
+				d.Widgets[3] = w
+				return d
+			},
+			expErr: true,
+		},
+		{
+			name: "A table widget column should have a label.",
+			dashboard: func() model.Dashboard {
+				d := getBaseDashboard()
+				w := d.Widgets[3]
+				w.Table.Columns = []model.TableColumn{
+					model.TableColumn{Title: "Pod"},
+				}
+				d.Widgets[3] = w
+				return d
+			},
+			expErr: true,
+		},
+
+		// Query transforms.
+		{
+			name: "A query transform should have only one type.",
+			dashboard: func() model.Dashboard {
+				d := getBaseDashboard()
+				w := d.Widgets[2]
+				w.Graph.Queries[0].Transforms = []model.Transform{
+					{Rate: &model.RateTransform{}, Scale: &model.ScaleTransform{Factor: 8}},
+				}
+				d.Widgets[2] = w
+				return d
+			},
+			expErr: true,
+		},
+		{
+			name: "A moving average query transform should have a window.",
+			dashboard: func() model.Dashboard {
+				d := getBaseDashboard()
+				w := d.Widgets[2]
+				w.Graph.Queries[0].Transforms = []model.Transform{{MovingAverage: &model.MovingAverageTransform{}}}
+				d.Widgets[2] = w
+				return d
+			},
+			expErr: true,
+		},
+		{
+			name: "A topk query transform should have a k greater than 0.",
+			dashboard: func() model.Dashboard {
+				d := getBaseDashboard()
+				w := d.Widgets[2]
+				w.Graph.Queries[0].Transforms = []model.Transform{{TopK: &model.TopKTransform{K: -1}}}
+				d.Widgets[2] = w
+				return d
+			},
+			expErr: true,
+		},
+		{
+			name: "A rename by regex query transform should have a valid regex.",
+			dashboard: func() model.Dashboard {
+				d := getBaseDashboard()
+				w := d.Widgets[2]
+				w.Graph.Queries[0].Transforms = []model.Transform{{RenameByRegex: &model.RenameByRegexTransform{Regex: "[a-z"}}}
+				d.Widgets[2] = w
+				return d
+			},
+			expErr: true,
+		},
+		{
+			name: "A query transform on a non graph query should fail.",
+			dashboard: func() model.Dashboard {
+				d := getBaseDashboard()
+				w := d.Widgets[1]
+				w.Singlestat.Query.Transforms = []model.Transform{{Scale: &model.ScaleTransform{Factor: 8}}}
+				d.Widgets[1] = w
+				return d
+			},
+			expErr: true,
+		},
+		{
+			name: "A rename by regex query transform should compile the regex.",
+			dashboard: func() model.Dashboard {
+				d := getBaseDashboard()
+				w := d.Widgets[2]
+				w.Graph.Queries[0].Transforms = []model.Transform{{RenameByRegex: &model.RenameByRegexTransform{Regex: "code=(.*)", Replacement: "$1"}}}
+				d.Widgets[2] = w
+				return d
+			},
+			expDashboard: func() model.Dashboard {
+				d := getBaseDashboard()
+				w := d.Widgets[2]
+				w.Graph.Queries[0].Transforms = []model.Transform{{RenameByRegex: &model.RenameByRegexTransform{
+					Regex:         "code=(.*)",
+					CompiledRegex: regexp.MustCompile("code=(.*)"),
+					Replacement:   "$1",
+				}}}
+				d.Widgets[2] = w
+				return d
+			},
+		},
 	}
 
 	for _, test := range tests {
@@ -422,3 +673,72 @@ func TestValidateDashboard(t *testing.T) {
 		})
 	}
 }
+
+func TestValueRepresentationWithMetadataDefaults(t *testing.T) {
+	tests := []struct {
+		name  string
+		vr    model.ValueRepresentation
+		expr  string
+		mds   []model.MetricMetadata
+		expVR model.ValueRepresentation
+	}{
+		{
+			name:  "A set unit should not be changed.",
+			vr:    model.ValueRepresentation{Unit: "percent", Decimals: 1},
+			expr:  `node_memory_free_bytes`,
+			mds:   []model.MetricMetadata{{Name: "node_memory_free_bytes", Type: "gauge"}},
+			expVR: model.ValueRepresentation{Unit: "percent", Decimals: 1},
+		},
+		{
+			name:  "The unit should be inferred from the name suffix.",
+			vr:    model.ValueRepresentation{Decimals: 1},
+			expr:  `node_memory_free_bytes`,
+			mds:   []model.MetricMetadata{{Name: "node_memory_free_bytes", Type: "gauge"}},
+			expVR: model.ValueRepresentation{Unit: "bytes", Decimals: 1},
+		},
+		{
+			name:  "The unit should be inferred from the metadata unit.",
+			expr:  `temperature`,
+			mds:   []model.MetricMetadata{{Name: "latency", Type: "gauge", Unit: "seconds"}},
+			expVR: model.ValueRepresentation{Unit: "seconds"},
+		},
+		{
+			name: "The unit of a histogram average should be inferred from the sum.",
+			expr: `rate(http_request_duration_seconds_sum[5m]) / rate(http_request_duration_seconds_count[5m])`,
+			mds: []model.MetricMetadata{
+				{Name: "http_request_duration_seconds_sum", Type: "histogram"},
+				{Name: "http_request_duration_seconds_count", Type: "histogram"},
+			},
+			expVR: model.ValueRepresentation{Unit: "seconds"},
+		},
+		{
+			name:  "The rate of a counter should be per second.",
+			expr:  `sum(rate(http_requests_total[5m]))`,
+			mds:   []model.MetricMetadata{{Name: "http_requests_total", Type: "counter"}},
+			expVR: model.ValueRepresentation{Unit: "reqps"},
+		},
+		{
+			name:  "The rate of a counter of seconds should not have unit.",
+			expr:  `rate(process_cpu_seconds_total[5m])`,
+			mds:   []model.MetricMetadata{{Name: "process_cpu_seconds_total", Type: "counter"}},
+			expVR: model.ValueRepresentation{},
+		},
+		{
+			name: "Metrics with different units should not have unit.",
+			expr: `node_memory_free_bytes / process_start_time_seconds`,
+			mds: []model.MetricMetadata{
+				{Name: "node_memory_free_bytes", Type: "gauge"},
+				{Name: "process_start_time_seconds", Type: "gauge"},
+			},
+			expVR: model.ValueRepresentation{},
+		},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			assert := assert.New(t)
+			got := test.vr.WithMetadataDefaults(test.expr, test.mds)
+			assert.Equal(test.expVR, got)
+		})
+	}
+}
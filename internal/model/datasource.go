@@ -1,19 +1,53 @@
 package model
 
-import "fmt"
+import (
+	"fmt"
+	"regexp"
+	"time"
+)
 
 // Datasource is where the data will be retrieved.
 type Datasource struct {
 	ID               string
 	DatasourceSource `json:",inline"`
+	// EnhancedFeatures overrides the application timeout, concurrency,
+	// retry and cache settings of the queries to the datasource.
+	EnhancedFeatures *EnhancedFeatures `json:"enhancedFeatures,omitempty"`
+}
+
+// EnhancedFeatures are the settings of the queries made to a datasource, the
+// settings that are not set use the application ones.
+type EnhancedFeatures struct {
+	// Disabled disables all the enhanced features on the datasource.
+	Disabled bool `json:"disabled,omitempty"`
+	// QueryTimeout is the timeout of each query attempt.
+	QueryTimeout string `json:"queryTimeout,omitempty"`
+	// MaxConcurrentQueries limits the queries made at the same time.
+	MaxConcurrentQueries int `json:"maxConcurrentQueries,omitempty"`
+	// DisableRetry disables the retries of the failed queries.
+	DisableRetry bool `json:"disableRetry,omitempty"`
+	// MaxRetries is the number of times a failed query is retried.
+	MaxRetries int `json:"maxRetries,omitempty"`
+	// DisableCache disables the cache of the queries results.
+	DisableCache bool `json:"disableCache,omitempty"`
+	// CacheSize is the maximum number of cached queries results.
+	CacheSize int64 `json:"cacheSize,omitempty"`
+	// CacheTTL is the time a query result is cached.
+	CacheTTL string `json:"cacheTTL,omitempty"`
 }
 
 // DatasourceSource represents the datasource.
 type DatasourceSource struct {
-	Fake       *FakeDatasource       `json:"fake,omitempty"`
-	Prometheus *PrometheusDatasource `json:"prometheus,omitempty"`
-	Graphite   *GraphiteDatasource   `json:"graphite,omitempty"`
-	InfluxDB   *InfluxDBDatasource   `json:"influxdb,omitempty"`
+	Fake          *FakeDatasource          `json:"fake,omitempty"`
+	Prometheus    *PrometheusDatasource    `json:"prometheus,omitempty"`
+	Graphite      *GraphiteDatasource      `json:"graphite,omitempty"`
+	InfluxDB      *InfluxDBDatasource      `json:"influxdb,omitempty"`
+	Elasticsearch *ElasticsearchDatasource `json:"elasticsearch,omitempty"`
+	InfluxDB2     *InfluxDB2Datasource     `json:"influxdb2,omitempty"`
+	Scrape        *ScrapeDatasource        `json:"scrape,omitempty"`
+	File          *FileDatasource          `json:"file,omitempty"`
+	Exec          *ExecDatasource          `json:"exec,omitempty"`
+	Expression    *ExpressionDatasource    `json:"expression,omitempty"`
 }
 
 // FakeDatasource is the fake datasource.
@@ -21,23 +55,125 @@ type FakeDatasource struct{}
 
 // PrometheusDatasource is the Prometheus kind datasource.
 type PrometheusDatasource struct {
-	Address string `json:"address,omitempty"`
+	Address          string `json:"address,omitempty"`
+	HTTPClientConfig `json:",inline"`
 }
 
 // GraphiteDatasource is the Graphite kind datasource.
 type GraphiteDatasource struct {
-	Address string `json:"address,omitempty"`
+	Address          string `json:"address,omitempty"`
+	HTTPClientConfig `json:",inline"`
 }
 
 // InfluxDBDatasource is the Graphite kind datasource.
 type InfluxDBDatasource struct {
-	Address  string `json:"address,omitempty"`
-	Insecure bool   `json:"insecure,omitempty"`
-	Database string `json:"database,omitempty"`
+	Address  string     `json:"address,omitempty"`
+	Insecure bool       `json:"insecure,omitempty"`
+	Database string     `json:"database,omitempty"`
+	Username string     `json:"username,omitempty"`
+	Password string     `json:"password,omitempty"`
+	TLS      *TLSConfig `json:"tls,omitempty"`
+}
+
+// InfluxDB2Datasource is the InfluxDB 2.x kind datasource that uses Flux queries.
+type InfluxDB2Datasource struct {
+	Address string `json:"address,omitempty"`
+	Org     string `json:"org,omitempty"`
+	// Bucket is the default bucket available on the queries as `v.defaultBucket`.
+	Bucket string     `json:"bucket,omitempty"`
+	Token  string     `json:"token,omitempty"`
+	TLS    *TLSConfig `json:"tls,omitempty"`
+}
+
+// ElasticsearchDatasource is the Elasticsearch (and OpenSearch) kind datasource.
+type ElasticsearchDatasource struct {
+	Address string `json:"address,omitempty"`
+	// Index is the index or index pattern (e.g `logs-*`) used by the queries.
+	Index string `json:"index,omitempty"`
+	// TimeField is the timestamp field of the documents, by default `@timestamp`.
+	TimeField        string `json:"timeField,omitempty"`
+	HTTPClientConfig `json:",inline"`
+}
+
+// ScrapeDatasource is the datasource that scrapes the metrics endpoints
+// directly and stores the samples in memory, without a Prometheus server.
+type ScrapeDatasource struct {
+	// Targets are the URLs of the metrics endpoints (e.g `http://127.0.0.1:8080/metrics`).
+	Targets []string `json:"targets,omitempty"`
+	// Interval is the interval between scrapes, by default `15s`.
+	Interval string `json:"interval,omitempty"`
+	// Retention is the time the samples are kept in memory, by default `1h`.
+	Retention        string `json:"retention,omitempty"`
+	HTTPClientConfig `json:",inline"`
+}
+
+// FileDatasource is the datasource that reads the series from CSV and JSON files.
+type FileDatasource struct {
+	// Directory is the directory used to resolve the relative paths of the
+	// queries, by default the working directory. Only the files inside the
+	// directory can be read.
+	Directory string `json:"directory,omitempty"`
+}
+
+// ExecDatasource is the datasource that runs a local command and gets the
+// values from the command output.
+type ExecDatasource struct {
+	// Command is the program and the arguments that will be executed, the
+	// query expression is appended as the last argument.
+	Command []string `json:"command,omitempty"`
+	// Stdin will write the query expression to the command standard input
+	// instead of appending it as an argument.
+	Stdin bool `json:"stdin,omitempty"`
+	// Env are the environment variables of the command.
+	Env map[string]string `json:"env,omitempty"`
+	// InheritEnv will set the grafterm environment variables on the command.
+	InheritEnv bool `json:"inheritEnv,omitempty"`
+	// Timeout is the max duration of a command execution, by default `10s`.
+	Timeout string `json:"timeout,omitempty"`
+	// MaxOutputBytes is the max size of the command output, by default 1MiB.
+	MaxOutputBytes int `json:"maxOutputBytes,omitempty"`
+	// Retention is the time the values are kept in the local history used
+	// by the range queries, by default `1h`.
+	Retention string `json:"retention,omitempty"`
+}
+
+// ExpressionDatasource is the virtual datasource that evaluates math expressions
+// (e.g `$A / $B * 100`) using the result of named queries to other datasources.
+type ExpressionDatasource struct {
+	// Queries are the named queries that the expressions can reference,
+	// the key is the name.
+	Queries map[string]Query `json:"queries,omitempty"`
+}
+
+// HTTPClientConfig is the configuration of the HTTP client used
+// to connect with the HTTP API based datasources.
+type HTTPClientConfig struct {
+	BasicAuth *BasicAuth `json:"basicAuth,omitempty"`
+	// BearerToken is the token set on the authorization header.
+	BearerToken string `json:"bearerToken,omitempty"`
+	// BearerTokenFile is the path to a file with the token set on
+	// the authorization header, is read on every request.
+	BearerTokenFile string            `json:"bearerTokenFile,omitempty"`
+	Headers         map[string]string `json:"headers,omitempty"`
+	TLS             *TLSConfig        `json:"tls,omitempty"`
+}
+
+// BasicAuth is the HTTP basic authentication.
+type BasicAuth struct {
 	Username string `json:"username,omitempty"`
 	Password string `json:"password,omitempty"`
 }
 
+// TLSConfig is the TLS configuration used to connect with the datasources.
+type TLSConfig struct {
+	// CAFile is the path to the CA bundle used to verify the server certificate.
+	CAFile string `json:"caFile,omitempty"`
+	// CertFile and KeyFile are the paths to the client certificate and key.
+	CertFile           string `json:"certFile,omitempty"`
+	KeyFile            string `json:"keyFile,omitempty"`
+	InsecureSkipVerify bool   `json:"insecureSkipVerify,omitempty"`
+}
+
 // Validate validates the object model is correct.
 func (d Datasource) Validate() error {
 	if d.ID == "" {
@@ -53,7 +189,17 @@ func (d Datasource) Validate() error {
 		err = d.Graphite.validate()
 	case d.InfluxDB != nil:
 		err = d.InfluxDB.validate()
-	case d.Fake != nil:
+	case d.Elasticsearch != nil:
+		err = d.Elasticsearch.validate()
+	case d.InfluxDB2 != nil:
+		err = d.InfluxDB2.validate()
+	case d.Scrape != nil:
+		err = d.Scrape.validate()
+	case d.Exec != nil:
+		err = d.Exec.validate()
+	case d.Expression != nil:
+		err = d.Expression.validate(d.ID)
+	case d.Fake != nil, d.File != nil:
 	default:
 		err = fmt.Errorf("declared datasource %s can't be empty", d.ID)
 	}
@@ -61,6 +207,46 @@ func (d Datasource) Validate() error {
 		return err
 	}
 
+	if d.EnhancedFeatures != nil {
+		err := d.EnhancedFeatures.validate()
+		if err != nil {
+			return fmt.Errorf("datasource %s enhanced features error: %s", d.ID, err)
+		}
+	}
+
+	return nil
+}
+
+func (e EnhancedFeatures) validate() error {
+	durations := []struct{ name, value string }{
+		{name: "query timeout", value: e.QueryTimeout},
+		{name: "cache TTL", value: e.CacheTTL},
+	}
+	for _, d := range durations {
+		if d.value == "" {
+			continue
+		}
+		dur, err := time.ParseDuration(d.value)
+		if err != nil {
+			return fmt.Errorf("invalid %s: %s", d.name, err)
+		}
+		if dur <= 0 {
+			return fmt.Errorf("%s should be greater than 0", d.name)
+		}
+	}
+
+	if e.MaxConcurrentQueries < 0 {
+		return fmt.Errorf("max concurrent queries can't be negative")
+	}
+
+	if e.MaxRetries < 0 {
+		return fmt.Errorf("max retries can't be negative")
+	}
+
+	if e.CacheSize < 0 {
+		return fmt.Errorf("cache size can't be negative")
+	}
+
 	return nil
 }
 
@@ -69,6 +255,11 @@ func (p PrometheusDatasource) validate() error {
 		return fmt.Errorf("prometheus address can't be empty")
 	}
 
+	err := p.HTTPClientConfig.validate()
+	if err != nil {
+		return fmt.Errorf("prometheus %s", err)
+	}
+
 	return nil
 }
 
@@ -77,6 +268,11 @@ func (g GraphiteDatasource) validate() error {
 		return fmt.Errorf("Graphite API address can't be empty")
 	}
 
+	err := g.HTTPClientConfig.validate()
+	if err != nil {
+		return fmt.Errorf("Graphite %s", err)
+	}
+
 	return nil
 }
 
@@ -85,5 +281,155 @@ func (g InfluxDBDatasource) validate() error {
 		return fmt.Errorf("InfluxDB API address can't be empty")
 	}
 
+	if g.TLS != nil {
+		err := g.TLS.validate()
+		if err != nil {
+			return fmt.Errorf("InfluxDB %s", err)
+		}
+	}
+
+	return nil
+}
+
+func (g InfluxDB2Datasource) validate() error {
+	if g.Address == "" {
+		return fmt.Errorf("InfluxDB 2 API address can't be empty")
+	}
+
+	if g.Org == "" {
+		return fmt.Errorf("InfluxDB 2 org can't be empty")
+	}
+
+	if g.TLS != nil {
+		err := g.TLS.validate()
+		if err != nil {
+			return fmt.Errorf("InfluxDB 2 %s", err)
+		}
+	}
+
+	return nil
+}
+
+func (e ElasticsearchDatasource) validate() error {
+	if e.Address == "" {
+		return fmt.Errorf("Elasticsearch API address can't be empty")
+	}
+
+	if e.Index == "" {
+		return fmt.Errorf("Elasticsearch index can't be empty")
+	}
+
+	err := e.HTTPClientConfig.validate()
+	if err != nil {
+		return fmt.Errorf("Elasticsearch %s", err)
+	}
+
+	return nil
+}
+
+func (s ScrapeDatasource) validate() error {
+	if len(s.Targets) == 0 {
+		return fmt.Errorf("scrape targets can't be empty")
+	}
+
+	for _, t := range s.Targets {
+		if t == "" {
+			return fmt.Errorf("scrape target can't be empty")
+		}
+	}
+
+	if s.Interval != "" {
+		if _, err := time.ParseDuration(s.Interval); err != nil {
+			return fmt.Errorf("scrape interval is not valid: %s", err)
+		}
+	}
+
+	if s.Retention != "" {
+		if _, err := time.ParseDuration(s.Retention); err != nil {
+			return fmt.Errorf("scrape retention is not valid: %s", err)
+		}
+	}
+
+	err := s.HTTPClientConfig.validate()
+	if err != nil {
+		return fmt.Errorf("scrape %s", err)
+	}
+
+	return nil
+}
+
+func (e ExecDatasource) validate() error {
+	if len(e.Command) == 0 || e.Command[0] == "" {
+		return fmt.Errorf("exec command can't be empty")
+	}
+
+	if e.Timeout != "" {
+		if _, err := time.ParseDuration(e.Timeout); err != nil {
+			return fmt.Errorf("exec timeout is not valid: %s", err)
+		}
+	}
+
+	if e.Retention != "" {
+		if _, err := time.ParseDuration(e.Retention); err != nil {
+			return fmt.Errorf("exec retention is not valid: %s", err)
+		}
+	}
+
+	if e.MaxOutputBytes < 0 {
+		return fmt.Errorf("exec max output bytes can't be negative")
+	}
+
+	return nil
+}
+
+var expressionQueryNameRegexp = regexp.MustCompile(`^[a-zA-Z_][a-zA-Z0-9_]*$`)
+
+func (e ExpressionDatasource) validate(id string) error {
+	if len(e.Queries) == 0 {
+		return fmt.Errorf("expression queries can't be empty")
+	}
+
+	for name, q := range e.Queries {
+		if !expressionQueryNameRegexp.MatchString(name) {
+			return fmt.Errorf("expression query name %q is not valid", name)
+		}
+		if q.DatasourceID == "" {
+			return fmt.Errorf("expression query %s datasource ID can't be empty", name)
+		}
+		if q.DatasourceID == id {
+			return fmt.Errorf("expression query %s can't reference its own datasource", name)
+		}
+		if q.Expr == "" {
+			return fmt.Errorf("expression query %s expression can't be empty", name)
+		}
+		if len(q.Transforms) > 0 {
+			return fmt.Errorf("expression query %s can't have transforms", name)
+		}
+	}
+
+	return nil
+}
+
+func (h HTTPClientConfig) validate() error {
+	if h.BearerToken != "" && h.BearerTokenFile != "" {
+		return fmt.Errorf("bearer token and bearer token file can't be set at the same time")
+	}
+
+	if h.BasicAuth != nil && (h.BearerToken != "" || h.BearerTokenFile != "") {
+		return fmt.Errorf("basic auth and bearer token can't be set at the same time")
+	}
+
+	if h.TLS != nil {
+		return h.TLS.validate()
+	}
+
+	return nil
+}
+
+func (t TLSConfig) validate() error {
+	if (t.CertFile == "") != (t.KeyFile == "") {
+		return fmt.Errorf("TLS client certificate and key should be set together")
+	}
+
 	return nil
 }
@@ -70,6 +70,192 @@ func TestValidateDatasource(t *testing.T) {
 			},
 			expErr: true,
 		},
+		{
+			name: "A Prometheus datasource with basic auth and bearer token should error.",
+			ds: func() model.Datasource {
+				d := getBaseDatasource()
+				d.Prometheus = &model.PrometheusDatasource{
+					Address: "http://127.0.0.1:9090",
+					HTTPClientConfig: model.HTTPClientConfig{
+						BasicAuth:   &model.BasicAuth{Username: "user", Password: "pass"},
+						BearerToken: "token",
+					},
+				}
+				return d
+			},
+			expErr: true,
+		},
+		{
+			name: "A Prometheus datasource with bearer token and bearer token file should error.",
+			ds: func() model.Datasource {
+				d := getBaseDatasource()
+				d.Prometheus = &model.PrometheusDatasource{
+					Address: "http://127.0.0.1:9090",
+					HTTPClientConfig: model.HTTPClientConfig{
+						BearerToken:     "token",
+						BearerTokenFile: "/tmp/token",
+					},
+				}
+				return d
+			},
+			expErr: true,
+		},
+		{
+			name: "A Graphite datasource with a TLS client certificate without key should error.",
+			ds: func() model.Datasource {
+				d := getBaseDatasource()
+				d.Graphite = &model.GraphiteDatasource{
+					Address: "http://127.0.0.1:8080",
+					HTTPClientConfig: model.HTTPClientConfig{
+						TLS: &model.TLSConfig{CertFile: "/tmp/cert.pem"},
+					},
+				}
+				return d
+			},
+			expErr: true,
+		},
+		{
+			name: "A Graphite datasource with auth, headers and TLS should not error.",
+			ds: func() model.Datasource {
+				d := getBaseDatasource()
+				d.Graphite = &model.GraphiteDatasource{
+					Address: "https://127.0.0.1:8080",
+					HTTPClientConfig: model.HTTPClientConfig{
+						BearerTokenFile: "/tmp/token",
+						Headers:         map[string]string{"X-Scope-OrgID": "test"},
+						TLS: &model.TLSConfig{
+							CAFile:   "/tmp/ca.pem",
+							CertFile: "/tmp/cert.pem",
+							KeyFile:  "/tmp/key.pem",
+						},
+					},
+				}
+				return d
+			},
+			expErr: false,
+		},
+		{
+			name: "A InfluxDB datasource with a TLS client key without certificate should error.",
+			ds: func() model.Datasource {
+				d := getBaseDatasource()
+				d.InfluxDB = &model.InfluxDBDatasource{
+					Address: "https://127.0.0.1:8086",
+					TLS:     &model.TLSConfig{KeyFile: "/tmp/key.pem"},
+				}
+				return d
+			},
+			expErr: true,
+		},
+		{
+			name: "A Elasticsearch datasource without address should error.",
+			ds: func() model.Datasource {
+				d := getBaseDatasource()
+				d.Elasticsearch = &model.ElasticsearchDatasource{
+					Index: "logs-*",
+				}
+				return d
+			},
+			expErr: true,
+		},
+		{
+			name: "A Elasticsearch datasource without index should error.",
+			ds: func() model.Datasource {
+				d := getBaseDatasource()
+				d.Elasticsearch = &model.ElasticsearchDatasource{
+					Address: "http://127.0.0.1:9200",
+				}
+				return d
+			},
+			expErr: true,
+		},
+		{
+			name: "A InfluxDB 2 datasource without org should error.",
+			ds: func() model.Datasource {
+				d := getBaseDatasource()
+				d.InfluxDB2 = &model.InfluxDB2Datasource{
+					Address: "http://127.0.0.1:8086",
+				}
+				return d
+			},
+			expErr: true,
+		},
+		{
+			name: "A scrape datasource without targets should error.",
+			ds: func() model.Datasource {
+				d := getBaseDatasource()
+				d.Scrape = &model.ScrapeDatasource{}
+				return d
+			},
+			expErr: true,
+		},
+		{
+			name: "A scrape datasource with an invalid interval should error.",
+			ds: func() model.Datasource {
+				d := getBaseDatasource()
+				d.Scrape = &model.ScrapeDatasource{
+					Targets:  []string{"http://127.0.0.1:8080/metrics"},
+					Interval: "15",
+				}
+				return d
+			},
+			expErr: true,
+		},
+		{
+			name: "A exec datasource without command should error.",
+			ds: func() model.Datasource {
+				d := getBaseDatasource()
+				d.Exec = &model.ExecDatasource{}
+				return d
+			},
+			expErr: true,
+		},
+		{
+			name: "A exec datasource with an invalid timeout should error.",
+			ds: func() model.Datasource {
+				d := getBaseDatasource()
+				d.Exec = &model.ExecDatasource{
+					Command: []string{"echo"},
+					Timeout: "5",
+				}
+				return d
+			},
+			expErr: true,
+		},
+		{
+			name: "A expression datasource without queries should error.",
+			ds: func() model.Datasource {
+				d := getBaseDatasource()
+				d.Expression = &model.ExpressionDatasource{}
+				return d
+			},
+			expErr: true,
+		},
+		{
+			name: "A expression datasource with a query to itself should error.",
+			ds: func() model.Datasource {
+				d := getBaseDatasource()
+				d.Expression = &model.ExpressionDatasource{
+					Queries: map[string]model.Query{
+						"A": {DatasourceID: "test", Expr: "$A"},
+					},
+				}
+				return d
+			},
+			expErr: true,
+		},
+		{
+			name: "A expression datasource with an invalid query name should error.",
+			ds: func() model.Datasource {
+				d := getBaseDatasource()
+				d.Expression = &model.ExpressionDatasource{
+					Queries: map[string]model.Query{
+						"A-1": {DatasourceID: "prom", Expr: "up"},
+					},
+				}
+				return d
+			},
+			expErr: true,
+		},
 		{
 			name: "A InfluxDB datasource without address should error.",
 			ds: func() model.Datasource {
@@ -81,6 +267,37 @@ func TestValidateDatasource(t *testing.T) {
 			},
 			expErr: true,
 		},
+		{
+			name: "A datasource with enhanced features should be valid.",
+			ds: func() model.Datasource {
+				d := getBaseDatasource()
+				d.EnhancedFeatures = &model.EnhancedFeatures{
+					QueryTimeout:         "10s",
+					MaxConcurrentQueries: 2,
+					MaxRetries:           1,
+					CacheTTL:             "1m",
+				}
+				return d
+			},
+		},
+		{
+			name: "A datasource with an invalid enhanced features query timeout should error.",
+			ds: func() model.Datasource {
+				d := getBaseDatasource()
+				d.EnhancedFeatures = &model.EnhancedFeatures{QueryTimeout: "10"}
+				return d
+			},
+			expErr: true,
+		},
+		{
+			name: "A datasource with a negative enhanced features max retries should error.",
+			ds: func() model.Datasource {
+				d := getBaseDatasource()
+				d.EnhancedFeatures = &model.EnhancedFeatures{MaxRetries: -1}
+				return d
+			},
+			expErr: true,
+		},
 	}
 
 	for _, test := range tests {
@@ -18,10 +18,48 @@ type MetricSeries struct {
 	Metrics []Metric
 }
 
-// TimeRange represents a time range for queries
-type TimeRange struct {
-	Start time.Time
-	End   time.Time
+// MetricMetadata is the metadata of a metric, it's used to infer how the
+// values of the metric should be represented.
+type MetricMetadata struct {
+	// Name is the name of the metric.
+	Name string
+	// Type is the type of the metric (e.g counter, gauge, histogram...).
+	Type string
+	// Unit is the unit of the metric (e.g seconds, bytes...).
+	Unit string
+	// Help is the description of the metric.
+	Help string
+}
+
+// CircuitState is the state of the circuit breaker of a datasource.
+type CircuitState string
+
+const (
+	// CircuitClosed is the state of a healthy datasource, the queries
+	// reach the datasource.
+	CircuitClosed CircuitState = "closed"
+	// CircuitOpen is the state of a failing datasource, the queries
+	// are rejected until the cool-down period ends.
+	CircuitOpen CircuitState = "open"
+	// CircuitHalfOpen is the state of a datasource after the cool-down
+	// period, a single query is probing if the datasource has recovered.
+	CircuitHalfOpen CircuitState = "half-open"
+)
+
+// DatasourceHealth is the health of a datasource based on the result of
+// the latest queries.
+type DatasourceHealth struct {
+	DatasourceID string
+	State        CircuitState
+	// ConsecutiveFailures are the number of queries that failed in a row.
+	ConsecutiveFailures int
+	// LastError is the error of the latest failed query.
+	LastError string
+	// Since is when the datasource changed to the current state.
+	Since time.Time
+	// RetryAt is when an open circuit will let a query probe the
+	// datasource again.
+	RetryAt time.Time
 }
 
 // Range is a duration representing a time range
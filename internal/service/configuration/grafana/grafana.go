@@ -0,0 +1,123 @@
+package grafana
+
+import "encoding/json"
+
+// The Grafana dashboard JSON model, only the fields that can be imported
+// are mapped. It supports the panels based schema (Grafana >= 5) and the
+// old rows based schema.
+
+type dashboard struct {
+	Title      string     `json:"title"`
+	Panels     []panel    `json:"panels"`
+	Rows       []row      `json:"rows"`
+	Templating templating `json:"templating"`
+}
+
+type row struct {
+	Panels []panel `json:"panels"`
+}
+
+type templating struct {
+	List []templateVariable `json:"list"`
+}
+
+type templateVariable struct {
+	Name       string          `json:"name"`
+	Type       string          `json:"type"`
+	Datasource json.RawMessage `json:"datasource"`
+	// Query can be a string or an object with the query (Grafana >= 7).
+	Query   json.RawMessage `json:"query"`
+	Regex   string          `json:"regex"`
+	Sort    int             `json:"sort"`
+	Multi   bool            `json:"multi"`
+	Refresh int             `json:"refresh"`
+	Current struct {
+		Value json.RawMessage `json:"value"`
+	} `json:"current"`
+}
+
+type panel struct {
+	Type       string          `json:"type"`
+	Title      string          `json:"title"`
+	Datasource json.RawMessage `json:"datasource"`
+	GridPos    gridPos         `json:"gridPos"`
+	Targets    []target        `json:"targets"`
+	// Panels are the panels of a collapsed row.
+	Panels      []panel     `json:"panels"`
+	FieldConfig fieldConfig `json:"fieldConfig"`
+	Options     struct {
+		Legend *struct {
+			ShowLegend  *bool  `json:"showLegend"`
+			DisplayMode string `json:"displayMode"`
+			Placement   string `json:"placement"`
+		} `json:"legend"`
+	} `json:"options"`
+
+	// Old schema settings.
+	Span   float64 `json:"span"`
+	Legend *struct {
+		Show      bool `json:"show"`
+		RightSide bool `json:"rightSide"`
+	} `json:"legend"`
+	Yaxes []struct {
+		Format   string   `json:"format"`
+		Decimals *float64 `json:"decimals"`
+	} `json:"yaxes"`
+	SeriesOverrides []struct {
+		Alias string `json:"alias"`
+		Color string `json:"color"`
+	} `json:"seriesOverrides"`
+	Format   string   `json:"format"`
+	Decimals *float64 `json:"decimals"`
+	// Thresholds are a string on singlestats (e.g `"50,80"`).
+	Thresholds json.RawMessage `json:"thresholds"`
+	Colors     []string        `json:"colors"`
+	Gauge      struct {
+		MinValue *float64 `json:"minValue"`
+		MaxValue *float64 `json:"maxValue"`
+		Show     bool     `json:"show"`
+	} `json:"gauge"`
+}
+
+type gridPos struct {
+	X int `json:"x"`
+	Y int `json:"y"`
+	W int `json:"w"`
+	H int `json:"h"`
+}
+
+type fieldConfig struct {
+	Defaults struct {
+		Unit       string   `json:"unit"`
+		Decimals   *float64 `json:"decimals"`
+		Min        *float64 `json:"min"`
+		Max        *float64 `json:"max"`
+		Thresholds *struct {
+			Mode  string `json:"mode"`
+			Steps []struct {
+				Color string   `json:"color"`
+				Value *float64 `json:"value"`
+			} `json:"steps"`
+		} `json:"thresholds"`
+	} `json:"defaults"`
+}
+
+type target struct {
+	RefID      string          `json:"refId"`
+	Datasource json.RawMessage `json:"datasource"`
+	Hide       bool            `json:"hide"`
+	// Prometheus.
+	Expr         string `json:"expr"`
+	LegendFormat string `json:"legendFormat"`
+	// Graphite.
+	Target string `json:"target"`
+	// InfluxDB.
+	Query    string `json:"query"`
+	RawQuery bool   `json:"rawQuery"`
+	Alias    string `json:"alias"`
+}
+
+type datasourceRef struct {
+	UID  string `json:"uid"`
+	Type string `json:"type"`
+}
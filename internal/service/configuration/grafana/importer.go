@@ -0,0 +1,744 @@
+package grafana
+
+import (
+	"encoding/json"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"math"
+	"regexp"
+	"sort"
+	"strconv"
+	"strings"
+
+	"github.com/slok/grafterm/internal/model"
+	"github.com/slok/grafterm/internal/service/configuration/meta"
+	v1 "github.com/slok/grafterm/internal/service/configuration/v1"
+)
+
+const (
+	// gridMaxWidth is the width of the Grafana grid.
+	gridMaxWidth = 24
+	// oldGridMaxWidth is the width of the Grafana grid on the old rows schema.
+	oldGridMaxWidth = 12
+	// intervalVariable is the variable used for the Grafana auto interval
+	// builtin variables.
+	intervalVariable = "__interval"
+	defIntervalSteps = 50
+	defDatasourceID  = "default"
+)
+
+var (
+	// variableRegexp matches the Grafana variable syntaxes: `$var`, `${var}`,
+	// `${var:format}` and `[[var]]`.
+	variableRegexp = regexp.MustCompile(`\$\{(\w+)(?::[^}]*)?\}|\[\[(\w+)(?::[^\]]*)?\]\]|\$(\w+)`)
+	// legendRegexp matches the Grafana legend format labels: `{{label}}`.
+	legendRegexp = regexp.MustCompile(`\{\{\s*(\w+)\s*\}\}`)
+	// influxAliasRegexp matches the InfluxDB alias tags: `$tag_host` and `[[tag_host]]`.
+	influxAliasRegexp = regexp.MustCompile(`\$tag_(\w+)|\[\[tag_(\w+)\]\]`)
+	// colorRGBRegexp matches the `rgb(r, g, b)` and `rgba(r, g, b, a)` colors.
+	colorRGBRegexp = regexp.MustCompile(`^rgba?\(\s*(\d+)\s*,\s*(\d+)\s*,\s*(\d+)\s*(?:,\s*[\d.]+\s*)?\)$`)
+)
+
+// intervalBuiltins are the Grafana builtin variables that will be replaced by
+// the auto interval variable.
+var intervalBuiltins = map[string]bool{
+	"__interval":      true,
+	"__rate_interval": true,
+}
+
+// namedColors are the Grafana named colors.
+var namedColors = map[string]string{
+	"green":       "#73BF69",
+	"dark-green":  "#37872D",
+	"red":         "#F2495C",
+	"dark-red":    "#C4162A",
+	"yellow":      "#FADE2A",
+	"dark-yellow": "#E0B400",
+	"orange":      "#FF9830",
+	"dark-orange": "#FA6400",
+	"blue":        "#5794F2",
+	"dark-blue":   "#1F60C4",
+	"purple":      "#B877D9",
+	"dark-purple": "#8F3BB8",
+	"white":       "#FFFFFF",
+	"black":       "#000000",
+	"transparent": "",
+}
+
+// units are the Grafana units that have an equivalent.
+var units = map[string]string{
+	"":            "",
+	"short":       "short",
+	"none":        "none",
+	"percent":     "percent",
+	"percentunit": "ratio",
+	"s":           "s",
+	"ms":          "ms",
+	"reqps":       "reqps",
+	"bytes":       "bytes",
+	"decbytes":    "bytes",
+}
+
+// variableSorts are the Grafana variable sorts by their ID.
+var variableSorts = map[int]model.VariableSort{
+	0: model.VariableSortNone,
+	1: model.VariableSortAlphabetical,
+	2: model.VariableSortAlphabeticalDesc,
+	3: model.VariableSortNumerical,
+	4: model.VariableSortNumericalDesc,
+	5: model.VariableSortAlphabetical,
+	6: model.VariableSortAlphabeticalDesc,
+}
+
+// UnsupportedPanel is a Grafana panel that could not be imported.
+type UnsupportedPanel struct {
+	Title  string
+	Type   string
+	Reason string
+}
+
+// Report is the summary of an import.
+type Report struct {
+	// Widgets is the number of imported widgets.
+	Widgets int
+	// Variables is the number of imported variables.
+	Variables int
+	// UnsupportedPanels are the panels that could not be imported.
+	UnsupportedPanels []UnsupportedPanel
+	// Warnings are the settings that could not be imported or have
+	// been imported partially.
+	Warnings []string
+	// Datasources are the IDs of the datasources referenced by the
+	// imported dashboard, these need to be configured by the user.
+	Datasources []string
+}
+
+// ImporterConfig is the configuration of the Importer.
+type ImporterConfig struct {
+	// DefaultDatasourceID is the datasource ID that will be used by the
+	// panels that use the Grafana default datasource.
+	DefaultDatasourceID string
+}
+
+func (c *ImporterConfig) defaults() {
+	if c.DefaultDatasourceID == "" {
+		c.DefaultDatasourceID = defDatasourceID
+	}
+}
+
+// Importer knows how to import Grafana dashboards in JSON format into
+// v1 configurations.
+type Importer struct {
+	cfg ImporterConfig
+}
+
+// NewImporter returns a new Importer.
+func NewImporter(cfg ImporterConfig) *Importer {
+	cfg.defaults()
+
+	return &Importer{
+		cfg: cfg,
+	}
+}
+
+// Import converts a Grafana dashboard into a v1 configuration. The configuration
+// will not have datasources, the referenced ones are returned on the report.
+func (i *Importer) Import(r io.Reader) (*v1.Configuration, *Report, error) {
+	bs, err := ioutil.ReadAll(r)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	var d dashboard
+	err = json.Unmarshal(bs, &d)
+	if err != nil {
+		return nil, nil, fmt.Errorf("error unmarshalling json: %s", err)
+	}
+
+	// Grafana API exports have the dashboard inside a `dashboard` key.
+	if len(d.Panels) == 0 && len(d.Rows) == 0 {
+		wrapped := struct {
+			Dashboard *dashboard `json:"dashboard"`
+		}{}
+		err := json.Unmarshal(bs, &wrapped)
+		if err == nil && wrapped.Dashboard != nil {
+			d = *wrapped.Dashboard
+		}
+	}
+
+	imp := &importer{
+		cfg:         i.cfg,
+		report:      &Report{},
+		variables:   map[string]bool{},
+		datasources: map[string]bool{},
+	}
+	cfg := imp.importDashboard(d)
+
+	return cfg, imp.report, nil
+}
+
+// importer has the state of a single import.
+type importer struct {
+	cfg         ImporterConfig
+	report      *Report
+	variables   map[string]bool
+	usesIntvl   bool
+	datasources map[string]bool
+}
+
+func (i *importer) importDashboard(d dashboard) *v1.Configuration {
+	cfg := &v1.Configuration{
+		Meta: meta.Meta{Version: v1.Version},
+		V1Dashboard: v1.Dashboard{
+			Grid:      model.Grid{MaxWidth: gridMaxWidth},
+			Variables: map[string]*model.Variable{},
+		},
+	}
+
+	// The variables need to be known before converting the expressions.
+	for _, tv := range d.Templating.List {
+		i.variables[tv.Name] = true
+	}
+	for _, tv := range d.Templating.List {
+		v, ok := i.importVariable(tv)
+		if !ok {
+			continue
+		}
+		cfg.V1Dashboard.Variables[tv.Name] = v
+	}
+
+	placed := []gridPos{}
+	for _, p := range i.flattenPanels(d) {
+		w, reason := i.importPanel(p)
+		if reason != "" {
+			i.report.UnsupportedPanels = append(i.report.UnsupportedPanels, UnsupportedPanel{
+				Title:  p.Title,
+				Type:   p.Type,
+				Reason: reason,
+			})
+			continue
+		}
+		i.checkPosition(p, placed)
+		placed = append(placed, p.GridPos)
+		cfg.V1Dashboard.Widgets = append(cfg.V1Dashboard.Widgets, *w)
+	}
+
+	// Add the auto interval variable if any expression uses it.
+	if i.usesIntvl {
+		if _, ok := cfg.V1Dashboard.Variables[intervalVariable]; !ok {
+			cfg.V1Dashboard.Variables[intervalVariable] = &model.Variable{
+				VariableSource: model.VariableSource{
+					Interval: &model.IntervalVariableSource{Steps: defIntervalSteps},
+				},
+			}
+		}
+	}
+
+	i.report.Widgets = len(cfg.V1Dashboard.Widgets)
+	i.report.Variables = len(cfg.V1Dashboard.Variables)
+	for id := range i.datasources {
+		i.report.Datasources = append(i.report.Datasources, id)
+	}
+	sort.Strings(i.report.Datasources)
+
+	return cfg
+}
+
+// flattenPanels returns the panels of the dashboard in the order they are
+// placed (top to bottom, left to right), the panels of collapsed rows and
+// the rows of the old schema are flattened.
+func (i *importer) flattenPanels(d dashboard) []panel {
+	ps := []panel{}
+	for _, p := range d.Panels {
+		if p.Type == "row" {
+			ps = append(ps, p.Panels...)
+			continue
+		}
+		ps = append(ps, p)
+	}
+	sort.SliceStable(ps, func(i, j int) bool {
+		if ps[i].GridPos.Y != ps[j].GridPos.Y {
+			return ps[i].GridPos.Y < ps[j].GridPos.Y
+		}
+		return ps[i].GridPos.X < ps[j].GridPos.X
+	})
+
+	// The old schema rows have 12 columns and the width is the span.
+	for _, r := range d.Rows {
+		for _, p := range r.Panels {
+			p.GridPos = gridPos{W: int(p.Span) * gridMaxWidth / oldGridMaxWidth}
+			ps = append(ps, p)
+		}
+	}
+
+	return ps
+}
+
+// checkPosition warns when the panel will not keep its horizontal position.
+// The grid places the widgets one after the other so if there is blank space
+// on the left of the panel (not used by the already placed panels) the
+// widget will be moved to the left.
+func (i *importer) checkPosition(p panel, placed []gridPos) {
+	left := []gridPos{}
+	for _, gp := range placed {
+		h := gp.H
+		if h <= 0 {
+			h = 1
+		}
+		if gp.X < p.GridPos.X && gp.Y <= p.GridPos.Y && p.GridPos.Y < gp.Y+h {
+			left = append(left, gp)
+		}
+	}
+	sort.SliceStable(left, func(i, j int) bool { return left[i].X < left[j].X })
+
+	filled := 0
+	for _, gp := range left {
+		if gp.X > filled {
+			break
+		}
+		if gp.X+gp.W > filled {
+			filled = gp.X + gp.W
+		}
+	}
+
+	if filled < p.GridPos.X {
+		i.warnf("%q panel: x position not kept, the blank space on its left has been removed", p.Title)
+	}
+}
+
+// importPanel returns the widget of the panel or the reason why it
+// could not be imported.
+func (i *importer) importPanel(p panel) (*model.Widget, string) {
+	w := &model.Widget{
+		Title: p.Title,
+		GridPos: model.GridPos{
+			W: p.GridPos.W,
+			H: p.GridPos.H,
+		},
+	}
+	if w.GridPos.W <= 0 {
+		w.GridPos.W = gridMaxWidth
+	}
+
+	var supported bool
+	switch p.Type {
+	case "graph", "timeseries", "stat", "singlestat", "gauge", "table", "table-old":
+		supported = true
+	}
+	if !supported {
+		return nil, "panel type not supported"
+	}
+
+	queries := i.importQueries(p)
+	if len(queries) == 0 {
+		return nil, "panel without supported queries"
+	}
+
+	unit, decimals := i.importValueRepresentation(p)
+	thresholds := i.importThresholds(p)
+
+	switch p.Type {
+	case "graph", "timeseries":
+		w.Graph = &model.GraphWidgetSource{
+			Queries: queries,
+			Visualization: model.GraphVisualization{
+				Legend:         i.importLegend(p),
+				SeriesOverride: i.importSeriesOverrides(p),
+				YAxis: model.YAxis{
+					ValueRepresentation: model.ValueRepresentation{Unit: unit, Decimals: decimals},
+				},
+			},
+		}
+	case "stat", "singlestat":
+		i.warnMultipleQueries(p, queries)
+		w.Singlestat = &model.SinglestatWidgetSource{
+			Query:               queries[0],
+			ValueRepresentation: model.ValueRepresentation{Unit: unit, Decimals: decimals},
+			Thresholds:          thresholds,
+		}
+	case "gauge":
+		i.warnMultipleQueries(p, queries)
+		min, max := i.importMinMax(p)
+		w.Gauge = &model.GaugeWidgetSource{
+			Query:        queries[0],
+			Min:          min,
+			Max:          max,
+			PercentValue: max > min,
+			Thresholds:   thresholds,
+		}
+	case "table", "table-old":
+		tqs := []model.TableQuery{}
+		for _, q := range queries {
+			tqs = append(tqs, model.TableQuery{
+				Query:               q,
+				ValueRepresentation: model.ValueRepresentation{Unit: unit, Decimals: decimals},
+				Thresholds:          thresholds,
+			})
+		}
+		w.Table = &model.TableWidgetSource{Queries: tqs}
+	}
+
+	return w, ""
+}
+
+func (i *importer) warnMultipleQueries(p panel, queries []model.Query) {
+	if len(queries) > 1 {
+		i.warnf("%q panel: only the first of %d queries imported", p.Title, len(queries))
+	}
+}
+
+// importQueries returns the queries of the panel targets that are supported.
+func (i *importer) importQueries(p panel) []model.Query {
+	qs := []model.Query{}
+	for _, t := range p.Targets {
+		if t.Hide {
+			continue
+		}
+
+		var expr, legend string
+		switch {
+		case t.Expr != "":
+			expr = t.Expr
+			legend = legendRegexp.ReplaceAllString(t.LegendFormat, "{{ .$1 }}")
+		case t.Target != "":
+			expr = t.Target
+		case t.Query != "" && t.RawQuery:
+			expr = t.Query
+			legend = influxAliasRegexp.ReplaceAllString(t.Alias, "{{ .$1$2 }}")
+		default:
+			i.warnf("%q panel: %s query ignored, only raw queries are supported", p.Title, t.RefID)
+			continue
+		}
+
+		// The target datasource has priority over the panel datasource.
+		dsID := i.datasourceID(t.Datasource)
+		if dsID == "" {
+			dsID = i.datasourceID(p.Datasource)
+		}
+		if dsID == "" {
+			dsID = i.cfg.DefaultDatasourceID
+		}
+		i.datasources[dsID] = true
+
+		qs = append(qs, model.Query{
+			Expr:         i.convertExpr(expr),
+			Legend:       i.convertExpr(legend),
+			DatasourceID: dsID,
+		})
+	}
+
+	return qs
+}
+
+// convertExpr converts the Grafana variables of an expression into templated
+// variables. The variables that are unknown are maintained.
+func (i *importer) convertExpr(expr string) string {
+	return variableRegexp.ReplaceAllStringFunc(expr, func(s string) string {
+		m := variableRegexp.FindStringSubmatch(s)
+		name := m[1] + m[2] + m[3]
+
+		switch {
+		case intervalBuiltins[name]:
+			i.usesIntvl = true
+			return fmt.Sprintf("{{ .%s }}", intervalVariable)
+		case i.variables[name]:
+			return fmt.Sprintf("{{ .%s }}", name)
+		default:
+			return s
+		}
+	})
+}
+
+// datasourceID returns the ID of a Grafana datasource reference, it can be
+// the name of the datasource or an object with the UID, if the reference is
+// a variable the name of the variable will be used as the ID. Empty means
+// that the reference doesn't point to a specific datasource.
+func (i *importer) datasourceID(raw json.RawMessage) string {
+	if len(raw) == 0 || string(raw) == "null" {
+		return ""
+	}
+
+	var id string
+	var ref datasourceRef
+	if err := json.Unmarshal(raw, &id); err != nil {
+		if err := json.Unmarshal(raw, &ref); err != nil {
+			return ""
+		}
+		id = ref.UID
+	}
+
+	switch id {
+	case "", "-- Mixed --", "-- Dashboard --":
+		return ""
+	case "default", "-- Grafana --":
+		return i.cfg.DefaultDatasourceID
+	}
+
+	if m := variableRegexp.FindStringSubmatch(id); m != nil {
+		return m[1] + m[2] + m[3]
+	}
+
+	return id
+}
+
+// importValueRepresentation returns the unit and decimals of the panel.
+func (i *importer) importValueRepresentation(p panel) (string, int) {
+	unit := p.FieldConfig.Defaults.Unit
+	decimals := p.FieldConfig.Defaults.Decimals
+	switch {
+	case unit == "" && len(p.Yaxes) > 0:
+		unit = p.Yaxes[0].Format
+		decimals = p.Yaxes[0].Decimals
+	case unit == "" && p.Format != "":
+		unit = p.Format
+		decimals = p.Decimals
+	}
+
+	u, ok := units[unit]
+	if !ok {
+		i.warnf("%q panel: %s unit not supported", p.Title, unit)
+	}
+
+	d := 0
+	if decimals != nil {
+		d = int(*decimals)
+	}
+
+	return u, d
+}
+
+// importThresholds returns the thresholds of the panel based on the field
+// config thresholds or the old singlestat thresholds and colors.
+func (i *importer) importThresholds(p panel) []model.Threshold {
+	ts := []model.Threshold{}
+	if th := p.FieldConfig.Defaults.Thresholds; th != nil {
+		if th.Mode == "percentage" {
+			i.warnf("%q panel: percentage thresholds imported as absolute", p.Title)
+		}
+		for _, s := range th.Steps {
+			color, ok := i.importColor(p, s.Color)
+			if !ok {
+				continue
+			}
+			// The base threshold doesn't have value.
+			t := model.Threshold{Color: color}
+			if s.Value != nil {
+				t.StartValue = *s.Value
+			}
+			ts = append(ts, t)
+		}
+		return dedupThresholds(ts)
+	}
+
+	// Old singlestat thresholds (e.g: "50,80" with 3 colors).
+	var values string
+	if err := json.Unmarshal(p.Thresholds, &values); err != nil || values == "" || len(p.Colors) == 0 {
+		return ts
+	}
+	starts := []float64{0}
+	for _, v := range strings.Split(values, ",") {
+		f, err := strconv.ParseFloat(strings.TrimSpace(v), 64)
+		if err != nil {
+			i.warnf("%q panel: invalid threshold %q", p.Title, v)
+			return []model.Threshold{}
+		}
+		starts = append(starts, f)
+	}
+	for idx, start := range starts {
+		if idx >= len(p.Colors) {
+			break
+		}
+		color, ok := i.importColor(p, p.Colors[idx])
+		if !ok {
+			continue
+		}
+		ts = append(ts, model.Threshold{StartValue: start, Color: color})
+	}
+
+	return dedupThresholds(ts)
+}
+
+// dedupThresholds removes the thresholds that have a repeated start value,
+// the last one wins.
+func dedupThresholds(ts []model.Threshold) []model.Threshold {
+	res := []model.Threshold{}
+	idxs := map[float64]int{}
+	for _, t := range ts {
+		if idx, ok := idxs[t.StartValue]; ok {
+			res[idx] = t
+			continue
+		}
+		idxs[t.StartValue] = len(res)
+		res = append(res, t)
+	}
+	return res
+}
+
+// importColor converts a Grafana color into a hex color.
+func (i *importer) importColor(p panel, c string) (string, bool) {
+	c = strings.TrimSpace(c)
+	if strings.HasPrefix(c, "#") {
+		return c, true
+	}
+
+	if hex, ok := namedColors[c]; ok && hex != "" {
+		return hex, true
+	}
+
+	if m := colorRGBRegexp.FindStringSubmatch(c); m != nil {
+		rgb := []int{}
+		for _, v := range m[1:] {
+			n, _ := strconv.Atoi(v)
+			rgb = append(rgb, n)
+		}
+		return fmt.Sprintf("#%02x%02x%02x", rgb[0], rgb[1], rgb[2]), true
+	}
+
+	i.warnf("%q panel: %s color not supported", p.Title, c)
+	return "", false
+}
+
+// importMinMax returns the min and max values of the gauge panel.
+func (i *importer) importMinMax(p panel) (int, int) {
+	min, max := p.FieldConfig.Defaults.Min, p.FieldConfig.Defaults.Max
+	if min == nil && max == nil {
+		min, max = p.Gauge.MinValue, p.Gauge.MaxValue
+	}
+
+	var mi, ma int
+	if min != nil {
+		mi = int(math.Round(*min))
+	}
+	if max != nil {
+		ma = int(math.Round(*max))
+	}
+
+	// Percent units without max are from 0 to 100.
+	if max == nil && p.FieldConfig.Defaults.Unit == "percent" {
+		ma = 100
+	}
+
+	return mi, ma
+}
+
+// importLegend returns the legend of the graph panel.
+func (i *importer) importLegend(p panel) model.Legend {
+	l := model.Legend{}
+	switch {
+	case p.Options.Legend != nil:
+		ol := p.Options.Legend
+		l.Disable = (ol.ShowLegend != nil && !*ol.ShowLegend) || ol.DisplayMode == "hidden"
+		l.RightSide = ol.Placement == "right"
+	case p.Legend != nil:
+		l.Disable = !p.Legend.Show
+		l.RightSide = p.Legend.RightSide
+	}
+
+	return l
+}
+
+// importSeriesOverrides returns the series colors of the graph panel, the
+// Grafana aliases can be regexes (`/regex/`) or the name of the series.
+func (i *importer) importSeriesOverrides(p panel) []model.SeriesOverride {
+	sos := []model.SeriesOverride{}
+	for _, so := range p.SeriesOverrides {
+		if so.Color == "" || so.Alias == "" {
+			continue
+		}
+
+		color, ok := i.importColor(p, so.Color)
+		if !ok {
+			continue
+		}
+
+		regex := "^" + regexp.QuoteMeta(so.Alias) + "$"
+		if len(so.Alias) > 1 && strings.HasPrefix(so.Alias, "/") && strings.HasSuffix(so.Alias, "/") {
+			regex = so.Alias[1 : len(so.Alias)-1]
+		}
+
+		sos = append(sos, model.SeriesOverride{Regex: regex, Color: color})
+	}
+
+	return sos
+}
+
+// importVariable returns the variable of the Grafana template variable.
+func (i *importer) importVariable(tv templateVariable) (*model.Variable, bool) {
+	query := rawQueryString(tv.Query)
+
+	v := &model.Variable{}
+	switch tv.Type {
+	case "query":
+		dsID := i.datasourceID(tv.Datasource)
+		if dsID == "" {
+			dsID = i.cfg.DefaultDatasourceID
+		}
+		i.datasources[dsID] = true
+
+		vs, ok := variableSorts[tv.Sort]
+		if !ok {
+			vs = model.VariableSortNone
+		}
+
+		v.Query = &model.QueryVariableSource{
+			Query: model.Query{
+				Expr:         i.convertExpr(query),
+				DatasourceID: dsID,
+			},
+			Regex: strings.TrimSuffix(strings.TrimPrefix(tv.Regex, "/"), "/"),
+			Sort:  vs,
+			Multi: tv.Multi,
+			// Refresh on time range change.
+			RefreshOnSync: tv.Refresh == 2,
+		}
+	case "constant":
+		v.Constant = &model.ConstantVariableSource{Value: query}
+	case "interval":
+		v.Interval = &model.IntervalVariableSource{Steps: defIntervalSteps}
+	case "custom", "textbox":
+		// Use the current value as a constant.
+		value := rawQueryString(tv.Current.Value)
+		if value == "" {
+			value = strings.TrimSpace(strings.Split(query, ",")[0])
+		}
+		if value == "" {
+			i.warnf("%q %s variable without value ignored", tv.Name, tv.Type)
+			return nil, false
+		}
+		i.warnf("%q %s variable imported as a constant with %q value", tv.Name, tv.Type, value)
+		v.Constant = &model.ConstantVariableSource{Value: value}
+	default:
+		i.warnf("%q variable ignored, %s variables are not supported", tv.Name, tv.Type)
+		return nil, false
+	}
+
+	return v, true
+}
+
+// rawQueryString returns the string of a raw value that can be a
+// string, an object with a query or a list of strings.
+func rawQueryString(raw json.RawMessage) string {
+	var s string
+	if err := json.Unmarshal(raw, &s); err == nil {
+		return s
+	}
+
+	var q struct {
+		Query string `json:"query"`
+	}
+	if err := json.Unmarshal(raw, &q); err == nil && q.Query != "" {
+		return q.Query
+	}
+
+	var ss []string
+	if err := json.Unmarshal(raw, &ss); err == nil && len(ss) > 0 {
+		return ss[0]
+	}
+
+	return ""
+}
+
+func (i *importer) warnf(format string, args ...interface{}) {
+	i.report.Warnings = append(i.report.Warnings, fmt.Sprintf(format, args...))
+}
@@ -0,0 +1,394 @@
+package grafana_test
+
+import (
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+
+	"github.com/slok/grafterm/internal/model"
+	"github.com/slok/grafterm/internal/service/configuration/grafana"
+	"github.com/slok/grafterm/internal/service/configuration/meta"
+	v1 "github.com/slok/grafterm/internal/service/configuration/v1"
+)
+
+func TestImporterImport(t *testing.T) {
+	tests := []struct {
+		name      string
+		cfg       grafana.ImporterConfig
+		dashboard string
+		expCfg    *v1.Configuration
+		expReport *grafana.Report
+		expErr    bool
+	}{
+		{
+			name:      "Invalid JSON should return an error.",
+			dashboard: `{"panels": [}`,
+			expErr:    true,
+		},
+		{
+			name: "Graph and timeseries panels should be imported as graphs.",
+			dashboard: `
+{
+  "panels": [
+    {
+      "type": "timeseries",
+      "title": "Requests",
+      "datasource": {"type": "prometheus", "uid": "prom"},
+      "gridPos": {"x": 12, "y": 0, "w": 12, "h": 8},
+      "fieldConfig": {"defaults": {"unit": "reqps", "decimals": 1}},
+      "options": {"legend": {"displayMode": "list", "placement": "right"}},
+      "targets": [
+        {"refId": "A", "expr": "sum(rate(http_requests_total[$__rate_interval])) by (code)", "legendFormat": "{{code}}"},
+        {"refId": "B", "expr": "up", "hide": true}
+      ]
+    },
+    {
+      "type": "graph",
+      "title": "Latency",
+      "datasource": "Prometheus",
+      "gridPos": {"x": 0, "y": 0, "w": 12, "h": 8},
+      "legend": {"show": false},
+      "yaxes": [{"format": "s", "decimals": 2}, {"format": "short"}],
+      "seriesOverrides": [{"alias": "p99", "color": "rgba(242, 73, 92, 1)"}, {"alias": "/p5.*/", "color": "#00ff00"}],
+      "targets": [{"refId": "A", "expr": "histogram_quantile(0.99, rate(latency_bucket[5m]))", "legendFormat": "p99"}]
+    }
+  ]
+}`,
+			expCfg: &v1.Configuration{
+				Meta: meta.Meta{Version: "v1"},
+				V1Dashboard: v1.Dashboard{
+					Grid: model.Grid{MaxWidth: 24},
+					Variables: map[string]*model.Variable{
+						"__interval": {VariableSource: model.VariableSource{Interval: &model.IntervalVariableSource{Steps: 50}}},
+					},
+					Widgets: []model.Widget{
+						{
+							Title:   "Latency",
+							GridPos: model.GridPos{W: 12, H: 8},
+							WidgetSource: model.WidgetSource{Graph: &model.GraphWidgetSource{
+								Queries: []model.Query{
+									{Expr: "histogram_quantile(0.99, rate(latency_bucket[5m]))", Legend: "p99", DatasourceID: "Prometheus"},
+								},
+								Visualization: model.GraphVisualization{
+									Legend: model.Legend{Disable: true},
+									SeriesOverride: []model.SeriesOverride{
+										{Regex: "^p99$", Color: "#f2495c"},
+										{Regex: "p5.*", Color: "#00ff00"},
+									},
+									YAxis: model.YAxis{ValueRepresentation: model.ValueRepresentation{Unit: "s", Decimals: 2}},
+								},
+							}},
+						},
+						{
+							Title:   "Requests",
+							GridPos: model.GridPos{W: 12, H: 8},
+							WidgetSource: model.WidgetSource{Graph: &model.GraphWidgetSource{
+								Queries: []model.Query{
+									{Expr: "sum(rate(http_requests_total[{{ .__interval }}])) by (code)", Legend: "{{ .code }}", DatasourceID: "prom"},
+								},
+								Visualization: model.GraphVisualization{
+									Legend:         model.Legend{RightSide: true},
+									SeriesOverride: []model.SeriesOverride{},
+									YAxis:          model.YAxis{ValueRepresentation: model.ValueRepresentation{Unit: "reqps", Decimals: 1}},
+								},
+							}},
+						},
+					},
+				},
+			},
+			expReport: &grafana.Report{
+				Widgets:     2,
+				Variables:   1,
+				Datasources: []string{"Prometheus", "prom"},
+			},
+		},
+		{
+			name: "Stat, singlestat and gauge panels should be imported with their thresholds.",
+			cfg:  grafana.ImporterConfig{DefaultDatasourceID: "ds"},
+			dashboard: `
+{
+  "panels": [
+    {
+      "type": "stat",
+      "title": "Up",
+      "gridPos": {"x": 0, "y": 0, "w": 6, "h": 4},
+      "fieldConfig": {"defaults": {"unit": "percentunit", "thresholds": {"mode": "absolute", "steps": [{"color": "red", "value": null}, {"color": "green", "value": 0.9}]}}},
+      "targets": [{"refId": "A", "expr": "avg(up)"}, {"refId": "B", "expr": "min(up)"}]
+    },
+    {
+      "type": "singlestat",
+      "title": "Errors",
+      "datasource": "$ds",
+      "gridPos": {"x": 6, "y": 0, "w": 6, "h": 4},
+      "format": "none",
+      "thresholds": "10,50",
+      "colors": ["#299c46", "rgb(237, 129, 40)", "#d44a3a"],
+      "targets": [{"refId": "A", "expr": "sum(errors)"}]
+    },
+    {
+      "type": "gauge",
+      "title": "CPU",
+      "gridPos": {"x": 12, "y": 0, "w": 6, "h": 4},
+      "fieldConfig": {"defaults": {"unit": "percent", "min": 0, "max": 100}},
+      "targets": [{"refId": "A", "expr": "cpu"}]
+    }
+  ]
+}`,
+			expCfg: &v1.Configuration{
+				Meta: meta.Meta{Version: "v1"},
+				V1Dashboard: v1.Dashboard{
+					Grid:      model.Grid{MaxWidth: 24},
+					Variables: map[string]*model.Variable{},
+					Widgets: []model.Widget{
+						{
+							Title:   "Up",
+							GridPos: model.GridPos{W: 6, H: 4},
+							WidgetSource: model.WidgetSource{Singlestat: &model.SinglestatWidgetSource{
+								Query:               model.Query{Expr: "avg(up)", DatasourceID: "ds"},
+								ValueRepresentation: model.ValueRepresentation{Unit: "ratio"},
+								Thresholds: []model.Threshold{
+									{StartValue: 0, Color: "#F2495C"},
+									{StartValue: 0.9, Color: "#73BF69"},
+								},
+							}},
+						},
+						{
+							Title:   "Errors",
+							GridPos: model.GridPos{W: 6, H: 4},
+							WidgetSource: model.WidgetSource{Singlestat: &model.SinglestatWidgetSource{
+								Query:               model.Query{Expr: "sum(errors)", DatasourceID: "ds"},
+								ValueRepresentation: model.ValueRepresentation{Unit: "none"},
+								Thresholds: []model.Threshold{
+									{StartValue: 0, Color: "#299c46"},
+									{StartValue: 10, Color: "#ed8128"},
+									{StartValue: 50, Color: "#d44a3a"},
+								},
+							}},
+						},
+						{
+							Title:   "CPU",
+							GridPos: model.GridPos{W: 6, H: 4},
+							WidgetSource: model.WidgetSource{Gauge: &model.GaugeWidgetSource{
+								Query:        model.Query{Expr: "cpu", DatasourceID: "ds"},
+								Min:          0,
+								Max:          100,
+								PercentValue: true,
+								Thresholds:   []model.Threshold{},
+							}},
+						},
+					},
+				},
+			},
+			expReport: &grafana.Report{
+				Widgets:     3,
+				Variables:   0,
+				Warnings:    []string{`"Up" panel: only the first of 2 queries imported`},
+				Datasources: []string{"ds"},
+			},
+		},
+		{
+			name: "Templating variables should be imported and used on the expressions.",
+			dashboard: `
+{
+  "dashboard": {
+    "templating": {
+      "list": [
+        {"name": "namespace", "type": "query", "datasource": {"uid": "prom"}, "query": {"query": "label_values(kube_pod_info, namespace)"}, "regex": "/team-(.*)/", "sort": 1, "multi": true, "refresh": 2},
+        {"name": "env", "type": "constant", "query": "prod"},
+        {"name": "interval", "type": "interval", "query": "1m,5m,10m"},
+        {"name": "job", "type": "custom", "query": "api,web", "current": {"value": "web"}},
+        {"name": "ds", "type": "datasource", "query": "prometheus"}
+      ]
+    },
+    "panels": [
+      {
+        "type": "table",
+        "title": "Pods",
+        "datasource": {"uid": "prom"},
+        "gridPos": {"x": 0, "y": 0, "w": 24, "h": 6},
+        "targets": [{"refId": "A", "expr": "sum(up{namespace=~\"$namespace\", env=\"${env}\", job=\"[[job]]\"}[$interval]) by (pod)", "legendFormat": "Up"}]
+      }
+    ]
+  }
+}`,
+			expCfg: &v1.Configuration{
+				Meta: meta.Meta{Version: "v1"},
+				V1Dashboard: v1.Dashboard{
+					Grid: model.Grid{MaxWidth: 24},
+					Variables: map[string]*model.Variable{
+						"namespace": {VariableSource: model.VariableSource{Query: &model.QueryVariableSource{
+							Query:         model.Query{Expr: "label_values(kube_pod_info, namespace)", DatasourceID: "prom"},
+							Regex:         "team-(.*)",
+							Sort:          model.VariableSortAlphabetical,
+							Multi:         true,
+							RefreshOnSync: true,
+						}}},
+						"env":      {VariableSource: model.VariableSource{Constant: &model.ConstantVariableSource{Value: "prod"}}},
+						"interval": {VariableSource: model.VariableSource{Interval: &model.IntervalVariableSource{Steps: 50}}},
+						"job":      {VariableSource: model.VariableSource{Constant: &model.ConstantVariableSource{Value: "web"}}},
+					},
+					Widgets: []model.Widget{
+						{
+							Title:   "Pods",
+							GridPos: model.GridPos{W: 24, H: 6},
+							WidgetSource: model.WidgetSource{Table: &model.TableWidgetSource{
+								Queries: []model.TableQuery{
+									{
+										Query: model.Query{
+											Expr:         `sum(up{namespace=~"{{ .namespace }}", env="{{ .env }}", job="{{ .job }}"}[{{ .interval }}]) by (pod)`,
+											Legend:       "Up",
+											DatasourceID: "prom",
+										},
+										Thresholds: []model.Threshold{},
+									},
+								},
+							}},
+						},
+					},
+				},
+			},
+			expReport: &grafana.Report{
+				Widgets:   1,
+				Variables: 4,
+				Warnings: []string{
+					`"job" custom variable imported as a constant with "web" value`,
+					`"ds" variable ignored, datasource variables are not supported`,
+				},
+				Datasources: []string{"prom"},
+			},
+		},
+		{
+			name: "Unsupported panels should be reported and the rows flattened.",
+			dashboard: `
+{
+  "panels": [
+    {"type": "text", "title": "Readme", "gridPos": {"x": 0, "y": 0, "w": 24, "h": 2}},
+    {
+      "type": "row",
+      "title": "Collapsed",
+      "collapsed": true,
+      "gridPos": {"x": 0, "y": 2, "w": 24, "h": 1},
+      "panels": [
+        {"type": "heatmap", "title": "Heat", "gridPos": {"x": 0, "y": 3, "w": 12, "h": 8}, "targets": [{"refId": "A", "expr": "x"}]},
+        {"type": "graph", "title": "Influx", "gridPos": {"x": 12, "y": 3, "w": 12, "h": 8}, "targets": [{"refId": "A", "measurement": "cpu"}]},
+        {"type": "graph", "title": "Graphite", "datasource": "graphite", "gridPos": {"x": 0, "y": 11, "w": 12, "h": 8}, "targets": [{"refId": "A", "target": "servers.*.cpu"}]}
+      ]
+    }
+  ]
+}`,
+			expCfg: &v1.Configuration{
+				Meta: meta.Meta{Version: "v1"},
+				V1Dashboard: v1.Dashboard{
+					Grid:      model.Grid{MaxWidth: 24},
+					Variables: map[string]*model.Variable{},
+					Widgets: []model.Widget{
+						{
+							Title:   "Graphite",
+							GridPos: model.GridPos{W: 12, H: 8},
+							WidgetSource: model.WidgetSource{Graph: &model.GraphWidgetSource{
+								Queries: []model.Query{{Expr: "servers.*.cpu", DatasourceID: "graphite"}},
+								Visualization: model.GraphVisualization{
+									SeriesOverride: []model.SeriesOverride{},
+								},
+							}},
+						},
+					},
+				},
+			},
+			expReport: &grafana.Report{
+				Widgets:   1,
+				Variables: 0,
+				UnsupportedPanels: []grafana.UnsupportedPanel{
+					{Title: "Readme", Type: "text", Reason: "panel type not supported"},
+					{Title: "Heat", Type: "heatmap", Reason: "panel type not supported"},
+					{Title: "Influx", Type: "graph", Reason: "panel without supported queries"},
+				},
+				Warnings:    []string{`"Influx" panel: A query ignored, only raw queries are supported`},
+				Datasources: []string{"graphite"},
+			},
+		},
+		{
+			name: "Panels that lose their horizontal position should be reported.",
+			dashboard: `
+{
+  "panels": [
+    {"type": "graph", "title": "Tall", "gridPos": {"x": 0, "y": 0, "w": 8, "h": 16}, "targets": [{"refId": "A", "target": "a"}]},
+    {"type": "graph", "title": "Gap", "gridPos": {"x": 12, "y": 0, "w": 12, "h": 8}, "targets": [{"refId": "A", "target": "b"}]},
+    {"type": "graph", "title": "Next to tall", "gridPos": {"x": 8, "y": 8, "w": 8, "h": 8}, "targets": [{"refId": "A", "target": "c"}]},
+    {"type": "text", "title": "Readme", "gridPos": {"x": 0, "y": 16, "w": 12, "h": 8}},
+    {"type": "graph", "title": "After unsupported", "gridPos": {"x": 12, "y": 16, "w": 12, "h": 8}, "targets": [{"refId": "A", "target": "d"}]}
+  ]
+}`,
+			expCfg: &v1.Configuration{
+				Meta: meta.Meta{Version: "v1"},
+				V1Dashboard: v1.Dashboard{
+					Grid:      model.Grid{MaxWidth: 24},
+					Variables: map[string]*model.Variable{},
+					Widgets: []model.Widget{
+						{
+							Title:   "Tall",
+							GridPos: model.GridPos{W: 8, H: 16},
+							WidgetSource: model.WidgetSource{Graph: &model.GraphWidgetSource{
+								Queries:       []model.Query{{Expr: "a", DatasourceID: "default"}},
+								Visualization: model.GraphVisualization{SeriesOverride: []model.SeriesOverride{}},
+							}},
+						},
+						{
+							Title:   "Gap",
+							GridPos: model.GridPos{W: 12, H: 8},
+							WidgetSource: model.WidgetSource{Graph: &model.GraphWidgetSource{
+								Queries:       []model.Query{{Expr: "b", DatasourceID: "default"}},
+								Visualization: model.GraphVisualization{SeriesOverride: []model.SeriesOverride{}},
+							}},
+						},
+						{
+							Title:   "Next to tall",
+							GridPos: model.GridPos{W: 8, H: 8},
+							WidgetSource: model.WidgetSource{Graph: &model.GraphWidgetSource{
+								Queries:       []model.Query{{Expr: "c", DatasourceID: "default"}},
+								Visualization: model.GraphVisualization{SeriesOverride: []model.SeriesOverride{}},
+							}},
+						},
+						{
+							Title:   "After unsupported",
+							GridPos: model.GridPos{W: 12, H: 8},
+							WidgetSource: model.WidgetSource{Graph: &model.GraphWidgetSource{
+								Queries:       []model.Query{{Expr: "d", DatasourceID: "default"}},
+								Visualization: model.GraphVisualization{SeriesOverride: []model.SeriesOverride{}},
+							}},
+						},
+					},
+				},
+			},
+			expReport: &grafana.Report{
+				Widgets:   4,
+				Variables: 0,
+				UnsupportedPanels: []grafana.UnsupportedPanel{
+					{Title: "Readme", Type: "text", Reason: "panel type not supported"},
+				},
+				Warnings: []string{
+					`"Gap" panel: x position not kept, the blank space on its left has been removed`,
+					`"After unsupported" panel: x position not kept, the blank space on its left has been removed`,
+				},
+				Datasources: []string{"default"},
+			},
+		},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			assert := assert.New(t)
+
+			imp := grafana.NewImporter(test.cfg)
+			gotCfg, gotReport, err := imp.Import(strings.NewReader(test.dashboard))
+
+			if test.expErr {
+				assert.Error(err)
+			} else if assert.NoError(err) {
+				assert.Equal(test.expCfg, gotCfg)
+				assert.Equal(test.expReport, gotReport)
+			}
+		})
+	}
+}
@@ -1,6 +1,10 @@
 package metric
 
-import "time"
+import (
+	"time"
+
+	"github.com/slok/grafterm/internal/model"
+)
 
 // EnhancedFeaturesConfig configures the enhanced metric gathering features
 type EnhancedFeaturesConfig struct {
@@ -28,6 +32,9 @@ type EnhancedFeaturesConfig struct {
 
 	// MaxConcurrentQueries limits parallel query execution
 	MaxConcurrentQueries int
+
+	// EnableCircuitBreaker enables the per datasource circuit breaker
+	EnableCircuitBreaker bool
 }
 
 // DefaultEnhancedFeaturesConfig returns the default configuration
@@ -41,6 +48,7 @@ func DefaultEnhancedFeaturesConfig() EnhancedFeaturesConfig {
 		MaxRetries:           3,
 		QueryTimeout:         5 * time.Second,
 		MaxConcurrentQueries: 10,
+		EnableCircuitBreaker: true,
 	}
 }
 
@@ -52,5 +60,42 @@ func LegacyConfig() EnhancedFeaturesConfig {
 		EnableRetry:          false,
 		QueryTimeout:         0, // No explicit timeout
 		MaxConcurrentQueries: 0, // No limit
+		EnableCircuitBreaker: false,
+	}
+}
+
+// WithDatasourceOverrides returns the configuration with the settings of a
+// datasource applied, the datasource can only disable the features, not enable
+// them. The datasource durations need to be already validated.
+func (c EnhancedFeaturesConfig) WithDatasourceOverrides(ef *model.EnhancedFeatures) EnhancedFeaturesConfig {
+	if ef == nil {
+		return c
+	}
+
+	if ef.Disabled {
+		c.Enabled = false
+	}
+	if ef.DisableCache {
+		c.EnableCaching = false
 	}
-}
\ No newline at end of file
+	if ef.DisableRetry {
+		c.EnableRetry = false
+	}
+	if d, err := time.ParseDuration(ef.QueryTimeout); err == nil {
+		c.QueryTimeout = d
+	}
+	if d, err := time.ParseDuration(ef.CacheTTL); err == nil {
+		c.CacheTTL = d
+	}
+	if ef.MaxConcurrentQueries > 0 {
+		c.MaxConcurrentQueries = ef.MaxConcurrentQueries
+	}
+	if ef.MaxRetries > 0 {
+		c.MaxRetries = ef.MaxRetries
+	}
+	if ef.CacheSize > 0 {
+		c.CacheSize = ef.CacheSize
+	}
+
+	return c
+}
@@ -5,6 +5,8 @@ import (
 	"time"
 
 	"github.com/stretchr/testify/assert"
+
+	"github.com/slok/grafterm/internal/model"
 )
 
 func TestDefaultEnhancedFeaturesConfig(t *testing.T) {
@@ -18,6 +20,7 @@ func TestDefaultEnhancedFeaturesConfig(t *testing.T) {
 	assert.Equal(t, 3, cfg.MaxRetries, "max retries should be 3")
 	assert.Equal(t, 5*time.Second, cfg.QueryTimeout, "query timeout should be 5s")
 	assert.Equal(t, 10, cfg.MaxConcurrentQueries, "max concurrent queries should be 10")
+	assert.True(t, cfg.EnableCircuitBreaker, "circuit breaker should be enabled by default")
 }
 
 func TestLegacyConfig(t *testing.T) {
@@ -28,6 +31,7 @@ func TestLegacyConfig(t *testing.T) {
 	assert.False(t, cfg.EnableRetry, "retry should be disabled in legacy mode")
 	assert.Equal(t, time.Duration(0), cfg.QueryTimeout, "query timeout should be 0 in legacy mode")
 	assert.Equal(t, 0, cfg.MaxConcurrentQueries, "max concurrent queries should be 0 in legacy mode")
+	assert.False(t, cfg.EnableCircuitBreaker, "circuit breaker should be disabled in legacy mode")
 }
 
 func TestEnhancedFeaturesConfigValues(t *testing.T) {
@@ -121,4 +125,53 @@ func TestConfigCompatibility(t *testing.T) {
 		assert.False(t, cfg.EnableCaching)
 		assert.False(t, cfg.EnableRetry)
 	})
-}
\ No newline at end of file
+}
+func TestEnhancedFeaturesConfigWithDatasourceOverrides(t *testing.T) {
+	tests := []struct {
+		name   string
+		ef     *model.EnhancedFeatures
+		expCfg func() EnhancedFeaturesConfig
+	}{
+		{
+			name:   "Without datasource settings the config should not change",
+			expCfg: DefaultEnhancedFeaturesConfig,
+		},
+		{
+			name: "Datasource settings should override the config",
+			ef: &model.EnhancedFeatures{
+				QueryTimeout:         "20s",
+				MaxConcurrentQueries: 2,
+				MaxRetries:           1,
+				CacheSize:            5,
+				CacheTTL:             "1m",
+			},
+			expCfg: func() EnhancedFeaturesConfig {
+				cfg := DefaultEnhancedFeaturesConfig()
+				cfg.QueryTimeout = 20 * time.Second
+				cfg.MaxConcurrentQueries = 2
+				cfg.MaxRetries = 1
+				cfg.CacheSize = 5
+				cfg.CacheTTL = time.Minute
+				return cfg
+			},
+		},
+		{
+			name: "Datasource settings should disable the features",
+			ef:   &model.EnhancedFeatures{Disabled: true, DisableCache: true, DisableRetry: true},
+			expCfg: func() EnhancedFeaturesConfig {
+				cfg := DefaultEnhancedFeaturesConfig()
+				cfg.Enabled = false
+				cfg.EnableCaching = false
+				cfg.EnableRetry = false
+				return cfg
+			},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			cfg := DefaultEnhancedFeaturesConfig().WithDatasourceOverrides(tt.ef)
+			assert.Equal(t, tt.expCfg(), cfg)
+		})
+	}
+}
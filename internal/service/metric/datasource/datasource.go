@@ -15,14 +15,23 @@ import (
 
 	"github.com/slok/grafterm/internal/model"
 	"github.com/slok/grafterm/internal/service/metric"
+	"github.com/slok/grafterm/internal/service/metric/elasticsearch"
+	"github.com/slok/grafterm/internal/service/metric/exec"
+	"github.com/slok/grafterm/internal/service/metric/expression"
 	"github.com/slok/grafterm/internal/service/metric/fake"
+	"github.com/slok/grafterm/internal/service/metric/file"
 	"github.com/slok/grafterm/internal/service/metric/graphite"
 	"github.com/slok/grafterm/internal/service/metric/influxdb"
+	"github.com/slok/grafterm/internal/service/metric/influxdb2"
+	"github.com/slok/grafterm/internal/service/metric/middleware"
 	"github.com/slok/grafterm/internal/service/metric/prometheus"
+	"github.com/slok/grafterm/internal/service/metric/scrape"
 )
 
 const (
-	defGraphiteTimeout = 7 * time.Second
+	defGraphiteTimeout      = 7 * time.Second
+	defElasticsearchTimeout = 10 * time.Second
+	defInfluxDB2Timeout     = 10 * time.Second
 )
 
 // ConfigGatherer is the configuration of the multi Gatherer.
@@ -38,17 +47,35 @@ type ConfigGatherer struct {
 	// The key of the map is the referenced ID on the dashboard, and the
 	// value of the map is the ID of the datasource that will be used.
 	Aliases map[string]string
-	// EnhancedFeatures configures enhanced timeout, caching, and retry features
-	// Set to nil or use metric.LegacyConfig() for backward compatibility
+	// Context is the context of the gatherers that work in the background
+	// (e.g the scrape datasource), when done they will stop. By default
+	// they will work until the app exits.
+	Context context.Context
+	// EnhancedFeatures configures the timeout, concurrency limit, retry and cache
+	// of the queries to all the datasources, the datasources can override them.
+	// Set to nil to use the defaults or use metric.LegacyConfig() for backward compatibility.
 	EnhancedFeatures *metric.EnhancedFeaturesConfig
 	// CreateFakeFunc is the function that will be called to create fake gatherers.
 	CreateFakeFunc func(ds model.FakeDatasource) (metric.Gatherer, error)
 	// CreatePrometheusFunc is the function that will be called to create Prometheus gatherers.
-	CreatePrometheusFunc func(ds model.PrometheusDatasource, dsID string) (metric.Gatherer, error)
+	CreatePrometheusFunc func(ds model.PrometheusDatasource) (metric.Gatherer, error)
 	// CreateGraphiteFunc is the function that will be called to create Graphite gatherers.
 	CreateGraphiteFunc func(ds model.GraphiteDatasource) (metric.Gatherer, error)
 	// CreateInfluxDBFunc is the function that will be called to create InfluxDB gatherers.
 	CreateInfluxDBFunc func(ds model.InfluxDBDatasource) (metric.Gatherer, error)
+	// CreateElasticsearchFunc is the function that will be called to create Elasticsearch gatherers.
+	CreateElasticsearchFunc func(ds model.ElasticsearchDatasource) (metric.Gatherer, error)
+	// CreateInfluxDB2Func is the function that will be called to create InfluxDB 2 gatherers.
+	CreateInfluxDB2Func func(ds model.InfluxDB2Datasource) (metric.Gatherer, error)
+	// CreateScrapeFunc is the function that will be called to create scrape gatherers.
+	CreateScrapeFunc func(ds model.ScrapeDatasource) (metric.Gatherer, error)
+	// CreateFileFunc is the function that will be called to create file gatherers.
+	CreateFileFunc func(ds model.FileDatasource) (metric.Gatherer, error)
+	// CreateExecFunc is the function that will be called to create exec gatherers.
+	CreateExecFunc func(ds model.ExecDatasource) (metric.Gatherer, error)
+	// CreateExpressionFunc is the function that will be called to create expression gatherers,
+	// the gatherer is the multi gatherer used to gather the expression named queries.
+	CreateExpressionFunc func(ds model.ExpressionDatasource, dsID string, g metric.Gatherer) (metric.Gatherer, error)
 }
 
 func (c *ConfigGatherer) defaults() {
@@ -59,6 +86,10 @@ func (c *ConfigGatherer) defaults() {
 		}
 	}
 
+	if c.Context == nil {
+		c.Context = context.Background()
+	}
+
 	// Set default enhanced features if not specified
 	if c.EnhancedFeatures == nil {
 		defaultCfg := metric.DefaultEnhancedFeaturesConfig()
@@ -67,30 +98,24 @@ func (c *ConfigGatherer) defaults() {
 
 	// Set default creator function for prometheus.
 	if c.CreatePrometheusFunc == nil {
-		c.CreatePrometheusFunc = func(ds model.PrometheusDatasource, dsID string) (metric.Gatherer, error) {
-			cli, err := prometheusapi.NewClient(prometheusapi.Config{
-				Address: ds.Address,
-			})
+		c.CreatePrometheusFunc = func(ds model.PrometheusDatasource) (metric.Gatherer, error) {
+			rt, err := newHTTPRoundTripper(ds.HTTPClientConfig)
 			if err != nil {
 				return nil, err
 			}
 
-			// Use enhanced gatherer if enabled, otherwise use standard
-			if c.EnhancedFeatures != nil && c.EnhancedFeatures.Enabled {
-				g := prometheus.NewEnhancedGatherer(prometheus.ConfigGatherer{
-					Client: prometheusv1.NewAPI(cli),
-				}, dsID)
-
-				// Configure timeout if specified
-				// Note: SetTimeout will be called via the EnhancedGatherer interface
-				// when we add it, but for now the default timeout is used
-
-				return g, nil
+			cli, err := prometheusapi.NewClient(prometheusapi.Config{
+				Address:      ds.Address,
+				RoundTripper: rt,
+			})
+			if err != nil {
+				return nil, err
 			}
+			cli = prometheus.NewErrorClassifierClient(cli)
 
-			// Legacy mode: use standard gatherer
 			g := prometheus.NewGatherer(prometheus.ConfigGatherer{
-				Client: prometheusv1.NewAPI(cli),
+				Client:    prometheusv1.NewAPI(cli),
+				APIClient: cli,
 			})
 
 			return g, nil
@@ -100,10 +125,16 @@ func (c *ConfigGatherer) defaults() {
 	// Set default creator function for Graphite.
 	if c.CreateGraphiteFunc == nil {
 		c.CreateGraphiteFunc = func(ds model.GraphiteDatasource) (metric.Gatherer, error) {
+			rt, err := newHTTPRoundTripper(ds.HTTPClientConfig)
+			if err != nil {
+				return nil, err
+			}
+
 			g, err := graphite.NewGatherer(graphite.ConfigGatherer{
 				GraphiteAPIURL: ds.Address,
 				HTTPCli: &http.Client{
-					Timeout: defGraphiteTimeout,
+					Transport: rt,
+					Timeout:   defGraphiteTimeout,
 				},
 			})
 			if err != nil {
@@ -117,11 +148,20 @@ func (c *ConfigGatherer) defaults() {
 	// Set default creator function for InfluxDB.
 	if c.CreateInfluxDBFunc == nil {
 		c.CreateInfluxDBFunc = func(ds model.InfluxDBDatasource) (metric.Gatherer, error) {
+			tlsCfg, err := newTLSConfig(ds.TLS)
+			if err != nil {
+				return nil, err
+			}
+			// The TLS config overrides the insecure option of the client.
+			if tlsCfg != nil && ds.Insecure {
+				tlsCfg.InsecureSkipVerify = true
+			}
 
 			cli, err := influxdbv2.NewHTTPClient(
 				influxdbv2.HTTPConfig{
 					Addr: ds.Address, InsecureSkipVerify: ds.Insecure,
 					Username: ds.Username, Password: ds.Password,
+					TLSConfig: tlsCfg,
 				},
 			)
 			if err != nil {
@@ -141,6 +181,137 @@ func (c *ConfigGatherer) defaults() {
 		}
 	}
 
+	// Set default creator function for Elasticsearch.
+	if c.CreateElasticsearchFunc == nil {
+		c.CreateElasticsearchFunc = func(ds model.ElasticsearchDatasource) (metric.Gatherer, error) {
+			rt, err := newHTTPRoundTripper(ds.HTTPClientConfig)
+			if err != nil {
+				return nil, err
+			}
+
+			g, err := elasticsearch.NewGatherer(elasticsearch.ConfigGatherer{
+				Address:   ds.Address,
+				Index:     ds.Index,
+				TimeField: ds.TimeField,
+				HTTPCli: &http.Client{
+					Transport: rt,
+					Timeout:   defElasticsearchTimeout,
+				},
+			})
+			if err != nil {
+				return nil, err
+			}
+
+			return g, nil
+		}
+	}
+
+	// Set default creator function for InfluxDB 2.
+	if c.CreateInfluxDB2Func == nil {
+		c.CreateInfluxDB2Func = func(ds model.InfluxDB2Datasource) (metric.Gatherer, error) {
+			rt, err := newHTTPRoundTripper(model.HTTPClientConfig{TLS: ds.TLS})
+			if err != nil {
+				return nil, err
+			}
+
+			g, err := influxdb2.NewGatherer(influxdb2.ConfigGatherer{
+				Address: ds.Address,
+				Org:     ds.Org,
+				Bucket:  ds.Bucket,
+				Token:   ds.Token,
+				HTTPCli: &http.Client{
+					Transport: rt,
+					Timeout:   defInfluxDB2Timeout,
+				},
+			})
+			if err != nil {
+				return nil, err
+			}
+
+			return g, nil
+		}
+	}
+
+	// Set default creator function for scrape.
+	if c.CreateScrapeFunc == nil {
+		c.CreateScrapeFunc = func(ds model.ScrapeDatasource) (metric.Gatherer, error) {
+			rt, err := newHTTPRoundTripper(ds.HTTPClientConfig)
+			if err != nil {
+				return nil, err
+			}
+
+			// Durations are validated on the model.
+			var interval, retention time.Duration
+			if ds.Interval != "" {
+				interval, _ = time.ParseDuration(ds.Interval)
+			}
+			if ds.Retention != "" {
+				retention, _ = time.ParseDuration(ds.Retention)
+			}
+
+			g, err := scrape.NewGatherer(c.Context, scrape.ConfigGatherer{
+				Targets:   ds.Targets,
+				Interval:  interval,
+				Retention: retention,
+				HTTPCli:   &http.Client{Transport: rt},
+			})
+			if err != nil {
+				return nil, err
+			}
+
+			return g, nil
+		}
+	}
+
+	// Set default creator function for file.
+	if c.CreateFileFunc == nil {
+		c.CreateFileFunc = func(ds model.FileDatasource) (metric.Gatherer, error) {
+			return file.NewGatherer(file.ConfigGatherer{
+				Directory: ds.Directory,
+			}), nil
+		}
+	}
+
+	// Set default creator function for exec.
+	if c.CreateExecFunc == nil {
+		c.CreateExecFunc = func(ds model.ExecDatasource) (metric.Gatherer, error) {
+			// Durations are validated on the model.
+			var timeout, retention time.Duration
+			if ds.Timeout != "" {
+				timeout, _ = time.ParseDuration(ds.Timeout)
+			}
+			if ds.Retention != "" {
+				retention, _ = time.ParseDuration(ds.Retention)
+			}
+
+			g, err := exec.NewGatherer(exec.ConfigGatherer{
+				Command:        ds.Command,
+				Stdin:          ds.Stdin,
+				Env:            ds.Env,
+				InheritEnv:     ds.InheritEnv,
+				Timeout:        timeout,
+				MaxOutputBytes: ds.MaxOutputBytes,
+				Retention:      retention,
+			})
+			if err != nil {
+				return nil, err
+			}
+
+			return g, nil
+		}
+	}
+
+	// Set default creator function for expression.
+	if c.CreateExpressionFunc == nil {
+		c.CreateExpressionFunc = func(ds model.ExpressionDatasource, dsID string, g metric.Gatherer) (metric.Gatherer, error) {
+			return expression.NewGatherer(expression.ConfigGatherer{
+				ID:       dsID,
+				Queries:  ds.Queries,
+				Gatherer: g,
+			})
+		}
+	}
+
 	if c.Aliases == nil {
 		c.Aliases = map[string]string{}
 	}
@@ -158,10 +329,32 @@ type gatherer struct {
 func NewGatherer(cfg ConfigGatherer) (metric.Gatherer, error) {
 	cfg.defaults()
 
+	// The expression datasources gather the named queries using this gatherer.
+	mg := &gatherer{cfg: cfg}
+
+	// The dashboard datasources replaced by the user datasources.
+	replaced := map[string]bool{}
+	for _, ds := range cfg.UserDatasources {
+		replaced[ds.ID] = true
+	}
+	for id := range cfg.Aliases {
+		replaced[id] = true
+	}
+
 	// Lowest priority (0).
 	gs := map[string]metric.Gatherer{}
 	for _, ds := range cfg.DashboardDatasources {
-		g, err := createGatherer(cfg, ds, ds.ID)
+		// The dashboards can be shared, so running commands from them
+		// is not allowed, exec datasources are only allowed on the user
+		// datasources.
+		if ds.Exec != nil {
+			if !replaced[ds.ID] {
+				return nil, fmt.Errorf("exec datasource %s is only allowed on the user datasources", ds.ID)
+			}
+			continue
+		}
+
+		g, err := createGatherer(cfg, ds, ds.ID, mg)
 		if err != nil {
 			return nil, err
 		}
@@ -171,7 +364,7 @@ func NewGatherer(cfg ConfigGatherer) (metric.Gatherer, error) {
 	// Mid priority (1).
 	ags := map[string]metric.Gatherer{}
 	for _, ds := range cfg.UserDatasources {
-		g, err := createGatherer(cfg, ds, ds.ID)
+		g, err := createGatherer(cfg, ds, ds.ID, mg)
 		if err != nil {
 			return nil, err
 		}
@@ -179,10 +372,10 @@ func NewGatherer(cfg ConfigGatherer) (metric.Gatherer, error) {
 	}
 
 	// Use the IDs from the dashboard to use the user datasources.
-	for id := range gs {
-		g, ok := ags[id]
+	for _, ds := range cfg.DashboardDatasources {
+		g, ok := ags[ds.ID]
 		if ok {
-			gs[id] = g
+			gs[ds.ID] = g
 		}
 	}
 
@@ -196,10 +389,9 @@ func NewGatherer(cfg ConfigGatherer) (metric.Gatherer, error) {
 		gs[id] = ag
 	}
 
-	return &gatherer{
-		cfg:       cfg,
-		gatherers: gs,
-	}, nil
+	mg.gatherers = gs
+
+	return mg, nil
 }
 
 func (g *gatherer) GatherSingle(ctx context.Context, query model.Query, t time.Time) ([]model.MetricSeries, error) {
@@ -218,6 +410,32 @@ func (g *gatherer) GatherRange(ctx context.Context, query model.Query, start, en
 	return dsg.GatherRange(ctx, query, start, end, step)
 }
 
+func (g *gatherer) DiscoverValues(ctx context.Context, query model.Query) ([]string, error) {
+	dsg, err := g.metricGatherer(query.DatasourceID)
+	if err != nil {
+		return nil, err
+	}
+
+	d, ok := dsg.(metric.Discoverer)
+	if !ok {
+		return nil, fmt.Errorf("datasource %s does not support values discovery", query.DatasourceID)
+	}
+	return d.DiscoverValues(ctx, query)
+}
+
+func (g *gatherer) GatherMetadata(ctx context.Context, query model.Query) ([]model.MetricMetadata, error) {
+	dsg, err := g.metricGatherer(query.DatasourceID)
+	if err != nil {
+		return nil, err
+	}
+
+	m, ok := dsg.(metric.MetadataGatherer)
+	if !ok {
+		return nil, fmt.Errorf("datasource %s: %w", query.DatasourceID, metric.ErrMetadataNotSupported)
+	}
+	return m.GatherMetadata(ctx, query)
+}
+
 func (g *gatherer) metricGatherer(id string) (metric.Gatherer, error) {
 	mg, ok := g.gatherers[id]
 	if !ok {
@@ -227,14 +445,44 @@ func (g *gatherer) metricGatherer(id string) (metric.Gatherer, error) {
 	return mg, nil
 }
 
-func createGatherer(cfg ConfigGatherer, ds model.Datasource, dsID string) (metric.Gatherer, error) {
+// createGatherer creates the gatherer of the datasource wrapped with the
+// enhanced features of the datasource.
+func createGatherer(cfg ConfigGatherer, ds model.Datasource, dsID string, mg metric.Gatherer) (metric.Gatherer, error) {
+	g, err := createBackendGatherer(cfg, ds, dsID, mg)
+	if err != nil {
+		return nil, err
+	}
+
+	// The expression named queries already use the enhanced features of
+	// their datasources.
+	if ds.Expression != nil {
+		return g, nil
+	}
+
+	efCfg := cfg.EnhancedFeatures.WithDatasourceOverrides(ds.EnhancedFeatures)
+	return middleware.EnhancedFeatures(efCfg, g), nil
+}
+
+func createBackendGatherer(cfg ConfigGatherer, ds model.Datasource, dsID string, mg metric.Gatherer) (metric.Gatherer, error) {
 	switch {
 	case ds.Prometheus != nil:
-		return cfg.CreatePrometheusFunc(*ds.Prometheus, dsID)
+		return cfg.CreatePrometheusFunc(*ds.Prometheus)
 	case ds.Graphite != nil:
 		return cfg.CreateGraphiteFunc(*ds.Graphite)
 	case ds.InfluxDB != nil:
 		return cfg.CreateInfluxDBFunc(*ds.InfluxDB)
+	case ds.Elasticsearch != nil:
+		return cfg.CreateElasticsearchFunc(*ds.Elasticsearch)
+	case ds.InfluxDB2 != nil:
+		return cfg.CreateInfluxDB2Func(*ds.InfluxDB2)
+	case ds.Scrape != nil:
+		return cfg.CreateScrapeFunc(*ds.Scrape)
+	case ds.File != nil:
+		return cfg.CreateFileFunc(*ds.File)
+	case ds.Exec != nil:
+		return cfg.CreateExecFunc(*ds.Exec)
+	case ds.Expression != nil:
+		return cfg.CreateExpressionFunc(*ds.Expression, dsID, mg)
 	case ds.Fake != nil:
 		return cfg.CreateFakeFunc(*ds.Fake)
 	}
@@ -145,7 +145,7 @@ func TestGathererGatherSingle(t *testing.T) {
 					gCount++
 					return g, nil
 				},
-				CreatePrometheusFunc: func(_ model.PrometheusDatasource, _ string) (metric.Gatherer, error) {
+				CreatePrometheusFunc: func(_ model.PrometheusDatasource) (metric.Gatherer, error) {
 					g := mgs[gCount]
 					gCount++
 					return g, nil
@@ -171,3 +171,76 @@ func TestGathererGatherSingle(t *testing.T) {
 		})
 	}
 }
+
+func TestNewGathererExecDatasources(t *testing.T) {
+	execDS := func(id string) model.Datasource {
+		return model.Datasource{
+			ID:               id,
+			DatasourceSource: model.DatasourceSource{Exec: &model.ExecDatasource{Command: []string{"date"}}},
+		}
+	}
+	fakeDS := func(id string) model.Datasource {
+		return model.Datasource{
+			ID:               id,
+			DatasourceSource: model.DatasourceSource{Fake: &model.FakeDatasource{}},
+		}
+	}
+
+	tests := []struct {
+		name                 string
+		dashboardDatasources []model.Datasource
+		userDatasources      []model.Datasource
+		aliases              map[string]string
+		expExecs             int
+		expErr               bool
+	}{
+		{
+			name:                 "An exec datasource on the dashboard should fail.",
+			dashboardDatasources: []model.Datasource{execDS("ds0")},
+			expErr:               true,
+		},
+		{
+			name:                 "An exec datasource on the dashboard replaced by a user datasource should not fail.",
+			dashboardDatasources: []model.Datasource{execDS("ds0")},
+			userDatasources:      []model.Datasource{fakeDS("ds0")},
+		},
+		{
+			name:                 "An exec datasource on the dashboard replaced by an alias should not fail.",
+			dashboardDatasources: []model.Datasource{execDS("ds0")},
+			userDatasources:      []model.Datasource{fakeDS("ds1")},
+			aliases:              map[string]string{"ds0": "ds1"},
+		},
+		{
+			name:            "An exec datasource on the user datasources should not fail.",
+			userDatasources: []model.Datasource{execDS("ds0")},
+			expExecs:        1,
+		},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			assert := assert.New(t)
+
+			execs := 0
+			_, err := datasource.NewGatherer(datasource.ConfigGatherer{
+				DashboardDatasources: test.dashboardDatasources,
+				UserDatasources:      test.userDatasources,
+				Aliases:              test.aliases,
+				CreateFakeFunc: func(_ model.FakeDatasource) (metric.Gatherer, error) {
+					return &mmetric.Gatherer{}, nil
+				},
+				CreateExecFunc: func(_ model.ExecDatasource) (metric.Gatherer, error) {
+					execs++
+					return &mmetric.Gatherer{}, nil
+				},
+			})
+
+			if test.expErr {
+				assert.Error(err)
+			} else {
+				assert.NoError(err)
+			}
+			assert.Equal(test.expExecs, execs)
+		})
+	}
+}
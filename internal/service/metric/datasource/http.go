@@ -0,0 +1,110 @@
+package datasource
+
+import (
+	"crypto/tls"
+	"crypto/x509"
+	"fmt"
+	"io/ioutil"
+	"net"
+	"net/http"
+	"strings"
+	"time"
+
+	"github.com/slok/grafterm/internal/model"
+)
+
+// newHTTPRoundTripper returns a round tripper that uses the TLS configuration
+// for the connections and sets the authentication and the headers of the
+// configuration on every request.
+func newHTTPRoundTripper(cfg model.HTTPClientConfig) (http.RoundTripper, error) {
+	tlsCfg, err := newTLSConfig(cfg.TLS)
+	if err != nil {
+		return nil, err
+	}
+
+	var rt http.RoundTripper = &http.Transport{
+		Proxy: http.ProxyFromEnvironment,
+		DialContext: (&net.Dialer{
+			Timeout:   30 * time.Second,
+			KeepAlive: 30 * time.Second,
+		}).DialContext,
+		TLSHandshakeTimeout: 10 * time.Second,
+		TLSClientConfig:     tlsCfg,
+	}
+
+	if cfg.BasicAuth == nil && cfg.BearerToken == "" && cfg.BearerTokenFile == "" && len(cfg.Headers) == 0 {
+		return rt, nil
+	}
+
+	return &authRoundTripper{
+		cfg:  cfg,
+		next: rt,
+	}, nil
+}
+
+// newTLSConfig returns the TLS configuration loading the CA bundle and
+// the client certificates, if the configuration is nil it returns nil
+// so the default TLS configuration is used.
+func newTLSConfig(cfg *model.TLSConfig) (*tls.Config, error) {
+	if cfg == nil {
+		return nil, nil
+	}
+
+	tlsCfg := &tls.Config{
+		InsecureSkipVerify: cfg.InsecureSkipVerify,
+	}
+
+	if cfg.CAFile != "" {
+		ca, err := ioutil.ReadFile(cfg.CAFile)
+		if err != nil {
+			return nil, fmt.Errorf("error reading CA file: %s", err)
+		}
+		pool := x509.NewCertPool()
+		if !pool.AppendCertsFromPEM(ca) {
+			return nil, fmt.Errorf("%s CA file doesn't have valid PEM certificates", cfg.CAFile)
+		}
+		tlsCfg.RootCAs = pool
+	}
+
+	if cfg.CertFile != "" {
+		cert, err := tls.LoadX509KeyPair(cfg.CertFile, cfg.KeyFile)
+		if err != nil {
+			return nil, fmt.Errorf("error loading client certificate: %s", err)
+		}
+		tlsCfg.Certificates = []tls.Certificate{cert}
+	}
+
+	return tlsCfg, nil
+}
+
+// authRoundTripper sets the headers and the authentication on the
+// requests before delegating to the next round tripper.
+type authRoundTripper struct {
+	cfg  model.HTTPClientConfig
+	next http.RoundTripper
+}
+
+func (a *authRoundTripper) RoundTrip(req *http.Request) (*http.Response, error) {
+	// Round trippers should not modify the original request.
+	req = req.Clone(req.Context())
+
+	for k, v := range a.cfg.Headers {
+		req.Header.Set(k, v)
+	}
+
+	switch {
+	case a.cfg.BasicAuth != nil:
+		req.SetBasicAuth(a.cfg.BasicAuth.Username, a.cfg.BasicAuth.Password)
+	case a.cfg.BearerToken != "":
+		req.Header.Set("Authorization", "Bearer "+a.cfg.BearerToken)
+	case a.cfg.BearerTokenFile != "":
+		// Read the file every time so the rotated tokens are used.
+		token, err := ioutil.ReadFile(a.cfg.BearerTokenFile)
+		if err != nil {
+			return nil, fmt.Errorf("error reading bearer token file: %s", err)
+		}
+		req.Header.Set("Authorization", "Bearer "+strings.TrimSpace(string(token)))
+	}
+
+	return a.next.RoundTrip(req)
+}
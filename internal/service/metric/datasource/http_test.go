@@ -0,0 +1,281 @@
+package datasource_test
+
+import (
+	"context"
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/tls"
+	"crypto/x509"
+	"crypto/x509/pkix"
+	"encoding/pem"
+	"io/ioutil"
+	"math/big"
+	"net/http"
+	"net/http/httptest"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/slok/grafterm/internal/model"
+	"github.com/slok/grafterm/internal/service/metric"
+	"github.com/slok/grafterm/internal/service/metric/datasource"
+)
+
+const (
+	promEmptyResponse     = `{"status":"success","data":{"resultType":"vector","result":[]}}`
+	graphiteEmptyResponse = `[]`
+)
+
+// newTestGatherer returns a gatherer with a single datasource using the
+// default gatherer creation funcs.
+func newTestGatherer(t *testing.T, ds model.Datasource) metric.Gatherer {
+	legacyCfg := metric.LegacyConfig()
+	g, err := datasource.NewGatherer(datasource.ConfigGatherer{
+		DashboardDatasources: []model.Datasource{ds},
+		EnhancedFeatures:     &legacyCfg,
+	})
+	require.NoError(t, err)
+	return g
+}
+
+// writeFile writes a file on a temporary directory and returns the path.
+func writeFile(t *testing.T, name string, data []byte) string {
+	path := filepath.Join(t.TempDir(), name)
+	require.NoError(t, ioutil.WriteFile(path, data, 0600))
+	return path
+}
+
+func TestGathererHTTPClientConfig(t *testing.T) {
+	tokenFile := writeFile(t, "token", []byte("file-token\n"))
+
+	tests := []struct {
+		name       string
+		httpCfg    model.HTTPClientConfig
+		expHeaders map[string]string
+	}{
+		{
+			name:       "Without auth the requests should not have authorization.",
+			httpCfg:    model.HTTPClientConfig{},
+			expHeaders: map[string]string{"Authorization": ""},
+		},
+		{
+			name: "Basic auth should set the basic authorization.",
+			httpCfg: model.HTTPClientConfig{
+				BasicAuth: &model.BasicAuth{Username: "user", Password: "pass"},
+			},
+			expHeaders: map[string]string{"Authorization": "Basic dXNlcjpwYXNz"},
+		},
+		{
+			name: "Bearer token should set the bearer authorization.",
+			httpCfg: model.HTTPClientConfig{
+				BearerToken: "inline-token",
+			},
+			expHeaders: map[string]string{"Authorization": "Bearer inline-token"},
+		},
+		{
+			name: "Bearer token file should set the bearer authorization with the token of the file.",
+			httpCfg: model.HTTPClientConfig{
+				BearerTokenFile: tokenFile,
+			},
+			expHeaders: map[string]string{"Authorization": "Bearer file-token"},
+		},
+		{
+			name: "Custom headers should be set on the requests.",
+			httpCfg: model.HTTPClientConfig{
+				BearerToken: "inline-token",
+				Headers: map[string]string{
+					"X-Scope-OrgID": "team-a",
+					"X-Custom":      "custom",
+				},
+			},
+			expHeaders: map[string]string{
+				"Authorization": "Bearer inline-token",
+				"X-Scope-OrgID": "team-a",
+				"X-Custom":      "custom",
+			},
+		},
+	}
+
+	for _, test := range tests {
+		test := test
+		dss := map[string]func(addr string) model.Datasource{
+			"prometheus": func(addr string) model.Datasource {
+				return model.Datasource{ID: "test", DatasourceSource: model.DatasourceSource{
+					Prometheus: &model.PrometheusDatasource{Address: addr, HTTPClientConfig: test.httpCfg},
+				}}
+			},
+			"graphite": func(addr string) model.Datasource {
+				return model.Datasource{ID: "test", DatasourceSource: model.DatasourceSource{
+					Graphite: &model.GraphiteDatasource{Address: addr, HTTPClientConfig: test.httpCfg},
+				}}
+			},
+		}
+
+		for dsType, ds := range dss {
+			dsType, ds := dsType, ds
+			t.Run(dsType+": "+test.name, func(t *testing.T) {
+				assert := assert.New(t)
+
+				var gotHeaders http.Header
+				srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+					gotHeaders = r.Header
+					w.Header().Set("Content-Type", "application/json")
+					if dsType == "prometheus" {
+						w.Write([]byte(promEmptyResponse))
+						return
+					}
+					w.Write([]byte(graphiteEmptyResponse))
+				}))
+				defer srv.Close()
+
+				g := newTestGatherer(t, ds(srv.URL))
+				_, err := g.GatherRange(context.TODO(), model.Query{DatasourceID: "test", Expr: "test"}, time.Now().Add(-time.Hour), time.Now(), time.Minute)
+				if assert.NoError(err) {
+					for k, v := range test.expHeaders {
+						assert.Equal(v, gotHeaders.Get(k), "header %s", k)
+					}
+				}
+			})
+		}
+	}
+}
+
+// newTestCert returns a self signed certificate and its key PEM encoded.
+func newTestCert(t *testing.T) (certPEM, keyPEM []byte) {
+	key, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	require.NoError(t, err)
+
+	tpl := &x509.Certificate{
+		SerialNumber: big.NewInt(1),
+		Subject:      pkix.Name{CommonName: "grafterm"},
+		NotBefore:    time.Now().Add(-time.Hour),
+		NotAfter:     time.Now().Add(time.Hour),
+		KeyUsage:     x509.KeyUsageDigitalSignature,
+		ExtKeyUsage:  []x509.ExtKeyUsage{x509.ExtKeyUsageClientAuth},
+	}
+	der, err := x509.CreateCertificate(rand.Reader, tpl, tpl, &key.PublicKey, key)
+	require.NoError(t, err)
+	keyDER, err := x509.MarshalECPrivateKey(key)
+	require.NoError(t, err)
+
+	certPEM = pem.EncodeToMemory(&pem.Block{Type: "CERTIFICATE", Bytes: der})
+	keyPEM = pem.EncodeToMemory(&pem.Block{Type: "EC PRIVATE KEY", Bytes: keyDER})
+	return certPEM, keyPEM
+}
+
+func TestGathererTLSConfig(t *testing.T) {
+	clientCert, clientKey := newTestCert(t)
+	clientCertFile := writeFile(t, "cert.pem", clientCert)
+	clientKeyFile := writeFile(t, "key.pem", clientKey)
+
+	tests := []struct {
+		name              string
+		requireClientCert bool
+		tlsCfg            func(caFile string) *model.TLSConfig
+		expErr            bool
+	}{
+		{
+			name:   "Without TLS config the server certificate should not be trusted.",
+			tlsCfg: func(_ string) *model.TLSConfig { return nil },
+			expErr: true,
+		},
+		{
+			name: "With the CA of the server certificate the server should be trusted.",
+			tlsCfg: func(caFile string) *model.TLSConfig {
+				return &model.TLSConfig{CAFile: caFile}
+			},
+		},
+		{
+			name: "With insecure skip verify the server certificate should not be verified.",
+			tlsCfg: func(_ string) *model.TLSConfig {
+				return &model.TLSConfig{InsecureSkipVerify: true}
+			},
+		},
+		{
+			name:              "Without client certificate the server requiring client certificates should fail.",
+			requireClientCert: true,
+			tlsCfg: func(caFile string) *model.TLSConfig {
+				return &model.TLSConfig{CAFile: caFile}
+			},
+			expErr: true,
+		},
+		{
+			name:              "With client certificate the server requiring client certificates should not fail.",
+			requireClientCert: true,
+			tlsCfg: func(caFile string) *model.TLSConfig {
+				return &model.TLSConfig{CAFile: caFile, CertFile: clientCertFile, KeyFile: clientKeyFile}
+			},
+		},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			assert := assert.New(t)
+
+			srv := httptest.NewUnstartedServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+				w.Header().Set("Content-Type", "application/json")
+				w.Write([]byte(promEmptyResponse))
+			}))
+			if test.requireClientCert {
+				srv.TLS = &tls.Config{ClientAuth: tls.RequireAnyClientCert}
+			}
+			srv.StartTLS()
+			defer srv.Close()
+
+			caFile := writeFile(t, "ca.pem", pem.EncodeToMemory(&pem.Block{Type: "CERTIFICATE", Bytes: srv.Certificate().Raw}))
+			ds := model.Datasource{ID: "test", DatasourceSource: model.DatasourceSource{
+				Prometheus: &model.PrometheusDatasource{
+					Address:          srv.URL,
+					HTTPClientConfig: model.HTTPClientConfig{TLS: test.tlsCfg(caFile)},
+				},
+			}}
+
+			g := newTestGatherer(t, ds)
+			_, err := g.GatherSingle(context.TODO(), model.Query{DatasourceID: "test", Expr: "test"}, time.Now())
+			if test.expErr {
+				assert.Error(err)
+			} else {
+				assert.NoError(err)
+			}
+		})
+	}
+}
+
+func TestNewGathererInvalidTLSFiles(t *testing.T) {
+	invalidCA := writeFile(t, "ca.pem", []byte("not a certificate"))
+
+	tests := map[string]model.DatasourceSource{
+		"Prometheus with an invalid CA file should fail.": {
+			Prometheus: &model.PrometheusDatasource{
+				Address:          "https://127.0.0.1:9090",
+				HTTPClientConfig: model.HTTPClientConfig{TLS: &model.TLSConfig{CAFile: invalidCA}},
+			},
+		},
+		"Graphite with a missing client certificate should fail.": {
+			Graphite: &model.GraphiteDatasource{
+				Address:          "https://127.0.0.1:8080",
+				HTTPClientConfig: model.HTTPClientConfig{TLS: &model.TLSConfig{CertFile: "/does/not/exist", KeyFile: "/does/not/exist"}},
+			},
+		},
+		"InfluxDB with a missing CA file should fail.": {
+			InfluxDB: &model.InfluxDBDatasource{
+				Address: "https://127.0.0.1:8086",
+				TLS:     &model.TLSConfig{CAFile: "/does/not/exist"},
+			},
+		},
+	}
+
+	for name, dss := range tests {
+		dss := dss
+		t.Run(name, func(t *testing.T) {
+			_, err := datasource.NewGatherer(datasource.ConfigGatherer{
+				DashboardDatasources: []model.Datasource{{ID: "test", DatasourceSource: dss}},
+			})
+			assert.Error(t, err)
+		})
+	}
+}
@@ -0,0 +1,574 @@
+package elasticsearch
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"io/ioutil"
+	"net/http"
+	"net/url"
+	"path"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/slok/grafterm/internal/model"
+	"github.com/slok/grafterm/internal/service/metric"
+)
+
+const (
+	defTimeField     = "@timestamp"
+	defTermsSize     = 10
+	defRangeSteps    = 100
+	defDiscoverySize = 100
+	instantRange     = 5 * time.Minute
+
+	histogramAggName = "histogram"
+	termsAggName     = "split"
+	metricAggName    = "metric"
+	percentileLabel  = "percentile"
+)
+
+// Metric aggregation types.
+const (
+	metricCount       = "count"
+	metricAvg         = "avg"
+	metricSum         = "sum"
+	metricMin         = "min"
+	metricMax         = "max"
+	metricPercentiles = "percentiles"
+)
+
+// ConfigGatherer is the configuration of the Elasticsearch gatherer.
+type ConfigGatherer struct {
+	// Address is the address of the Elasticsearch or OpenSearch API.
+	Address string
+	// Index is the index or index pattern where the queries are made.
+	Index string
+	// TimeField is the field of the documents timestamp.
+	TimeField string
+	HTTPCli   *http.Client
+}
+
+func (c *ConfigGatherer) defaults() error {
+	if c.Index == "" {
+		return fmt.Errorf("no elasticsearch index given")
+	}
+
+	if c.TimeField == "" {
+		c.TimeField = defTimeField
+	}
+
+	if c.HTTPCli == nil {
+		c.HTTPCli = http.DefaultClient
+	}
+
+	return nil
+}
+
+type gatherer struct {
+	cfg       ConfigGatherer
+	searchURL string
+}
+
+// NewGatherer returns a new metric gatherer for Elasticsearch and OpenSearch
+// backends.
+//
+// The query expression can be a Lucene query string (e.g `status:500`) that
+// will count the matched documents, or a JSON object with the query, the
+// metric aggregation and the optional terms split:
+//
+//	{
+//	  "query": "service:api",
+//	  "metric": "percentiles",
+//	  "field": "latency",
+//	  "percents": [50, 99],
+//	  "terms": {"field": "host", "size": 5}
+//	}
+//
+// The query of the JSON object can be a Lucene query string or an
+// Elasticsearch query DSL object.
+func NewGatherer(cfg ConfigGatherer) (metric.Gatherer, error) {
+	err := cfg.defaults()
+	if err != nil {
+		return nil, err
+	}
+
+	u, err := url.Parse(cfg.Address)
+	if err != nil {
+		return nil, err
+	}
+	u.Path = path.Join(u.Path, cfg.Index, "_search")
+
+	return &gatherer{
+		cfg:       cfg,
+		searchURL: u.String(),
+	}, nil
+}
+
+// expression is the JSON query expression.
+type expression struct {
+	Query    json.RawMessage `json:"query,omitempty"`
+	Metric   string          `json:"metric,omitempty"`
+	Field    string          `json:"field,omitempty"`
+	Percents []float64       `json:"percents,omitempty"`
+	Terms    *terms          `json:"terms,omitempty"`
+}
+
+type terms struct {
+	Field string `json:"field"`
+	Size  int    `json:"size,omitempty"`
+}
+
+func parseExpression(expr string) (*expression, error) {
+	expr = strings.TrimSpace(expr)
+
+	// Lucene query string.
+	if !strings.HasPrefix(expr, "{") {
+		q, _ := json.Marshal(expr)
+		return &expression{
+			Query:  q,
+			Metric: metricCount,
+		}, nil
+	}
+
+	e := &expression{}
+	err := json.Unmarshal([]byte(expr), e)
+	if err != nil {
+		return nil, fmt.Errorf("invalid elasticsearch query expression: %s", err)
+	}
+
+	if e.Metric == "" {
+		e.Metric = metricCount
+	}
+	switch e.Metric {
+	case metricCount:
+	case metricAvg, metricSum, metricMin, metricMax, metricPercentiles:
+		if e.Field == "" {
+			return nil, fmt.Errorf("%s metric requires a field", e.Metric)
+		}
+	default:
+		return nil, fmt.Errorf("%s metric is not supported", e.Metric)
+	}
+
+	if e.Terms != nil {
+		if e.Terms.Field == "" {
+			return nil, fmt.Errorf("terms split requires a field")
+		}
+		if e.Terms.Size <= 0 {
+			e.Terms.Size = defTermsSize
+		}
+	}
+
+	return e, nil
+}
+
+func (g *gatherer) GatherSingle(ctx context.Context, query model.Query, t time.Time) ([]model.MetricSeries, error) {
+	e, err := parseExpression(query.Expr)
+	if err != nil {
+		return nil, err
+	}
+
+	// Without step the metric is aggregated for the whole range.
+	resp, err := g.search(ctx, g.searchBody(e, t.Add(-1*instantRange), t, 0))
+	if err != nil {
+		return nil, err
+	}
+
+	return g.toModel(e, resp, t)
+}
+
+func (g *gatherer) GatherRange(ctx context.Context, query model.Query, start, end time.Time, step time.Duration) ([]model.MetricSeries, error) {
+	e, err := parseExpression(query.Expr)
+	if err != nil {
+		return nil, err
+	}
+
+	if step <= 0 {
+		step = end.Sub(start) / defRangeSteps
+	}
+	if step < time.Millisecond {
+		step = time.Millisecond
+	}
+
+	resp, err := g.search(ctx, g.searchBody(e, start, end, step))
+	if err != nil {
+		return nil, err
+	}
+
+	return g.toModel(e, resp, end)
+}
+
+// DiscoverValues satisfies metric.Discoverer interface. The query is the
+// field name (e.g `host.keyword`) and the values are the most common values
+// of the field.
+func (g *gatherer) DiscoverValues(ctx context.Context, query model.Query) ([]string, error) {
+	body := map[string]interface{}{
+		"size": 0,
+		"aggs": map[string]interface{}{
+			termsAggName: map[string]interface{}{
+				"terms": map[string]interface{}{
+					"field": strings.TrimSpace(query.Expr),
+					"size":  defDiscoverySize,
+				},
+			},
+		},
+	}
+
+	resp, err := g.search(ctx, body)
+	if err != nil {
+		return nil, err
+	}
+
+	res := []string{}
+	if resp.Aggregations.Split == nil {
+		return res, nil
+	}
+	for _, b := range resp.Aggregations.Split.Buckets {
+		res = append(res, b.key())
+	}
+
+	return res, nil
+}
+
+// searchBody returns the search request body, if the step is 0 the metric
+// will not be aggregated using a date histogram.
+func (g *gatherer) searchBody(e *expression, start, end time.Time, step time.Duration) map[string]interface{} {
+	startMS, endMS := toMillis(start), toMillis(end)
+
+	filters := []interface{}{
+		map[string]interface{}{
+			"range": map[string]interface{}{
+				g.cfg.TimeField: map[string]interface{}{
+					"gte":    startMS,
+					"lte":    endMS,
+					"format": "epoch_millis",
+				},
+			},
+		},
+	}
+	if q := e.query(); q != nil {
+		filters = append(filters, q)
+	}
+
+	// Metric aggregation, the count uses the doc count of the buckets and
+	// without buckets counts the documents with timestamp.
+	aggs := map[string]interface{}{}
+	switch e.Metric {
+	case metricCount:
+		if step <= 0 && e.Terms == nil {
+			aggs[metricAggName] = map[string]interface{}{"value_count": map[string]interface{}{"field": g.cfg.TimeField}}
+		}
+	case metricPercentiles:
+		p := map[string]interface{}{"field": e.Field}
+		if len(e.Percents) > 0 {
+			p["percents"] = e.Percents
+		}
+		aggs[metricAggName] = map[string]interface{}{metricPercentiles: p}
+	default:
+		aggs[metricAggName] = map[string]interface{}{e.Metric: map[string]interface{}{"field": e.Field}}
+	}
+
+	if step > 0 {
+		aggs = map[string]interface{}{
+			histogramAggName: map[string]interface{}{
+				"date_histogram": map[string]interface{}{
+					"field":          g.cfg.TimeField,
+					"fixed_interval": fmt.Sprintf("%dms", step.Milliseconds()),
+					"min_doc_count":  0,
+					"extended_bounds": map[string]interface{}{
+						"min": startMS,
+						"max": endMS,
+					},
+				},
+				"aggs": aggs,
+			},
+		}
+	}
+
+	if e.Terms != nil {
+		aggs = map[string]interface{}{
+			termsAggName: map[string]interface{}{
+				"terms": map[string]interface{}{
+					"field": e.Terms.Field,
+					"size":  e.Terms.Size,
+				},
+				"aggs": aggs,
+			},
+		}
+	}
+
+	return map[string]interface{}{
+		"size": 0,
+		"query": map[string]interface{}{
+			"bool": map[string]interface{}{
+				"filter": filters,
+			},
+		},
+		"aggs": aggs,
+	}
+}
+
+// query returns the query of the expression as an Elasticsearch query,
+// the query strings are Lucene queries.
+func (e *expression) query() interface{} {
+	if len(e.Query) == 0 {
+		return nil
+	}
+
+	var qs string
+	if err := json.Unmarshal(e.Query, &qs); err == nil {
+		if strings.TrimSpace(qs) == "" {
+			return nil
+		}
+		return map[string]interface{}{
+			"query_string": map[string]interface{}{
+				"query": qs,
+			},
+		}
+	}
+
+	return e.Query
+}
+
+// search response.
+type searchResponse struct {
+	Aggregations aggregations `json:"aggregations"`
+}
+
+type aggregations struct {
+	Histogram *histogramAgg `json:"histogram"`
+	Split     *termsAgg     `json:"split"`
+	Metric    *metricAgg    `json:"metric"`
+}
+
+type termsAgg struct {
+	Buckets []termsBucket `json:"buckets"`
+}
+
+type termsBucket struct {
+	Key         interface{}   `json:"key"`
+	KeyAsString string        `json:"key_as_string"`
+	DocCount    float64       `json:"doc_count"`
+	Histogram   *histogramAgg `json:"histogram"`
+	Metric      *metricAgg    `json:"metric"`
+}
+
+func (t termsBucket) key() string {
+	if t.KeyAsString != "" {
+		return t.KeyAsString
+	}
+	return fmt.Sprintf("%v", t.Key)
+}
+
+type histogramAgg struct {
+	Buckets []histogramBucket `json:"buckets"`
+}
+
+type histogramBucket struct {
+	Key      int64      `json:"key"`
+	DocCount float64    `json:"doc_count"`
+	Metric   *metricAgg `json:"metric"`
+}
+
+type metricAgg struct {
+	Value  *float64            `json:"value"`
+	Values map[string]*float64 `json:"values"`
+}
+
+type errorResponse struct {
+	Error struct {
+		Type   string `json:"type"`
+		Reason string `json:"reason"`
+	} `json:"error"`
+}
+
+func (g *gatherer) search(ctx context.Context, body map[string]interface{}) (*searchResponse, error) {
+	bs, err := json.Marshal(body)
+	if err != nil {
+		return nil, err
+	}
+
+	req, err := http.NewRequest(http.MethodPost, g.searchURL, bytes.NewReader(bs))
+	if err != nil {
+		return nil, err
+	}
+	req = req.WithContext(ctx)
+	req.Header.Set("Content-Type", "application/json")
+
+	resp, err := g.cfg.HTTPCli.Do(req)
+	if err != nil {
+		return nil, err
+	}
+	defer resp.Body.Close()
+
+	rbs, err := ioutil.ReadAll(resp.Body)
+	if err != nil {
+		return nil, err
+	}
+
+	if resp.StatusCode != http.StatusOK {
+		er := &errorResponse{}
+		if err := json.Unmarshal(rbs, er); err == nil && er.Error.Reason != "" {
+			return nil, metric.NewHTTPError(resp, fmt.Errorf("elasticsearch error (%d): %s: %s", resp.StatusCode, er.Error.Type, er.Error.Reason))
+		}
+		return nil, metric.NewHTTPError(resp, fmt.Errorf("elasticsearch error (%d): %s", resp.StatusCode, string(rbs)))
+	}
+
+	sr := &searchResponse{}
+	err = json.Unmarshal(rbs, sr)
+	if err != nil {
+		return nil, fmt.Errorf("invalid elasticsearch response: %s", err)
+	}
+
+	return sr, nil
+}
+
+// toModel transforms the aggregations of the response to the domain series,
+// the metrics without histogram will use the ts timestamp.
+func (g *gatherer) toModel(e *expression, resp *searchResponse, ts time.Time) ([]model.MetricSeries, error) {
+	aggs := resp.Aggregations
+	sb := newSeriesBuilder(e)
+
+	if e.Terms == nil {
+		sb.add(map[string]string{}, aggs.Histogram, aggs.Metric, nil, ts)
+		return sb.series(), nil
+	}
+
+	if aggs.Split == nil {
+		return nil, fmt.Errorf("elasticsearch response doesn't have the terms aggregation")
+	}
+	for _, b := range aggs.Split.Buckets {
+		docCount := b.DocCount
+		sb.add(map[string]string{e.Terms.Field: b.key()}, b.Histogram, b.Metric, &docCount, ts)
+	}
+
+	return sb.series(), nil
+}
+
+// seriesBuilder groups the metrics of the aggregations by series.
+type seriesBuilder struct {
+	expr  *expression
+	order []string
+	byID  map[string]*model.MetricSeries
+}
+
+func newSeriesBuilder(e *expression) *seriesBuilder {
+	return &seriesBuilder{
+		expr: e,
+		byID: map[string]*model.MetricSeries{},
+	}
+}
+
+// add adds the metrics of a histogram, or if there is no histogram the
+// single metric aggregation at ts timestamp.
+func (s *seriesBuilder) add(labels map[string]string, h *histogramAgg, m *metricAgg, docCount *float64, ts time.Time) {
+	if h == nil {
+		s.addMetric(labels, m, docCount, ts)
+		return
+	}
+
+	for _, b := range h.Buckets {
+		docCount := b.DocCount
+		s.addMetric(labels, b.Metric, &docCount, time.Unix(0, b.Key*int64(time.Millisecond)))
+	}
+}
+
+func (s *seriesBuilder) addMetric(labels map[string]string, m *metricAgg, docCount *float64, ts time.Time) {
+	switch {
+	case s.expr.Metric == metricCount && docCount != nil:
+		s.append(labels, *docCount, ts)
+	case m == nil:
+	case s.expr.Metric == metricPercentiles:
+		// Percentiles come from a map, sort them so the order is stable.
+		ps := []string{}
+		for p := range m.Values {
+			ps = append(ps, p)
+		}
+		sort.Slice(ps, func(i, j int) bool {
+			pi, _ := strconv.ParseFloat(ps[i], 64)
+			pj, _ := strconv.ParseFloat(ps[j], 64)
+			return pi < pj
+		})
+
+		for _, p := range ps {
+			v := m.Values[p]
+			if v == nil {
+				continue
+			}
+			pl := map[string]string{percentileLabel: formatPercent(p)}
+			for k, v := range labels {
+				pl[k] = v
+			}
+			s.append(pl, *v, ts)
+		}
+	case m.Value != nil:
+		s.append(labels, *m.Value, ts)
+	}
+}
+
+func (s *seriesBuilder) append(labels map[string]string, value float64, ts time.Time) {
+	id := seriesID(s.expr, labels)
+	ms, ok := s.byID[id]
+	if !ok {
+		ms = &model.MetricSeries{ID: id, Labels: labels}
+		s.byID[id] = ms
+		s.order = append(s.order, id)
+	}
+	ms.Metrics = append(ms.Metrics, model.Metric{TS: ts, Value: value})
+}
+
+func (s *seriesBuilder) series() []model.MetricSeries {
+	res := []model.MetricSeries{}
+	for _, id := range s.order {
+		ms := s.byID[id]
+		// Sort the metrics by time.
+		sort.SliceStable(ms.Metrics, func(i, j int) bool {
+			return ms.Metrics[i].TS.Before(ms.Metrics[j].TS)
+		})
+		res = append(res, *ms)
+	}
+
+	return res
+}
+
+// seriesID returns the ID of the series using the metric and the labels
+// in the form of `avg(latency){host="a"}`.
+func seriesID(e *expression, labels map[string]string) string {
+	id := e.Metric
+	if e.Field != "" && e.Metric != metricCount {
+		id = fmt.Sprintf("%s(%s)", e.Metric, e.Field)
+	}
+
+	if len(labels) == 0 {
+		return id
+	}
+
+	keys := []string{}
+	for k := range labels {
+		keys = append(keys, k)
+	}
+	sort.Strings(keys)
+
+	ls := []string{}
+	for _, k := range keys {
+		ls = append(ls, fmt.Sprintf("%s=%q", k, labels[k]))
+	}
+
+	return fmt.Sprintf("%s{%s}", id, strings.Join(ls, ","))
+}
+
+// formatPercent formats the percentile keys of the response (e.g `99.0` to `99`).
+func formatPercent(p string) string {
+	f, err := strconv.ParseFloat(p, 64)
+	if err != nil {
+		return p
+	}
+	return strconv.FormatFloat(f, 'f', -1, 64)
+}
+
+func toMillis(t time.Time) int64 {
+	return t.UnixNano() / int64(time.Millisecond)
+}
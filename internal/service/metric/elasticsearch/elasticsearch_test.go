@@ -0,0 +1,295 @@
+package elasticsearch_test
+
+import (
+	"context"
+	"encoding/json"
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/slok/grafterm/internal/model"
+	"github.com/slok/grafterm/internal/service/metric"
+	"github.com/slok/grafterm/internal/service/metric/elasticsearch"
+)
+
+var (
+	start = time.Unix(1558275600, 0)
+	end   = start.Add(2 * time.Minute)
+)
+
+// ms returns the epoch milliseconds of the minute from the start.
+func ms(minute int) time.Time {
+	return start.Add(time.Duration(minute) * time.Minute)
+}
+
+// testServer returns a server that responds with the response and stores
+// the requested path and body.
+func testServer(t *testing.T, status int, response string, gotPath *string, gotBody *map[string]interface{}) *httptest.Server {
+	return httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		bs, err := ioutil.ReadAll(r.Body)
+		require.NoError(t, err)
+		*gotPath = r.URL.Path
+		*gotBody = map[string]interface{}{}
+		require.NoError(t, json.Unmarshal(bs, gotBody))
+
+		w.Header().Set("Content-Type", "application/json")
+		w.WriteHeader(status)
+		w.Write([]byte(response))
+	}))
+}
+
+// path returns the value of the path on the decoded JSON object.
+func path(obj map[string]interface{}, keys ...string) interface{} {
+	var v interface{} = obj
+	for _, k := range keys {
+		m, ok := v.(map[string]interface{})
+		if !ok {
+			return nil
+		}
+		v = m[k]
+	}
+	return v
+}
+
+func newGatherer(t *testing.T, addr string) metric.Gatherer {
+	g, err := elasticsearch.NewGatherer(elasticsearch.ConfigGatherer{
+		Address: addr,
+		Index:   "logs-*",
+	})
+	require.NoError(t, err)
+	return g
+}
+
+func TestGathererGatherRange(t *testing.T) {
+	tests := map[string]struct {
+		expr            string
+		step            time.Duration
+		status          int
+		response        string
+		expRequest      func(t *testing.T, body map[string]interface{})
+		expMetricSeries []model.MetricSeries
+		expErr          bool
+	}{
+		"A Lucene query should count the documents using a date histogram with the step.": {
+			expr:   "status:500",
+			step:   time.Minute,
+			status: http.StatusOK,
+			response: `{"aggregations": {"histogram": {"buckets": [
+				{"key": 1558275600000, "doc_count": 5},
+				{"key": 1558275660000, "doc_count": 0},
+				{"key": 1558275720000, "doc_count": 7}
+			]}}}`,
+			expRequest: func(t *testing.T, body map[string]interface{}) {
+				assert := assert.New(t)
+				h := path(body, "aggs", "histogram", "date_histogram")
+				assert.Equal("@timestamp", path(h.(map[string]interface{}), "field"))
+				assert.Equal("60000ms", path(h.(map[string]interface{}), "fixed_interval"))
+				filters := path(body, "query", "bool", "filter").([]interface{})
+				if assert.Len(filters, 2) {
+					assert.Equal(float64(1558275600000), path(filters[0].(map[string]interface{}), "range", "@timestamp", "gte"))
+					assert.Equal(float64(1558275720000), path(filters[0].(map[string]interface{}), "range", "@timestamp", "lte"))
+					assert.Equal("status:500", path(filters[1].(map[string]interface{}), "query_string", "query"))
+				}
+			},
+			expMetricSeries: []model.MetricSeries{
+				{
+					ID:     "count",
+					Labels: map[string]string{},
+					Metrics: []model.Metric{
+						{TS: ms(0), Value: 5},
+						{TS: ms(1), Value: 0},
+						{TS: ms(2), Value: 7},
+					},
+				},
+			},
+		},
+		"A metric aggregation with terms split should return a series per bucket key.": {
+			expr:   `{"query": {"term": {"service": "api"}}, "metric": "avg", "field": "latency", "terms": {"field": "host", "size": 2}}`,
+			step:   time.Minute,
+			status: http.StatusOK,
+			response: `{"aggregations": {"split": {"buckets": [
+				{"key": "host-a", "doc_count": 10, "histogram": {"buckets": [
+					{"key": 1558275600000, "doc_count": 4, "metric": {"value": 12.5}},
+					{"key": 1558275660000, "doc_count": 0, "metric": {"value": null}}
+				]}},
+				{"key": "host-b", "doc_count": 3, "histogram": {"buckets": [
+					{"key": 1558275660000, "doc_count": 3, "metric": {"value": 30}}
+				]}}
+			]}}}`,
+			expRequest: func(t *testing.T, body map[string]interface{}) {
+				assert := assert.New(t)
+				assert.Equal("host", path(body, "aggs", "split", "terms", "field"))
+				assert.Equal(float64(2), path(body, "aggs", "split", "terms", "size"))
+				assert.Equal("latency", path(body, "aggs", "split", "aggs", "histogram", "aggs", "metric", "avg", "field"))
+				filters := path(body, "query", "bool", "filter").([]interface{})
+				if assert.Len(filters, 2) {
+					assert.Equal("api", path(filters[1].(map[string]interface{}), "term", "service"))
+				}
+			},
+			expMetricSeries: []model.MetricSeries{
+				{
+					ID:      `avg(latency){host="host-a"}`,
+					Labels:  map[string]string{"host": "host-a"},
+					Metrics: []model.Metric{{TS: ms(0), Value: 12.5}},
+				},
+				{
+					ID:      `avg(latency){host="host-b"}`,
+					Labels:  map[string]string{"host": "host-b"},
+					Metrics: []model.Metric{{TS: ms(1), Value: 30}},
+				},
+			},
+		},
+		"Percentiles should return a series per percentile.": {
+			expr:   `{"query": "service:api", "metric": "percentiles", "field": "latency", "percents": [99, 50]}`,
+			step:   time.Minute,
+			status: http.StatusOK,
+			response: `{"aggregations": {"histogram": {"buckets": [
+				{"key": 1558275600000, "doc_count": 4, "metric": {"values": {"99.0": 40, "50.0": 10}}},
+				{"key": 1558275660000, "doc_count": 4, "metric": {"values": {"99.0": 45, "50.0": null}}}
+			]}}}`,
+			expRequest: func(t *testing.T, body map[string]interface{}) {
+				assert.Equal(t, []interface{}{float64(99), float64(50)}, path(body, "aggs", "histogram", "aggs", "metric", "percentiles", "percents"))
+			},
+			expMetricSeries: []model.MetricSeries{
+				{
+					ID:      `percentiles(latency){percentile="50"}`,
+					Labels:  map[string]string{"percentile": "50"},
+					Metrics: []model.Metric{{TS: ms(0), Value: 10}},
+				},
+				{
+					ID:      `percentiles(latency){percentile="99"}`,
+					Labels:  map[string]string{"percentile": "99"},
+					Metrics: []model.Metric{{TS: ms(0), Value: 40}, {TS: ms(1), Value: 45}},
+				},
+			},
+		},
+		"An error response should return an error.": {
+			expr:     "status:500",
+			step:     time.Minute,
+			status:   http.StatusBadRequest,
+			response: `{"error": {"type": "parsing_exception", "reason": "wrong query"}, "status": 400}`,
+			expErr:   true,
+		},
+		"A metric aggregation without field should error.": {
+			expr:   `{"metric": "sum"}`,
+			step:   time.Minute,
+			status: http.StatusOK,
+			expErr: true,
+		},
+		"A not supported metric aggregation should error.": {
+			expr:   `{"metric": "cardinality", "field": "user"}`,
+			step:   time.Minute,
+			status: http.StatusOK,
+			expErr: true,
+		},
+	}
+
+	for name, test := range tests {
+		test := test
+		t.Run(name, func(t *testing.T) {
+			assert := assert.New(t)
+
+			var gotPath string
+			var gotBody map[string]interface{}
+			srv := testServer(t, test.status, test.response, &gotPath, &gotBody)
+			defer srv.Close()
+
+			g := newGatherer(t, srv.URL)
+			gotms, err := g.GatherRange(context.TODO(), model.Query{Expr: test.expr}, start, end, test.step)
+			if test.expErr {
+				assert.Error(err)
+				return
+			}
+
+			if assert.NoError(err) {
+				assert.Equal("/logs-*/_search", gotPath)
+				assert.Equal(test.expMetricSeries, gotms)
+				if test.expRequest != nil {
+					test.expRequest(t, gotBody)
+				}
+			}
+		})
+	}
+}
+
+func TestGathererGatherSingle(t *testing.T) {
+	tests := map[string]struct {
+		expr            string
+		response        string
+		expRequest      func(t *testing.T, body map[string]interface{})
+		expMetricSeries []model.MetricSeries
+	}{
+		"A Lucene query should count the documents of the instant range.": {
+			expr:     "status:500",
+			response: `{"aggregations": {"metric": {"value": 42}}}`,
+			expRequest: func(t *testing.T, body map[string]interface{}) {
+				assert := assert.New(t)
+				assert.Nil(path(body, "aggs", "histogram"))
+				assert.Equal("@timestamp", path(body, "aggs", "metric", "value_count", "field"))
+			},
+			expMetricSeries: []model.MetricSeries{
+				{ID: "count", Labels: map[string]string{}, Metrics: []model.Metric{{TS: end, Value: 42}}},
+			},
+		},
+		"A metric aggregation with terms split should return the metric of each bucket.": {
+			expr: `{"metric": "max", "field": "latency", "terms": {"field": "host"}}`,
+			response: `{"aggregations": {"split": {"buckets": [
+				{"key": "host-a", "doc_count": 10, "metric": {"value": 12.5}},
+				{"key": 1, "doc_count": 3, "metric": {"value": 30}}
+			]}}}`,
+			expRequest: func(t *testing.T, body map[string]interface{}) {
+				assert := assert.New(t)
+				assert.Equal(float64(10), path(body, "aggs", "split", "terms", "size"))
+				assert.Equal("latency", path(body, "aggs", "split", "aggs", "metric", "max", "field"))
+			},
+			expMetricSeries: []model.MetricSeries{
+				{ID: `max(latency){host="host-a"}`, Labels: map[string]string{"host": "host-a"}, Metrics: []model.Metric{{TS: end, Value: 12.5}}},
+				{ID: `max(latency){host="1"}`, Labels: map[string]string{"host": "1"}, Metrics: []model.Metric{{TS: end, Value: 30}}},
+			},
+		},
+	}
+
+	for name, test := range tests {
+		test := test
+		t.Run(name, func(t *testing.T) {
+			assert := assert.New(t)
+
+			var gotPath string
+			var gotBody map[string]interface{}
+			srv := testServer(t, http.StatusOK, test.response, &gotPath, &gotBody)
+			defer srv.Close()
+
+			g := newGatherer(t, srv.URL)
+			gotms, err := g.GatherSingle(context.TODO(), model.Query{Expr: test.expr}, end)
+			if assert.NoError(err) {
+				assert.Equal(test.expMetricSeries, gotms)
+				test.expRequest(t, gotBody)
+			}
+		})
+	}
+}
+
+func TestGathererDiscoverValues(t *testing.T) {
+	assert := assert.New(t)
+
+	var gotPath string
+	var gotBody map[string]interface{}
+	srv := testServer(t, http.StatusOK, `{"aggregations": {"split": {"buckets": [
+		{"key": "host-a", "doc_count": 10},
+		{"key": "host-b", "doc_count": 3}
+	]}}}`, &gotPath, &gotBody)
+	defer srv.Close()
+
+	g := newGatherer(t, srv.URL)
+	d := g.(metric.Discoverer)
+	got, err := d.DiscoverValues(context.TODO(), model.Query{Expr: "host.keyword"})
+	if assert.NoError(err) {
+		assert.Equal([]string{"host-a", "host-b"}, got)
+		assert.Equal("host.keyword", path(gotBody, "aggs", "split", "terms", "field"))
+	}
+}
@@ -0,0 +1,160 @@
+package metric
+
+import (
+	"context"
+	"errors"
+	"net"
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// ErrorClass is the class of a failed query, it's used to know if the
+// query can be retried.
+type ErrorClass string
+
+const (
+	// ErrorClassUnknown is the class of the errors that could not be classified.
+	ErrorClassUnknown ErrorClass = "unknown"
+	// ErrorClassBadQuery is the class of the invalid queries (e.g a query with syntax errors).
+	ErrorClassBadQuery ErrorClass = "bad query"
+	// ErrorClassAuth is the class of the queries rejected by the datasource authentication.
+	ErrorClassAuth ErrorClass = "auth"
+	// ErrorClassUnavailable is the class of the queries that failed because the
+	// datasource could not be reached or had an internal error.
+	ErrorClassUnavailable ErrorClass = "unavailable"
+	// ErrorClassTimeout is the class of the queries that took too long.
+	ErrorClassTimeout ErrorClass = "timeout"
+	// ErrorClassRateLimited is the class of the queries rejected by the datasource
+	// because there were too many.
+	ErrorClassRateLimited ErrorClass = "rate limited"
+	// ErrorClassCanceled is the class of the queries canceled by the caller.
+	ErrorClassCanceled ErrorClass = "canceled"
+)
+
+// Transient returns true if the errors of the class are temporary and the
+// query could succeed if retried. The timed out queries are not transient,
+// retrying a slow query multiplies the load it puts on the datasource.
+func (e ErrorClass) Transient() bool {
+	return e == ErrorClassUnavailable || e == ErrorClassRateLimited
+}
+
+// Error is a classified error of a gatherer, the gatherers wrap their
+// errors with it so the failed queries can be handled by its class.
+type Error struct {
+	Class ErrorClass
+	// RetryAfter is the wait the datasource asked for before retrying
+	// the query (e.g `Retry-After` HTTP header), 0 if not asked.
+	RetryAfter time.Duration
+	Err        error
+}
+
+// NewError returns a new classified error.
+func NewError(class ErrorClass, err error) error {
+	return &Error{Class: class, Err: err}
+}
+
+// NewHTTPError returns a new error classified by the status code of the
+// HTTP response, it will use the `Retry-After` header of the response
+// as the retry wait.
+func NewHTTPError(resp *http.Response, err error) error {
+	return &Error{
+		Class:      HTTPStatusErrorClass(resp.StatusCode),
+		RetryAfter: HTTPRetryAfter(resp),
+		Err:        err,
+	}
+}
+
+func (e *Error) Error() string { return e.Err.Error() }
+
+func (e *Error) Unwrap() error { return e.Err }
+
+// ErrorClassOf returns the class of the error. The errors that have not been
+// classified by the gatherers are classified by the context errors and
+// the network errors.
+func ErrorClassOf(err error) ErrorClass {
+	if err == nil {
+		return ""
+	}
+
+	var e *Error
+	if errors.As(err, &e) {
+		return e.Class
+	}
+
+	if errors.Is(err, context.DeadlineExceeded) {
+		return ErrorClassTimeout
+	}
+
+	if errors.Is(err, context.Canceled) {
+		return ErrorClassCanceled
+	}
+
+	var nerr net.Error
+	if errors.As(err, &nerr) {
+		if nerr.Timeout() {
+			return ErrorClassTimeout
+		}
+		return ErrorClassUnavailable
+	}
+
+	return ErrorClassUnknown
+}
+
+// ErrorRetryAfter returns the wait the datasource asked for before retrying
+// the failed query, 0 if not asked.
+func ErrorRetryAfter(err error) time.Duration {
+	var e *Error
+	if errors.As(err, &e) {
+		return e.RetryAfter
+	}
+	return 0
+}
+
+// HTTPStatusErrorClass returns the error class of an HTTP response status code.
+func HTTPStatusErrorClass(code int) ErrorClass {
+	switch {
+	case code == http.StatusUnauthorized, code == http.StatusForbidden:
+		return ErrorClassAuth
+	case code == http.StatusTooManyRequests:
+		return ErrorClassRateLimited
+	case code == http.StatusRequestTimeout, code == http.StatusGatewayTimeout:
+		return ErrorClassTimeout
+	case code >= 400 && code < 500:
+		return ErrorClassBadQuery
+	case code >= 500 && code < 600:
+		return ErrorClassUnavailable
+	}
+
+	return ErrorClassUnknown
+}
+
+// HTTPRetryAfter returns the wait of the `Retry-After` header of the HTTP
+// response, 0 if not set.
+func HTTPRetryAfter(resp *http.Response) time.Duration {
+	return parseRetryAfter(resp.Header.Get("Retry-After"), time.Now())
+}
+
+// parseRetryAfter parses the `Retry-After` HTTP header, that can be
+// the number of seconds to wait or the date to retry at.
+func parseRetryAfter(v string, now time.Time) time.Duration {
+	v = strings.TrimSpace(v)
+	if v == "" {
+		return 0
+	}
+
+	if secs, err := strconv.Atoi(v); err == nil {
+		if secs < 0 {
+			return 0
+		}
+		return time.Duration(secs) * time.Second
+	}
+
+	t, err := http.ParseTime(v)
+	if err != nil || !t.After(now) {
+		return 0
+	}
+
+	return t.Sub(now)
+}
@@ -0,0 +1,124 @@
+package metric
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net"
+	"net/http"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestErrorClassOf(t *testing.T) {
+	tests := []struct {
+		name     string
+		err      error
+		expClass ErrorClass
+	}{
+		{
+			name:     "A nil error should not have class.",
+			err:      nil,
+			expClass: "",
+		},
+		{
+			name:     "A classified error should return its class.",
+			err:      NewError(ErrorClassAuth, errors.New("wanted error")),
+			expClass: ErrorClassAuth,
+		},
+		{
+			name:     "A wrapped classified error should return its class.",
+			err:      fmt.Errorf("query failed: %w", NewError(ErrorClassRateLimited, errors.New("wanted error"))),
+			expClass: ErrorClassRateLimited,
+		},
+		{
+			name:     "A context deadline error should be a timeout.",
+			err:      fmt.Errorf("query failed: %w", context.DeadlineExceeded),
+			expClass: ErrorClassTimeout,
+		},
+		{
+			name:     "A context canceled error should be canceled.",
+			err:      context.Canceled,
+			expClass: ErrorClassCanceled,
+		},
+		{
+			name:     "A network error should be unavailable.",
+			err:      &net.OpError{Op: "dial", Err: errors.New("connection refused")},
+			expClass: ErrorClassUnavailable,
+		},
+		{
+			name:     "A not classified error should be unknown.",
+			err:      errors.New("wanted error"),
+			expClass: ErrorClassUnknown,
+		},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			assert.Equal(t, test.expClass, ErrorClassOf(test.err))
+		})
+	}
+}
+
+func TestHTTPStatusErrorClass(t *testing.T) {
+	tests := map[int]ErrorClass{
+		http.StatusBadRequest:          ErrorClassBadQuery,
+		http.StatusNotFound:            ErrorClassBadQuery,
+		http.StatusUnauthorized:        ErrorClassAuth,
+		http.StatusForbidden:           ErrorClassAuth,
+		http.StatusTooManyRequests:     ErrorClassRateLimited,
+		http.StatusRequestTimeout:      ErrorClassTimeout,
+		http.StatusGatewayTimeout:      ErrorClassTimeout,
+		http.StatusInternalServerError: ErrorClassUnavailable,
+		http.StatusServiceUnavailable:  ErrorClassUnavailable,
+		http.StatusFound:               ErrorClassUnknown,
+	}
+
+	for code, expClass := range tests {
+		assert.Equal(t, expClass, HTTPStatusErrorClass(code), "status code %d", code)
+	}
+}
+
+func TestParseRetryAfter(t *testing.T) {
+	now := time.Date(2019, 5, 12, 9, 0, 0, 0, time.UTC)
+
+	tests := []struct {
+		name     string
+		value    string
+		expAfter time.Duration
+	}{
+		{
+			name:     "Missing header should not wait.",
+			value:    "",
+			expAfter: 0,
+		},
+		{
+			name:     "Seconds should wait the seconds.",
+			value:    "3",
+			expAfter: 3 * time.Second,
+		},
+		{
+			name:     "A date should wait until the date.",
+			value:    "Sun, 12 May 2019 09:00:10 GMT",
+			expAfter: 10 * time.Second,
+		},
+		{
+			name:     "A past date should not wait.",
+			value:    "Sun, 12 May 2019 08:00:00 GMT",
+			expAfter: 0,
+		},
+		{
+			name:     "An invalid value should not wait.",
+			value:    "soon",
+			expAfter: 0,
+		},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			assert.Equal(t, test.expAfter, parseRetryAfter(test.value, now))
+		})
+	}
+}
@@ -0,0 +1,426 @@
+package exec
+
+import (
+	"bufio"
+	"bytes"
+	"context"
+	"fmt"
+	"os"
+	"os/exec"
+	"regexp"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/slok/grafterm/internal/model"
+	"github.com/slok/grafterm/internal/service/metric"
+)
+
+const (
+	defTimeout        = 10 * time.Second
+	defRetention      = 1 * time.Hour
+	defMaxOutputBytes = 1024 * 1024
+	// waitDelay is the time waited for the output of the command after it
+	// has been killed, the children of the command could have the output open.
+	waitDelay = 500 * time.Millisecond
+
+	nameLabel = "__name__"
+)
+
+// ConfigGatherer is the configuration of the exec gatherer.
+type ConfigGatherer struct {
+	// Command is the program and the arguments that will be executed.
+	Command []string
+	// Stdin will write the query expression to the command standard input
+	// instead of appending it as the last argument.
+	Stdin bool
+	// Env are the environment variables of the command.
+	Env map[string]string
+	// InheritEnv will set the grafterm environment variables on the command
+	// in addition to Env.
+	InheritEnv bool
+	// Timeout is the max duration of a command execution.
+	Timeout time.Duration
+	// MaxOutputBytes is the max size of the command output.
+	MaxOutputBytes int
+	// Retention is the time the values are kept in the local history.
+	Retention time.Duration
+}
+
+func (c *ConfigGatherer) defaults() error {
+	if len(c.Command) == 0 || c.Command[0] == "" {
+		return fmt.Errorf("no command given")
+	}
+
+	if c.Timeout <= 0 {
+		c.Timeout = defTimeout
+	}
+
+	if c.MaxOutputBytes <= 0 {
+		c.MaxOutputBytes = defMaxOutputBytes
+	}
+
+	if c.Retention <= 0 {
+		c.Retention = defRetention
+	}
+
+	return nil
+}
+
+type gatherer struct {
+	cfg ConfigGatherer
+	env []string
+
+	mu sync.Mutex
+	// history has the series of every query expression.
+	history map[string]map[string]*model.MetricSeries
+}
+
+// NewGatherer returns a new metric gatherer that runs a local command and
+// gets the values from the output. The values are accumulated on a local
+// history that is used by the range queries.
+func NewGatherer(cfg ConfigGatherer) (metric.Gatherer, error) {
+	err := cfg.defaults()
+	if err != nil {
+		return nil, err
+	}
+
+	env := []string{}
+	if cfg.InheritEnv {
+		env = append(env, os.Environ()...)
+	}
+	keys := make([]string, 0, len(cfg.Env))
+	for k := range cfg.Env {
+		keys = append(keys, k)
+	}
+	sort.Strings(keys)
+	for _, k := range keys {
+		env = append(env, k+"="+cfg.Env[k])
+	}
+
+	return &gatherer{
+		cfg:     cfg,
+		env:     env,
+		history: map[string]map[string]*model.MetricSeries{},
+	}, nil
+}
+
+// GatherSingle runs the command and returns the latest value of each series,
+// the values without timestamp will use the query time.
+func (g *gatherer) GatherSingle(ctx context.Context, query model.Query, t time.Time) ([]model.MetricSeries, error) {
+	series, err := g.run(ctx, query.Expr, t)
+	if err != nil {
+		return []model.MetricSeries{}, err
+	}
+	g.record(query.Expr, series, t)
+
+	res := make([]model.MetricSeries, 0, len(series))
+	for _, s := range series {
+		s.Metrics = s.Metrics[len(s.Metrics)-1:]
+		res = append(res, s)
+	}
+
+	return res, nil
+}
+
+// GatherRange runs the command and returns the values of the local history in
+// the time range, the values without timestamp will use the end of the range.
+func (g *gatherer) GatherRange(ctx context.Context, query model.Query, start, end time.Time, step time.Duration) ([]model.MetricSeries, error) {
+	series, err := g.run(ctx, query.Expr, end)
+	if err != nil {
+		return []model.MetricSeries{}, err
+	}
+	g.record(query.Expr, series, end)
+
+	g.mu.Lock()
+	defer g.mu.Unlock()
+
+	res := []model.MetricSeries{}
+	for _, s := range g.history[query.Expr] {
+		metrics := []model.Metric{}
+		for _, m := range s.Metrics {
+			if !m.TS.Before(start) && !m.TS.After(end) {
+				metrics = append(metrics, m)
+			}
+		}
+		if len(metrics) == 0 {
+			continue
+		}
+
+		res = append(res, model.MetricSeries{
+			ID:      s.ID,
+			Labels:  s.Labels,
+			Metrics: metrics,
+		})
+	}
+	sort.Slice(res, func(i, j int) bool { return res[i].ID < res[j].ID })
+
+	return res, nil
+}
+
+// record adds the series to the history of the query and removes the values
+// that are out of the retention.
+func (g *gatherer) record(expr string, series []model.MetricSeries, now time.Time) {
+	g.mu.Lock()
+	defer g.mu.Unlock()
+
+	h, ok := g.history[expr]
+	if !ok {
+		h = map[string]*model.MetricSeries{}
+		g.history[expr] = h
+	}
+
+	for _, s := range series {
+		hs, ok := h[s.ID]
+		if !ok {
+			hs = &model.MetricSeries{ID: s.ID, Labels: s.Labels}
+			h[s.ID] = hs
+		}
+		for _, m := range s.Metrics {
+			// Ignore the values that are not newer than the latest one.
+			if n := len(hs.Metrics); n > 0 && !m.TS.After(hs.Metrics[n-1].TS) {
+				continue
+			}
+			hs.Metrics = append(hs.Metrics, m)
+		}
+	}
+
+	min := now.Add(-1 * g.cfg.Retention)
+	for id, hs := range h {
+		i := sort.Search(len(hs.Metrics), func(i int) bool {
+			return !hs.Metrics[i].TS.Before(min)
+		})
+		if i >= len(hs.Metrics) {
+			delete(h, id)
+			continue
+		}
+		hs.Metrics = hs.Metrics[i:]
+	}
+}
+
+// limitedBuffer is a buffer that cancels the command when the limit
+// is exceeded.
+type limitedBuffer struct {
+	buf      bytes.Buffer
+	limit    int
+	exceeded bool
+	cancel   func()
+}
+
+func (l *limitedBuffer) Write(p []byte) (int, error) {
+	if l.buf.Len()+len(p) > l.limit {
+		l.exceeded = true
+		l.cancel()
+		return 0, fmt.Errorf("output exceeds %d bytes", l.limit)
+	}
+	return l.buf.Write(p)
+}
+
+// run executes the command and parses the output.
+func (g *gatherer) run(ctx context.Context, expr string, t time.Time) ([]model.MetricSeries, error) {
+	queryCtx := ctx
+	ctx, cancel := context.WithTimeout(ctx, g.cfg.Timeout)
+	defer cancel()
+
+	args := append([]string{}, g.cfg.Command[1:]...)
+	if !g.cfg.Stdin {
+		args = append(args, expr)
+	}
+
+	cmd := exec.CommandContext(ctx, g.cfg.Command[0], args...)
+	cmd.Env = g.env
+	cmd.WaitDelay = waitDelay
+	if g.cfg.Stdin {
+		cmd.Stdin = strings.NewReader(expr)
+	}
+	stdout := &limitedBuffer{limit: g.cfg.MaxOutputBytes, cancel: cancel}
+	stderr := &limitedBuffer{limit: g.cfg.MaxOutputBytes, cancel: cancel}
+	cmd.Stdout = stdout
+	cmd.Stderr = stderr
+
+	err := cmd.Run()
+	switch {
+	case stdout.exceeded || stderr.exceeded:
+		return nil, fmt.Errorf("command output exceeds the %d bytes limit", g.cfg.MaxOutputBytes)
+	case queryCtx.Err() != nil:
+		// The query has been stopped before the command timeout.
+		if deadline, ok := queryCtx.Deadline(); ok && queryCtx.Err() == context.DeadlineExceeded {
+			return nil, fmt.Errorf("command stopped by the query deadline (%s): %w", deadline.Format(time.RFC3339Nano), queryCtx.Err())
+		}
+		return nil, fmt.Errorf("command stopped: %w", queryCtx.Err())
+	case ctx.Err() == context.DeadlineExceeded:
+		return nil, metric.NewError(metric.ErrorClassTimeout, fmt.Errorf("command timed out after %s", g.cfg.Timeout))
+	case err != nil:
+		if msg := strings.TrimSpace(stderr.buf.String()); msg != "" {
+			return nil, fmt.Errorf("command failed: %w: %s", err, msg)
+		}
+		return nil, fmt.Errorf("command failed: %w", err)
+	}
+
+	series, err := parseOutput(&stdout.buf, t)
+	if err != nil {
+		return nil, err
+	}
+	if len(series) == 0 {
+		return nil, fmt.Errorf("command didn't return any value")
+	}
+
+	return series, nil
+}
+
+var labelRegexp = regexp.MustCompile(`^\s*([a-zA-Z_][a-zA-Z0-9_]*)\s*=\s*"((?:[^"\\]|\\.)*)"\s*$`)
+
+// parseOutput parses the command output line protocol, every line can be:
+//   - `value`: a value without labels.
+//   - `timestamp value`: a value without labels on a timestamp (RFC3339 or unix seconds or milliseconds).
+//   - `labels value`: a value of the series identified by the labels (e.g `queue{name="jobs"} 12`).
+//   - `labels timestamp value`: a value of the series on a timestamp.
+//
+// The values without timestamp will use the default time. The empty lines and
+// the lines starting with `#` are ignored.
+func parseOutput(b *bytes.Buffer, t time.Time) ([]model.MetricSeries, error) {
+	res := []model.MetricSeries{}
+	index := map[string]int{}
+
+	sc := bufio.NewScanner(b)
+	lineNum := 0
+	for sc.Scan() {
+		lineNum++
+		line := strings.TrimSpace(sc.Text())
+		if line == "" || strings.HasPrefix(line, "#") {
+			continue
+		}
+
+		id, ls, rest, err := parseLineLabels(line)
+		if err != nil {
+			return nil, fmt.Errorf("invalid output line %d: %w", lineNum, err)
+		}
+
+		fields := strings.Fields(rest)
+		ts := t
+		switch len(fields) {
+		case 1:
+		case 2:
+			ts, err = metric.ParseTimestamp(fields[0])
+			if err != nil {
+				return nil, fmt.Errorf("invalid output line %d: invalid timestamp: %w", lineNum, err)
+			}
+		default:
+			return nil, fmt.Errorf("invalid output line %d: expected a value", lineNum)
+		}
+
+		v, err := strconv.ParseFloat(fields[len(fields)-1], 64)
+		if err != nil {
+			return nil, fmt.Errorf("invalid output line %d: invalid value: %w", lineNum, err)
+		}
+
+		i, ok := index[id]
+		if !ok {
+			i = len(res)
+			index[id] = i
+			res = append(res, model.MetricSeries{ID: id, Labels: ls})
+		}
+		res[i].Metrics = append(res[i].Metrics, model.Metric{TS: ts, Value: v})
+	}
+	if err := sc.Err(); err != nil {
+		return nil, err
+	}
+
+	for _, s := range res {
+		sort.SliceStable(s.Metrics, func(i, j int) bool { return s.Metrics[i].TS.Before(s.Metrics[j].TS) })
+	}
+
+	return res, nil
+}
+
+// parseLineLabels parses the labels at the start of the line in the form of
+// `name{a="b"}`, `{a="b"}` or `name` and returns the series ID, the labels and
+// the rest of the line. If the line doesn't start with labels the ID will be
+// empty.
+func parseLineLabels(line string) (string, map[string]string, string, error) {
+	i := 0
+	for i < len(line) && (line[i] == '_' || line[i] == ':' ||
+		(line[i] >= 'a' && line[i] <= 'z') || (line[i] >= 'A' && line[i] <= 'Z') ||
+		(i > 0 && line[i] >= '0' && line[i] <= '9')) {
+		i++
+	}
+	name := line[:i]
+	rest := line[i:]
+
+	// Only a name (not a number like `Inf` or `NaN`).
+	if !strings.HasPrefix(rest, "{") {
+		if _, err := strconv.ParseFloat(strings.Fields(line)[0], 64); name == "" || err == nil {
+			return "", nil, line, nil
+		}
+		ls := map[string]string{nameLabel: name}
+		return seriesID(ls), ls, rest, nil
+	}
+
+	// Split the labels by the commas that are not quoted until the end of them.
+	parts := []string{}
+	quoted := false
+	st, end := 1, -1
+	for j := 1; j < len(rest) && end < 0; j++ {
+		switch {
+		case rest[j] == '\\' && quoted:
+			j++
+		case rest[j] == '"':
+			quoted = !quoted
+		case rest[j] == ',' && !quoted:
+			parts = append(parts, rest[st:j])
+			st = j + 1
+		case rest[j] == '}' && !quoted:
+			parts = append(parts, rest[st:j])
+			end = j
+		}
+	}
+	if end < 0 {
+		return "", nil, "", fmt.Errorf("unclosed labels")
+	}
+
+	ls := map[string]string{}
+	if name != "" {
+		ls[nameLabel] = name
+	}
+	for _, part := range parts {
+		if strings.TrimSpace(part) == "" {
+			continue
+		}
+		sm := labelRegexp.FindStringSubmatch(part)
+		if sm == nil {
+			return "", nil, "", fmt.Errorf("invalid label %q", strings.TrimSpace(part))
+		}
+		v, err := strconv.Unquote(`"` + sm[2] + `"`)
+		if err != nil {
+			return "", nil, "", fmt.Errorf("invalid label %q: %w", sm[1], err)
+		}
+		ls[sm[1]] = v
+	}
+
+	return seriesID(ls), ls, rest[end+1:], nil
+}
+
+// seriesID returns the ID of the series in the form of `name{a="b", c="d"}`,
+// or `name` if the series only has the name.
+func seriesID(ls map[string]string) string {
+	keys := []string{}
+	for k := range ls {
+		if k != nameLabel {
+			keys = append(keys, k)
+		}
+	}
+	sort.Strings(keys)
+
+	if len(keys) == 0 && ls[nameLabel] != "" {
+		return ls[nameLabel]
+	}
+
+	pairs := make([]string, 0, len(keys))
+	for _, k := range keys {
+		pairs = append(pairs, fmt.Sprintf("%s=%q", k, ls[k]))
+	}
+
+	return ls[nameLabel] + "{" + strings.Join(pairs, ", ") + "}"
+}
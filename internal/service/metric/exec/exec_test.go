@@ -0,0 +1,190 @@
+package exec_test
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/slok/grafterm/internal/model"
+	"github.com/slok/grafterm/internal/service/metric/exec"
+)
+
+var t0 = time.Date(2019, 5, 19, 14, 0, 0, 0, time.UTC)
+
+// shell returns a command that runs the script with `sh`, the query expression
+// will be available as `$1`.
+func shell(script string) []string {
+	return []string{"sh", "-c", script, "--"}
+}
+
+func TestGathererGatherSingle(t *testing.T) {
+	tests := map[string]struct {
+		cfg       exec.ConfigGatherer
+		expr      string
+		expSeries []model.MetricSeries
+		expErr    bool
+	}{
+		"A value should return a series without labels on the query time.": {
+			cfg: exec.ConfigGatherer{Command: shell(`echo 42`)},
+			expSeries: []model.MetricSeries{
+				{Metrics: []model.Metric{{TS: t0, Value: 42}}},
+			},
+		},
+		"A timestamp and a value should return the latest value.": {
+			cfg: exec.ConfigGatherer{Command: shell(`echo "1558274400 1"; echo "2019-05-19T13:59:00Z 2"`)},
+			expSeries: []model.MetricSeries{
+				{Metrics: []model.Metric{{TS: t0, Value: 1}}},
+			},
+		},
+		"Labels and values should return a series per labels.": {
+			cfg: exec.ConfigGatherer{Command: shell(`echo '# queues'; echo 'queue{name="jobs, high"} 12'; echo '{name="mails"} 1558274400000 3'; echo 'pods 7'`)},
+			expSeries: []model.MetricSeries{
+				{
+					ID:      `queue{name="jobs, high"}`,
+					Labels:  map[string]string{"__name__": "queue", "name": "jobs, high"},
+					Metrics: []model.Metric{{TS: t0, Value: 12}},
+				},
+				{
+					ID:      `{name="mails"}`,
+					Labels:  map[string]string{"name": "mails"},
+					Metrics: []model.Metric{{TS: t0, Value: 3}},
+				},
+				{
+					ID:      `pods`,
+					Labels:  map[string]string{"__name__": "pods"},
+					Metrics: []model.Metric{{TS: t0, Value: 7}},
+				},
+			},
+		},
+		"The expression should be the last argument of the command.": {
+			cfg:  exec.ConfigGatherer{Command: shell(`echo "$1"`)},
+			expr: "17",
+			expSeries: []model.MetricSeries{
+				{Metrics: []model.Metric{{TS: t0, Value: 17}}},
+			},
+		},
+		"The expression should be written on stdin.": {
+			cfg:  exec.ConfigGatherer{Command: shell(`read v; echo "$v $#"`), Stdin: true},
+			expr: "1558274400",
+			expSeries: []model.MetricSeries{
+				{Metrics: []model.Metric{{TS: t0, Value: 0}}},
+			},
+		},
+		"The command should only have the configured environment.": {
+			cfg: exec.ConfigGatherer{
+				Command: shell(`echo "${VALUE:-0}${HOME:-}"`),
+				Env:     map[string]string{"VALUE": "5"},
+			},
+			expSeries: []model.MetricSeries{
+				{Metrics: []model.Metric{{TS: t0, Value: 5}}},
+			},
+		},
+		"A command failure should error.": {
+			cfg:    exec.ConfigGatherer{Command: shell(`echo "wanted error" >&2; exit 1`)},
+			expErr: true,
+		},
+		"A command that exceeds the timeout should error.": {
+			cfg:    exec.ConfigGatherer{Command: shell(`sleep 5`), Timeout: 50 * time.Millisecond},
+			expErr: true,
+		},
+		"A command that exceeds the output limit should error.": {
+			cfg:    exec.ConfigGatherer{Command: shell(`echo 1234567890`), MaxOutputBytes: 5},
+			expErr: true,
+		},
+		"An invalid output should error.": {
+			cfg:    exec.ConfigGatherer{Command: shell(`echo "a b c d"`)},
+			expErr: true,
+		},
+		"An empty output should error.": {
+			cfg:    exec.ConfigGatherer{Command: shell(`true`)},
+			expErr: true,
+		},
+	}
+
+	for name, test := range tests {
+		test := test
+		t.Run(name, func(t *testing.T) {
+			assert := assert.New(t)
+			require := require.New(t)
+
+			g, err := exec.NewGatherer(test.cfg)
+			require.NoError(err)
+
+			gotSeries, err := g.GatherSingle(context.TODO(), model.Query{Expr: test.expr}, t0)
+			if test.expErr {
+				assert.Error(err)
+				return
+			}
+
+			if assert.NoError(err) {
+				for i := range gotSeries {
+					for j := range gotSeries[i].Metrics {
+						gotSeries[i].Metrics[j].TS = gotSeries[i].Metrics[j].TS.UTC()
+					}
+				}
+				assert.Equal(test.expSeries, gotSeries)
+			}
+		})
+	}
+}
+
+func TestGathererGatherSingleQueryDeadline(t *testing.T) {
+	assert := assert.New(t)
+	require := require.New(t)
+
+	g, err := exec.NewGatherer(exec.ConfigGatherer{Command: shell(`sleep 5`), Timeout: time.Minute})
+	require.NoError(err)
+
+	// The query deadline is reached before the command timeout.
+	ctx, cancel := context.WithTimeout(context.Background(), 50*time.Millisecond)
+	defer cancel()
+	_, err = g.GatherSingle(ctx, model.Query{}, t0)
+	if assert.Error(err) {
+		assert.True(errors.Is(err, context.DeadlineExceeded))
+		assert.NotContains(err.Error(), "command timed out")
+	}
+}
+
+func TestGathererGatherRangeHistory(t *testing.T) {
+	assert := assert.New(t)
+	require := require.New(t)
+
+	g, err := exec.NewGatherer(exec.ConfigGatherer{
+		Command:   shell(`echo "queue{name=\"$1\"} 10"`),
+		Retention: 2 * time.Minute,
+	})
+	require.NoError(err)
+
+	q := model.Query{Expr: "jobs"}
+	for i := 0; i < 4; i++ {
+		_, err := g.GatherSingle(context.TODO(), q, t0.Add(time.Duration(i)*time.Minute))
+		require.NoError(err)
+	}
+
+	// The first value is out of the retention.
+	end := t0.Add(4 * time.Minute)
+	gotSeries, err := g.GatherRange(context.TODO(), q, t0, end, time.Minute)
+	expSeries := []model.MetricSeries{
+		{
+			ID:     `queue{name="jobs"}`,
+			Labels: map[string]string{"__name__": "queue", "name": "jobs"},
+			Metrics: []model.Metric{
+				{TS: t0.Add(2 * time.Minute), Value: 10},
+				{TS: t0.Add(3 * time.Minute), Value: 10},
+				{TS: end, Value: 10},
+			},
+		},
+	}
+	if assert.NoError(err) {
+		assert.Equal(expSeries, gotSeries)
+	}
+}
+
+func TestNewGathererWithoutCommand(t *testing.T) {
+	_, err := exec.NewGatherer(exec.ConfigGatherer{})
+	assert.Error(t, err)
+}
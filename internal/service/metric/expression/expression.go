@@ -0,0 +1,395 @@
+package expression
+
+import (
+	"context"
+	"fmt"
+	"math"
+	"sort"
+	"sync"
+	"time"
+
+	"github.com/slok/grafterm/internal/model"
+	"github.com/slok/grafterm/internal/service/metric"
+)
+
+// ConfigGatherer is the configuration of the expression gatherer.
+type ConfigGatherer struct {
+	// ID is the ID of the expression datasource, is used to detect the
+	// expressions that reference themselves.
+	ID string
+	// Queries are the named queries that the expressions can reference
+	// (e.g `$A`).
+	Queries map[string]model.Query
+	// Gatherer is the gatherer used to gather the named queries, normally
+	// the multi datasource gatherer.
+	Gatherer metric.Gatherer
+}
+
+func (c *ConfigGatherer) defaults() error {
+	if len(c.Queries) == 0 {
+		return fmt.Errorf("no expression queries given")
+	}
+
+	if c.Gatherer == nil {
+		return fmt.Errorf("no gatherer for the expression queries given")
+	}
+
+	return nil
+}
+
+// nameLabel is the label of the metric name on the Prometheus like series.
+const nameLabel = "__name__"
+
+type gatherer struct {
+	cfg ConfigGatherer
+}
+
+// NewGatherer returns a new metric gatherer that evaluates math expressions
+// using the result of named queries to other datasources.
+func NewGatherer(cfg ConfigGatherer) (metric.Gatherer, error) {
+	err := cfg.defaults()
+	if err != nil {
+		return nil, err
+	}
+
+	return &gatherer{cfg: cfg}, nil
+}
+
+func (g *gatherer) GatherSingle(ctx context.Context, query model.Query, t time.Time) ([]model.MetricSeries, error) {
+	align := func(time.Time) time.Time { return t }
+	gather := func(ctx context.Context, q model.Query) ([]model.MetricSeries, error) {
+		return g.cfg.Gatherer.GatherSingle(ctx, q, t)
+	}
+
+	v, err := g.eval(ctx, query.Expr, gather, align)
+	if err != nil {
+		return []model.MetricSeries{}, err
+	}
+
+	return v.toMetricSeries([]time.Time{t}), nil
+}
+
+// GatherRange aligns the metrics of the named queries to the steps of the range
+// so the metrics of different datasources can be used on the same expression.
+func (g *gatherer) GatherRange(ctx context.Context, query model.Query, start, end time.Time, step time.Duration) ([]model.MetricSeries, error) {
+	align := func(ts time.Time) time.Time { return ts }
+	steps := []time.Time{start, end}
+	if step > 0 {
+		align = func(ts time.Time) time.Time {
+			n := math.Round(float64(ts.Sub(start)) / float64(step))
+			return start.Add(time.Duration(n) * step)
+		}
+		steps = []time.Time{}
+		for ts := start; !ts.After(end); ts = ts.Add(step) {
+			steps = append(steps, ts)
+		}
+	}
+	gather := func(ctx context.Context, q model.Query) ([]model.MetricSeries, error) {
+		return g.cfg.Gatherer.GatherRange(ctx, q, start, end, step)
+	}
+
+	v, err := g.eval(ctx, query.Expr, gather, align)
+	if err != nil {
+		return []model.MetricSeries{}, err
+	}
+
+	return v.toMetricSeries(steps), nil
+}
+
+type visitedKey struct{}
+
+// eval gathers the named queries referenced on the expression and evaluates
+// the expression.
+func (g *gatherer) eval(ctx context.Context, expr string, gather func(context.Context, model.Query) ([]model.MetricSeries, error), align func(time.Time) time.Time) (value, error) {
+	// Expressions referencing themselves would never end.
+	visited, _ := ctx.Value(visitedKey{}).([]string)
+	for _, id := range visited {
+		if id == g.cfg.ID {
+			return value{}, fmt.Errorf("expression datasource %s references itself", g.cfg.ID)
+		}
+	}
+	ctx = context.WithValue(ctx, visitedKey{}, append(append([]string{}, visited...), g.cfg.ID))
+
+	n, err := parseExpr(expr)
+	if err != nil {
+		return value{}, fmt.Errorf("invalid expression: %w", err)
+	}
+
+	// Gather the referenced queries concurrently.
+	names := []string{}
+	seen := map[string]bool{}
+	for _, name := range queryRefs(n) {
+		if seen[name] {
+			continue
+		}
+		if _, ok := g.cfg.Queries[name]; !ok {
+			return value{}, fmt.Errorf("query %s is not defined on the expression datasource", name)
+		}
+		seen[name] = true
+		names = append(names, name)
+	}
+
+	results := make([][]model.MetricSeries, len(names))
+	errs := make([]error, len(names))
+	var wg sync.WaitGroup
+	for i, name := range names {
+		wg.Add(1)
+		go func(i int, q model.Query) {
+			defer wg.Done()
+			results[i], errs[i] = gather(ctx, q)
+		}(i, g.cfg.Queries[name])
+	}
+	wg.Wait()
+
+	refs := map[string][]series{}
+	for i, name := range names {
+		if errs[i] != nil {
+			return value{}, fmt.Errorf("query %s failed: %w", name, errs[i])
+		}
+		refs[name] = newSeries(results[i], align)
+	}
+
+	return evaluator{refs: refs}.eval(n)
+}
+
+// series is a series with the metrics indexed by the aligned timestamp.
+type series struct {
+	id      string
+	labels  map[string]string
+	metrics map[int64]model.Metric
+}
+
+func newSeries(mss []model.MetricSeries, align func(time.Time) time.Time) []series {
+	res := make([]series, 0, len(mss))
+	for _, ms := range mss {
+		s := series{id: ms.ID, labels: ms.Labels, metrics: map[int64]model.Metric{}}
+		for _, m := range ms.Metrics {
+			ts := align(m.TS)
+			s.metrics[ts.UnixNano()] = model.Metric{TS: ts, Value: m.Value}
+		}
+		res = append(res, s)
+	}
+
+	return res
+}
+
+// value is the result of an expression evaluation, a scalar or a group
+// of series.
+type value struct {
+	isScalar bool
+	scalar   float64
+	series   []series
+}
+
+// toMetricSeries returns the value as metric series, the scalars will have
+// a metric on every step.
+func (v value) toMetricSeries(steps []time.Time) []model.MetricSeries {
+	if v.isScalar {
+		ms := model.MetricSeries{}
+		for _, ts := range steps {
+			ms.Metrics = append(ms.Metrics, model.Metric{TS: ts, Value: v.scalar})
+		}
+		return []model.MetricSeries{ms}
+	}
+
+	res := []model.MetricSeries{}
+	for _, s := range v.series {
+		if len(s.metrics) == 0 {
+			continue
+		}
+
+		ms := model.MetricSeries{ID: s.id, Labels: s.labels}
+		for _, m := range s.metrics {
+			ms.Metrics = append(ms.Metrics, m)
+		}
+		sort.Slice(ms.Metrics, func(i, j int) bool { return ms.Metrics[i].TS.Before(ms.Metrics[j].TS) })
+		res = append(res, ms)
+	}
+
+	return res
+}
+
+// evaluator evaluates the expressions using the series of the named queries.
+type evaluator struct {
+	refs map[string][]series
+}
+
+func (e evaluator) eval(n node) (value, error) {
+	switch v := n.(type) {
+	case *numberLiteral:
+		return value{isScalar: true, scalar: v.value}, nil
+
+	case *queryRef:
+		return value{series: e.refs[v.name]}, nil
+
+	case *reduceCall:
+		arg, err := e.eval(v.arg)
+		if err != nil {
+			return value{}, err
+		}
+		if arg.isScalar {
+			return arg, nil
+		}
+		return value{series: reduce(v, arg.series)}, nil
+
+	case *binaryExpr:
+		lhs, err := e.eval(v.lhs)
+		if err != nil {
+			return value{}, err
+		}
+		rhs, err := e.eval(v.rhs)
+		if err != nil {
+			return value{}, err
+		}
+		return binaryOp(v.op, lhs, rhs), nil
+	}
+
+	return value{}, fmt.Errorf("unknown expression %T", n)
+}
+
+// reduce reduces all the series to one series using the function on the
+// metrics of every timestamp.
+func reduce(r *reduceCall, ss []series) []series {
+	if len(ss) == 0 {
+		return nil
+	}
+
+	grouped := map[int64][]float64{}
+	times := map[int64]time.Time{}
+	for _, s := range ss {
+		for k, m := range s.metrics {
+			grouped[k] = append(grouped[k], m.Value)
+			times[k] = m.TS
+		}
+	}
+
+	res := series{id: r.String(), labels: map[string]string{}, metrics: map[int64]model.Metric{}}
+	for k, vs := range grouped {
+		v := vs[0]
+		for _, x := range vs[1:] {
+			switch r.fn {
+			case "sum", "avg":
+				v += x
+			case "max":
+				v = math.Max(v, x)
+			case "min":
+				v = math.Min(v, x)
+			}
+		}
+		if r.fn == "avg" {
+			v = v / float64(len(vs))
+		}
+		res.metrics[k] = model.Metric{TS: times[k], Value: v}
+	}
+
+	return []series{res}
+}
+
+// binaryOp applies the operation to the values. The series are matched
+// one-to-one when both sides have one series, if only one side has one
+// series it will be used with all the series of the other side, otherwise
+// the series are matched by the values of the labels they have in common.
+// Only the timestamps that are on both series are used.
+func binaryOp(op string, lhs, rhs value) value {
+	switch {
+	case lhs.isScalar && rhs.isScalar:
+		return value{isScalar: true, scalar: arithmetic(op, lhs.scalar, rhs.scalar)}
+
+	case rhs.isScalar:
+		res := make([]series, 0, len(lhs.series))
+		for _, s := range lhs.series {
+			res = append(res, mapSeries(s, func(v float64) float64 { return arithmetic(op, v, rhs.scalar) }))
+		}
+		return value{series: res}
+
+	case lhs.isScalar:
+		res := make([]series, 0, len(rhs.series))
+		for _, s := range rhs.series {
+			res = append(res, mapSeries(s, func(v float64) float64 { return arithmetic(op, lhs.scalar, v) }))
+		}
+		return value{series: res}
+	}
+
+	res := []series{}
+	switch {
+	case len(lhs.series) == 1 && len(rhs.series) == 1:
+		res = append(res, combineSeries(op, lhs.series[0], rhs.series[0], lhs.series[0]))
+
+	case len(rhs.series) == 1:
+		for _, l := range lhs.series {
+			res = append(res, combineSeries(op, l, rhs.series[0], l))
+		}
+
+	case len(lhs.series) == 1:
+		for _, r := range rhs.series {
+			res = append(res, combineSeries(op, lhs.series[0], r, r))
+		}
+
+	default:
+		for _, l := range lhs.series {
+			for _, r := range rhs.series {
+				if sharedLabelsMatch(l.labels, r.labels) {
+					res = append(res, combineSeries(op, l, r, l))
+					break
+				}
+			}
+		}
+	}
+
+	return value{series: res}
+}
+
+func mapSeries(s series, fn func(float64) float64) series {
+	res := series{id: s.id, labels: s.labels, metrics: make(map[int64]model.Metric, len(s.metrics))}
+	for k, m := range s.metrics {
+		res.metrics[k] = model.Metric{TS: m.TS, Value: fn(m.Value)}
+	}
+
+	return res
+}
+
+// combineSeries applies the operation to the metrics of the same timestamp,
+// the result has the ID and the labels of the owner series.
+func combineSeries(op string, l, r, owner series) series {
+	res := series{id: owner.id, labels: owner.labels, metrics: map[int64]model.Metric{}}
+	for k, lm := range l.metrics {
+		rm, ok := r.metrics[k]
+		if !ok {
+			continue
+		}
+		res.metrics[k] = model.Metric{TS: lm.TS, Value: arithmetic(op, lm.Value, rm.Value)}
+	}
+
+	return res
+}
+
+// sharedLabelsMatch returns true if the labels that are on both sets have the
+// same values, the metric name is ignored.
+func sharedLabelsMatch(l, r map[string]string) bool {
+	for k, lv := range l {
+		if k == nameLabel {
+			continue
+		}
+		if rv, ok := r[k]; ok && rv != lv {
+			return false
+		}
+	}
+	return true
+}
+
+func arithmetic(op string, l, r float64) float64 {
+	switch op {
+	case "+":
+		return l + r
+	case "-":
+		return l - r
+	case "*":
+		return l * r
+	case "/":
+		return l / r
+	case "%":
+		return math.Mod(l, r)
+	}
+
+	return math.NaN()
+}
@@ -0,0 +1,220 @@
+package expression_test
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/mock"
+	"github.com/stretchr/testify/require"
+
+	mmetric "github.com/slok/grafterm/internal/mocks/service/metric"
+	"github.com/slok/grafterm/internal/model"
+	"github.com/slok/grafterm/internal/service/metric/expression"
+)
+
+var t0 = time.Date(2019, 5, 12, 9, 0, 0, 0, time.UTC)
+
+// ts returns the timestamp of the minute plus the seconds.
+func ts(minute, second int) time.Time {
+	return t0.Add(time.Duration(minute)*time.Minute + time.Duration(second)*time.Second)
+}
+
+var (
+	queryA = model.Query{DatasourceID: "prometheus", Expr: `sum(rate(http_requests_total{code=~"5.."}[1m])) by (job)`}
+	queryB = model.Query{DatasourceID: "graphite", Expr: `sumSeries(api.requests.*)`}
+	queryC = model.Query{DatasourceID: "prometheus", Expr: `sum(rate(http_requests_total[1m])) by (job)`}
+)
+
+func TestGathererGatherRange(t *testing.T) {
+	// Series of different backends with metrics not aligned to the steps.
+	errorsA := []model.MetricSeries{
+		{
+			ID:      `{job="api"}`,
+			Labels:  map[string]string{"job": "api"},
+			Metrics: []model.Metric{{TS: ts(0, 0), Value: 2}, {TS: ts(1, 0), Value: 4}, {TS: ts(2, 0), Value: 6}},
+		},
+		{
+			ID:      `{job="web"}`,
+			Labels:  map[string]string{"job": "web"},
+			Metrics: []model.Metric{{TS: ts(0, 0), Value: 1}, {TS: ts(1, 0), Value: 1}, {TS: ts(2, 0), Value: 1}},
+		},
+	}
+	requestsB := []model.MetricSeries{
+		{
+			ID:      "sumSeries(api.requests.*)",
+			Labels:  map[string]string{"target": "sumSeries(api.requests.*)"},
+			Metrics: []model.Metric{{TS: ts(0, 10), Value: 20}, {TS: ts(0, 55), Value: 40}, {TS: ts(2, 5), Value: 28}},
+		},
+	}
+	requestsC := []model.MetricSeries{
+		{
+			ID:      `{job="web"}`,
+			Labels:  map[string]string{"job": "web"},
+			Metrics: []model.Metric{{TS: ts(0, 0), Value: 10}, {TS: ts(1, 0), Value: 10}},
+		},
+		{
+			ID:      `{job="api"}`,
+			Labels:  map[string]string{"job": "api"},
+			Metrics: []model.Metric{{TS: ts(0, 0), Value: 20}, {TS: ts(1, 0), Value: 20}, {TS: ts(2, 0), Value: 20}},
+		},
+	}
+
+	tests := map[string]struct {
+		expr      string
+		mock      func(m *mmetric.Gatherer)
+		expSeries []model.MetricSeries
+		expErr    bool
+	}{
+		"A reduced query divided by a query of other datasource should align the metrics to the steps.": {
+			expr: `sum($A) / $B * 100`,
+			mock: func(m *mmetric.Gatherer) {
+				m.On("GatherRange", mock.Anything, queryA, ts(0, 0), ts(2, 0), time.Minute).Once().Return(errorsA, nil)
+				m.On("GatherRange", mock.Anything, queryB, ts(0, 0), ts(2, 0), time.Minute).Once().Return(requestsB, nil)
+			},
+			expSeries: []model.MetricSeries{
+				{
+					ID:      "sum($A)",
+					Labels:  map[string]string{},
+					Metrics: []model.Metric{{TS: ts(0, 0), Value: 15}, {TS: ts(1, 0), Value: 12.5}, {TS: ts(2, 0), Value: 25}},
+				},
+			},
+		},
+		"Queries with multiple series should be matched by labels.": {
+			expr: `$A / $C`,
+			mock: func(m *mmetric.Gatherer) {
+				m.On("GatherRange", mock.Anything, queryA, ts(0, 0), ts(2, 0), time.Minute).Once().Return(errorsA, nil)
+				m.On("GatherRange", mock.Anything, queryC, ts(0, 0), ts(2, 0), time.Minute).Once().Return(requestsC, nil)
+			},
+			expSeries: []model.MetricSeries{
+				{
+					ID:      `{job="api"}`,
+					Labels:  map[string]string{"job": "api"},
+					Metrics: []model.Metric{{TS: ts(0, 0), Value: 0.1}, {TS: ts(1, 0), Value: 0.2}, {TS: ts(2, 0), Value: 0.3}},
+				},
+				{
+					ID:      `{job="web"}`,
+					Labels:  map[string]string{"job": "web"},
+					Metrics: []model.Metric{{TS: ts(0, 0), Value: 0.1}, {TS: ts(1, 0), Value: 0.1}},
+				},
+			},
+		},
+		"Reduce functions should reduce the series on every step.": {
+			expr: `max($A) - min($A) + avg($C)`,
+			mock: func(m *mmetric.Gatherer) {
+				m.On("GatherRange", mock.Anything, queryA, ts(0, 0), ts(2, 0), time.Minute).Once().Return(errorsA, nil)
+				m.On("GatherRange", mock.Anything, queryC, ts(0, 0), ts(2, 0), time.Minute).Once().Return(requestsC, nil)
+			},
+			expSeries: []model.MetricSeries{
+				{
+					ID:      "max($A)",
+					Labels:  map[string]string{},
+					Metrics: []model.Metric{{TS: ts(0, 0), Value: 16}, {TS: ts(1, 0), Value: 18}, {TS: ts(2, 0), Value: 25}},
+				},
+			},
+		},
+		"A scalar expression should return a metric on every step.": {
+			expr: `(1 + 2) * -2`,
+			mock: func(m *mmetric.Gatherer) {},
+			expSeries: []model.MetricSeries{
+				{Metrics: []model.Metric{{TS: ts(0, 0), Value: -6}, {TS: ts(1, 0), Value: -6}, {TS: ts(2, 0), Value: -6}}},
+			},
+		},
+		"A query that is not defined should error.": {
+			expr:   `$A / $Z`,
+			mock:   func(m *mmetric.Gatherer) {},
+			expErr: true,
+		},
+		"An invalid expression should error.": {
+			expr:   `$A / rate($B)`,
+			mock:   func(m *mmetric.Gatherer) {},
+			expErr: true,
+		},
+		"A failed query should error.": {
+			expr: `$A / $B`,
+			mock: func(m *mmetric.Gatherer) {
+				m.On("GatherRange", mock.Anything, queryA, ts(0, 0), ts(2, 0), time.Minute).Once().Return(errorsA, nil)
+				m.On("GatherRange", mock.Anything, queryB, ts(0, 0), ts(2, 0), time.Minute).Once().Return(nil, errors.New("wanted error"))
+			},
+			expErr: true,
+		},
+	}
+
+	for name, test := range tests {
+		test := test
+		t.Run(name, func(t *testing.T) {
+			assert := assert.New(t)
+			require := require.New(t)
+
+			mg := &mmetric.Gatherer{}
+			test.mock(mg)
+
+			g, err := expression.NewGatherer(expression.ConfigGatherer{
+				ID:       "expr",
+				Queries:  map[string]model.Query{"A": queryA, "B": queryB, "C": queryC},
+				Gatherer: mg,
+			})
+			require.NoError(err)
+
+			gotSeries, err := g.GatherRange(context.TODO(), model.Query{DatasourceID: "expr", Expr: test.expr}, ts(0, 0), ts(2, 0), time.Minute)
+			if test.expErr {
+				assert.Error(err)
+				return
+			}
+
+			if assert.NoError(err) {
+				assert.ElementsMatch(test.expSeries, gotSeries)
+				mg.AssertExpectations(t)
+			}
+		})
+	}
+}
+
+func TestGathererGatherSingle(t *testing.T) {
+	assert := assert.New(t)
+	require := require.New(t)
+
+	mg := &mmetric.Gatherer{}
+	mg.On("GatherSingle", mock.Anything, queryA, ts(5, 0)).Once().Return([]model.MetricSeries{
+		{ID: "a", Metrics: []model.Metric{{TS: ts(4, 30), Value: 5}}},
+	}, nil)
+	mg.On("GatherSingle", mock.Anything, queryB, ts(5, 0)).Once().Return([]model.MetricSeries{
+		{ID: "b", Metrics: []model.Metric{{TS: ts(4, 50), Value: 20}}},
+	}, nil)
+
+	g, err := expression.NewGatherer(expression.ConfigGatherer{
+		ID:       "expr",
+		Queries:  map[string]model.Query{"A": queryA, "B": queryB},
+		Gatherer: mg,
+	})
+	require.NoError(err)
+
+	gotSeries, err := g.GatherSingle(context.TODO(), model.Query{DatasourceID: "expr", Expr: "$A / $B"}, ts(5, 0))
+	expSeries := []model.MetricSeries{
+		{ID: "a", Metrics: []model.Metric{{TS: ts(5, 0), Value: 0.25}}},
+	}
+	if assert.NoError(err) {
+		assert.Equal(expSeries, gotSeries)
+	}
+}
+
+func TestGathererReferencingItself(t *testing.T) {
+	mg := &mmetric.Gatherer{}
+	g, err := expression.NewGatherer(expression.ConfigGatherer{
+		ID:       "expr",
+		Queries:  map[string]model.Query{"A": {DatasourceID: "expr", Expr: "$A"}},
+		Gatherer: mg,
+	})
+	require.NoError(t, err)
+
+	// The mock gatherer calls the expression gatherer like the multi datasource gatherer.
+	mg.On("GatherSingle", mock.Anything, mock.Anything, t0).Return(nil, nil).Run(func(args mock.Arguments) {
+		_, err := g.GatherSingle(args.Get(0).(context.Context), args.Get(1).(model.Query), t0)
+		assert.Error(t, err)
+	})
+
+	_, err = g.GatherSingle(context.TODO(), model.Query{DatasourceID: "expr", Expr: "$A"}, t0)
+	assert.NoError(t, err)
+}
@@ -0,0 +1,287 @@
+package expression
+
+import (
+	"fmt"
+	"strconv"
+	"strings"
+)
+
+// The expression nodes.
+type (
+	node interface{}
+
+	numberLiteral struct {
+		value float64
+	}
+
+	queryRef struct {
+		name string
+	}
+
+	reduceCall struct {
+		fn  string
+		arg node
+	}
+
+	binaryExpr struct {
+		op       string
+		lhs, rhs node
+	}
+)
+
+// reduceFunctions are the functions that reduce all the series of an
+// expression to one series.
+var reduceFunctions = map[string]bool{"sum": true, "avg": true, "max": true, "min": true}
+
+var binaryPrecedence = map[string]int{"+": 1, "-": 1, "*": 2, "/": 2, "%": 2}
+
+type tokenKind int
+
+const (
+	tokenEOF tokenKind = iota
+	tokenRef
+	tokenIdent
+	tokenNumber
+	tokenOp
+)
+
+type token struct {
+	kind tokenKind
+	val  string
+	pos  int
+}
+
+func isIdentChar(c byte, first bool) bool {
+	switch {
+	case c >= 'a' && c <= 'z', c >= 'A' && c <= 'Z', c == '_':
+		return true
+	case c >= '0' && c <= '9':
+		return !first
+	}
+	return false
+}
+
+// lex splits the expression in tokens.
+func lex(expr string) ([]token, error) {
+	tokens := []token{}
+	i := 0
+	for i < len(expr) {
+		c := expr[i]
+		switch {
+		case c == ' ' || c == '\t' || c == '\n' || c == '\r':
+			i++
+
+		case c == '$':
+			st := i
+			i++
+			for i < len(expr) && isIdentChar(expr[i], i == st+1) {
+				i++
+			}
+			if i == st+1 {
+				return nil, fmt.Errorf("missing query name at position %d", st)
+			}
+			tokens = append(tokens, token{kind: tokenRef, val: expr[st+1 : i], pos: st})
+
+		case isIdentChar(c, true):
+			st := i
+			for i < len(expr) && isIdentChar(expr[i], false) {
+				i++
+			}
+			tokens = append(tokens, token{kind: tokenIdent, val: expr[st:i], pos: st})
+
+		case (c >= '0' && c <= '9') || c == '.':
+			st := i
+			for i < len(expr) && ((expr[i] >= '0' && expr[i] <= '9') || expr[i] == '.') {
+				i++
+			}
+			if i < len(expr) && (expr[i] == 'e' || expr[i] == 'E') {
+				i++
+				if i < len(expr) && (expr[i] == '+' || expr[i] == '-') {
+					i++
+				}
+				for i < len(expr) && expr[i] >= '0' && expr[i] <= '9' {
+					i++
+				}
+			}
+			tokens = append(tokens, token{kind: tokenNumber, val: expr[st:i], pos: st})
+
+		case strings.ContainsRune("()+-*/%", rune(c)):
+			tokens = append(tokens, token{kind: tokenOp, val: string(c), pos: i})
+			i++
+
+		default:
+			return nil, fmt.Errorf("unexpected character %q at position %d", c, i)
+		}
+	}
+
+	tokens = append(tokens, token{kind: tokenEOF, pos: len(expr)})
+	return tokens, nil
+}
+
+// parser is a recursive descent parser of the expressions: query references
+// (`$A`), numbers, `+`, `-`, `*`, `/` and `%` arithmetic, parentheses and the
+// `sum`, `avg`, `max` and `min` reduce functions.
+type parser struct {
+	tokens []token
+	pos    int
+}
+
+// parseExpr parses an expression.
+func parseExpr(expr string) (node, error) {
+	tokens, err := lex(expr)
+	if err != nil {
+		return nil, err
+	}
+
+	p := &parser{tokens: tokens}
+	n, err := p.parseBinary(0)
+	if err != nil {
+		return nil, err
+	}
+	if t := p.peek(); t.kind != tokenEOF {
+		return nil, fmt.Errorf("unexpected %q at position %d", t.val, t.pos)
+	}
+
+	return n, nil
+}
+
+func (p *parser) peek() token {
+	return p.tokens[p.pos]
+}
+
+func (p *parser) next() token {
+	t := p.tokens[p.pos]
+	if t.kind != tokenEOF {
+		p.pos++
+	}
+	return t
+}
+
+func (p *parser) expectOp(op string) error {
+	t := p.next()
+	if t.kind != tokenOp || t.val != op {
+		return fmt.Errorf("expected %q at position %d", op, t.pos)
+	}
+	return nil
+}
+
+func (p *parser) parseBinary(minPrec int) (node, error) {
+	lhs, err := p.parseUnary()
+	if err != nil {
+		return nil, err
+	}
+
+	for {
+		t := p.peek()
+		prec, ok := binaryPrecedence[t.val]
+		if t.kind != tokenOp || !ok || prec <= minPrec {
+			return lhs, nil
+		}
+		p.next()
+
+		rhs, err := p.parseBinary(prec)
+		if err != nil {
+			return nil, err
+		}
+		lhs = &binaryExpr{op: t.val, lhs: lhs, rhs: rhs}
+	}
+}
+
+func (p *parser) parseUnary() (node, error) {
+	t := p.peek()
+	if t.kind == tokenOp && (t.val == "-" || t.val == "+") {
+		p.next()
+		n, err := p.parseUnary()
+		if err != nil {
+			return nil, err
+		}
+		if t.val == "+" {
+			return n, nil
+		}
+		return &binaryExpr{op: "*", lhs: &numberLiteral{value: -1}, rhs: n}, nil
+	}
+
+	return p.parsePrimary()
+}
+
+func (p *parser) parsePrimary() (node, error) {
+	t := p.next()
+	switch t.kind {
+	case tokenNumber:
+		v, err := strconv.ParseFloat(t.val, 64)
+		if err != nil {
+			return nil, fmt.Errorf("invalid number %q at position %d", t.val, t.pos)
+		}
+		return &numberLiteral{value: v}, nil
+
+	case tokenRef:
+		return &queryRef{name: t.val}, nil
+
+	case tokenIdent:
+		if !reduceFunctions[t.val] {
+			return nil, fmt.Errorf("function %q not supported", t.val)
+		}
+		if err := p.expectOp("("); err != nil {
+			return nil, err
+		}
+		arg, err := p.parseBinary(0)
+		if err != nil {
+			return nil, err
+		}
+		if err := p.expectOp(")"); err != nil {
+			return nil, err
+		}
+		return &reduceCall{fn: t.val, arg: arg}, nil
+
+	case tokenOp:
+		if t.val == "(" {
+			n, err := p.parseBinary(0)
+			if err != nil {
+				return nil, err
+			}
+			if err := p.expectOp(")"); err != nil {
+				return nil, err
+			}
+			return n, nil
+		}
+	}
+
+	if t.kind == tokenEOF {
+		return nil, fmt.Errorf("unexpected end of expression")
+	}
+	return nil, fmt.Errorf("unexpected %q at position %d", t.val, t.pos)
+}
+
+// queryRefs returns the names of the queries referenced on the expression.
+func queryRefs(n node) []string {
+	switch v := n.(type) {
+	case *queryRef:
+		return []string{v.name}
+	case *reduceCall:
+		return queryRefs(v.arg)
+	case *binaryExpr:
+		return append(queryRefs(v.lhs), queryRefs(v.rhs)...)
+	}
+
+	return nil
+}
+
+// String returns the expression of the reduce call (e.g `sum($A)`).
+func (r *reduceCall) String() string {
+	return r.fn + "(" + nodeString(r.arg) + ")"
+}
+
+func nodeString(n node) string {
+	switch v := n.(type) {
+	case *numberLiteral:
+		return strconv.FormatFloat(v.value, 'f', -1, 64)
+	case *queryRef:
+		return "$" + v.name
+	case *reduceCall:
+		return v.String()
+	case *binaryExpr:
+		return "(" + nodeString(v.lhs) + " " + v.op + " " + nodeString(v.rhs) + ")"
+	}
+
+	return ""
+}
@@ -53,6 +53,11 @@ func (g Gatherer) GatherRange(ctx context.Context, query model.Query, start, end
 	return series, nil
 }
 
+// DiscoverValues satisfies metric.Discoverer interface.
+func (g Gatherer) DiscoverValues(_ context.Context, _ model.Query) ([]string, error) {
+	return []string{"fake-0", "fake-1", "fake-2"}, nil
+}
+
 func generateMetrics(offset int, start, end time.Time, step time.Duration) []model.Metric {
 	metrics := []model.Metric{}
 	for i := 1; ; i++ {
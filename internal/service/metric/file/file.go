@@ -0,0 +1,446 @@
+package file
+
+import (
+	"context"
+	"encoding/csv"
+	"encoding/json"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"regexp"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/slok/grafterm/internal/model"
+	"github.com/slok/grafterm/internal/service/metric"
+)
+
+const (
+	// columnLabelKey is the label that has the column name of the CSV series.
+	columnLabelKey = "column"
+)
+
+// timestampColumns are the names of the CSV timestamp column, if none is
+// present the first column will be used.
+var timestampColumns = map[string]bool{"timestamp": true, "time": true, "ts": true}
+
+// ConfigGatherer is the configuration of the file gatherer.
+type ConfigGatherer struct {
+	// Directory is the directory used to resolve the relative paths of the
+	// queries, by default the working directory. Only the files inside the
+	// directory can be read.
+	Directory string
+}
+
+type gatherer struct {
+	cfg ConfigGatherer
+
+	mu    sync.Mutex
+	files map[string]*loadedFile
+}
+
+// loadedFile is a file that has been read, the modification time and
+// the size are used to know if the file has changed.
+type loadedFile struct {
+	modTime time.Time
+	size    int64
+	series  []model.MetricSeries
+}
+
+// NewGatherer returns a new metric gatherer that reads the series from CSV
+// and JSON files. The files are read again when they change.
+func NewGatherer(cfg ConfigGatherer) metric.Gatherer {
+	return &gatherer{
+		cfg:   cfg,
+		files: map[string]*loadedFile{},
+	}
+}
+
+// GatherSingle returns the latest metric until the time of each series.
+func (g *gatherer) GatherSingle(ctx context.Context, query model.Query, t time.Time) ([]model.MetricSeries, error) {
+	series, err := g.series(query.Expr)
+	if err != nil {
+		return []model.MetricSeries{}, err
+	}
+
+	res := []model.MetricSeries{}
+	for _, s := range series {
+		i := sort.Search(len(s.Metrics), func(i int) bool {
+			return s.Metrics[i].TS.After(t)
+		})
+		if i == 0 {
+			continue
+		}
+
+		s.Metrics = []model.Metric{s.Metrics[i-1]}
+		res = append(res, s)
+	}
+
+	return res, nil
+}
+
+// GatherRange returns the metrics of the series in the time range. If the step
+// is set the metrics will be grouped in buckets of the step using the average,
+// every bucket ends on a `start + N*step` timestamp.
+func (g *gatherer) GatherRange(ctx context.Context, query model.Query, start, end time.Time, step time.Duration) ([]model.MetricSeries, error) {
+	series, err := g.series(query.Expr)
+	if err != nil {
+		return []model.MetricSeries{}, err
+	}
+
+	res := []model.MetricSeries{}
+	for _, s := range series {
+		metrics := []model.Metric{}
+		for _, m := range s.Metrics {
+			if m.TS.Before(start) || m.TS.After(end) {
+				continue
+			}
+			metrics = append(metrics, m)
+		}
+
+		if step > 0 {
+			metrics = bucket(metrics, start, step)
+		}
+
+		if len(metrics) > 0 {
+			s.Metrics = metrics
+			res = append(res, s)
+		}
+	}
+
+	return res, nil
+}
+
+// bucket groups the sorted metrics in buckets of the step using the average.
+func bucket(metrics []model.Metric, start time.Time, step time.Duration) []model.Metric {
+	res := []model.Metric{}
+	count := 0
+	for _, m := range metrics {
+		// The bucket that ends on the first `start + N*step` not before the metric.
+		n := (m.TS.Sub(start) + step - 1) / step
+		ts := start.Add(n * step)
+
+		last := len(res) - 1
+		if last >= 0 && res[last].TS.Equal(ts) {
+			count++
+			res[last].Value += (m.Value - res[last].Value) / float64(count)
+			continue
+		}
+
+		count = 1
+		res = append(res, model.Metric{TS: ts, Value: m.Value})
+	}
+
+	return res
+}
+
+// series returns the series of the file selected by the query expression
+// that match the label matchers.
+func (g *gatherer) series(expr string) ([]model.MetricSeries, error) {
+	path, matchers, err := parseExpr(expr)
+	if err != nil {
+		return nil, err
+	}
+
+	path, err = g.resolve(path)
+	if err != nil {
+		return nil, err
+	}
+
+	series, err := g.load(path)
+	if err != nil {
+		return nil, err
+	}
+
+	res := []model.MetricSeries{}
+	for _, s := range series {
+		if matchLabels(matchers, s.Labels) {
+			res = append(res, s)
+		}
+	}
+
+	return res, nil
+}
+
+// resolve returns the path of the file resolved from the directory. The
+// dashboards can be shared, so the paths (absolute or relative) that are
+// outside the directory are rejected to not read any file of the host.
+func (g *gatherer) resolve(path string) (string, error) {
+	dir, err := filepath.Abs(g.cfg.Directory)
+	if err != nil {
+		return "", err
+	}
+
+	if !filepath.IsAbs(path) {
+		path = filepath.Join(dir, path)
+	}
+	path = filepath.Clean(path)
+
+	rel, err := filepath.Rel(dir, path)
+	if err != nil || rel == ".." || strings.HasPrefix(rel, ".."+string(filepath.Separator)) {
+		return "", fmt.Errorf("file %s is outside the %s directory", path, dir)
+	}
+
+	return path, nil
+}
+
+// load returns the series of the file, the file is only read if it has
+// changed since the last time.
+func (g *gatherer) load(path string) ([]model.MetricSeries, error) {
+	fi, err := os.Stat(path)
+	if err != nil {
+		return nil, fmt.Errorf("could not stat file: %w", err)
+	}
+
+	g.mu.Lock()
+	defer g.mu.Unlock()
+
+	lf, ok := g.files[path]
+	if ok && lf.modTime.Equal(fi.ModTime()) && lf.size == fi.Size() {
+		return lf.series, nil
+	}
+
+	f, err := os.Open(path)
+	if err != nil {
+		return nil, fmt.Errorf("could not open file: %w", err)
+	}
+	defer f.Close()
+
+	var series []model.MetricSeries
+	switch strings.ToLower(filepath.Ext(path)) {
+	case ".csv":
+		series, err = readCSV(f)
+	case ".json":
+		series, err = readJSON(f)
+	default:
+		return nil, fmt.Errorf("unsupported file format %q, only .csv and .json are supported", filepath.Ext(path))
+	}
+	if err != nil {
+		return nil, fmt.Errorf("could not read %s: %w", path, err)
+	}
+
+	for _, s := range series {
+		sort.SliceStable(s.Metrics, func(i, j int) bool { return s.Metrics[i].TS.Before(s.Metrics[j].TS) })
+	}
+
+	g.files[path] = &loadedFile{
+		modTime: fi.ModTime(),
+		size:    fi.Size(),
+		series:  series,
+	}
+
+	return series, nil
+}
+
+// readCSV reads a CSV with a header, a timestamp column and a column per
+// series. The empty and the invalid values are ignored.
+func readCSV(r io.Reader) ([]model.MetricSeries, error) {
+	cr := csv.NewReader(r)
+	cr.FieldsPerRecord = -1
+	cr.TrimLeadingSpace = true
+
+	header, err := cr.Read()
+	if err != nil {
+		return nil, fmt.Errorf("could not read header: %w", err)
+	}
+
+	tsCol := 0
+	for i, h := range header {
+		if timestampColumns[strings.ToLower(strings.TrimSpace(h))] {
+			tsCol = i
+			break
+		}
+	}
+
+	series := []model.MetricSeries{}
+	cols := map[int]int{}
+	for i, h := range header {
+		if i == tsCol {
+			continue
+		}
+		h = strings.TrimSpace(h)
+		cols[i] = len(series)
+		series = append(series, model.MetricSeries{
+			ID:     h,
+			Labels: map[string]string{columnLabelKey: h},
+		})
+	}
+
+	line := 1
+	for {
+		record, err := cr.Read()
+		if err == io.EOF {
+			break
+		}
+		line++
+		if err != nil {
+			return nil, err
+		}
+		if tsCol >= len(record) {
+			continue
+		}
+
+		ts, err := metric.ParseTimestamp(record[tsCol])
+		if err != nil {
+			return nil, fmt.Errorf("invalid timestamp on line %d: %w", line, err)
+		}
+
+		for i, v := range record {
+			si, ok := cols[i]
+			if !ok {
+				continue
+			}
+			f, err := strconv.ParseFloat(strings.TrimSpace(v), 64)
+			if err != nil {
+				continue
+			}
+			series[si].Metrics = append(series[si].Metrics, model.Metric{TS: ts, Value: f})
+		}
+	}
+
+	return series, nil
+}
+
+// jsonSeries is the JSON file series, it's the same format of the `query`
+// command JSON output.
+type jsonSeries struct {
+	ID      string            `json:"id"`
+	Labels  map[string]string `json:"labels"`
+	Metrics []struct {
+		TS    time.Time `json:"timestamp"`
+		Value *float64  `json:"value"`
+	} `json:"metrics"`
+}
+
+// readJSON reads a JSON array of series, the null values are ignored.
+func readJSON(r io.Reader) ([]model.MetricSeries, error) {
+	jss := []jsonSeries{}
+	err := json.NewDecoder(r).Decode(&jss)
+	if err != nil {
+		return nil, err
+	}
+
+	series := make([]model.MetricSeries, 0, len(jss))
+	for _, js := range jss {
+		s := model.MetricSeries{
+			ID:     js.ID,
+			Labels: js.Labels,
+		}
+		for _, m := range js.Metrics {
+			if m.Value == nil {
+				continue
+			}
+			s.Metrics = append(s.Metrics, model.Metric{TS: m.TS, Value: *m.Value})
+		}
+		series = append(series, s)
+	}
+
+	return series, nil
+}
+
+var matcherRegexp = regexp.MustCompile(`^\s*([a-zA-Z_][a-zA-Z0-9_]*)\s*(=~|!~|!=|=)\s*"((?:[^"\\]|\\.)*)"\s*$`)
+
+// matcher is a label matcher of the query expression.
+type matcher struct {
+	name  string
+	op    string
+	value string
+	re    *regexp.Regexp
+}
+
+func (m matcher) matches(v string) bool {
+	switch m.op {
+	case "=":
+		return v == m.value
+	case "!=":
+		return v != m.value
+	case "=~":
+		return m.re.MatchString(v)
+	case "!~":
+		return !m.re.MatchString(v)
+	}
+	return false
+}
+
+// parseExpr parses the query expression in the form of `path{label="value"}`,
+// the label matchers are optional and can use `=`, `!=`, `=~` and `!~`.
+func parseExpr(expr string) (string, []matcher, error) {
+	expr = strings.TrimSpace(expr)
+
+	i := strings.Index(expr, "{")
+	if i < 0 {
+		if expr == "" {
+			return "", nil, fmt.Errorf("query file path can't be empty")
+		}
+		return expr, nil, nil
+	}
+
+	path := strings.TrimSpace(expr[:i])
+	if path == "" {
+		return "", nil, fmt.Errorf("query file path can't be empty")
+	}
+	if !strings.HasSuffix(expr, "}") {
+		return "", nil, fmt.Errorf("unclosed label matchers on %q", expr)
+	}
+
+	matchers := []matcher{}
+	for _, part := range splitMatchers(expr[i+1 : len(expr)-1]) {
+		if strings.TrimSpace(part) == "" {
+			continue
+		}
+
+		sm := matcherRegexp.FindStringSubmatch(part)
+		if sm == nil {
+			return "", nil, fmt.Errorf("invalid label matcher %q", strings.TrimSpace(part))
+		}
+
+		value, err := strconv.Unquote(`"` + sm[3] + `"`)
+		if err != nil {
+			return "", nil, fmt.Errorf("invalid label matcher value %q: %w", sm[3], err)
+		}
+
+		m := matcher{name: sm[1], op: sm[2], value: value}
+		if m.op == "=~" || m.op == "!~" {
+			m.re, err = regexp.Compile("^(?:" + value + ")$")
+			if err != nil {
+				return "", nil, fmt.Errorf("invalid regex on %s matcher: %w", m.name, err)
+			}
+		}
+		matchers = append(matchers, m)
+	}
+
+	return path, matchers, nil
+}
+
+// splitMatchers splits the matchers by the commas that are not quoted.
+func splitMatchers(s string) []string {
+	res := []string{}
+	quoted := false
+	st := 0
+	for i := 0; i < len(s); i++ {
+		switch {
+		case s[i] == '\\' && quoted:
+			i++
+		case s[i] == '"':
+			quoted = !quoted
+		case s[i] == ',' && !quoted:
+			res = append(res, s[st:i])
+			st = i + 1
+		}
+	}
+
+	return append(res, s[st:])
+}
+
+func matchLabels(matchers []matcher, ls map[string]string) bool {
+	for _, m := range matchers {
+		if !m.matches(ls[m.name]) {
+			return false
+		}
+	}
+	return true
+}
@@ -0,0 +1,252 @@
+package file_test
+
+import (
+	"context"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/slok/grafterm/internal/model"
+	"github.com/slok/grafterm/internal/service/metric/file"
+)
+
+var start = time.Date(2019, 5, 19, 14, 0, 0, 0, time.UTC)
+
+func ts(minute int) time.Time {
+	return start.Add(time.Duration(minute) * time.Minute)
+}
+
+const benchCSV = `timestamp,p50,p99
+2019-05-19T14:00:00Z,10,100
+2019-05-19T14:01:00Z,20,
+1558274520,30,300
+1558274580000,40,400
+`
+
+const dumpJSON = `[
+  {
+    "id": "{host=\"a\"}",
+    "labels": {"host": "a"},
+    "metrics": [
+      {"timestamp": "2019-05-19T14:01:00Z", "value": 2},
+      {"timestamp": "2019-05-19T14:00:00Z", "value": 1},
+      {"timestamp": "2019-05-19T14:02:00Z", "value": null}
+    ]
+  },
+  {
+    "id": "{host=\"b\"}",
+    "labels": {"host": "b"},
+    "metrics": [
+      {"timestamp": "2019-05-19T14:00:00Z", "value": 5}
+    ]
+  }
+]`
+
+func writeFile(t *testing.T, dir, name, content string) {
+	require.NoError(t, os.WriteFile(filepath.Join(dir, name), []byte(content), 0644))
+}
+
+func TestGathererGatherRange(t *testing.T) {
+	tests := map[string]struct {
+		expr      string
+		start     time.Time
+		end       time.Time
+		step      time.Duration
+		expSeries []model.MetricSeries
+		expErr    bool
+	}{
+		"A CSV file should return a series per column in the time range.": {
+			expr:  "bench.csv",
+			start: ts(1),
+			end:   ts(2),
+			expSeries: []model.MetricSeries{
+				{
+					ID:      "p50",
+					Labels:  map[string]string{"column": "p50"},
+					Metrics: []model.Metric{{TS: ts(1), Value: 20}, {TS: ts(2), Value: 30}},
+				},
+				{
+					ID:      "p99",
+					Labels:  map[string]string{"column": "p99"},
+					Metrics: []model.Metric{{TS: ts(2), Value: 300}},
+				},
+			},
+		},
+		"A CSV file with a column matcher and a step should return the bucketed column.": {
+			expr:  `bench.csv{column="p50"}`,
+			start: ts(0),
+			end:   ts(3),
+			step:  2 * time.Minute,
+			expSeries: []model.MetricSeries{
+				{
+					ID:     "p50",
+					Labels: map[string]string{"column": "p50"},
+					Metrics: []model.Metric{
+						{TS: ts(0), Value: 10},
+						{TS: ts(2), Value: 25},
+						{TS: ts(4), Value: 40},
+					},
+				},
+			},
+		},
+		"A JSON file with a label regex matcher should return the sorted matched series.": {
+			expr:  `dump.json{host=~"a|c"}`,
+			start: ts(0),
+			end:   ts(10),
+			expSeries: []model.MetricSeries{
+				{
+					ID:      `{host="a"}`,
+					Labels:  map[string]string{"host": "a"},
+					Metrics: []model.Metric{{TS: ts(0), Value: 1}, {TS: ts(1), Value: 2}},
+				},
+			},
+		},
+		"A missing file should error.": {
+			expr:   "missing.csv",
+			start:  ts(0),
+			end:    ts(10),
+			expErr: true,
+		},
+		"An unsupported file format should error.": {
+			expr:   "bench.txt",
+			start:  ts(0),
+			end:    ts(10),
+			expErr: true,
+		},
+		"An invalid label matcher should error.": {
+			expr:   `dump.json{host~"a"}`,
+			start:  ts(0),
+			end:    ts(10),
+			expErr: true,
+		},
+	}
+
+	for name, test := range tests {
+		test := test
+		t.Run(name, func(t *testing.T) {
+			assert := assert.New(t)
+
+			dir := t.TempDir()
+			writeFile(t, dir, "bench.csv", benchCSV)
+			writeFile(t, dir, "bench.txt", benchCSV)
+			writeFile(t, dir, "dump.json", dumpJSON)
+
+			g := file.NewGatherer(file.ConfigGatherer{Directory: dir})
+			gotSeries, err := g.GatherRange(context.TODO(), model.Query{Expr: test.expr}, test.start, test.end, test.step)
+			if test.expErr {
+				assert.Error(err)
+				return
+			}
+
+			if assert.NoError(err) {
+				for i := range gotSeries {
+					for j := range gotSeries[i].Metrics {
+						gotSeries[i].Metrics[j].TS = gotSeries[i].Metrics[j].TS.UTC()
+					}
+				}
+				assert.Equal(test.expSeries, gotSeries)
+			}
+		})
+	}
+}
+
+func TestGathererGatherSingle(t *testing.T) {
+	assert := assert.New(t)
+
+	dir := t.TempDir()
+	writeFile(t, dir, "dump.json", dumpJSON)
+
+	g := file.NewGatherer(file.ConfigGatherer{Directory: dir})
+	gotSeries, err := g.GatherSingle(context.TODO(), model.Query{Expr: "dump.json"}, ts(0).Add(30*time.Second))
+	expSeries := []model.MetricSeries{
+		{
+			ID:      `{host="a"}`,
+			Labels:  map[string]string{"host": "a"},
+			Metrics: []model.Metric{{TS: ts(0), Value: 1}},
+		},
+		{
+			ID:      `{host="b"}`,
+			Labels:  map[string]string{"host": "b"},
+			Metrics: []model.Metric{{TS: ts(0), Value: 5}},
+		},
+	}
+	if assert.NoError(err) {
+		assert.Equal(expSeries, gotSeries)
+	}
+}
+
+func TestGathererReadsChangedFile(t *testing.T) {
+	assert := assert.New(t)
+	require := require.New(t)
+
+	dir := t.TempDir()
+	path := filepath.Join(dir, "results.csv")
+	writeFile(t, dir, "results.csv", "ts,value\n1558274400,1\n")
+
+	g := file.NewGatherer(file.ConfigGatherer{Directory: dir})
+	q := model.Query{Expr: "results.csv"}
+
+	gotSeries, err := g.GatherSingle(context.TODO(), q, ts(10))
+	require.NoError(err)
+	require.Len(gotSeries, 1)
+	assert.Equal(1.0, gotSeries[0].Metrics[0].Value)
+
+	// Append a new result to the file.
+	f, err := os.OpenFile(path, os.O_APPEND|os.O_WRONLY, 0644)
+	require.NoError(err)
+	_, err = f.WriteString("1558274460,2\n")
+	require.NoError(err)
+	require.NoError(f.Close())
+
+	gotSeries, err = g.GatherSingle(context.TODO(), q, ts(10))
+	require.NoError(err)
+	require.Len(gotSeries, 1)
+	assert.Equal(2.0, gotSeries[0].Metrics[0].Value)
+}
+
+func TestGathererRejectsFilesOutsideDirectory(t *testing.T) {
+	root := t.TempDir()
+	dir := filepath.Join(root, "data")
+	require.NoError(t, os.Mkdir(dir, 0755))
+	writeFile(t, dir, "dump.json", dumpJSON)
+	writeFile(t, root, "secret.json", dumpJSON)
+
+	tests := map[string]struct {
+		expr   string
+		expErr bool
+	}{
+		"A relative path inside the directory should be read.": {
+			expr: "dump.json",
+		},
+		"An absolute path inside the directory should be read.": {
+			expr: filepath.Join(dir, "dump.json"),
+		},
+		"An absolute path outside the directory should be rejected.": {
+			expr:   filepath.Join(root, "secret.json"),
+			expErr: true,
+		},
+		"A relative path outside the directory should be rejected.": {
+			expr:   "../secret.json",
+			expErr: true,
+		},
+	}
+
+	for name, test := range tests {
+		test := test
+		t.Run(name, func(t *testing.T) {
+			assert := assert.New(t)
+
+			g := file.NewGatherer(file.ConfigGatherer{Directory: dir})
+			_, err := g.GatherSingle(context.TODO(), model.Query{Expr: test.expr}, ts(0))
+			if test.expErr {
+				assert.Error(err)
+			} else {
+				assert.NoError(err)
+			}
+		})
+	}
+}
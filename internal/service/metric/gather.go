@@ -2,6 +2,7 @@ package metric
 
 import (
 	"context"
+	"errors"
 	"time"
 
 	"github.com/slok/grafterm/internal/model"
@@ -17,9 +18,29 @@ type Gatherer interface {
 	GatherRange(ctx context.Context, query model.Query, start, end time.Time, step time.Duration) ([]model.MetricSeries, error)
 }
 
-// IdentifiableGatherer extends Gatherer with an ID for caching and tracking
-type IdentifiableGatherer interface {
-	Gatherer
-	// ID returns a unique identifier for this gatherer (typically the datasource ID)
-	ID() string
+// Discoverer knows how to discover the values available on the backends,
+// for example the values of a label, this is used to populate variables
+// dynamically from a datasource.
+type Discoverer interface {
+	// DiscoverValues returns the values that the backend returns for the query.
+	DiscoverValues(ctx context.Context, query model.Query) ([]string, error)
+}
+
+// ErrMetadataNotSupported is the error returned when the gatherer doesn't
+// support the metrics metadata.
+var ErrMetadataNotSupported = errors.New("metrics metadata not supported")
+
+// MetadataGatherer knows how to gather the metadata of the metrics used on a
+// query, this is used to infer the representation of the values (e.g the unit)
+// when the dashboard doesn't set it.
+type MetadataGatherer interface {
+	// GatherMetadata returns the metadata of the metrics used on the query.
+	GatherMetadata(ctx context.Context, query model.Query) ([]model.MetricMetadata, error)
+}
+
+// HealthReporter knows how to report the health of the datasources, this is
+// used to show the state of the datasources to the user.
+type HealthReporter interface {
+	// DatasourcesHealth returns the health of the datasources that have been queried.
+	DatasourcesHealth() []model.DatasourceHealth
 }
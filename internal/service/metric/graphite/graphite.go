@@ -2,13 +2,14 @@ package graphite
 
 import (
 	"context"
+	"encoding/json"
 	"fmt"
 	"net/http"
 	"net/url"
+	"path"
+	"strconv"
 	"time"
 
-	graphite "github.com/JensRantil/graphite-client"
-
 	"github.com/slok/grafterm/internal/model"
 	"github.com/slok/grafterm/internal/service/metric"
 )
@@ -26,7 +27,7 @@ func (c *ConfigGatherer) defaults() {
 }
 
 type gatherer struct {
-	cli *graphite.Client
+	url url.URL
 	cfg ConfigGatherer
 }
 
@@ -34,18 +35,14 @@ type gatherer struct {
 func NewGatherer(cfg ConfigGatherer) (metric.Gatherer, error) {
 	cfg.defaults()
 
-	url, err := url.Parse(cfg.GraphiteAPIURL)
+	u, err := url.Parse(cfg.GraphiteAPIURL)
 	if err != nil {
 		return nil, err
 	}
-	cli := &graphite.Client{
-		URL:    *url,
-		Client: cfg.HTTPCli,
-	}
 
 	return &gatherer{
 		cfg: cfg,
-		cli: cli,
+		url: *u,
 	}, nil
 }
 
@@ -73,42 +70,62 @@ func (g *gatherer) GatherSingle(ctx context.Context, query model.Query, t time.T
 	return res, nil
 }
 
-func (g *gatherer) GatherRange(ctx context.Context, query model.Query, start, end time.Time, _ time.Duration) ([]model.MetricSeries, error) {
+// renderSeries is a series of the Graphite render API response.
+type renderSeries struct {
+	Target     string            `json:"target"`
+	Tags       map[string]string `json:"tags"`
+	Datapoints [][2]*json.Number `json:"datapoints"`
+}
+
+// GatherRange uses the Graphite render API. If the step is set, the
+// `maxDataPoints` of the request will be the number of steps on the time
+// range so Graphite consolidates the datapoints instead of returning
+// all the raw datapoints. The tags of the series (e.g `seriesByTag`
+// results) will be set as labels.
+func (g *gatherer) GatherRange(ctx context.Context, query model.Query, start, end time.Time, step time.Duration) ([]model.MetricSeries, error) {
 	// Get the data from the Graphite API.
-	result, err := g.cli.QueryMulti([]string{query.Expr}, graphite.TimeInterval{
-		From: start,
-		To:   end,
-	})
+	result, err := g.render(ctx, query.Expr, start, end, step)
 	if err != nil {
 		return []model.MetricSeries{}, err
 	}
 
 	// For every metric series.
 	mss := []model.MetricSeries{}
-	for _, resultDps := range result {
-		dps, err := resultDps.AsFloats()
-		if err != nil {
-			continue
+	for _, rs := range result {
+		// Get all it's datapoints.
+		m := []model.Metric{}
+		for _, dp := range rs.Datapoints {
+			if dp[0] == nil || dp[1] == nil {
+				continue
+			}
+			v, err := dp[0].Float64()
+			if err != nil {
+				continue
+			}
+			ts, err := dp[1].Int64()
+			if err != nil {
+				continue
+			}
+			m = append(m, model.Metric{
+				TS:    time.Unix(ts, 0),
+				Value: v,
+			})
 		}
-		if len(dps) < 1 {
+
+		// Ignore the series without values (e.g all the datapoints are null).
+		if len(m) < 1 {
 			continue
 		}
 
-		// Get all it's datapoints.
-		m := []model.Metric{}
-		for _, dp := range dps {
-			if dp.Value != nil {
-				m = append(m, model.Metric{
-					TS:    dp.Time,
-					Value: *dp.Value,
-				})
-			}
+		labels := map[string]string{}
+		for k, v := range rs.Tags {
+			labels[k] = v
 		}
+		labels[targetLabelKey] = rs.Target
+
 		ms := model.MetricSeries{
-			ID: resultDps.Target,
-			Labels: map[string]string{
-				targetLabelKey: resultDps.Target,
-			},
+			ID:      rs.Target,
+			Labels:  labels,
 			Metrics: m,
 		}
 
@@ -117,3 +134,98 @@ func (g *gatherer) GatherRange(ctx context.Context, query model.Query, start, en
 
 	return mss, nil
 }
+
+func (g *gatherer) render(ctx context.Context, target string, start, end time.Time, step time.Duration) ([]renderSeries, error) {
+	q := url.Values{
+		"target": []string{target},
+		"from":   []string{strconv.FormatInt(start.Unix(), 10)},
+		"until":  []string{strconv.FormatInt(end.Unix(), 10)},
+		"format": []string{"json"},
+	}
+	if step > 0 {
+		q.Set("maxDataPoints", strconv.Itoa(maxDataPoints(start, end, step)))
+	}
+
+	u := g.url
+	u.Path = path.Join(u.Path, "/render")
+	u.RawQuery = q.Encode()
+
+	req, err := http.NewRequest(http.MethodGet, u.String(), nil)
+	if err != nil {
+		return nil, err
+	}
+	req = req.WithContext(ctx)
+
+	resp, err := g.cfg.HTTPCli.Do(req)
+	if err != nil {
+		return nil, fmt.Errorf("graphite render query failed: %w", err)
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode != http.StatusOK {
+		return nil, metric.NewHTTPError(resp, fmt.Errorf("graphite render query failed with status code %d", resp.StatusCode))
+	}
+
+	res := []renderSeries{}
+	dec := json.NewDecoder(resp.Body)
+	dec.UseNumber()
+	err = dec.Decode(&res)
+	if err != nil {
+		return nil, fmt.Errorf("could not decode graphite render response: %w", err)
+	}
+
+	return res, nil
+}
+
+// maxDataPoints returns the number of steps on the time range.
+func maxDataPoints(start, end time.Time, step time.Duration) int {
+	n := int(end.Sub(start) / step)
+	if n < 1 {
+		return 1
+	}
+	return n
+}
+
+// findNode is a node of the Graphite metrics find API response.
+type findNode struct {
+	Text string `json:"text"`
+	ID   string `json:"id"`
+}
+
+// DiscoverValues satisfies metric.Discoverer interface. It will use the
+// Graphite metrics find API, the query is the find pattern (e.g `servers.*`)
+// and the values will be the names of the matched nodes.
+func (g *gatherer) DiscoverValues(ctx context.Context, query model.Query) ([]string, error) {
+	u := g.url
+	u.Path = path.Join(u.Path, "/metrics/find")
+	u.RawQuery = url.Values{"query": []string{query.Expr}}.Encode()
+
+	req, err := http.NewRequest(http.MethodGet, u.String(), nil)
+	if err != nil {
+		return nil, err
+	}
+	req = req.WithContext(ctx)
+
+	resp, err := g.cfg.HTTPCli.Do(req)
+	if err != nil {
+		return nil, fmt.Errorf("graphite metrics find query failed: %w", err)
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode != http.StatusOK {
+		return nil, metric.NewHTTPError(resp, fmt.Errorf("graphite metrics find query failed with status code %d", resp.StatusCode))
+	}
+
+	nodes := []findNode{}
+	err = json.NewDecoder(resp.Body).Decode(&nodes)
+	if err != nil {
+		return nil, fmt.Errorf("could not decode graphite metrics find response: %w", err)
+	}
+
+	res := make([]string, 0, len(nodes))
+	for _, n := range nodes {
+		res = append(res, n.Text)
+	}
+
+	return res, nil
+}
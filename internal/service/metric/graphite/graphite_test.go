@@ -4,12 +4,14 @@ import (
 	"context"
 	"net/http"
 	"net/http/httptest"
+	"net/url"
 	"testing"
 	"time"
 
 	"github.com/stretchr/testify/assert"
 
 	"github.com/slok/grafterm/internal/model"
+	"github.com/slok/grafterm/internal/service/metric"
 	"github.com/slok/grafterm/internal/service/metric/graphite"
 )
 
@@ -48,6 +50,20 @@ func TestGathererGatherSingle(t *testing.T) {
 			graphiteResponse: `[]`,
 			expErr:           true,
 		},
+		"Getting a metric series with only null datapoints should error.": {
+			graphiteResponse: `[{"target": "batman", "datapoints": [[null, 1558275625]]}]`,
+			expErr:           true,
+		},
+		"Getting a metric series with a null last datapoint should return the latest not null metric.": {
+			graphiteResponse: `[{"target": "batman", "datapoints": [[612.54, 1558275625], [null, 1558275725]]}]`,
+			expMetricSeries: []model.MetricSeries{
+				{
+					ID:      "batman",
+					Labels:  map[string]string{"target": "batman"},
+					Metrics: []model.Metric{{Value: 612.54, TS: time.Unix(1558275625, 0)}},
+				},
+			},
+		},
 		"Getting more than one metric series should error.": {
 			graphiteResponse: `
 [
@@ -139,6 +155,21 @@ func TestGathererGatherRange(t *testing.T) {
 				},
 			},
 		},
+		"When Graphite API returns time series without values the gatherer should ignore them.": {
+			graphiteResponse: `
+[
+	{"target": "batman", "datapoints": [[612.54, 1558332722], [null, 1558332724]]},
+	{"target": "deadpool", "datapoints": [[null, 1558332822], [null, 1558332832]]},
+	{"target": "wolverine", "datapoints": []}
+]`,
+			expMetricSeries: []model.MetricSeries{
+				{
+					ID:      "batman",
+					Labels:  map[string]string{"target": "batman"},
+					Metrics: []model.Metric{{Value: 612.54, TS: time.Unix(1558332722, 0)}},
+				},
+			},
+		},
 	}
 
 	for name, test := range tests {
@@ -167,3 +198,156 @@ func TestGathererGatherRange(t *testing.T) {
 		})
 	}
 }
+
+func TestGathererGatherRangeRequest(t *testing.T) {
+	start := time.Unix(1558332720, 0)
+	end := start.Add(time.Hour)
+
+	tests := map[string]struct {
+		graphiteResponse string
+		query            model.Query
+		step             time.Duration
+		expParams        url.Values
+		expMetricSeries  []model.MetricSeries
+	}{
+		"Gathering with a step should request the max datapoints based on the step.": {
+			graphiteResponse: `[{"target": "batman", "datapoints": [[612.54, 1558332722], [null, 1558332724]]}]`,
+			query:            model.Query{Expr: "heroes.batman"},
+			step:             time.Minute,
+			expParams: url.Values{
+				"target":        []string{"heroes.batman"},
+				"from":          []string{"1558332720"},
+				"until":         []string{"1558336320"},
+				"format":        []string{"json"},
+				"maxDataPoints": []string{"60"},
+			},
+			expMetricSeries: []model.MetricSeries{
+				{
+					ID:      "batman",
+					Labels:  map[string]string{"target": "batman"},
+					Metrics: []model.Metric{{Value: 612.54, TS: time.Unix(1558332722, 0)}},
+				},
+			},
+		},
+		"Gathering without a step should not request the max datapoints.": {
+			graphiteResponse: `[]`,
+			query:            model.Query{Expr: "heroes.batman"},
+			expParams: url.Values{
+				"target": []string{"heroes.batman"},
+				"from":   []string{"1558332720"},
+				"until":  []string{"1558336320"},
+				"format": []string{"json"},
+			},
+			expMetricSeries: []model.MetricSeries{},
+		},
+		"Gathering tagged series should set the tags as labels.": {
+			graphiteResponse: `
+[
+	{"target": "cpu;host=a", "tags": {"name": "cpu", "host": "a"}, "datapoints": [[1, 1558332722]]},
+	{"target": "cpu;host=b", "tags": {"name": "cpu", "host": "b"}, "datapoints": [[2, 1558332722]]}
+]`,
+			query: model.Query{Expr: "seriesByTag('name=cpu')"},
+			step:  30 * time.Second,
+			expParams: url.Values{
+				"target":        []string{"seriesByTag('name=cpu')"},
+				"from":          []string{"1558332720"},
+				"until":         []string{"1558336320"},
+				"format":        []string{"json"},
+				"maxDataPoints": []string{"120"},
+			},
+			expMetricSeries: []model.MetricSeries{
+				{
+					ID:      "cpu;host=a",
+					Labels:  map[string]string{"target": "cpu;host=a", "name": "cpu", "host": "a"},
+					Metrics: []model.Metric{{Value: 1, TS: time.Unix(1558332722, 0)}},
+				},
+				{
+					ID:      "cpu;host=b",
+					Labels:  map[string]string{"target": "cpu;host=b", "name": "cpu", "host": "b"},
+					Metrics: []model.Metric{{Value: 2, TS: time.Unix(1558332722, 0)}},
+				},
+			},
+		},
+	}
+
+	for name, test := range tests {
+		test := test
+		t.Run(name, func(t *testing.T) {
+			assert := assert.New(t)
+
+			// Mock server response.
+			var gotPath string
+			var gotParams url.Values
+			srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+				gotPath = r.URL.Path
+				gotParams = r.URL.Query()
+				w.Write([]byte(test.graphiteResponse))
+			}))
+			defer srv.Close()
+
+			g, _ := graphite.NewGatherer(graphite.ConfigGatherer{GraphiteAPIURL: srv.URL})
+			gotms, err := g.GatherRange(context.TODO(), test.query, start, end, test.step)
+			if assert.NoError(err) {
+				assert.Equal("/render", gotPath)
+				assert.Equal(test.expParams, gotParams)
+				assert.Equal(test.expMetricSeries, gotms)
+			}
+		})
+	}
+}
+
+func TestGathererDiscoverValues(t *testing.T) {
+	tests := map[string]struct {
+		graphiteResponse string
+		graphiteCode     int
+		query            model.Query
+		expQuery         string
+		expValues        []string
+		expErr           bool
+	}{
+		"When Graphite API returns the found nodes the gatherer should return the node names.": {
+			graphiteResponse: `
+[
+	{"text": "batman", "id": "heroes.batman", "leaf": 0, "expandable": 1, "allowChildren": 1},
+	{"text": "deadpool", "id": "heroes.deadpool", "leaf": 0, "expandable": 1, "allowChildren": 1}
+]`,
+			graphiteCode: http.StatusOK,
+			query:        model.Query{Expr: "heroes.*"},
+			expQuery:     "heroes.*",
+			expValues:    []string{"batman", "deadpool"},
+		},
+		"When Graphite API returns an error the gatherer should return an error.": {
+			graphiteCode: http.StatusInternalServerError,
+			query:        model.Query{Expr: "heroes.*"},
+			expQuery:     "heroes.*",
+			expErr:       true,
+		},
+	}
+
+	for name, test := range tests {
+		t.Run(name, func(t *testing.T) {
+			assert := assert.New(t)
+
+			// Mock server response.
+			var gotQuery, gotPath string
+			srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+				gotPath = r.URL.Path
+				gotQuery = r.URL.Query().Get("query")
+				w.WriteHeader(test.graphiteCode)
+				w.Write([]byte(test.graphiteResponse))
+			}))
+			defer srv.Close()
+
+			g, _ := graphite.NewGatherer(graphite.ConfigGatherer{GraphiteAPIURL: srv.URL})
+			gotvs, err := g.(metric.Discoverer).DiscoverValues(context.TODO(), test.query)
+
+			assert.Equal("/metrics/find", gotPath)
+			assert.Equal(test.expQuery, gotQuery)
+			if test.expErr {
+				assert.Error(err)
+			} else if assert.NoError(err) {
+				assert.Equal(test.expValues, gotvs)
+			}
+		})
+	}
+}
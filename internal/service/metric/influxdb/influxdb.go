@@ -4,6 +4,10 @@ import (
 	"context"
 	"encoding/json"
 	"fmt"
+	"regexp"
+	"sort"
+	"strconv"
+	"strings"
 	"time"
 
 	_ "github.com/influxdata/influxdb1-client" // needed due to go mod bug
@@ -54,8 +58,14 @@ func NewGatherer(cfg ConfigGatherer) (metric.Gatherer, error) {
 	}, nil
 }
 
+const (
+	defRangeSteps = 100
+	instantRange  = 5 * time.Minute
+	fieldLabelKey = "field"
+)
+
 func (g *gatherer) GatherSingle(ctx context.Context, query model.Query, t time.Time) ([]model.MetricSeries, error) {
-	res, err := g.GatherRange(ctx, query, t.Add(-1*5), t, 0)
+	res, err := g.GatherRange(ctx, query, t.Add(-1*instantRange), t, instantRange)
 	if err != nil {
 		return []model.MetricSeries{}, err
 	}
@@ -63,69 +73,232 @@ func (g *gatherer) GatherSingle(ctx context.Context, query model.Query, t time.T
 	if len(res) < 1 {
 		return []model.MetricSeries{}, fmt.Errorf("server didn't return any metric series")
 	}
-	if len(res) > 1 {
-		return []model.MetricSeries{}, fmt.Errorf("server returned more than one metric series, got %d", len(res))
-	}
 
-	// Get the latest datapoint
-	res[0].Metrics = res[0].Metrics[len(res[0].Metrics)-1:]
+	// Get the latest datapoint of every series.
+	for i, s := range res {
+		if len(s.Metrics) > 0 {
+			res[i].Metrics = s.Metrics[len(s.Metrics)-1:]
+		}
+	}
 
 	return res, nil
 }
 
-func (g *gatherer) GatherRange(ctx context.Context, query model.Query, start, end time.Time, _ time.Duration) ([]model.MetricSeries, error) {
+func (g *gatherer) GatherRange(ctx context.Context, query model.Query, start, end time.Time, step time.Duration) ([]model.MetricSeries, error) {
 	res := []model.MetricSeries{}
 
+	if step <= 0 {
+		step = end.Sub(start) / defRangeSteps
+	}
+	if step < time.Millisecond {
+		step = time.Millisecond
+	}
+
 	// Get the data from the InfluxDB API
-	q := influxdbv2.NewQuery(query.Expr, g.cfg.Database, "ms")
+	q := influxdbv2.NewQuery(expandMacros(query.Expr, start, end, step), g.cfg.Database, "ms")
 	resp, err := g.cli.Query(q)
 	if err != nil {
-		return res, err
+		return res, classifyError(err)
 	}
 	if resp.Error() != nil {
-		return res, resp.Error()
+		return res, metric.NewError(metric.ErrorClassBadQuery, resp.Error())
 	}
 
-	// Build the metric series
+	// Build the metric series, one per field column of every InfluxDB series.
 	for _, result := range resp.Results {
 		for _, serie := range result.Series {
-			metrics := []model.Metric{}
-			for _, value := range serie.Values {
-				v, err := value[1].(json.Number).Float64()
-				if err != nil {
-					return res, err
-				}
-				t := time.Time{}
-				switch value[0].(type) {
-				case string:
-					t, err = time.Parse("2006-01-02T15:04:05Z", value[0].(string))
-					if err != nil {
-						return res, err
+			fields := len(serie.Columns) - 1
+			for col := 1; col < len(serie.Columns); col++ {
+				metrics := []model.Metric{}
+				for _, value := range serie.Values {
+					if len(value) <= col {
+						continue
 					}
-				case json.Number:
-					c, err := value[0].(json.Number).Int64()
+					v, ok := toFloat(value[col])
+					if !ok {
+						continue
+					}
+					t, err := toTime(value[0])
 					if err != nil {
 						return res, err
 					}
-					t = time.Unix(c/1000, c%1000)
-				default:
+					metrics = append(metrics, model.Metric{
+						TS:    t,
+						Value: v,
+					})
 				}
-				m := model.Metric{
-					TS:    t,
-					Value: v,
+
+				field := ""
+				if fields > 1 {
+					field = serie.Columns[col]
 				}
-				metrics = append(metrics, m)
+				res = append(res, model.MetricSeries{
+					ID:      seriesID(serie.Name, field, serie.Tags),
+					Labels:  seriesLabels(field, serie.Tags),
+					Metrics: metrics,
+				})
 			}
-			label := serie.Name
-			//TODO(rochaporto): Allow alias based on a tag value
-			//if serie.Tags != nil {
-			//	if v1, ok := serie.Tags["version"]; ok {
-			//		label = v1
-			//	}
-			//}
-			res = append(res, model.MetricSeries{ID: label, Metrics: metrics})
 		}
 	}
 
 	return res, nil
 }
+
+// expandMacros replaces the time range and interval macros of the query:
+//
+//   - `$timeFilter`: the time range condition (e.g `time >= 1558275600000ms and time <= 1558279200000ms`).
+//   - `$__interval`: the step as an InfluxQL duration (e.g `60000ms`).
+//   - `$__interval_ms`: the step in milliseconds (e.g `60000`).
+func expandMacros(expr string, start, end time.Time, step time.Duration) string {
+	timeFilter := fmt.Sprintf("time >= %dms and time <= %dms", toMillis(start), toMillis(end))
+	stepMS := fmt.Sprintf("%d", step.Milliseconds())
+
+	// The `$__interval_ms` macro needs to be before `$__interval` because
+	// the replacements are compared in argument order.
+	r := strings.NewReplacer(
+		"$timeFilter", timeFilter,
+		"$__interval_ms", stepMS,
+		"$__interval", stepMS+"ms",
+	)
+
+	return r.Replace(expr)
+}
+
+// seriesID returns the ID of the series using the InfluxDB series name, the
+// field (if there are multiple fields) and the tags, e.g `cpu.mean{host="a"}`.
+func seriesID(name, field string, tags map[string]string) string {
+	id := name
+	if field != "" {
+		id = fmt.Sprintf("%s.%s", name, field)
+	}
+
+	if len(tags) == 0 {
+		return id
+	}
+
+	keys := []string{}
+	for k := range tags {
+		keys = append(keys, k)
+	}
+	sort.Strings(keys)
+
+	ls := []string{}
+	for _, k := range keys {
+		ls = append(ls, fmt.Sprintf("%s=%q", k, tags[k]))
+	}
+
+	return fmt.Sprintf("%s{%s}", id, strings.Join(ls, ","))
+}
+
+// seriesLabels returns the labels of the series based on the tags and
+// the field (if there are multiple fields).
+func seriesLabels(field string, tags map[string]string) map[string]string {
+	if len(tags) == 0 && field == "" {
+		return nil
+	}
+
+	labels := map[string]string{}
+	for k, v := range tags {
+		labels[k] = v
+	}
+	if field != "" {
+		labels[fieldLabelKey] = field
+	}
+
+	return labels
+}
+
+func toFloat(v interface{}) (float64, bool) {
+	n, ok := v.(json.Number)
+	if !ok {
+		return 0, false
+	}
+
+	f, err := n.Float64()
+	if err != nil {
+		return 0, false
+	}
+
+	return f, true
+}
+
+func toTime(v interface{}) (time.Time, error) {
+	switch tv := v.(type) {
+	case string:
+		return time.Parse(time.RFC3339Nano, tv)
+	case json.Number:
+		ms, err := tv.Int64()
+		if err != nil {
+			return time.Time{}, err
+		}
+		return time.Unix(0, ms*int64(time.Millisecond)), nil
+	}
+
+	return time.Time{}, nil
+}
+
+func toMillis(t time.Time) int64 {
+	return t.UnixNano() / int64(time.Millisecond)
+}
+
+// discoverValueColumn is the column that has the values on the InfluxDB
+// meta queries that return key/value pairs (e.g `SHOW TAG VALUES`).
+const discoverValueColumn = "value"
+
+// DiscoverValues satisfies metric.Discoverer interface. The query is an
+// InfluxQL meta query (e.g `SHOW TAG VALUES WITH KEY = "host"` or
+// `SHOW MEASUREMENTS`), the values will be the ones on the `value` column
+// or if not present, the ones on the first column.
+func (g *gatherer) DiscoverValues(ctx context.Context, query model.Query) ([]string, error) {
+	q := influxdbv2.NewQuery(query.Expr, g.cfg.Database, "ms")
+	resp, err := g.cli.Query(q)
+	if err != nil {
+		return nil, err
+	}
+	if resp.Error() != nil {
+		return nil, resp.Error()
+	}
+
+	res := []string{}
+	seen := map[string]bool{}
+	for _, result := range resp.Results {
+		for _, serie := range result.Series {
+			col := 0
+			for i, c := range serie.Columns {
+				if c == discoverValueColumn {
+					col = i
+					break
+				}
+			}
+
+			for _, value := range serie.Values {
+				if len(value) <= col || value[col] == nil {
+					continue
+				}
+
+				v := fmt.Sprintf("%v", value[col])
+				if seen[v] {
+					continue
+				}
+				seen[v] = true
+				res = append(res, v)
+			}
+		}
+	}
+
+	return res, nil
+}
+
+var statusCodeRegexp = regexp.MustCompile(`status code (\d{3})`)
+
+// classifyError classifies the errors of the InfluxDB client, the client
+// only has the status code of the failed responses on the error message.
+func classifyError(err error) error {
+	sm := statusCodeRegexp.FindStringSubmatch(err.Error())
+	if sm == nil {
+		return err
+	}
+
+	code, _ := strconv.Atoi(sm[1])
+	return metric.NewError(metric.HTTPStatusErrorClass(code), err)
+}
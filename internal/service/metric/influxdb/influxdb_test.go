@@ -13,6 +13,7 @@ import (
 	"github.com/stretchr/testify/assert"
 
 	"github.com/slok/grafterm/internal/model"
+	"github.com/slok/grafterm/internal/service/metric"
 	"github.com/slok/grafterm/internal/service/metric/influxdb"
 )
 
@@ -116,6 +117,48 @@ func TestGathererGatherRange(t *testing.T) {
 				},
 			},
 		},
+		"When influxdb returns series with tags and multiple fields the gatherer should return a series per field with the tags as labels": {
+			influxdbResponse: `
+{"results":[
+  {
+  "series": [
+     {"name":"cpu","tags":{"host":"server01"},"columns":["time","mean","max"],"values":[[1488327378000,12.34,20],[1488327438000,null,30]]},
+     {"name":"cpu","tags":{"host":"server02"},"columns":["time","mean","max"],"values":[[1488327378000,1.5,2]]}
+  ]
+  }
+]}`,
+			expMetricSeries: []model.MetricSeries{
+				{
+					ID:     `cpu.mean{host="server01"}`,
+					Labels: map[string]string{"host": "server01", "field": "mean"},
+					Metrics: []model.Metric{
+						{Value: 12.34, TS: time.Unix(1488327378, 0)},
+					},
+				},
+				{
+					ID:     `cpu.max{host="server01"}`,
+					Labels: map[string]string{"host": "server01", "field": "max"},
+					Metrics: []model.Metric{
+						{Value: 20, TS: time.Unix(1488327378, 0)},
+						{Value: 30, TS: time.Unix(1488327438, 0)},
+					},
+				},
+				{
+					ID:     `cpu.mean{host="server02"}`,
+					Labels: map[string]string{"host": "server02", "field": "mean"},
+					Metrics: []model.Metric{
+						{Value: 1.5, TS: time.Unix(1488327378, 0)},
+					},
+				},
+				{
+					ID:     `cpu.max{host="server02"}`,
+					Labels: map[string]string{"host": "server02", "field": "max"},
+					Metrics: []model.Metric{
+						{Value: 2, TS: time.Unix(1488327378, 0)},
+					},
+				},
+			},
+		},
 	}
 
 	for name, test := range tests {
@@ -150,6 +193,131 @@ func TestGathererGatherRange(t *testing.T) {
 	}
 }
 
+func TestGathererMacros(t *testing.T) {
+	start := time.Unix(1558275600, 0)
+	end := start.Add(time.Hour)
+
+	tests := map[string]struct {
+		query    string
+		gather   func(g metric.Gatherer, q model.Query) error
+		expQuery string
+	}{
+		"Gathering a range should expand the time filter and the interval macros.": {
+			query: `SELECT mean("value") FROM "cpu" WHERE $timeFilter GROUP BY time($__interval) LIMIT $__interval_ms`,
+			gather: func(g metric.Gatherer, q model.Query) error {
+				_, err := g.GatherRange(context.TODO(), q, start, end, time.Minute)
+				return err
+			},
+			expQuery: `SELECT mean("value") FROM "cpu" WHERE time >= 1558275600000ms and time <= 1558279200000ms GROUP BY time(60000ms) LIMIT 60000`,
+		},
+		"Gathering a range without step should use a step based on the time range.": {
+			query: `SELECT mean("value") FROM "cpu" WHERE $timeFilter GROUP BY time($__interval)`,
+			gather: func(g metric.Gatherer, q model.Query) error {
+				_, err := g.GatherRange(context.TODO(), q, start, end, 0)
+				return err
+			},
+			expQuery: `SELECT mean("value") FROM "cpu" WHERE time >= 1558275600000ms and time <= 1558279200000ms GROUP BY time(36000ms)`,
+		},
+		"Gathering a single value should expand the macros with the instant range.": {
+			query: `SELECT last("value") FROM "cpu" WHERE $timeFilter GROUP BY time($__interval)`,
+			gather: func(g metric.Gatherer, q model.Query) error {
+				_, err := g.GatherSingle(context.TODO(), q, end)
+				return err
+			},
+			expQuery: `SELECT last("value") FROM "cpu" WHERE time >= 1558278900000ms and time <= 1558279200000ms GROUP BY time(300000ms)`,
+		},
+	}
+
+	for name, test := range tests {
+		test := test
+		t.Run(name, func(t *testing.T) {
+			assert := assert.New(t)
+
+			var gotQuery string
+			srv := httptest.NewServer(
+				http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+					r.ParseForm()
+					gotQuery = r.Form.Get("q")
+					w.Header().Set("Content-Type", "application/json")
+					w.Write([]byte(`{"results":[{"series":[{"name":"cpu","columns":["time","value"],"values":[[1558279200000,1]]}]}]}`))
+				}))
+			defer srv.Close()
+
+			g, _ := influxdb.NewGatherer(influxdb.ConfigGatherer{
+				Addr:     srv.URL,
+				Client:   influxdbClient(srv.URL),
+				Database: "dummy",
+			})
+			err := test.gather(g, model.Query{Expr: test.query})
+			if assert.NoError(err) {
+				assert.Equal(test.expQuery, gotQuery)
+			}
+		})
+	}
+}
+
+func TestGathererDiscoverValues(t *testing.T) {
+	tests := map[string]struct {
+		influxdbResponse string
+		expValues        []string
+		expErr           bool
+	}{
+		"When influxdb returns key value pairs the gatherer should return the values.": {
+			influxdbResponse: `
+{"results":[
+  {
+  "series": [
+     {"name":"cpu","columns":["key","value"],"values":[["host","server01"],["host","server02"]]},
+     {"name":"mem","columns":["key","value"],"values":[["host","server02"],["host","server03"]]}
+  ]
+  }
+]}`,
+			expValues: []string{"server01", "server02", "server03"},
+		},
+		"When influxdb returns a single column the gatherer should return the values of the column.": {
+			influxdbResponse: `
+{"results":[
+  {
+  "series": [
+     {"name":"measurements","columns":["name"],"values":[["cpu"],["mem"]]}
+  ]
+  }
+]}`,
+			expValues: []string{"cpu", "mem"},
+		},
+		"When influxdb returns an error the gatherer should return an error.": {
+			influxdbResponse: `{"results":[{"error":"wanted error"}]}`,
+			expErr:           true,
+		},
+	}
+
+	for name, test := range tests {
+		t.Run(name, func(t *testing.T) {
+			assert := assert.New(t)
+
+			// Mock server response.
+			srv := httptest.NewServer(
+				http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+					w.Header().Set("Content-Type", "application/json")
+					w.Write([]byte(test.influxdbResponse))
+				}))
+			defer srv.Close()
+
+			g, _ := influxdb.NewGatherer(influxdb.ConfigGatherer{
+				Addr:     srv.URL,
+				Client:   influxdbClient(srv.URL),
+				Database: "dummy",
+			})
+			gotvs, err := g.(metric.Discoverer).DiscoverValues(context.TODO(), model.Query{Expr: `SHOW TAG VALUES WITH KEY = "host"`})
+			if test.expErr {
+				assert.Error(err)
+			} else if assert.NoError(err) {
+				assert.Equal(test.expValues, gotvs)
+			}
+		})
+	}
+}
+
 func influxdbClient(addr string) influxdbv2.Client {
 	cli, _ := influxdbv2.NewHTTPClient(
 		influxdbv2.HTTPConfig{
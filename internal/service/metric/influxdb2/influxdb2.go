@@ -0,0 +1,418 @@
+package influxdb2
+
+import (
+	"bytes"
+	"context"
+	"encoding/csv"
+	"encoding/json"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"net/http"
+	"net/url"
+	"path"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/slok/grafterm/internal/model"
+	"github.com/slok/grafterm/internal/service/metric"
+)
+
+const (
+	defRangeSteps = 100
+	instantRange  = 5 * time.Minute
+)
+
+// Annotated CSV columns.
+const (
+	annotationDatatype = "#datatype"
+	annotationGroup    = "#group"
+	annotationDefault  = "#default"
+
+	columnResult = "result"
+	columnTable  = "table"
+	columnStart  = "_start"
+	columnStop   = "_stop"
+	columnTime   = "_time"
+	columnValue  = "_value"
+	columnError  = "error"
+)
+
+// ConfigGatherer is the configuration of the InfluxDB 2 gatherer.
+type ConfigGatherer struct {
+	// Address is the address of the InfluxDB 2 API.
+	Address string
+	// Org is the organization used on the queries.
+	Org string
+	// Token is the API token used to authenticate.
+	Token string
+	// Bucket is the default bucket that will be available on the
+	// queries as `v.defaultBucket`.
+	Bucket  string
+	HTTPCli *http.Client
+}
+
+func (c *ConfigGatherer) defaults() error {
+	if c.Org == "" {
+		return fmt.Errorf("no influxdb2 org given")
+	}
+
+	if c.HTTPCli == nil {
+		c.HTTPCli = http.DefaultClient
+	}
+
+	return nil
+}
+
+type gatherer struct {
+	cfg      ConfigGatherer
+	queryURL string
+}
+
+// NewGatherer returns a new metric gatherer for InfluxDB 2 backends using
+// Flux queries. The queries can use the `v.timeRangeStart`, `v.timeRangeStop`
+// and `v.windowPeriod` variables (and `v.defaultBucket` if the bucket is
+// configured) that are set based on the gathered time range, e.g:
+//
+//	from(bucket: v.defaultBucket)
+//	  |> range(start: v.timeRangeStart, stop: v.timeRangeStop)
+//	  |> filter(fn: (r) => r._measurement == "cpu" and r._field == "usage_user")
+//	  |> aggregateWindow(every: v.windowPeriod, fn: mean)
+//
+// Every Flux table will be a series with the group key columns as labels.
+func NewGatherer(cfg ConfigGatherer) (metric.Gatherer, error) {
+	err := cfg.defaults()
+	if err != nil {
+		return nil, err
+	}
+
+	u, err := url.Parse(cfg.Address)
+	if err != nil {
+		return nil, err
+	}
+	u.Path = path.Join(u.Path, "/api/v2/query")
+	u.RawQuery = url.Values{"org": []string{cfg.Org}}.Encode()
+
+	return &gatherer{
+		cfg:      cfg,
+		queryURL: u.String(),
+	}, nil
+}
+
+func (g *gatherer) GatherSingle(ctx context.Context, query model.Query, t time.Time) ([]model.MetricSeries, error) {
+	res, err := g.GatherRange(ctx, query, t.Add(-1*instantRange), t, instantRange)
+	if err != nil {
+		return nil, err
+	}
+
+	// Get the latest datapoint of every series.
+	for i, s := range res {
+		if len(s.Metrics) > 0 {
+			res[i].Metrics = s.Metrics[len(s.Metrics)-1:]
+		}
+	}
+
+	return res, nil
+}
+
+func (g *gatherer) GatherRange(ctx context.Context, query model.Query, start, end time.Time, step time.Duration) ([]model.MetricSeries, error) {
+	if step <= 0 {
+		step = end.Sub(start) / defRangeSteps
+	}
+	if step < time.Millisecond {
+		step = time.Millisecond
+	}
+
+	tables, err := g.query(ctx, g.withVariables(query.Expr, start, end, step))
+	if err != nil {
+		return nil, err
+	}
+
+	res := []model.MetricSeries{}
+	for _, t := range tables {
+		s := model.MetricSeries{
+			ID:     seriesID(t.groupKey),
+			Labels: t.groupKey,
+		}
+		for _, r := range t.rows {
+			v, ok := r.value()
+			if !ok {
+				continue
+			}
+			ts, ok := r.time()
+			if !ok {
+				ts = end
+			}
+			s.Metrics = append(s.Metrics, model.Metric{TS: ts, Value: v})
+		}
+
+		if len(s.Metrics) > 0 {
+			res = append(res, s)
+		}
+	}
+
+	return res, nil
+}
+
+// DiscoverValues satisfies metric.Discoverer interface. The query is a Flux
+// query (e.g `import "influxdata/influxdb/schema" schema.tagValues(bucket: "b", tag: "host")`)
+// and the values are the ones on the `_value` column.
+func (g *gatherer) DiscoverValues(ctx context.Context, query model.Query) ([]string, error) {
+	end := time.Now()
+	tables, err := g.query(ctx, g.withVariables(query.Expr, end.Add(-1*time.Hour), end, time.Minute))
+	if err != nil {
+		return nil, err
+	}
+
+	res := []string{}
+	seen := map[string]bool{}
+	for _, t := range tables {
+		for _, r := range t.rows {
+			v := r.columns[columnValue]
+			if v == "" || seen[v] {
+				continue
+			}
+			seen[v] = true
+			res = append(res, v)
+		}
+	}
+
+	return res, nil
+}
+
+// withVariables sets the dashboard variables that Flux queries use to know
+// the time range and the aggregation window.
+func (g *gatherer) withVariables(flux string, start, end time.Time, step time.Duration) string {
+	vars := []string{
+		"timeRangeStart: " + start.UTC().Format(time.RFC3339Nano),
+		"timeRangeStop: " + end.UTC().Format(time.RFC3339Nano),
+		fmt.Sprintf("windowPeriod: %dms", step.Milliseconds()),
+	}
+	if g.cfg.Bucket != "" {
+		vars = append(vars, "defaultBucket: "+strconv.Quote(g.cfg.Bucket))
+	}
+
+	return fmt.Sprintf("option v = {%s}\n\n%s", strings.Join(vars, ", "), flux)
+}
+
+type queryRequest struct {
+	Query   string  `json:"query"`
+	Type    string  `json:"type"`
+	Dialect dialect `json:"dialect"`
+}
+
+type dialect struct {
+	Annotations []string `json:"annotations"`
+	Header      bool     `json:"header"`
+	Delimiter   string   `json:"delimiter"`
+}
+
+type errorResponse struct {
+	Code    string `json:"code"`
+	Message string `json:"message"`
+}
+
+func (g *gatherer) query(ctx context.Context, flux string) ([]*table, error) {
+	bs, err := json.Marshal(queryRequest{
+		Query: flux,
+		Type:  "flux",
+		Dialect: dialect{
+			Annotations: []string{"datatype", "group", "default"},
+			Header:      true,
+			Delimiter:   ",",
+		},
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	req, err := http.NewRequest(http.MethodPost, g.queryURL, bytes.NewReader(bs))
+	if err != nil {
+		return nil, err
+	}
+	req = req.WithContext(ctx)
+	req.Header.Set("Content-Type", "application/json")
+	req.Header.Set("Accept", "application/csv")
+	if g.cfg.Token != "" {
+		req.Header.Set("Authorization", "Token "+g.cfg.Token)
+	}
+
+	resp, err := g.cfg.HTTPCli.Do(req)
+	if err != nil {
+		return nil, err
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode != http.StatusOK {
+		rbs, _ := ioutil.ReadAll(resp.Body)
+		er := &errorResponse{}
+		if err := json.Unmarshal(rbs, er); err == nil && er.Message != "" {
+			return nil, metric.NewHTTPError(resp, fmt.Errorf("influxdb2 error (%d): %s", resp.StatusCode, er.Message))
+		}
+		return nil, metric.NewHTTPError(resp, fmt.Errorf("influxdb2 error (%d): %s", resp.StatusCode, string(rbs)))
+	}
+
+	return parseAnnotatedCSV(resp.Body)
+}
+
+// table is a Flux table of the response.
+type table struct {
+	groupKey map[string]string
+	rows     []row
+}
+
+// row is a record of a Flux table.
+type row struct {
+	columns   map[string]string
+	datatypes map[string]string
+}
+
+func (r row) value() (float64, bool) {
+	v, ok := r.columns[columnValue]
+	if !ok || v == "" {
+		return 0, false
+	}
+
+	switch r.datatypes[columnValue] {
+	case "boolean":
+		if v == "true" {
+			return 1, true
+		}
+		return 0, true
+	case "string":
+		return 0, false
+	}
+
+	f, err := strconv.ParseFloat(v, 64)
+	if err != nil {
+		return 0, false
+	}
+	return f, true
+}
+
+func (r row) time() (time.Time, bool) {
+	for _, c := range []string{columnTime, columnStop} {
+		v := r.columns[c]
+		if v == "" {
+			continue
+		}
+		t, err := time.Parse(time.RFC3339Nano, v)
+		if err == nil {
+			return t, true
+		}
+	}
+
+	return time.Time{}, false
+}
+
+// parseAnnotatedCSV parses the Flux annotated CSV response, every table of
+// the response is identified by the result and the table columns.
+func parseAnnotatedCSV(r io.Reader) ([]*table, error) {
+	cr := csv.NewReader(r)
+	cr.FieldsPerRecord = -1
+
+	var (
+		datatypes, groups, defaults, header []string
+		tables                              = []*table{}
+		byID                                = map[string]*table{}
+	)
+
+	for {
+		record, err := cr.Read()
+		if err == io.EOF {
+			break
+		}
+		if err != nil {
+			return nil, fmt.Errorf("invalid influxdb2 CSV response: %s", err)
+		}
+
+		// Annotations start a new table set with a new header.
+		switch record[0] {
+		case annotationDatatype:
+			datatypes, header = record, nil
+			continue
+		case annotationGroup:
+			groups, header = record, nil
+			continue
+		case annotationDefault:
+			defaults, header = record, nil
+			continue
+		}
+
+		if header == nil {
+			header = record
+			continue
+		}
+
+		columns := map[string]string{}
+		types := map[string]string{}
+		for i, h := range header {
+			if h == "" {
+				continue
+			}
+			v := ""
+			if i < len(record) {
+				v = record[i]
+			}
+			if v == "" && i < len(defaults) {
+				v = defaults[i]
+			}
+			columns[h] = v
+			if i < len(datatypes) {
+				types[h] = datatypes[i]
+			}
+		}
+
+		if msg, ok := columns[columnError]; ok {
+			return nil, metric.NewError(metric.ErrorClassBadQuery, fmt.Errorf("influxdb2 query error: %s", msg))
+		}
+
+		id := columns[columnResult] + "/" + columns[columnTable]
+		t, ok := byID[id]
+		if !ok {
+			t = &table{groupKey: groupKey(header, groups, columns)}
+			byID[id] = t
+			tables = append(tables, t)
+		}
+		t.rows = append(t.rows, row{columns: columns, datatypes: types})
+	}
+
+	return tables, nil
+}
+
+// groupKey returns the group key columns of the table without the
+// result, table and time range columns.
+func groupKey(header, groups []string, columns map[string]string) map[string]string {
+	gk := map[string]string{}
+	for i, h := range header {
+		if i >= len(groups) || groups[i] != "true" {
+			continue
+		}
+		switch h {
+		case "", columnResult, columnTable, columnStart, columnStop:
+			continue
+		}
+		gk[h] = columns[h]
+	}
+
+	return gk
+}
+
+// seriesID returns the ID of the series based on the group key in the
+// form of `{_field="usage",_measurement="cpu"}`.
+func seriesID(gk map[string]string) string {
+	keys := []string{}
+	for k := range gk {
+		keys = append(keys, k)
+	}
+	sort.Strings(keys)
+
+	ls := []string{}
+	for _, k := range keys {
+		ls = append(ls, fmt.Sprintf("%s=%q", k, gk[k]))
+	}
+
+	return "{" + strings.Join(ls, ",") + "}"
+}
@@ -0,0 +1,259 @@
+package influxdb2_test
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/slok/grafterm/internal/model"
+	"github.com/slok/grafterm/internal/service/metric"
+	"github.com/slok/grafterm/internal/service/metric/influxdb2"
+)
+
+const twoTablesResponse = `#datatype,string,long,dateTime:RFC3339,dateTime:RFC3339,dateTime:RFC3339,double,string,string,string
+#group,false,false,true,true,false,false,true,true,true
+#default,_result,,,,,,,,
+,result,table,_start,_stop,_time,_value,_field,_measurement,host
+,,0,2019-05-19T14:00:00Z,2019-05-19T14:10:00Z,2019-05-19T14:01:00Z,10.5,usage_user,cpu,host-a
+,,0,2019-05-19T14:00:00Z,2019-05-19T14:10:00Z,2019-05-19T14:02:00Z,11,usage_user,cpu,host-a
+,,1,2019-05-19T14:00:00Z,2019-05-19T14:10:00Z,2019-05-19T14:01:00Z,20,usage_user,cpu,host-b
+,,1,2019-05-19T14:00:00Z,2019-05-19T14:10:00Z,2019-05-19T14:02:00Z,,usage_user,cpu,host-b
+,,1,2019-05-19T14:00:00Z,2019-05-19T14:10:00Z,2019-05-19T14:03:00Z,22,usage_user,cpu,host-b
+
+`
+
+var (
+	start = time.Date(2019, 5, 19, 14, 0, 0, 0, time.UTC)
+	end   = start.Add(10 * time.Minute)
+)
+
+func ts(minute int) time.Time {
+	return start.Add(time.Duration(minute) * time.Minute)
+}
+
+type request struct {
+	path    string
+	org     string
+	auth    string
+	accept  string
+	query   string
+	dialect map[string]interface{}
+}
+
+// testServer returns a server that responds with the response and stores the request.
+func testServer(t *testing.T, status int, response string, gotReq *request) *httptest.Server {
+	return httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		body := struct {
+			Query   string                 `json:"query"`
+			Dialect map[string]interface{} `json:"dialect"`
+		}{}
+		require.NoError(t, json.NewDecoder(r.Body).Decode(&body))
+		*gotReq = request{
+			path:    r.URL.Path,
+			org:     r.URL.Query().Get("org"),
+			auth:    r.Header.Get("Authorization"),
+			accept:  r.Header.Get("Accept"),
+			query:   body.Query,
+			dialect: body.Dialect,
+		}
+
+		w.WriteHeader(status)
+		w.Write([]byte(response))
+	}))
+}
+
+func TestGathererGatherRange(t *testing.T) {
+	tests := map[string]struct {
+		cfg             influxdb2.ConfigGatherer
+		query           string
+		step            time.Duration
+		status          int
+		response        string
+		expQuery        string
+		expMetricSeries []model.MetricSeries
+		expErr          bool
+	}{
+		"Flux tables should be returned as series with the group key as labels.": {
+			cfg:      influxdb2.ConfigGatherer{Org: "my-org", Token: "my-token"},
+			query:    `from(bucket: "b") |> range(start: v.timeRangeStart, stop: v.timeRangeStop)`,
+			step:     time.Minute,
+			status:   http.StatusOK,
+			response: twoTablesResponse,
+			expQuery: "option v = {timeRangeStart: 2019-05-19T14:00:00Z, timeRangeStop: 2019-05-19T14:10:00Z, windowPeriod: 60000ms}\n\n" +
+				`from(bucket: "b") |> range(start: v.timeRangeStart, stop: v.timeRangeStop)`,
+			expMetricSeries: []model.MetricSeries{
+				{
+					ID:      `{_field="usage_user",_measurement="cpu",host="host-a"}`,
+					Labels:  map[string]string{"_field": "usage_user", "_measurement": "cpu", "host": "host-a"},
+					Metrics: []model.Metric{{TS: ts(1), Value: 10.5}, {TS: ts(2), Value: 11}},
+				},
+				{
+					ID:      `{_field="usage_user",_measurement="cpu",host="host-b"}`,
+					Labels:  map[string]string{"_field": "usage_user", "_measurement": "cpu", "host": "host-b"},
+					Metrics: []model.Metric{{TS: ts(1), Value: 20}, {TS: ts(3), Value: 22}},
+				},
+			},
+		},
+		"The default bucket should be set as a variable.": {
+			cfg:      influxdb2.ConfigGatherer{Org: "my-org", Bucket: "telegraf"},
+			query:    `from(bucket: v.defaultBucket)`,
+			step:     30 * time.Second,
+			status:   http.StatusOK,
+			response: "",
+			expQuery: "option v = {timeRangeStart: 2019-05-19T14:00:00Z, timeRangeStop: 2019-05-19T14:10:00Z, windowPeriod: 30000ms, defaultBucket: \"telegraf\"}\n\n" +
+				`from(bucket: v.defaultBucket)`,
+			expMetricSeries: []model.MetricSeries{},
+		},
+		"Multiple table sets with different annotations should be parsed.": {
+			cfg:   influxdb2.ConfigGatherer{Org: "my-org"},
+			query: `q`,
+			step:  time.Minute,
+			response: `#datatype,string,long,dateTime:RFC3339,double,string
+#group,false,false,false,false,true
+#default,mean,,,,
+,result,table,_time,_value,_field
+,,0,2019-05-19T14:01:00Z,1,a
+
+#datatype,string,long,dateTime:RFC3339,long,boolean,string
+#group,false,false,false,false,false,true
+#default,max,,,,,
+,result,table,_time,_value,ok,_field
+,,0,2019-05-19T14:02:00Z,2,true,b
+`,
+			status:   http.StatusOK,
+			expQuery: "option v = {timeRangeStart: 2019-05-19T14:00:00Z, timeRangeStop: 2019-05-19T14:10:00Z, windowPeriod: 60000ms}\n\nq",
+			expMetricSeries: []model.MetricSeries{
+				{ID: `{_field="a"}`, Labels: map[string]string{"_field": "a"}, Metrics: []model.Metric{{TS: ts(1), Value: 1}}},
+				{ID: `{_field="b"}`, Labels: map[string]string{"_field": "b"}, Metrics: []model.Metric{{TS: ts(2), Value: 2}}},
+			},
+		},
+		"Rows without time should use the stop of the table.": {
+			cfg:   influxdb2.ConfigGatherer{Org: "my-org"},
+			query: `q`,
+			step:  time.Minute,
+			response: `#datatype,string,long,dateTime:RFC3339,dateTime:RFC3339,double
+#group,false,false,true,true,false
+#default,_result,,,,
+,result,table,_start,_stop,_value
+,,0,2019-05-19T14:00:00Z,2019-05-19T14:05:00Z,42
+`,
+			status:   http.StatusOK,
+			expQuery: "option v = {timeRangeStart: 2019-05-19T14:00:00Z, timeRangeStop: 2019-05-19T14:10:00Z, windowPeriod: 60000ms}\n\nq",
+			expMetricSeries: []model.MetricSeries{
+				{ID: `{}`, Labels: map[string]string{}, Metrics: []model.Metric{{TS: ts(5), Value: 42}}},
+			},
+		},
+		"An error on the CSV response should return an error.": {
+			cfg:   influxdb2.ConfigGatherer{Org: "my-org"},
+			query: `q`,
+			step:  time.Minute,
+			response: `#datatype,string,string
+#group,true,true
+#default,,
+,error,reference
+,"error calling function ""filter""",
+`,
+			status: http.StatusOK,
+			expErr: true,
+		},
+		"An error status should return an error.": {
+			cfg:      influxdb2.ConfigGatherer{Org: "my-org"},
+			query:    `q`,
+			step:     time.Minute,
+			response: `{"code":"invalid","message":"compilation failed"}`,
+			status:   http.StatusBadRequest,
+			expErr:   true,
+		},
+	}
+
+	for name, test := range tests {
+		test := test
+		t.Run(name, func(t *testing.T) {
+			assert := assert.New(t)
+			require := require.New(t)
+
+			var gotReq request
+			srv := testServer(t, test.status, test.response, &gotReq)
+			defer srv.Close()
+
+			test.cfg.Address = srv.URL
+			g, err := influxdb2.NewGatherer(test.cfg)
+			require.NoError(err)
+
+			gotms, err := g.GatherRange(context.TODO(), model.Query{Expr: test.query}, start, end, test.step)
+			if test.expErr {
+				assert.Error(err)
+				return
+			}
+
+			if assert.NoError(err) {
+				assert.Equal(test.expMetricSeries, gotms)
+				assert.Equal("/api/v2/query", gotReq.path)
+				assert.Equal(test.cfg.Org, gotReq.org)
+				assert.Equal("application/csv", gotReq.accept)
+				assert.Equal(test.expQuery, gotReq.query)
+				assert.Equal([]interface{}{"datatype", "group", "default"}, gotReq.dialect["annotations"])
+				if test.cfg.Token != "" {
+					assert.Equal("Token "+test.cfg.Token, gotReq.auth)
+				}
+			}
+		})
+	}
+}
+
+func TestGathererGatherSingle(t *testing.T) {
+	assert := assert.New(t)
+
+	var gotReq request
+	srv := testServer(t, http.StatusOK, twoTablesResponse, &gotReq)
+	defer srv.Close()
+
+	g, err := influxdb2.NewGatherer(influxdb2.ConfigGatherer{Address: srv.URL, Org: "my-org"})
+	require.NoError(t, err)
+
+	gotms, err := g.GatherSingle(context.TODO(), model.Query{Expr: "q"}, end)
+	if assert.NoError(err) {
+		assert.Equal("option v = {timeRangeStart: 2019-05-19T14:05:00Z, timeRangeStop: 2019-05-19T14:10:00Z, windowPeriod: 300000ms}\n\nq", gotReq.query)
+		assert.Equal([]model.MetricSeries{
+			{
+				ID:      `{_field="usage_user",_measurement="cpu",host="host-a"}`,
+				Labels:  map[string]string{"_field": "usage_user", "_measurement": "cpu", "host": "host-a"},
+				Metrics: []model.Metric{{TS: ts(2), Value: 11}},
+			},
+			{
+				ID:      `{_field="usage_user",_measurement="cpu",host="host-b"}`,
+				Labels:  map[string]string{"_field": "usage_user", "_measurement": "cpu", "host": "host-b"},
+				Metrics: []model.Metric{{TS: ts(3), Value: 22}},
+			},
+		}, gotms)
+	}
+}
+
+func TestGathererDiscoverValues(t *testing.T) {
+	assert := assert.New(t)
+
+	var gotReq request
+	srv := testServer(t, http.StatusOK, `#datatype,string,long,string
+#group,false,false,false
+#default,_result,,
+,result,table,_value
+,,0,host-a
+,,0,host-b
+,,0,host-a
+`, &gotReq)
+	defer srv.Close()
+
+	g, err := influxdb2.NewGatherer(influxdb2.ConfigGatherer{Address: srv.URL, Org: "my-org"})
+	require.NoError(t, err)
+
+	got, err := g.(metric.Discoverer).DiscoverValues(context.TODO(), model.Query{Expr: "q"})
+	if assert.NoError(err) {
+		assert.Equal([]string{"host-a", "host-b"}, got)
+	}
+}
@@ -0,0 +1,149 @@
+package middleware
+
+import (
+	"context"
+	"fmt"
+	"sync"
+	"time"
+
+	"github.com/slok/grafterm/internal/model"
+	"github.com/slok/grafterm/internal/service/metric"
+)
+
+const (
+	defCacheSize = 100
+	defCacheTTL  = 30 * time.Second
+)
+
+// CacheConfig is the configuration of the Cache middleware.
+type CacheConfig struct {
+	// Size is the maximum number of cached queries.
+	Size int64
+	// TTL is the time a query result is cached.
+	TTL time.Duration
+}
+
+func (c *CacheConfig) defaults() {
+	if c.Size <= 0 {
+		c.Size = defCacheSize
+	}
+
+	if c.TTL <= 0 {
+		c.TTL = defCacheTTL
+	}
+}
+
+// cacheKey identifies the cached series of a query.
+type cacheKey struct {
+	datasourceID string
+	expr         string
+	t            int64
+}
+
+type cacheEntry struct {
+	series  []model.MetricSeries
+	expires time.Time
+}
+
+type cache struct {
+	cfg  CacheConfig
+	next metric.Gatherer
+
+	mu      sync.Mutex
+	entries map[cacheKey]cacheEntry
+}
+
+// Cache is a gatherer middleware that caches the series of the successful
+// single queries by datasource, query and time during the TTL. Only the
+// queries of the exact same time hit the cache, so the refreshes in live mode
+// (a new time on every sync) always get fresh values, and the repeated queries
+// of a fixed time (e.g paused dashboards) are cached. The range queries are not
+// cached, they are already cached by the RangeCache. When the cache is full the expired
+// queries are evicted, and if still full, the query that expires first.
+func Cache(cfg CacheConfig, next metric.Gatherer) metric.Gatherer {
+	cfg.defaults()
+
+	return &cache{
+		cfg:     cfg,
+		next:    next,
+		entries: map[cacheKey]cacheEntry{},
+	}
+}
+
+func (c *cache) GatherSingle(ctx context.Context, query model.Query, t time.Time) ([]model.MetricSeries, error) {
+	key := cacheKey{
+		datasourceID: query.DatasourceID,
+		expr:         query.Expr,
+		t:            t.UnixNano(),
+	}
+	return c.get(key, func() ([]model.MetricSeries, error) {
+		return c.next.GatherSingle(ctx, query, t)
+	})
+}
+
+func (c *cache) GatherRange(ctx context.Context, query model.Query, start, end time.Time, step time.Duration) ([]model.MetricSeries, error) {
+	return c.next.GatherRange(ctx, query, start, end, step)
+}
+
+func (c *cache) DiscoverValues(ctx context.Context, query model.Query) ([]string, error) {
+	d, ok := c.next.(metric.Discoverer)
+	if !ok {
+		return nil, fmt.Errorf("gatherer does not support values discovery")
+	}
+	return d.DiscoverValues(ctx, query)
+}
+
+func (c *cache) GatherMetadata(ctx context.Context, query model.Query) ([]model.MetricMetadata, error) {
+	m, ok := c.next.(metric.MetadataGatherer)
+	if !ok {
+		return nil, metric.ErrMetadataNotSupported
+	}
+	return m.GatherMetadata(ctx, query)
+}
+
+// get returns a copy of the cached series of the query or gathers and caches
+// them, the cached series are copied so the callers can't modify them.
+func (c *cache) get(key cacheKey, gather func() ([]model.MetricSeries, error)) ([]model.MetricSeries, error) {
+	now := time.Now()
+
+	c.mu.Lock()
+	e, ok := c.entries[key]
+	c.mu.Unlock()
+	if ok && now.Before(e.expires) {
+		return copySeries(e.series), nil
+	}
+
+	series, err := gather()
+	if err != nil {
+		return nil, err
+	}
+
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	if _, ok := c.entries[key]; !ok && int64(len(c.entries)) >= c.cfg.Size {
+		c.evict(now)
+	}
+	c.entries[key] = cacheEntry{series: copySeries(series), expires: now.Add(c.cfg.TTL)}
+
+	return series, nil
+}
+
+// evict removes the expired queries, if there aren't expired queries removes
+// the one that expires first. Needs to be called with the lock acquired.
+func (c *cache) evict(now time.Time) {
+	var first *cacheKey
+	for k, e := range c.entries {
+		if !now.Before(e.expires) {
+			delete(c.entries, k)
+			continue
+		}
+		if first == nil || e.expires.Before(c.entries[*first].expires) {
+			k := k
+			first = &k
+		}
+	}
+
+	if first != nil && int64(len(c.entries)) >= c.cfg.Size {
+		delete(c.entries, *first)
+	}
+}
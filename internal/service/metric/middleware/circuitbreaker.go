@@ -0,0 +1,281 @@
+package middleware
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"sort"
+	"sync"
+	"time"
+
+	"github.com/slok/grafterm/internal/model"
+	"github.com/slok/grafterm/internal/service/metric"
+)
+
+const (
+	defCircuitBreakerFailureThreshold = 5
+	defCircuitBreakerCoolDown         = 30 * time.Second
+)
+
+// ErrCircuitOpen is the error returned when the query is rejected because
+// the circuit of the datasource is open.
+var ErrCircuitOpen = errors.New("circuit open")
+
+// CircuitBreakerConfig is the configuration of the CircuitBreaker middleware.
+type CircuitBreakerConfig struct {
+	// FailureThreshold is the number of consecutive failed queries of a
+	// datasource that will open its circuit.
+	FailureThreshold int
+	// CoolDown is the time the circuit will be open before letting a query
+	// probe the datasource again.
+	CoolDown time.Duration
+}
+
+func (c *CircuitBreakerConfig) defaults() {
+	if c.FailureThreshold <= 0 {
+		c.FailureThreshold = defCircuitBreakerFailureThreshold
+	}
+
+	if c.CoolDown <= 0 {
+		c.CoolDown = defCircuitBreakerCoolDown
+	}
+}
+
+// circuit is the circuit breaker state of a datasource.
+type circuit struct {
+	state     model.CircuitState
+	failures  int
+	lastError string
+	since     time.Time
+	// probing is true when the half-open probe query is in flight.
+	probing bool
+}
+
+type circuitBreaker struct {
+	cfg  CircuitBreakerConfig
+	next metric.Gatherer
+
+	mu       sync.Mutex
+	circuits map[string]*circuit
+}
+
+// CircuitBreaker is a gatherer middleware that tracks the consecutive failed
+// queries of each datasource (by the query datasource ID). When a datasource
+// reaches the failure threshold its circuit is opened and the queries to it
+// are rejected without waiting during the cool-down period, after the
+// cool-down a single query probes the datasource (half-open) and closes the
+// circuit if it succeeds or opens it again if it fails.
+// The health of the datasources is reported with metric.HealthReporter.
+func CircuitBreaker(cfg CircuitBreakerConfig, next metric.Gatherer) metric.Gatherer {
+	cfg.defaults()
+
+	return &circuitBreaker{
+		cfg:      cfg,
+		next:     next,
+		circuits: map[string]*circuit{},
+	}
+}
+
+func (c *circuitBreaker) GatherSingle(ctx context.Context, query model.Query, t time.Time) ([]model.MetricSeries, error) {
+	var res []model.MetricSeries
+	err := c.call(ctx, query.DatasourceID, func() (err error) {
+		res, err = c.next.GatherSingle(ctx, query, t)
+		return err
+	})
+	return res, err
+}
+
+func (c *circuitBreaker) GatherRange(ctx context.Context, query model.Query, start, end time.Time, step time.Duration) ([]model.MetricSeries, error) {
+	var res []model.MetricSeries
+	err := c.call(ctx, query.DatasourceID, func() (err error) {
+		res, err = c.next.GatherRange(ctx, query, start, end, step)
+		return err
+	})
+	return res, err
+}
+
+// DiscoverValues is rejected when the circuit is open but its result doesn't
+// change the circuit state, the datasource could not support the discovery.
+func (c *circuitBreaker) DiscoverValues(ctx context.Context, query model.Query) ([]string, error) {
+	d, ok := c.next.(metric.Discoverer)
+	if !ok {
+		return nil, fmt.Errorf("gatherer does not support values discovery")
+	}
+
+	err := c.rejectOpen(query.DatasourceID)
+	if err != nil {
+		return nil, err
+	}
+	return d.DiscoverValues(ctx, query)
+}
+
+// GatherMetadata is rejected when the circuit is open but its result doesn't
+// change the circuit state, the datasource could not support the metadata.
+func (c *circuitBreaker) GatherMetadata(ctx context.Context, query model.Query) ([]model.MetricMetadata, error) {
+	m, ok := c.next.(metric.MetadataGatherer)
+	if !ok {
+		return nil, metric.ErrMetadataNotSupported
+	}
+
+	err := c.rejectOpen(query.DatasourceID)
+	if err != nil {
+		return nil, err
+	}
+	return m.GatherMetadata(ctx, query)
+}
+
+func (c *circuitBreaker) DatasourcesHealth() []model.DatasourceHealth {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	res := make([]model.DatasourceHealth, 0, len(c.circuits))
+	for id, cr := range c.circuits {
+		h := model.DatasourceHealth{
+			DatasourceID:        id,
+			State:               cr.state,
+			ConsecutiveFailures: cr.failures,
+			LastError:           cr.lastError,
+			Since:               cr.since,
+		}
+		if cr.state == model.CircuitOpen {
+			h.RetryAt = cr.since.Add(c.cfg.CoolDown)
+		}
+		res = append(res, h)
+	}
+	sort.Slice(res, func(i, j int) bool { return res[i].DatasourceID < res[j].DatasourceID })
+
+	return res
+}
+
+// call calls the function if the circuit of the datasource lets the query
+// reach the datasource and updates the circuit with the result.
+func (c *circuitBreaker) call(ctx context.Context, dsID string, f func() error) error {
+	probe, err := c.allow(dsID)
+	if err != nil {
+		return err
+	}
+
+	err = f()
+
+	// The queries canceled by the caller don't say anything about the
+	// datasource health.
+	if err != nil && ctx.Err() == context.Canceled {
+		c.release(dsID, probe)
+		return err
+	}
+
+	// The queries that failed without a datasource problem (e.g a bad query
+	// or an auth error) prove the datasource is answering.
+	if err != nil && !datasourceFailure(err) {
+		c.record(dsID, probe, nil)
+		return err
+	}
+
+	c.record(dsID, probe, err)
+	return err
+}
+
+// datasourceFailure returns true if the error is a failure of the datasource
+// that counts to open its circuit.
+func datasourceFailure(err error) bool {
+	switch metric.ErrorClassOf(err) {
+	case metric.ErrorClassUnavailable, metric.ErrorClassTimeout, metric.ErrorClassRateLimited:
+		return true
+	}
+	return false
+}
+
+// allow returns an error if the query should be rejected, and if the query
+// is the probe of a half-open circuit.
+func (c *circuitBreaker) allow(dsID string) (probe bool, err error) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	cr := c.circuit(dsID)
+	now := time.Now()
+	if cr.state == model.CircuitOpen && now.Sub(cr.since) >= c.cfg.CoolDown {
+		cr.state = model.CircuitHalfOpen
+		cr.since = now
+	}
+
+	switch {
+	case cr.state == model.CircuitOpen:
+		return false, c.openErr(dsID, cr)
+	case cr.state == model.CircuitHalfOpen && cr.probing:
+		return false, c.openErr(dsID, cr)
+	case cr.state == model.CircuitHalfOpen:
+		cr.probing = true
+		return true, nil
+	}
+
+	return false, nil
+}
+
+// rejectOpen returns an error if the circuit of the datasource doesn't let
+// the queries reach the datasource.
+func (c *circuitBreaker) rejectOpen(dsID string) error {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	cr, ok := c.circuits[dsID]
+	if !ok || cr.state == model.CircuitClosed {
+		return nil
+	}
+
+	return c.openErr(dsID, cr)
+}
+
+// record updates the circuit of the datasource with the result of a query.
+func (c *circuitBreaker) record(dsID string, probe bool, err error) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	cr := c.circuit(dsID)
+	if probe {
+		cr.probing = false
+	}
+
+	if err == nil {
+		if cr.state != model.CircuitClosed {
+			cr.state = model.CircuitClosed
+			cr.since = time.Now()
+		}
+		cr.failures = 0
+		return
+	}
+
+	cr.failures++
+	cr.lastError = err.Error()
+
+	// A failed probe or too many failures open the circuit.
+	if probe || (cr.state == model.CircuitClosed && cr.failures >= c.cfg.FailureThreshold) {
+		cr.state = model.CircuitOpen
+		cr.since = time.Now()
+	}
+}
+
+// release lets other query probe the datasource if the query was the probe.
+func (c *circuitBreaker) release(dsID string, probe bool) {
+	if !probe {
+		return
+	}
+
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	c.circuit(dsID).probing = false
+}
+
+// circuit returns the circuit of the datasource, it needs to be called with
+// the lock acquired.
+func (c *circuitBreaker) circuit(dsID string) *circuit {
+	cr, ok := c.circuits[dsID]
+	if !ok {
+		cr = &circuit{state: model.CircuitClosed, since: time.Now()}
+		c.circuits[dsID] = cr
+	}
+	return cr
+}
+
+func (c *circuitBreaker) openErr(dsID string, cr *circuit) error {
+	return fmt.Errorf("datasource %s is unhealthy after %d consecutive failures (last error: %s): %w", dsID, cr.failures, cr.lastError, ErrCircuitOpen)
+}
@@ -0,0 +1,217 @@
+package middleware_test
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/mock"
+	"github.com/stretchr/testify/require"
+
+	mmetric "github.com/slok/grafterm/internal/mocks/service/metric"
+	"github.com/slok/grafterm/internal/model"
+	"github.com/slok/grafterm/internal/service/metric"
+	"github.com/slok/grafterm/internal/service/metric/middleware"
+)
+
+func TestCircuitBreaker(t *testing.T) {
+	qa := model.Query{DatasourceID: "a", Expr: "up"}
+	qb := model.Query{DatasourceID: "b", Expr: "up"}
+	errWanted := metric.NewError(metric.ErrorClassUnavailable, errors.New("wanted error"))
+	errBadQuery := metric.NewError(metric.ErrorClassBadQuery, errors.New("bad query"))
+	coolDown := 50 * time.Millisecond
+
+	tests := []struct {
+		name      string
+		mock      func(m *mmetric.Gatherer)
+		run       func(t *testing.T, g metric.Gatherer)
+		expHealth []model.DatasourceHealth
+	}{
+		{
+			name: "Successful queries should maintain the circuit closed.",
+			mock: func(m *mmetric.Gatherer) {
+				m.On("GatherSingle", mock.Anything, qa, t0).Times(3).Return([]model.MetricSeries{}, nil)
+			},
+			run: func(t *testing.T, g metric.Gatherer) {
+				for i := 0; i < 3; i++ {
+					_, err := g.GatherSingle(context.TODO(), qa, t0)
+					assert.NoError(t, err)
+				}
+			},
+			expHealth: []model.DatasourceHealth{
+				{DatasourceID: "a", State: model.CircuitClosed},
+			},
+		},
+		{
+			name: "Consecutive failures should open the circuit and reject the queries only of the failing datasource.",
+			mock: func(m *mmetric.Gatherer) {
+				m.On("GatherRange", mock.Anything, qa, t0, ts(10), time.Minute).Times(3).Return(nil, errWanted)
+				m.On("GatherRange", mock.Anything, qb, t0, ts(10), time.Minute).Once().Return([]model.MetricSeries{}, nil)
+			},
+			run: func(t *testing.T, g metric.Gatherer) {
+				for i := 0; i < 3; i++ {
+					_, err := g.GatherRange(context.TODO(), qa, t0, ts(10), time.Minute)
+					assert.Error(t, err)
+					assert.False(t, errors.Is(err, middleware.ErrCircuitOpen))
+				}
+
+				// Rejected without reaching the datasource.
+				_, err := g.GatherRange(context.TODO(), qa, t0, ts(10), time.Minute)
+				assert.True(t, errors.Is(err, middleware.ErrCircuitOpen))
+
+				_, err = g.GatherRange(context.TODO(), qb, t0, ts(10), time.Minute)
+				assert.NoError(t, err)
+			},
+			expHealth: []model.DatasourceHealth{
+				{DatasourceID: "a", State: model.CircuitOpen, ConsecutiveFailures: 3, LastError: "wanted error"},
+				{DatasourceID: "b", State: model.CircuitClosed},
+			},
+		},
+		{
+			name: "A success should reset the consecutive failures.",
+			mock: func(m *mmetric.Gatherer) {
+				m.On("GatherSingle", mock.Anything, qa, t0).Twice().Return(nil, errWanted)
+				m.On("GatherSingle", mock.Anything, qa, t0).Once().Return([]model.MetricSeries{}, nil)
+				m.On("GatherSingle", mock.Anything, qa, t0).Twice().Return(nil, errWanted)
+			},
+			run: func(t *testing.T, g metric.Gatherer) {
+				for i := 0; i < 5; i++ {
+					_, _ = g.GatherSingle(context.TODO(), qa, t0)
+				}
+			},
+			expHealth: []model.DatasourceHealth{
+				{DatasourceID: "a", State: model.CircuitClosed, ConsecutiveFailures: 2, LastError: "wanted error"},
+			},
+		},
+		{
+			name: "After the cool-down a successful probe should close the circuit.",
+			mock: func(m *mmetric.Gatherer) {
+				m.On("GatherSingle", mock.Anything, qa, t0).Times(3).Return(nil, errWanted)
+				m.On("GatherSingle", mock.Anything, qa, t0).Once().Return([]model.MetricSeries{}, nil)
+			},
+			run: func(t *testing.T, g metric.Gatherer) {
+				for i := 0; i < 3; i++ {
+					_, _ = g.GatherSingle(context.TODO(), qa, t0)
+				}
+				time.Sleep(coolDown)
+
+				_, err := g.GatherSingle(context.TODO(), qa, t0)
+				assert.NoError(t, err)
+			},
+			expHealth: []model.DatasourceHealth{
+				{DatasourceID: "a", State: model.CircuitClosed, LastError: "wanted error"},
+			},
+		},
+		{
+			name: "After the cool-down a failed probe should open the circuit again.",
+			mock: func(m *mmetric.Gatherer) {
+				m.On("GatherSingle", mock.Anything, qa, t0).Times(4).Return(nil, errWanted)
+			},
+			run: func(t *testing.T, g metric.Gatherer) {
+				for i := 0; i < 3; i++ {
+					_, _ = g.GatherSingle(context.TODO(), qa, t0)
+				}
+				time.Sleep(coolDown)
+
+				_, err := g.GatherSingle(context.TODO(), qa, t0)
+				assert.False(t, errors.Is(err, middleware.ErrCircuitOpen))
+				_, err = g.GatherSingle(context.TODO(), qa, t0)
+				assert.True(t, errors.Is(err, middleware.ErrCircuitOpen))
+			},
+			expHealth: []model.DatasourceHealth{
+				{DatasourceID: "a", State: model.CircuitOpen, ConsecutiveFailures: 4, LastError: "wanted error"},
+			},
+		},
+		{
+			name: "While the half-open probe is in flight the other queries should be rejected.",
+			mock: func(m *mmetric.Gatherer) {
+				m.On("GatherSingle", mock.Anything, qa, t0).Times(3).Return(nil, errWanted)
+				m.On("GatherSingle", mock.Anything, qa, t0).Once().Return([]model.MetricSeries{}, nil).WaitUntil(time.After(100 * time.Millisecond))
+			},
+			run: func(t *testing.T, g metric.Gatherer) {
+				for i := 0; i < 3; i++ {
+					_, _ = g.GatherSingle(context.TODO(), qa, t0)
+				}
+				time.Sleep(coolDown)
+
+				probeErr := make(chan error)
+				go func() {
+					_, err := g.GatherSingle(context.TODO(), qa, t0)
+					probeErr <- err
+				}()
+				require.Eventually(t, func() bool {
+					hs := g.(metric.HealthReporter).DatasourcesHealth()
+					return hs[0].State == model.CircuitHalfOpen
+				}, time.Second, 5*time.Millisecond)
+
+				_, err := g.GatherSingle(context.TODO(), qa, t0)
+				assert.True(t, errors.Is(err, middleware.ErrCircuitOpen))
+				assert.NoError(t, <-probeErr)
+			},
+			expHealth: []model.DatasourceHealth{
+				{DatasourceID: "a", State: model.CircuitClosed, LastError: "wanted error"},
+			},
+		},
+		{
+			name: "Queries that failed without a datasource problem should not open the circuit.",
+			mock: func(m *mmetric.Gatherer) {
+				m.On("GatherSingle", mock.Anything, qa, t0).Times(5).Return(nil, errBadQuery)
+				m.On("GatherSingle", mock.Anything, qa, t0).Once().Return(nil, metric.NewError(metric.ErrorClassAuth, errors.New("forbidden")))
+			},
+			run: func(t *testing.T, g metric.Gatherer) {
+				for i := 0; i < 6; i++ {
+					_, err := g.GatherSingle(context.TODO(), qa, t0)
+					assert.Error(t, err)
+					assert.False(t, errors.Is(err, middleware.ErrCircuitOpen))
+				}
+			},
+			expHealth: []model.DatasourceHealth{
+				{DatasourceID: "a", State: model.CircuitClosed},
+			},
+		},
+		{
+			name: "Queries canceled by the caller should not be counted as failures.",
+			mock: func(m *mmetric.Gatherer) {
+				m.On("GatherSingle", mock.Anything, qa, t0).Times(5).Return(nil, context.Canceled)
+			},
+			run: func(t *testing.T, g metric.Gatherer) {
+				ctx, cancel := context.WithCancel(context.TODO())
+				cancel()
+				for i := 0; i < 5; i++ {
+					_, err := g.GatherSingle(ctx, qa, t0)
+					assert.False(t, errors.Is(err, middleware.ErrCircuitOpen))
+				}
+			},
+			expHealth: []model.DatasourceHealth{
+				{DatasourceID: "a", State: model.CircuitClosed},
+			},
+		},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			assert := assert.New(t)
+
+			mg := &mmetric.Gatherer{}
+			test.mock(mg)
+
+			g := middleware.CircuitBreaker(middleware.CircuitBreakerConfig{
+				FailureThreshold: 3,
+				CoolDown:         coolDown,
+			}, mg)
+			test.run(t, g)
+
+			mg.AssertExpectations(t)
+
+			// Ignore the times.
+			gotHealth := g.(metric.HealthReporter).DatasourcesHealth()
+			for i := range gotHealth {
+				gotHealth[i].Since = time.Time{}
+				gotHealth[i].RetryAt = time.Time{}
+			}
+			assert.Equal(test.expHealth, gotHealth)
+		})
+	}
+}
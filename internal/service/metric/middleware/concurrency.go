@@ -0,0 +1,87 @@
+package middleware
+
+import (
+	"context"
+	"fmt"
+	"time"
+
+	"github.com/slok/grafterm/internal/model"
+	"github.com/slok/grafterm/internal/service/metric"
+)
+
+// ConcurrencyLimitConfig is the configuration of the ConcurrencyLimit middleware.
+type ConcurrencyLimitConfig struct {
+	// MaxConcurrent is the maximum number of queries made at the same time.
+	MaxConcurrent int
+}
+
+type concurrencyLimit struct {
+	next metric.Gatherer
+	sem  chan struct{}
+}
+
+// ConcurrencyLimit is a gatherer middleware that limits the number of queries
+// made at the same time, the queries wait until there is a free slot or the
+// context is done.
+func ConcurrencyLimit(cfg ConcurrencyLimitConfig, next metric.Gatherer) metric.Gatherer {
+	return &concurrencyLimit{
+		next: next,
+		sem:  make(chan struct{}, cfg.MaxConcurrent),
+	}
+}
+
+func (c *concurrencyLimit) GatherSingle(ctx context.Context, query model.Query, t time.Time) ([]model.MetricSeries, error) {
+	release, err := c.acquire(ctx)
+	if err != nil {
+		return nil, err
+	}
+	defer release()
+	return c.next.GatherSingle(ctx, query, t)
+}
+
+func (c *concurrencyLimit) GatherRange(ctx context.Context, query model.Query, start, end time.Time, step time.Duration) ([]model.MetricSeries, error) {
+	release, err := c.acquire(ctx)
+	if err != nil {
+		return nil, err
+	}
+	defer release()
+	return c.next.GatherRange(ctx, query, start, end, step)
+}
+
+func (c *concurrencyLimit) DiscoverValues(ctx context.Context, query model.Query) ([]string, error) {
+	d, ok := c.next.(metric.Discoverer)
+	if !ok {
+		return nil, fmt.Errorf("gatherer does not support values discovery")
+	}
+
+	release, err := c.acquire(ctx)
+	if err != nil {
+		return nil, err
+	}
+	defer release()
+	return d.DiscoverValues(ctx, query)
+}
+
+func (c *concurrencyLimit) GatherMetadata(ctx context.Context, query model.Query) ([]model.MetricMetadata, error) {
+	m, ok := c.next.(metric.MetadataGatherer)
+	if !ok {
+		return nil, metric.ErrMetadataNotSupported
+	}
+
+	release, err := c.acquire(ctx)
+	if err != nil {
+		return nil, err
+	}
+	defer release()
+	return m.GatherMetadata(ctx, query)
+}
+
+// acquire waits for a free slot and returns the function that releases it.
+func (c *concurrencyLimit) acquire(ctx context.Context) (func(), error) {
+	select {
+	case c.sem <- struct{}{}:
+		return func() { <-c.sem }, nil
+	case <-ctx.Done():
+		return nil, fmt.Errorf("timeout waiting for a free concurrent query slot: %w", ctx.Err())
+	}
+}
@@ -0,0 +1,36 @@
+package middleware
+
+import (
+	"github.com/slok/grafterm/internal/service/metric"
+)
+
+// EnhancedFeatures wraps the gatherer with the middlewares of the enabled
+// features, from the inner to the outer: timeout, concurrency limit, retry
+// and cache (single and range queries). This way every retry attempt has its
+// own timeout and waits for its own concurrent query slot, and the cached
+// queries don't use any of them.
+func EnhancedFeatures(cfg metric.EnhancedFeaturesConfig, next metric.Gatherer) metric.Gatherer {
+	if !cfg.Enabled {
+		return next
+	}
+
+	g := next
+	if cfg.QueryTimeout > 0 {
+		g = Timeout(TimeoutConfig{Timeout: cfg.QueryTimeout}, g)
+	}
+
+	if cfg.MaxConcurrentQueries > 0 {
+		g = ConcurrencyLimit(ConcurrencyLimitConfig{MaxConcurrent: cfg.MaxConcurrentQueries}, g)
+	}
+
+	if cfg.EnableRetry && cfg.MaxRetries > 0 {
+		g = Retry(RetryConfig{MaxRetries: cfg.MaxRetries}, g)
+	}
+
+	if cfg.EnableCaching {
+		g = Cache(CacheConfig{Size: cfg.CacheSize, TTL: cfg.CacheTTL}, g)
+		g = RangeCache(RangeCacheConfig{}, g)
+	}
+
+	return g
+}
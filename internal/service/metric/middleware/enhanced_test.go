@@ -0,0 +1,347 @@
+package middleware_test
+
+import (
+	"context"
+	"errors"
+	"sync"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/mock"
+
+	mmetric "github.com/slok/grafterm/internal/mocks/service/metric"
+	"github.com/slok/grafterm/internal/model"
+	"github.com/slok/grafterm/internal/service/metric"
+	"github.com/slok/grafterm/internal/service/metric/middleware"
+)
+
+func TestEnhancedFeatures(t *testing.T) {
+	q := model.Query{DatasourceID: "a", Expr: "up"}
+	errWanted := errors.New("wanted error")
+	errUnavailable := metric.NewError(metric.ErrorClassUnavailable, errors.New("unavailable"))
+	ms := []model.MetricSeries{series("s0", 0, 2)}
+
+	tests := []struct {
+		name string
+		cfg  metric.EnhancedFeaturesConfig
+		mock func(m *mmetric.Gatherer)
+		run  func(t *testing.T, g metric.Gatherer)
+	}{
+		{
+			name: "Disabled enhanced features should not wrap the queries.",
+			cfg: metric.EnhancedFeaturesConfig{
+				Enabled:       false,
+				EnableCaching: true,
+				EnableRetry:   true,
+				MaxRetries:    3,
+			},
+			mock: func(m *mmetric.Gatherer) {
+				m.On("GatherSingle", mock.Anything, q, t0).Twice().Return(ms, nil)
+				m.On("GatherRange", mock.Anything, q, t0, ts(10), time.Minute).Once().Return(nil, errWanted)
+			},
+			run: func(t *testing.T, g metric.Gatherer) {
+				for i := 0; i < 2; i++ {
+					_, err := g.GatherSingle(context.TODO(), q, t0)
+					assert.NoError(t, err)
+				}
+
+				_, err := g.GatherRange(context.TODO(), q, t0, ts(10), time.Minute)
+				assert.Equal(t, errWanted, err)
+			},
+		},
+		{
+			name: "Cache should return the cached series of the single queries on the same time.",
+			cfg: metric.EnhancedFeaturesConfig{
+				Enabled:       true,
+				EnableCaching: true,
+				CacheTTL:      time.Minute,
+			},
+			mock: func(m *mmetric.Gatherer) {
+				m.On("GatherSingle", mock.Anything, q, t0).Once().Return(ms, nil)
+				m.On("GatherSingle", mock.Anything, q, ts(1)).Once().Return(ms, nil)
+			},
+			run: func(t *testing.T, g metric.Gatherer) {
+				for i := 0; i < 3; i++ {
+					got, err := g.GatherSingle(context.TODO(), q, t0)
+					assert.NoError(t, err)
+					assert.Equal(t, ms, got)
+
+					_, err = g.GatherSingle(context.TODO(), q, ts(1))
+					assert.NoError(t, err)
+				}
+			},
+		},
+		{
+			name: "Cache should not return the cached series of the refreshes in live mode.",
+			cfg: metric.EnhancedFeaturesConfig{
+				Enabled:       true,
+				EnableCaching: true,
+				CacheTTL:      30 * time.Second,
+			},
+			mock: func(m *mmetric.Gatherer) {
+				m.On("GatherSingle", mock.Anything, q, t0).Once().Return([]model.MetricSeries{series("s0", 0, 1)}, nil)
+				m.On("GatherSingle", mock.Anything, q, t0.Add(10*time.Second)).Once().Return(ms, nil)
+			},
+			run: func(t *testing.T, g metric.Gatherer) {
+				got1, err := g.GatherSingle(context.TODO(), q, t0)
+				assert.NoError(t, err)
+				got2, err := g.GatherSingle(context.TODO(), q, t0.Add(10*time.Second))
+				assert.NoError(t, err)
+
+				assert.NotEqual(t, got1, got2)
+				assert.Equal(t, ms, got2)
+			},
+		},
+		{
+			name: "Cache should gather only the missing tail of the range queries.",
+			cfg: metric.EnhancedFeaturesConfig{
+				Enabled:       true,
+				EnableCaching: true,
+				CacheTTL:      time.Minute,
+			},
+			mock: func(m *mmetric.Gatherer) {
+				m.On("GatherRange", mock.Anything, q, t0, ts(2), time.Minute).Once().Return(ms, nil)
+				m.On("GatherRange", mock.Anything, q, ts(2), ts(3), time.Minute).Once().Return([]model.MetricSeries{series("s0", 2, 3)}, nil)
+			},
+			run: func(t *testing.T, g metric.Gatherer) {
+				_, err := g.GatherRange(context.TODO(), q, t0, ts(2), time.Minute)
+				assert.NoError(t, err)
+
+				got, err := g.GatherRange(context.TODO(), q, ts(1), ts(3), time.Minute)
+				assert.NoError(t, err)
+				assert.Equal(t, []model.MetricSeries{series("s0", 1, 3)}, got)
+			},
+		},
+		{
+			name: "Disabled cache should not cache the single nor the range queries.",
+			cfg: metric.EnhancedFeaturesConfig{
+				Enabled:       true,
+				EnableCaching: false,
+			},
+			mock: func(m *mmetric.Gatherer) {
+				m.On("GatherSingle", mock.Anything, q, t0).Times(3).Return(ms, nil)
+				m.On("GatherRange", mock.Anything, q, t0, ts(10), time.Minute).Times(3).Return(ms, nil)
+			},
+			run: func(t *testing.T, g metric.Gatherer) {
+				for i := 0; i < 3; i++ {
+					got, err := g.GatherSingle(context.TODO(), q, t0)
+					assert.NoError(t, err)
+					assert.Equal(t, ms, got)
+
+					got, err = g.GatherRange(context.TODO(), q, t0, ts(10), time.Minute)
+					assert.NoError(t, err)
+					assert.Equal(t, ms, got)
+				}
+			},
+		},
+		{
+			name: "Cache should return a copy of the cached series.",
+			cfg: metric.EnhancedFeaturesConfig{
+				Enabled:       true,
+				EnableCaching: true,
+				CacheTTL:      time.Minute,
+			},
+			mock: func(m *mmetric.Gatherer) {
+				m.On("GatherSingle", mock.Anything, q, t0).Once().Return([]model.MetricSeries{series("s0", 0, 2)}, nil)
+			},
+			run: func(t *testing.T, g metric.Gatherer) {
+				got, err := g.GatherSingle(context.TODO(), q, t0)
+				assert.NoError(t, err)
+				got[0].Metrics[0].Value = 1000
+
+				got, err = g.GatherSingle(context.TODO(), q, t0)
+				assert.NoError(t, err)
+				assert.Equal(t, ms, got)
+				got[0].Metrics[0].Value = 1000
+
+				got, err = g.GatherSingle(context.TODO(), q, t0)
+				assert.NoError(t, err)
+				assert.Equal(t, ms, got)
+			},
+		},
+		{
+			name: "Cache should not cache the failed queries.",
+			cfg: metric.EnhancedFeaturesConfig{
+				Enabled:       true,
+				EnableCaching: true,
+			},
+			mock: func(m *mmetric.Gatherer) {
+				m.On("GatherSingle", mock.Anything, q, t0).Once().Return(nil, errWanted)
+				m.On("GatherSingle", mock.Anything, q, t0).Once().Return(ms, nil)
+			},
+			run: func(t *testing.T, g metric.Gatherer) {
+				_, err := g.GatherSingle(context.TODO(), q, t0)
+				assert.Error(t, err)
+
+				for i := 0; i < 2; i++ {
+					got, err := g.GatherSingle(context.TODO(), q, t0)
+					assert.NoError(t, err)
+					assert.Equal(t, ms, got)
+				}
+			},
+		},
+		{
+			name: "Cache should evict the queries when is full.",
+			cfg: metric.EnhancedFeaturesConfig{
+				Enabled:       true,
+				EnableCaching: true,
+				CacheSize:     1,
+			},
+			mock: func(m *mmetric.Gatherer) {
+				m.On("GatherSingle", mock.Anything, q, t0).Twice().Return(ms, nil)
+				m.On("GatherSingle", mock.Anything, q, ts(1)).Once().Return(ms, nil)
+			},
+			run: func(t *testing.T, g metric.Gatherer) {
+				_, _ = g.GatherSingle(context.TODO(), q, t0)
+				_, _ = g.GatherSingle(context.TODO(), q, ts(1))
+				_, _ = g.GatherSingle(context.TODO(), q, t0)
+			},
+		},
+		{
+			name: "Retry should retry the failed queries until they succeed.",
+			cfg: metric.EnhancedFeaturesConfig{
+				Enabled:     true,
+				EnableRetry: true,
+				MaxRetries:  3,
+			},
+			mock: func(m *mmetric.Gatherer) {
+				m.On("GatherRange", mock.Anything, q, t0, ts(10), time.Minute).Twice().Return(nil, errUnavailable)
+				m.On("GatherRange", mock.Anything, q, t0, ts(10), time.Minute).Once().Return(ms, nil)
+			},
+			run: func(t *testing.T, g metric.Gatherer) {
+				got, err := g.GatherRange(context.TODO(), q, t0, ts(10), time.Minute)
+				assert.NoError(t, err)
+				assert.Equal(t, ms, got)
+			},
+		},
+		{
+			name: "Retry should return the error when the retries are exhausted.",
+			cfg: metric.EnhancedFeaturesConfig{
+				Enabled:     true,
+				EnableRetry: true,
+				MaxRetries:  1,
+			},
+			mock: func(m *mmetric.Gatherer) {
+				m.On("GatherSingle", mock.Anything, q, t0).Twice().Return(nil, errUnavailable)
+			},
+			run: func(t *testing.T, g metric.Gatherer) {
+				_, err := g.GatherSingle(context.TODO(), q, t0)
+				assert.True(t, errors.Is(err, errUnavailable))
+				assert.Equal(t, metric.ErrorClassUnavailable, metric.ErrorClassOf(err))
+			},
+		},
+		{
+			name: "Retry should not retry the queries that failed with a non transient error.",
+			cfg: metric.EnhancedFeaturesConfig{
+				Enabled:     true,
+				EnableRetry: true,
+				MaxRetries:  3,
+			},
+			mock: func(m *mmetric.Gatherer) {
+				m.On("GatherSingle", mock.Anything, q, t0).Once().Return(nil, metric.NewError(metric.ErrorClassBadQuery, errWanted))
+				m.On("GatherRange", mock.Anything, q, t0, ts(10), time.Minute).Once().Return(nil, errWanted)
+			},
+			run: func(t *testing.T, g metric.Gatherer) {
+				_, err := g.GatherSingle(context.TODO(), q, t0)
+				assert.Equal(t, metric.ErrorClassBadQuery, metric.ErrorClassOf(err))
+
+				_, err = g.GatherRange(context.TODO(), q, t0, ts(10), time.Minute)
+				assert.Equal(t, errWanted, err)
+			},
+		},
+		{
+			name: "Retry should wait the time asked by the datasource before retrying.",
+			cfg: metric.EnhancedFeaturesConfig{
+				Enabled:     true,
+				EnableRetry: true,
+				MaxRetries:  1,
+			},
+			mock: func(m *mmetric.Gatherer) {
+				err := &metric.Error{Class: metric.ErrorClassRateLimited, RetryAfter: 50 * time.Millisecond, Err: errWanted}
+				m.On("GatherSingle", mock.Anything, q, t0).Once().Return(nil, err)
+				m.On("GatherSingle", mock.Anything, q, t0).Once().Return(ms, nil)
+			},
+			run: func(t *testing.T, g metric.Gatherer) {
+				start := time.Now()
+				_, err := g.GatherSingle(context.TODO(), q, t0)
+				assert.NoError(t, err)
+				assert.True(t, time.Since(start) >= 50*time.Millisecond)
+			},
+		},
+		{
+			name: "Timeout should cancel the slow queries and they should not be retried.",
+			cfg: metric.EnhancedFeaturesConfig{
+				Enabled:      true,
+				QueryTimeout: 20 * time.Millisecond,
+				EnableRetry:  true,
+				MaxRetries:   3,
+			},
+			mock: func(m *mmetric.Gatherer) {
+				m.On("GatherSingle", mock.Anything, q, t0).Once().Run(func(args mock.Arguments) {
+					<-args.Get(0).(context.Context).Done()
+				}).Return(nil, context.DeadlineExceeded)
+			},
+			run: func(t *testing.T, g metric.Gatherer) {
+				_, err := g.GatherSingle(context.TODO(), q, t0)
+				assert.True(t, errors.Is(err, context.DeadlineExceeded))
+			},
+		},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			// Mocks.
+			mg := &mmetric.Gatherer{}
+			test.mock(mg)
+
+			// Run.
+			g := middleware.EnhancedFeatures(test.cfg, mg)
+			test.run(t, g)
+
+			// Check.
+			mg.AssertExpectations(t)
+		})
+	}
+}
+
+func TestEnhancedFeaturesConcurrencyLimit(t *testing.T) {
+	assert := assert.New(t)
+
+	q := model.Query{DatasourceID: "a", Expr: "up"}
+	maxConcurrent := 2
+
+	var running, maxRunning int32
+	mg := &mmetric.Gatherer{}
+	mg.On("GatherSingle", mock.Anything, q, t0).Run(func(args mock.Arguments) {
+		r := atomic.AddInt32(&running, 1)
+		for {
+			m := atomic.LoadInt32(&maxRunning)
+			if r <= m || atomic.CompareAndSwapInt32(&maxRunning, m, r) {
+				break
+			}
+		}
+		time.Sleep(10 * time.Millisecond)
+		atomic.AddInt32(&running, -1)
+	}).Return([]model.MetricSeries{}, nil)
+
+	g := middleware.EnhancedFeatures(metric.EnhancedFeaturesConfig{
+		Enabled:              true,
+		MaxConcurrentQueries: maxConcurrent,
+	}, mg)
+
+	var wg sync.WaitGroup
+	for i := 0; i < 10; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			_, err := g.GatherSingle(context.TODO(), q, t0)
+			assert.NoError(err)
+		}()
+	}
+	wg.Wait()
+
+	assert.Equal(int32(maxConcurrent), atomic.LoadInt32(&maxRunning))
+	mg.AssertNumberOfCalls(t, "GatherSingle", 10)
+}
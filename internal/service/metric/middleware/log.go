@@ -2,6 +2,7 @@ package middleware
 
 import (
 	"context"
+	"fmt"
 	"time"
 
 	"github.com/slok/grafterm/internal/model"
@@ -37,3 +38,37 @@ func (l *logger) GatherRange(ctx context.Context, query model.Query, start, end
 	}()
 	return l.next.GatherRange(ctx, query, start, end, step)
 }
+
+func (l *logger) DiscoverValues(ctx context.Context, query model.Query) ([]string, error) {
+	d, ok := l.next.(metric.Discoverer)
+	if !ok {
+		return nil, fmt.Errorf("gatherer does not support values discovery")
+	}
+
+	st := time.Now()
+	defer func() {
+		l.logger.Infof("(%s) discovering values on %s: %s", time.Since(st), query.DatasourceID, query.Expr)
+	}()
+	return d.DiscoverValues(ctx, query)
+}
+
+func (l *logger) GatherMetadata(ctx context.Context, query model.Query) ([]model.MetricMetadata, error) {
+	m, ok := l.next.(metric.MetadataGatherer)
+	if !ok {
+		return nil, metric.ErrMetadataNotSupported
+	}
+
+	st := time.Now()
+	defer func() {
+		l.logger.Infof("(%s) gathering metrics metadata on %s: %s", time.Since(st), query.DatasourceID, query.Expr)
+	}()
+	return m.GatherMetadata(ctx, query)
+}
+
+func (l *logger) DatasourcesHealth() []model.DatasourceHealth {
+	h, ok := l.next.(metric.HealthReporter)
+	if !ok {
+		return nil
+	}
+	return h.DatasourcesHealth()
+}
@@ -0,0 +1,226 @@
+package middleware
+
+import (
+	"context"
+	"fmt"
+	"sync"
+	"time"
+
+	"github.com/slok/grafterm/internal/model"
+	"github.com/slok/grafterm/internal/service/metric"
+)
+
+const defRangeCacheMaxIdle = 10 * time.Minute
+
+// RangeCacheConfig is the configuration of the RangeCache middleware.
+type RangeCacheConfig struct {
+	// MaxIdle is the time a cached query is maintained without being used.
+	MaxIdle time.Duration
+}
+
+func (c *RangeCacheConfig) defaults() {
+	if c.MaxIdle <= 0 {
+		c.MaxIdle = defRangeCacheMaxIdle
+	}
+}
+
+// rangeCacheKey identifies the cached series of a range query.
+type rangeCacheKey struct {
+	datasourceID string
+	expr         string
+	step         time.Duration
+}
+
+// rangeCacheEntry are the cached series of a range query. The points
+// of the series are aligned to the steps from the start.
+type rangeCacheEntry struct {
+	start    time.Time
+	end      time.Time
+	series   []model.MetricSeries
+	lastUsed time.Time
+}
+
+type rangeCache struct {
+	cfg  RangeCacheConfig
+	next metric.Gatherer
+
+	mu      sync.Mutex
+	entries map[rangeCacheKey]*rangeCacheEntry
+}
+
+// RangeCache is a gatherer middleware that caches the series of the range
+// queries by datasource, query and step. When the time range of a query
+// moves forward (e.g relative time ranges), only the missing tail since the
+// last gathered end will be gathered, merged with the cached series and
+// the points outside the time range evicted.
+func RangeCache(cfg RangeCacheConfig, next metric.Gatherer) metric.Gatherer {
+	cfg.defaults()
+
+	return &rangeCache{
+		cfg:     cfg,
+		next:    next,
+		entries: map[rangeCacheKey]*rangeCacheEntry{},
+	}
+}
+
+func (r *rangeCache) GatherSingle(ctx context.Context, query model.Query, t time.Time) ([]model.MetricSeries, error) {
+	return r.next.GatherSingle(ctx, query, t)
+}
+
+func (r *rangeCache) GatherRange(ctx context.Context, query model.Query, start, end time.Time, step time.Duration) ([]model.MetricSeries, error) {
+	// Without step we can't align the points.
+	if step <= 0 {
+		return r.next.GatherRange(ctx, query, start, end, step)
+	}
+
+	key := rangeCacheKey{
+		datasourceID: query.DatasourceID,
+		expr:         query.Expr,
+		step:         step,
+	}
+
+	r.mu.Lock()
+	r.evictIdle(time.Now())
+	entry, ok := r.entries[key]
+	r.mu.Unlock()
+
+	// If the cached range can't be reused gather all the range again.
+	if !ok || start.Before(entry.start) || !start.Before(entry.end) || end.Before(entry.end) {
+		series, err := r.next.GatherRange(ctx, query, start, end, step)
+		if err != nil {
+			return nil, err
+		}
+
+		r.mu.Lock()
+		r.entries[key] = &rangeCacheEntry{
+			start:    start,
+			end:      end,
+			series:   copySeries(series),
+			lastUsed: time.Now(),
+		}
+		r.mu.Unlock()
+
+		return series, nil
+	}
+
+	// Gather the tail starting on the last step of the cached range, this
+	// way the tail points are aligned with the cached ones and the last
+	// cached point (that could be incomplete) is refreshed.
+	tailStart := entry.start.Add(entry.end.Sub(entry.start) / step * step)
+	tail, err := r.next.GatherRange(ctx, query, tailStart, end, step)
+	if err != nil {
+		return nil, err
+	}
+
+	series := mergeSeries(entry.series, tail, start, tailStart)
+
+	r.mu.Lock()
+	r.entries[key] = &rangeCacheEntry{
+		start:    entry.start.Add(start.Sub(entry.start) / step * step),
+		end:      end,
+		series:   series,
+		lastUsed: time.Now(),
+	}
+	r.mu.Unlock()
+
+	return copySeries(series), nil
+}
+
+func (r *rangeCache) DiscoverValues(ctx context.Context, query model.Query) ([]string, error) {
+	d, ok := r.next.(metric.Discoverer)
+	if !ok {
+		return nil, fmt.Errorf("gatherer does not support values discovery")
+	}
+	return d.DiscoverValues(ctx, query)
+}
+
+func (r *rangeCache) GatherMetadata(ctx context.Context, query model.Query) ([]model.MetricMetadata, error) {
+	m, ok := r.next.(metric.MetadataGatherer)
+	if !ok {
+		return nil, metric.ErrMetadataNotSupported
+	}
+	return m.GatherMetadata(ctx, query)
+}
+
+func (r *rangeCache) DatasourcesHealth() []model.DatasourceHealth {
+	h, ok := r.next.(metric.HealthReporter)
+	if !ok {
+		return nil
+	}
+	return h.DatasourcesHealth()
+}
+
+// evictIdle removes the cached queries that have not been used for a while.
+// Needs to be called with the lock acquired.
+func (r *rangeCache) evictIdle(now time.Time) {
+	for k, e := range r.entries {
+		if now.Sub(e.lastUsed) > r.cfg.MaxIdle {
+			delete(r.entries, k)
+		}
+	}
+}
+
+// mergeSeries merges the cached series with the tail series, the cached
+// points from the tail start are replaced by the tail ones and the points
+// before the start are evicted. The series without points are removed.
+func mergeSeries(cached, tail []model.MetricSeries, start, tailStart time.Time) []model.MetricSeries {
+	tailByID := map[string]model.MetricSeries{}
+	for _, s := range tail {
+		metrics := []model.Metric{}
+		for _, m := range s.Metrics {
+			if !m.TS.Before(start) {
+				metrics = append(metrics, m)
+			}
+		}
+		s.Metrics = metrics
+		tailByID[s.ID] = s
+	}
+
+	res := []model.MetricSeries{}
+	addSeries := func(s model.MetricSeries, metrics []model.Metric) {
+		if len(metrics) == 0 {
+			return
+		}
+		s.Metrics = metrics
+		res = append(res, s)
+	}
+
+	for _, s := range cached {
+		metrics := []model.Metric{}
+		for _, m := range s.Metrics {
+			if !m.TS.Before(start) && m.TS.Before(tailStart) {
+				metrics = append(metrics, m)
+			}
+		}
+
+		ts, ok := tailByID[s.ID]
+		if ok {
+			metrics = append(metrics, ts.Metrics...)
+			s.Labels = ts.Labels
+			delete(tailByID, s.ID)
+		}
+
+		addSeries(s, metrics)
+	}
+
+	// The new series that were not on the cache, maintain the order
+	// that the tail has.
+	for _, s := range tail {
+		if ts, ok := tailByID[s.ID]; ok {
+			addSeries(ts, ts.Metrics)
+		}
+	}
+
+	return res
+}
+
+// copySeries copies the series so the cached series can't be modified
+// by the users of the returned series.
+func copySeries(series []model.MetricSeries) []model.MetricSeries {
+	res := make([]model.MetricSeries, 0, len(series))
+	for _, s := range series {
+		s.Metrics = append([]model.Metric{}, s.Metrics...)
+		res = append(res, s)
+	}
+	return res
+}
@@ -0,0 +1,207 @@
+package middleware_test
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/mock"
+
+	mmetric "github.com/slok/grafterm/internal/mocks/service/metric"
+	"github.com/slok/grafterm/internal/model"
+	"github.com/slok/grafterm/internal/service/metric/middleware"
+)
+
+var t0 = time.Date(2019, 5, 12, 9, 0, 0, 0, time.UTC)
+
+// ts returns the timestamp of the minute.
+func ts(minute int) time.Time {
+	return t0.Add(time.Duration(minute) * time.Minute)
+}
+
+// series returns a series with a point per minute between from and to,
+// the value of the point is the minute.
+func series(id string, from, to int) model.MetricSeries {
+	s := model.MetricSeries{ID: id, Labels: map[string]string{"id": id}}
+	for i := from; i <= to; i++ {
+		s.Metrics = append(s.Metrics, model.Metric{TS: ts(i), Value: float64(i)})
+	}
+	return s
+}
+
+type rangeQuery struct {
+	start, end time.Time
+	step       time.Duration
+}
+
+func TestRangeCacheGatherRange(t *testing.T) {
+	q := model.Query{DatasourceID: "ds", Expr: "up"}
+
+	tests := []struct {
+		name      string
+		queries   []rangeQuery
+		mock      func(m *mmetric.Gatherer)
+		expSeries []model.MetricSeries
+		expErr    bool
+	}{
+		{
+			name: "A query without cache should gather the whole range.",
+			queries: []rangeQuery{
+				{start: ts(0), end: ts(10), step: time.Minute},
+			},
+			mock: func(m *mmetric.Gatherer) {
+				m.On("GatherRange", mock.Anything, q, ts(0), ts(10), time.Minute).Once().Return([]model.MetricSeries{series("a", 0, 10)}, nil)
+			},
+			expSeries: []model.MetricSeries{series("a", 0, 10)},
+		},
+		{
+			name: "A query moved forward should only gather the tail from the last cached step and evict the old points.",
+			queries: []rangeQuery{
+				{start: ts(0), end: ts(10), step: time.Minute},
+				{start: ts(3), end: ts(13), step: time.Minute},
+			},
+			mock: func(m *mmetric.Gatherer) {
+				m.On("GatherRange", mock.Anything, q, ts(0), ts(10), time.Minute).Once().Return([]model.MetricSeries{series("a", 0, 10)}, nil)
+				// The last point is refreshed with a new value.
+				tail := series("a", 10, 13)
+				tail.Metrics[0].Value = 100
+				m.On("GatherRange", mock.Anything, q, ts(10), ts(13), time.Minute).Once().Return([]model.MetricSeries{tail}, nil)
+			},
+			expSeries: func() []model.MetricSeries {
+				s := series("a", 3, 13)
+				s.Metrics[7].Value = 100
+				return []model.MetricSeries{s}
+			}(),
+		},
+		{
+			name: "A query moved forward in the middle of a step should gather the tail aligned with the cached points.",
+			queries: []rangeQuery{
+				{start: ts(0), end: ts(10).Add(30 * time.Second), step: time.Minute},
+				{start: ts(0).Add(50 * time.Second), end: ts(11).Add(20 * time.Second), step: time.Minute},
+			},
+			mock: func(m *mmetric.Gatherer) {
+				m.On("GatherRange", mock.Anything, q, ts(0), ts(10).Add(30*time.Second), time.Minute).Once().Return([]model.MetricSeries{series("a", 0, 10)}, nil)
+				m.On("GatherRange", mock.Anything, q, ts(10), ts(11).Add(20*time.Second), time.Minute).Once().Return([]model.MetricSeries{series("a", 10, 11)}, nil)
+			},
+			expSeries: []model.MetricSeries{series("a", 1, 11)},
+		},
+		{
+			name: "New series on the tail should be added and the cached series that are not on the tail should be maintained.",
+			queries: []rangeQuery{
+				{start: ts(0), end: ts(10), step: time.Minute},
+				{start: ts(5), end: ts(15), step: time.Minute},
+			},
+			mock: func(m *mmetric.Gatherer) {
+				m.On("GatherRange", mock.Anything, q, ts(0), ts(10), time.Minute).Once().Return([]model.MetricSeries{series("a", 0, 10), series("b", 0, 3)}, nil)
+				m.On("GatherRange", mock.Anything, q, ts(10), ts(15), time.Minute).Once().Return([]model.MetricSeries{series("c", 12, 15), series("a", 10, 15)}, nil)
+			},
+			expSeries: []model.MetricSeries{series("a", 5, 15), series("c", 12, 15)},
+		},
+		{
+			name: "A query with a different step should gather the whole range.",
+			queries: []rangeQuery{
+				{start: ts(0), end: ts(10), step: time.Minute},
+				{start: ts(2), end: ts(12), step: 2 * time.Minute},
+			},
+			mock: func(m *mmetric.Gatherer) {
+				m.On("GatherRange", mock.Anything, q, ts(0), ts(10), time.Minute).Once().Return([]model.MetricSeries{series("a", 0, 10)}, nil)
+				m.On("GatherRange", mock.Anything, q, ts(2), ts(12), 2*time.Minute).Once().Return([]model.MetricSeries{series("a", 2, 12)}, nil)
+			},
+			expSeries: []model.MetricSeries{series("a", 2, 12)},
+		},
+		{
+			name: "A query moved backward should gather the whole range.",
+			queries: []rangeQuery{
+				{start: ts(10), end: ts(20), step: time.Minute},
+				{start: ts(5), end: ts(15), step: time.Minute},
+			},
+			mock: func(m *mmetric.Gatherer) {
+				m.On("GatherRange", mock.Anything, q, ts(10), ts(20), time.Minute).Once().Return([]model.MetricSeries{series("a", 10, 20)}, nil)
+				m.On("GatherRange", mock.Anything, q, ts(5), ts(15), time.Minute).Once().Return([]model.MetricSeries{series("a", 5, 15)}, nil)
+			},
+			expSeries: []model.MetricSeries{series("a", 5, 15)},
+		},
+		{
+			name: "A query that doesn't overlap with the cached range should gather the whole range.",
+			queries: []rangeQuery{
+				{start: ts(0), end: ts(10), step: time.Minute},
+				{start: ts(20), end: ts(30), step: time.Minute},
+			},
+			mock: func(m *mmetric.Gatherer) {
+				m.On("GatherRange", mock.Anything, q, ts(0), ts(10), time.Minute).Once().Return([]model.MetricSeries{series("a", 0, 10)}, nil)
+				m.On("GatherRange", mock.Anything, q, ts(20), ts(30), time.Minute).Once().Return([]model.MetricSeries{series("a", 20, 30)}, nil)
+			},
+			expSeries: []model.MetricSeries{series("a", 20, 30)},
+		},
+		{
+			name: "An error gathering the tail should return an error.",
+			queries: []rangeQuery{
+				{start: ts(0), end: ts(10), step: time.Minute},
+				{start: ts(3), end: ts(13), step: time.Minute},
+			},
+			mock: func(m *mmetric.Gatherer) {
+				m.On("GatherRange", mock.Anything, q, ts(0), ts(10), time.Minute).Once().Return([]model.MetricSeries{series("a", 0, 10)}, nil)
+				m.On("GatherRange", mock.Anything, q, ts(10), ts(13), time.Minute).Once().Return(nil, errors.New("wanted error"))
+			},
+			expErr: true,
+		},
+		{
+			name: "A query without step should not be cached.",
+			queries: []rangeQuery{
+				{start: ts(0), end: ts(10)},
+				{start: ts(3), end: ts(13)},
+			},
+			mock: func(m *mmetric.Gatherer) {
+				m.On("GatherRange", mock.Anything, q, ts(0), ts(10), time.Duration(0)).Once().Return([]model.MetricSeries{series("a", 0, 10)}, nil)
+				m.On("GatherRange", mock.Anything, q, ts(3), ts(13), time.Duration(0)).Once().Return([]model.MetricSeries{series("a", 3, 13)}, nil)
+			},
+			expSeries: []model.MetricSeries{series("a", 3, 13)},
+		},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			assert := assert.New(t)
+
+			mg := &mmetric.Gatherer{}
+			test.mock(mg)
+
+			g := middleware.RangeCache(middleware.RangeCacheConfig{}, mg)
+			var gotSeries []model.MetricSeries
+			var err error
+			for _, rq := range test.queries {
+				gotSeries, err = g.GatherRange(context.TODO(), q, rq.start, rq.end, rq.step)
+			}
+
+			if test.expErr {
+				assert.Error(err)
+			} else if assert.NoError(err) {
+				assert.Equal(test.expSeries, gotSeries)
+			}
+			mg.AssertExpectations(t)
+		})
+	}
+}
+
+func TestRangeCacheReturnedSeriesAreNotShared(t *testing.T) {
+	assert := assert.New(t)
+	q := model.Query{DatasourceID: "ds", Expr: "up"}
+
+	mg := &mmetric.Gatherer{}
+	mg.On("GatherRange", mock.Anything, q, ts(0), ts(10), time.Minute).Once().Return([]model.MetricSeries{series("a", 0, 10)}, nil)
+	mg.On("GatherRange", mock.Anything, q, ts(10), ts(11), time.Minute).Once().Return([]model.MetricSeries{series("a", 10, 11)}, nil)
+
+	g := middleware.RangeCache(middleware.RangeCacheConfig{}, mg)
+	got, err := g.GatherRange(context.TODO(), q, ts(0), ts(10), time.Minute)
+	assert.NoError(err)
+
+	// Modifying the returned series should not modify the cached ones.
+	got[0].Metrics[5].Value = -1
+
+	got, err = g.GatherRange(context.TODO(), q, ts(1), ts(11), time.Minute)
+	if assert.NoError(err) {
+		assert.Equal([]model.MetricSeries{series("a", 1, 11)}, got)
+	}
+}
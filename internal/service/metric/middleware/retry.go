@@ -0,0 +1,73 @@
+package middleware
+
+import (
+	"context"
+	"fmt"
+	"time"
+
+	"github.com/slok/grafterm/internal/model"
+	"github.com/slok/grafterm/internal/service/metric"
+)
+
+// RetryConfig is the configuration of the Retry middleware.
+type RetryConfig struct {
+	// MaxRetries is the number of times a failed query is retried.
+	MaxRetries int
+	// Backoff is the wait before the first retry, it's doubled on every retry.
+	Backoff time.Duration
+	// MaxBackoff is the maximum wait before a retry.
+	MaxBackoff time.Duration
+}
+
+type retry struct {
+	policy metric.RetryPolicy
+	next   metric.Gatherer
+}
+
+// Retry is a gatherer middleware that retries the failed queries using
+// the metric.RetryPolicy, only the queries that failed with a transient
+// error class are retried.
+func Retry(cfg RetryConfig, next metric.Gatherer) metric.Gatherer {
+	return &retry{
+		policy: metric.RetryPolicy{
+			MaxRetries: cfg.MaxRetries,
+			Backoff:    cfg.Backoff,
+			MaxBackoff: cfg.MaxBackoff,
+		},
+		next: next,
+	}
+}
+
+func (r *retry) GatherSingle(ctx context.Context, query model.Query, t time.Time) ([]model.MetricSeries, error) {
+	var res []model.MetricSeries
+	err := r.policy.Do(ctx, func() (err error) {
+		res, err = r.next.GatherSingle(ctx, query, t)
+		return err
+	})
+	return res, err
+}
+
+func (r *retry) GatherRange(ctx context.Context, query model.Query, start, end time.Time, step time.Duration) ([]model.MetricSeries, error) {
+	var res []model.MetricSeries
+	err := r.policy.Do(ctx, func() (err error) {
+		res, err = r.next.GatherRange(ctx, query, start, end, step)
+		return err
+	})
+	return res, err
+}
+
+func (r *retry) DiscoverValues(ctx context.Context, query model.Query) ([]string, error) {
+	d, ok := r.next.(metric.Discoverer)
+	if !ok {
+		return nil, fmt.Errorf("gatherer does not support values discovery")
+	}
+	return d.DiscoverValues(ctx, query)
+}
+
+func (r *retry) GatherMetadata(ctx context.Context, query model.Query) ([]model.MetricMetadata, error) {
+	m, ok := r.next.(metric.MetadataGatherer)
+	if !ok {
+		return nil, metric.ErrMetadataNotSupported
+	}
+	return m.GatherMetadata(ctx, query)
+}
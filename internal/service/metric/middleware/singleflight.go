@@ -0,0 +1,137 @@
+package middleware
+
+import (
+	"context"
+	"fmt"
+	"sync"
+	"time"
+
+	"github.com/slok/grafterm/internal/model"
+	"github.com/slok/grafterm/internal/service/log"
+	"github.com/slok/grafterm/internal/service/metric"
+)
+
+// flightKey identifies the identical queries.
+type flightKey struct {
+	datasourceID string
+	expr         string
+	start        int64
+	end          int64
+	step         time.Duration
+	single       bool
+}
+
+// flight is a query in flight, the queries with the same key wait until
+// it's done and share its result.
+type flight struct {
+	done   chan struct{}
+	series []model.MetricSeries
+	err    error
+}
+
+type singleFlight struct {
+	next   metric.Gatherer
+	logger log.Logger
+
+	mu        sync.Mutex
+	flights   map[flightKey]*flight
+	queries   int64
+	collapsed int64
+}
+
+// SingleFlight is a gatherer middleware that collapses the concurrent
+// identical queries (same datasource, expression, time and step) in a single
+// query to the next gatherer and shares the result with all of them. The
+// shared series must not be modified by the callers.
+// The number of queries and the collapsed ones are logged on every collapse.
+func SingleFlight(l log.Logger, next metric.Gatherer) metric.Gatherer {
+	return &singleFlight{
+		next:    next,
+		logger:  l,
+		flights: map[flightKey]*flight{},
+	}
+}
+
+func (s *singleFlight) GatherSingle(ctx context.Context, query model.Query, t time.Time) ([]model.MetricSeries, error) {
+	key := flightKey{
+		datasourceID: query.DatasourceID,
+		expr:         query.Expr,
+		start:        t.UnixNano(),
+		single:       true,
+	}
+	return s.do(ctx, key, func() ([]model.MetricSeries, error) {
+		return s.next.GatherSingle(ctx, query, t)
+	})
+}
+
+func (s *singleFlight) GatherRange(ctx context.Context, query model.Query, start, end time.Time, step time.Duration) ([]model.MetricSeries, error) {
+	key := flightKey{
+		datasourceID: query.DatasourceID,
+		expr:         query.Expr,
+		start:        start.UnixNano(),
+		end:          end.UnixNano(),
+		step:         step,
+	}
+	return s.do(ctx, key, func() ([]model.MetricSeries, error) {
+		return s.next.GatherRange(ctx, query, start, end, step)
+	})
+}
+
+func (s *singleFlight) DiscoverValues(ctx context.Context, query model.Query) ([]string, error) {
+	d, ok := s.next.(metric.Discoverer)
+	if !ok {
+		return nil, fmt.Errorf("gatherer does not support values discovery")
+	}
+	return d.DiscoverValues(ctx, query)
+}
+
+func (s *singleFlight) GatherMetadata(ctx context.Context, query model.Query) ([]model.MetricMetadata, error) {
+	m, ok := s.next.(metric.MetadataGatherer)
+	if !ok {
+		return nil, metric.ErrMetadataNotSupported
+	}
+	return m.GatherMetadata(ctx, query)
+}
+
+func (s *singleFlight) DatasourcesHealth() []model.DatasourceHealth {
+	h, ok := s.next.(metric.HealthReporter)
+	if !ok {
+		return nil
+	}
+	return h.DatasourcesHealth()
+}
+
+// do calls the gather function if there isn't an identical query in flight,
+// otherwise it waits for the result of the query in flight.
+func (s *singleFlight) do(ctx context.Context, key flightKey, gather func() ([]model.MetricSeries, error)) ([]model.MetricSeries, error) {
+	s.mu.Lock()
+	s.queries++
+	if f, ok := s.flights[key]; ok {
+		s.collapsed++
+		queries, collapsed := s.queries, s.collapsed
+		s.mu.Unlock()
+
+		s.logger.Infof("collapsed query on %s with the query in flight: %s (queries: %d, collapsed: %d)", key.datasourceID, key.expr, queries, collapsed)
+
+		// Don't wait for the query in flight if the caller is not waiting.
+		select {
+		case <-f.done:
+			return f.series, f.err
+		case <-ctx.Done():
+			return nil, ctx.Err()
+		}
+	}
+
+	f := &flight{done: make(chan struct{})}
+	s.flights[key] = f
+	s.mu.Unlock()
+
+	f.series, f.err = gather()
+
+	s.mu.Lock()
+	delete(s.flights, key)
+	s.mu.Unlock()
+	close(f.done)
+
+	return f.series, f.err
+}
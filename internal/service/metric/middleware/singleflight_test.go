@@ -0,0 +1,164 @@
+package middleware_test
+
+import (
+	"context"
+	"errors"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/mock"
+	"github.com/stretchr/testify/require"
+
+	mmetric "github.com/slok/grafterm/internal/mocks/service/metric"
+	"github.com/slok/grafterm/internal/model"
+	"github.com/slok/grafterm/internal/service/log"
+	"github.com/slok/grafterm/internal/service/metric"
+	"github.com/slok/grafterm/internal/service/metric/middleware"
+)
+
+// countLogger is a logger that counts the logged messages.
+type countLogger struct {
+	log.Logger
+
+	mu    sync.Mutex
+	infos int
+}
+
+func (c *countLogger) Infof(format string, args ...interface{}) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	c.infos++
+}
+
+func (c *countLogger) count() int {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	return c.infos
+}
+
+func TestSingleFlightCollapsesConcurrentQueries(t *testing.T) {
+	q := model.Query{DatasourceID: "ds", Expr: "up"}
+	expSeries := []model.MetricSeries{series("a", 0, 10)}
+
+	tests := []struct {
+		name   string
+		gather func(ctx context.Context, g metric.Gatherer) ([]model.MetricSeries, error)
+		mock   func(m *mmetric.Gatherer, started chan struct{}, release chan struct{})
+	}{
+		{
+			name: "Concurrent identical range queries should be collapsed in one query.",
+			gather: func(ctx context.Context, g metric.Gatherer) ([]model.MetricSeries, error) {
+				return g.GatherRange(ctx, q, ts(0), ts(10), time.Minute)
+			},
+			mock: func(m *mmetric.Gatherer, started chan struct{}, release chan struct{}) {
+				m.On("GatherRange", mock.Anything, q, ts(0), ts(10), time.Minute).Once().Return(expSeries, nil).Run(func(mock.Arguments) {
+					close(started)
+					<-release
+				})
+			},
+		},
+		{
+			name: "Concurrent identical single queries should be collapsed in one query.",
+			gather: func(ctx context.Context, g metric.Gatherer) ([]model.MetricSeries, error) {
+				return g.GatherSingle(ctx, q, ts(10))
+			},
+			mock: func(m *mmetric.Gatherer, started chan struct{}, release chan struct{}) {
+				m.On("GatherSingle", mock.Anything, q, ts(10)).Once().Return(expSeries, nil).Run(func(mock.Arguments) {
+					close(started)
+					<-release
+				})
+			},
+		},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			assert := assert.New(t)
+
+			started, release := make(chan struct{}), make(chan struct{})
+			mg := &mmetric.Gatherer{}
+			test.mock(mg, started, release)
+
+			l := &countLogger{Logger: log.Dummy}
+			g := middleware.SingleFlight(l, mg)
+
+			// Start the first query and when it's in flight the identical ones.
+			const followers = 4
+			var wg sync.WaitGroup
+			results := make([][]model.MetricSeries, followers+1)
+			errs := make([]error, followers+1)
+			for i := 0; i <= followers; i++ {
+				wg.Add(1)
+				go func(i int) {
+					defer wg.Done()
+					results[i], errs[i] = test.gather(context.TODO(), g)
+				}(i)
+				if i == 0 {
+					<-started
+				}
+			}
+
+			// Every collapsed query is logged.
+			require.Eventually(t, func() bool { return l.count() == followers }, time.Second, time.Millisecond)
+			close(release)
+			wg.Wait()
+
+			mg.AssertExpectations(t)
+			for i := range results {
+				assert.NoError(errs[i])
+				assert.Equal(expSeries, results[i])
+			}
+		})
+	}
+}
+
+func TestSingleFlightDoesNotCollapseDifferentQueries(t *testing.T) {
+	assert := assert.New(t)
+
+	q := model.Query{DatasourceID: "ds", Expr: "up"}
+	mg := &mmetric.Gatherer{}
+	mg.On("GatherRange", mock.Anything, q, ts(0), ts(10), time.Minute).Twice().Return([]model.MetricSeries{series("a", 0, 10)}, nil)
+	mg.On("GatherRange", mock.Anything, q, ts(0), ts(10), 2*time.Minute).Once().Return([]model.MetricSeries{series("b", 0, 10)}, nil)
+	mg.On("GatherSingle", mock.Anything, q, ts(10)).Once().Return(nil, errors.New("wanted error"))
+
+	l := &countLogger{Logger: log.Dummy}
+	g := middleware.SingleFlight(l, mg)
+
+	// Sequential identical queries are not in flight at the same time.
+	for i := 0; i < 2; i++ {
+		_, err := g.GatherRange(context.TODO(), q, ts(0), ts(10), time.Minute)
+		assert.NoError(err)
+	}
+	_, err := g.GatherRange(context.TODO(), q, ts(0), ts(10), 2*time.Minute)
+	assert.NoError(err)
+	_, err = g.GatherSingle(context.TODO(), q, ts(10))
+	assert.Error(err)
+
+	mg.AssertExpectations(t)
+	assert.Equal(0, l.count())
+}
+
+func TestSingleFlightCanceledCollapsedQuery(t *testing.T) {
+	assert := assert.New(t)
+
+	q := model.Query{DatasourceID: "ds", Expr: "up"}
+	started, release := make(chan struct{}), make(chan struct{})
+	mg := &mmetric.Gatherer{}
+	mg.On("GatherSingle", mock.Anything, q, ts(10)).Once().Return([]model.MetricSeries{}, nil).Run(func(mock.Arguments) {
+		close(started)
+		<-release
+	})
+	defer close(release)
+
+	g := middleware.SingleFlight(log.Dummy, mg)
+	go func() { _, _ = g.GatherSingle(context.TODO(), q, ts(10)) }()
+	<-started
+
+	// The collapsed query should not wait for the query in flight.
+	ctx, cancel := context.WithCancel(context.TODO())
+	cancel()
+	_, err := g.GatherSingle(ctx, q, ts(10))
+	assert.Equal(context.Canceled, err)
+}
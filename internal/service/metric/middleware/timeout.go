@@ -0,0 +1,64 @@
+package middleware
+
+import (
+	"context"
+	"fmt"
+	"time"
+
+	"github.com/slok/grafterm/internal/model"
+	"github.com/slok/grafterm/internal/service/metric"
+)
+
+// TimeoutConfig is the configuration of the Timeout middleware.
+type TimeoutConfig struct {
+	// Timeout is the maximum duration of a query.
+	Timeout time.Duration
+}
+
+type timeout struct {
+	cfg  TimeoutConfig
+	next metric.Gatherer
+}
+
+// Timeout is a gatherer middleware that cancels the queries that take
+// more than the timeout.
+func Timeout(cfg TimeoutConfig, next metric.Gatherer) metric.Gatherer {
+	return &timeout{
+		cfg:  cfg,
+		next: next,
+	}
+}
+
+func (t *timeout) GatherSingle(ctx context.Context, query model.Query, ts time.Time) ([]model.MetricSeries, error) {
+	ctx, cancel := context.WithTimeout(ctx, t.cfg.Timeout)
+	defer cancel()
+	return t.next.GatherSingle(ctx, query, ts)
+}
+
+func (t *timeout) GatherRange(ctx context.Context, query model.Query, start, end time.Time, step time.Duration) ([]model.MetricSeries, error) {
+	ctx, cancel := context.WithTimeout(ctx, t.cfg.Timeout)
+	defer cancel()
+	return t.next.GatherRange(ctx, query, start, end, step)
+}
+
+func (t *timeout) DiscoverValues(ctx context.Context, query model.Query) ([]string, error) {
+	d, ok := t.next.(metric.Discoverer)
+	if !ok {
+		return nil, fmt.Errorf("gatherer does not support values discovery")
+	}
+
+	ctx, cancel := context.WithTimeout(ctx, t.cfg.Timeout)
+	defer cancel()
+	return d.DiscoverValues(ctx, query)
+}
+
+func (t *timeout) GatherMetadata(ctx context.Context, query model.Query) ([]model.MetricMetadata, error) {
+	m, ok := t.next.(metric.MetadataGatherer)
+	if !ok {
+		return nil, metric.ErrMetadataNotSupported
+	}
+
+	ctx, cancel := context.WithTimeout(ctx, t.cfg.Timeout)
+	defer cancel()
+	return m.GatherMetadata(ctx, query)
+}
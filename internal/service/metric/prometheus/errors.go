@@ -0,0 +1,79 @@
+package prometheus
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"strings"
+
+	promapi "github.com/prometheus/client_golang/api"
+	promv1 "github.com/prometheus/client_golang/api/prometheus/v1"
+
+	"github.com/slok/grafterm/internal/service/metric"
+)
+
+// statusAPIError is the status code of the Prometheus API query errors.
+const statusAPIError = 422
+
+// NewErrorClassifierClient wraps a Prometheus HTTP API client so the
+// responses with an error status code that the Prometheus API client
+// doesn't parse (e.g a 429 of a proxy, a 503 of an overloaded Prometheus)
+// are returned as classified errors with the `Retry-After` of the response.
+func NewErrorClassifierClient(cli promapi.Client) promapi.Client {
+	return errorClassifierClient{Client: cli}
+}
+
+type errorClassifierClient struct {
+	promapi.Client
+}
+
+func (c errorClassifierClient) Do(ctx context.Context, req *http.Request) (*http.Response, []byte, promapi.Warnings, error) {
+	resp, body, warnings, err := c.Client.Do(ctx, req)
+	if err != nil || resp.StatusCode/100 == 2 || resp.StatusCode == http.StatusBadRequest || resp.StatusCode == statusAPIError {
+		return resp, body, warnings, err
+	}
+
+	// Prometheus sets the error type on the body of some of these responses
+	// (e.g timeouts), it's more precise than the status code.
+	apiResp := struct {
+		ErrorType promv1.ErrorType `json:"errorType"`
+		Error     string           `json:"error"`
+	}{}
+	if json.Unmarshal(body, &apiResp) == nil && apiResp.ErrorType != "" {
+		return resp, body, warnings, &metric.Error{
+			Class:      errorTypeClass(apiResp.ErrorType),
+			RetryAfter: metric.HTTPRetryAfter(resp),
+			Err:        fmt.Errorf("%s: %s", apiResp.ErrorType, apiResp.Error),
+		}
+	}
+
+	return resp, body, warnings, metric.NewHTTPError(resp, fmt.Errorf("%d status code: %s", resp.StatusCode, strings.TrimSpace(string(body))))
+}
+
+// classifyError classifies the errors of the Prometheus API client.
+func classifyError(err error) error {
+	var perr *promv1.Error
+	if !errors.As(err, &perr) {
+		return err
+	}
+
+	return metric.NewError(errorTypeClass(perr.Type), err)
+}
+
+// errorTypeClass returns the error class of a Prometheus API error type.
+func errorTypeClass(t promv1.ErrorType) metric.ErrorClass {
+	switch t {
+	case promv1.ErrBadData, promv1.ErrExec, promv1.ErrClient:
+		return metric.ErrorClassBadQuery
+	case promv1.ErrTimeout:
+		return metric.ErrorClassTimeout
+	case promv1.ErrCanceled:
+		return metric.ErrorClassCanceled
+	case promv1.ErrServer, promv1.ErrBadResponse:
+		return metric.ErrorClassUnavailable
+	}
+
+	return metric.ErrorClassUnknown
+}
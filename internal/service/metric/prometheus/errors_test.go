@@ -0,0 +1,96 @@
+package prometheus_test
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	promapi "github.com/prometheus/client_golang/api"
+	promv1 "github.com/prometheus/client_golang/api/prometheus/v1"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/slok/grafterm/internal/model"
+	"github.com/slok/grafterm/internal/service/metric"
+	"github.com/slok/grafterm/internal/service/metric/prometheus"
+)
+
+func TestGathererErrorClasses(t *testing.T) {
+	tests := map[string]struct {
+		status        int
+		retryAfter    string
+		body          string
+		expClass      metric.ErrorClass
+		expRetryAfter time.Duration
+	}{
+		"A bad PromQL query should be a bad query error.": {
+			status:   http.StatusBadRequest,
+			body:     `{"status": "error", "errorType": "bad_data", "error": "parse error"}`,
+			expClass: metric.ErrorClassBadQuery,
+		},
+		"A query execution error should be a bad query error.": {
+			status:   422,
+			body:     `{"status": "error", "errorType": "execution", "error": "too many samples"}`,
+			expClass: metric.ErrorClassBadQuery,
+		},
+		"An unauthorized response should be an auth error.": {
+			status:   http.StatusUnauthorized,
+			expClass: metric.ErrorClassAuth,
+		},
+		"A forbidden response should be an auth error.": {
+			status:   http.StatusForbidden,
+			expClass: metric.ErrorClassAuth,
+		},
+		"A too many requests response should be a rate limited error with the retry after.": {
+			status:        http.StatusTooManyRequests,
+			retryAfter:    "2",
+			expClass:      metric.ErrorClassRateLimited,
+			expRetryAfter: 2 * time.Second,
+		},
+		"A service unavailable response should be an unavailable error with the retry after.": {
+			status:        http.StatusServiceUnavailable,
+			retryAfter:    "5",
+			expClass:      metric.ErrorClassUnavailable,
+			expRetryAfter: 5 * time.Second,
+		},
+		"A Prometheus query timeout should be a timeout error.": {
+			status:   http.StatusServiceUnavailable,
+			body:     `{"status": "error", "errorType": "timeout", "error": "query timed out"}`,
+			expClass: metric.ErrorClassTimeout,
+		},
+	}
+
+	for name, test := range tests {
+		test := test
+		t.Run(name, func(t *testing.T) {
+			assert := assert.New(t)
+			require := require.New(t)
+
+			srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+				if test.retryAfter != "" {
+					w.Header().Set("Retry-After", test.retryAfter)
+				}
+				w.Header().Set("Content-Type", "application/json")
+				w.WriteHeader(test.status)
+				w.Write([]byte(test.body))
+			}))
+			defer srv.Close()
+
+			cli, err := promapi.NewClient(promapi.Config{Address: srv.URL})
+			require.NoError(err)
+			cli = prometheus.NewErrorClassifierClient(cli)
+
+			g := prometheus.NewGatherer(prometheus.ConfigGatherer{
+				Client:    promv1.NewAPI(cli),
+				APIClient: cli,
+			})
+			_, err = g.GatherSingle(context.TODO(), model.Query{Expr: "up"}, time.Now())
+			if assert.Error(err) {
+				assert.Equal(test.expClass, metric.ErrorClassOf(err))
+				assert.Equal(test.expRetryAfter, metric.ErrorRetryAfter(err))
+			}
+		})
+	}
+}
@@ -0,0 +1,160 @@
+package prometheus
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"regexp"
+	"strings"
+
+	"github.com/slok/grafterm/internal/model"
+)
+
+const epMetadata = "/api/v1/metadata"
+
+var (
+	// Regexes used to remove the parts of a PromQL expression that are not
+	// metric names.
+	exprStringsRegexp  = regexp.MustCompile(`"(?:[^"\\]|\\.)*"|'(?:[^'\\]|\\.)*'|` + "`[^`]*`")
+	exprMatchersRegexp = regexp.MustCompile(`\{[^}]*\}`)
+	exprRangesRegexp   = regexp.MustCompile(`\[[^\]]*\]`)
+	exprGroupingRegexp = regexp.MustCompile(`\b(?:by|without|on|ignoring|group_left|group_right)\s*\([^)]*\)`)
+	exprNamesRegexp    = regexp.MustCompile(`[a-zA-Z_:][a-zA-Z0-9_:]*`)
+
+	exprKeywords = map[string]bool{
+		"by": true, "without": true, "on": true, "ignoring": true, "group_left": true, "group_right": true,
+		"offset": true, "bool": true, "and": true, "or": true, "unless": true, "inf": true, "nan": true,
+	}
+
+	// histogramSuffixes are the suffixes of the histograms and summaries
+	// series, the metadata of these is on the base metric name.
+	histogramSuffixes = []string{"_bucket", "_sum", "_count"}
+)
+
+type metadataResponse struct {
+	Status    string                                `json:"status"`
+	Data      map[string][]metadataResponseMetadata `json:"data"`
+	ErrorType string                                `json:"errorType"`
+	Error     string                                `json:"error"`
+}
+
+type metadataResponseMetadata struct {
+	Type string `json:"type"`
+	Help string `json:"help"`
+	Unit string `json:"unit"`
+}
+
+// GatherMetadata satisfies metric.MetadataGatherer interface. It will get the
+// metadata of the metrics used on the query expression from the Prometheus
+// metadata API. The metrics without metadata will be ignored.
+func (g *gatherer) GatherMetadata(ctx context.Context, query model.Query) ([]model.MetricMetadata, error) {
+	if g.cfg.APIClient == nil {
+		return nil, fmt.Errorf("prometheus metadata not supported without an API client")
+	}
+
+	res := []model.MetricMetadata{}
+	for _, name := range metricNames(query.Expr) {
+		md, ok, err := g.metricMetadata(ctx, name)
+		if err != nil {
+			return nil, err
+		}
+
+		// Histograms and summaries have the metadata on the base name.
+		if !ok {
+			for _, suffix := range histogramSuffixes {
+				if strings.HasSuffix(name, suffix) {
+					md, ok, err = g.metricMetadata(ctx, strings.TrimSuffix(name, suffix))
+					if err != nil {
+						return nil, err
+					}
+					break
+				}
+			}
+		}
+
+		if ok {
+			md.Name = name
+			res = append(res, md)
+		}
+	}
+
+	return res, nil
+}
+
+func (g *gatherer) metricMetadata(ctx context.Context, name string) (model.MetricMetadata, bool, error) {
+	u := g.cfg.APIClient.URL(epMetadata, nil)
+	q := u.Query()
+	q.Set("metric", name)
+	u.RawQuery = q.Encode()
+
+	req, err := http.NewRequest(http.MethodGet, u.String(), nil)
+	if err != nil {
+		return model.MetricMetadata{}, false, err
+	}
+
+	_, body, _, err := g.cfg.APIClient.Do(ctx, req)
+	if err != nil {
+		return model.MetricMetadata{}, false, fmt.Errorf("prometheus metadata query failed: %w", err)
+	}
+
+	resp := &metadataResponse{}
+	err = json.Unmarshal(body, resp)
+	if err != nil {
+		return model.MetricMetadata{}, false, fmt.Errorf("could not decode prometheus metadata response: %w", err)
+	}
+	if resp.Status != "success" {
+		return model.MetricMetadata{}, false, fmt.Errorf("prometheus metadata query failed: %s: %s", resp.ErrorType, resp.Error)
+	}
+
+	mds := resp.Data[name]
+	if len(mds) == 0 {
+		return model.MetricMetadata{}, false, nil
+	}
+
+	return model.MetricMetadata{
+		Name: name,
+		Type: mds[0].Type,
+		Unit: mds[0].Unit,
+		Help: mds[0].Help,
+	}, true, nil
+}
+
+// metricNames returns the metric names used on a PromQL expression, this is
+// a best effort based on removing the parts of the expression that can't be
+// metric names (strings, label matchers, ranges, groupings, functions...).
+func metricNames(expr string) []string {
+	expr = exprStringsRegexp.ReplaceAllString(expr, " ")
+	expr = exprMatchersRegexp.ReplaceAllString(expr, " ")
+	expr = exprRangesRegexp.ReplaceAllString(expr, " ")
+	expr = exprGroupingRegexp.ReplaceAllString(expr, " ")
+
+	res := []string{}
+	seen := map[string]bool{}
+	for _, loc := range exprNamesRegexp.FindAllStringIndex(expr, -1) {
+		name := expr[loc[0]:loc[1]]
+
+		// Ignore numbers (e.g `1e3`), keywords and functions.
+		if loc[0] > 0 && isNumberChar(expr[loc[0]-1]) {
+			continue
+		}
+		if exprKeywords[strings.ToLower(name)] {
+			continue
+		}
+		if strings.HasPrefix(strings.TrimSpace(expr[loc[1]:]), "(") {
+			continue
+		}
+
+		if seen[name] {
+			continue
+		}
+		seen[name] = true
+		res = append(res, name)
+	}
+
+	return res
+}
+
+func isNumberChar(c byte) bool {
+	return (c >= '0' && c <= '9') || c == '.'
+}
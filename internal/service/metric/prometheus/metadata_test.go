@@ -0,0 +1,116 @@
+package prometheus_test
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	promapi "github.com/prometheus/client_golang/api"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	mpromv1 "github.com/slok/grafterm/internal/mocks/github.com/prometheus/client_golang/api/prometheus/v1"
+	"github.com/slok/grafterm/internal/model"
+	"github.com/slok/grafterm/internal/service/metric"
+	"github.com/slok/grafterm/internal/service/metric/prometheus"
+)
+
+func TestGathererGatherMetadata(t *testing.T) {
+	histogramMetadata := map[string]interface{}{
+		"http_request_duration_seconds": []map[string]string{{"type": "histogram", "help": "Request latency.", "unit": ""}},
+	}
+
+	tests := map[string]struct {
+		expr        string
+		metadata    map[string]interface{}
+		status      int
+		expMetrics  []string
+		expMetadata []model.MetricMetadata
+		expErr      bool
+	}{
+		"The metadata of the metrics on the query should be gathered.": {
+			expr: `sum(rate(http_requests_total{job="api", code=~"5.."}[5m])) by (code) / on(job) group_left up`,
+			metadata: map[string]interface{}{
+				"http_requests_total": []map[string]string{{"type": "counter", "help": "Total requests."}},
+			},
+			status:     http.StatusOK,
+			expMetrics: []string{"http_requests_total", "up"},
+			expMetadata: []model.MetricMetadata{
+				{Name: "http_requests_total", Type: "counter", Help: "Total requests."},
+			},
+		},
+		"The metadata of histogram series should be gathered from the base metric.": {
+			expr:     `rate(http_request_duration_seconds_sum[5m]) / rate(http_request_duration_seconds_count[5m]) * 1e3`,
+			metadata: histogramMetadata,
+			status:   http.StatusOK,
+			expMetrics: []string{
+				"http_request_duration_seconds_sum", "http_request_duration_seconds",
+				"http_request_duration_seconds_count", "http_request_duration_seconds",
+			},
+			expMetadata: []model.MetricMetadata{
+				{Name: "http_request_duration_seconds_sum", Type: "histogram", Help: "Request latency."},
+				{Name: "http_request_duration_seconds_count", Type: "histogram", Help: "Request latency."},
+			},
+		},
+		"An error response should return an error.": {
+			expr:   `up`,
+			status: http.StatusBadRequest,
+			expErr: true,
+		},
+	}
+
+	for name, test := range tests {
+		test := test
+		t.Run(name, func(t *testing.T) {
+			assert := assert.New(t)
+			require := require.New(t)
+
+			gotMetrics := []string{}
+			srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+				assert.Equal("/api/v1/metadata", r.URL.Path)
+				name := r.URL.Query().Get("metric")
+				gotMetrics = append(gotMetrics, name)
+
+				w.Header().Set("Content-Type", "application/json")
+				w.WriteHeader(test.status)
+				if test.status != http.StatusOK {
+					w.Write([]byte(`{"status": "error", "errorType": "bad_data", "error": "wanted error"}`))
+					return
+				}
+
+				data := map[string]interface{}{}
+				if md, ok := test.metadata[name]; ok {
+					data[name] = md
+				}
+				json.NewEncoder(w).Encode(map[string]interface{}{"status": "success", "data": data})
+			}))
+			defer srv.Close()
+
+			cli, err := promapi.NewClient(promapi.Config{Address: srv.URL})
+			require.NoError(err)
+
+			g := prometheus.NewGatherer(prometheus.ConfigGatherer{
+				Client:    &mpromv1.API{},
+				APIClient: cli,
+			})
+			gotmds, err := g.(metric.MetadataGatherer).GatherMetadata(context.TODO(), model.Query{Expr: test.expr})
+			if test.expErr {
+				assert.Error(err)
+				return
+			}
+
+			if assert.NoError(err) {
+				assert.Equal(test.expMetrics, gotMetrics)
+				assert.Equal(test.expMetadata, gotmds)
+			}
+		})
+	}
+}
+
+func TestGathererGatherMetadataWithoutAPIClient(t *testing.T) {
+	g := prometheus.NewGatherer(prometheus.ConfigGatherer{Client: &mpromv1.API{}})
+	_, err := g.(metric.MetadataGatherer).GatherMetadata(context.TODO(), model.Query{Expr: "up"})
+	assert.Error(t, err)
+}
@@ -3,9 +3,11 @@ package prometheus
 import (
 	"context"
 	"fmt"
+	"regexp"
 	"strings"
 	"time"
 
+	promapi "github.com/prometheus/client_golang/api"
 	promv1 "github.com/prometheus/client_golang/api/prometheus/v1"
 	prommodel "github.com/prometheus/common/model"
 
@@ -17,6 +19,10 @@ import (
 type ConfigGatherer struct {
 	// Client is the prometheus API client.
 	Client promv1.API
+	// APIClient is the prometheus HTTP API client used for the endpoints that
+	// the API client doesn't support (e.g metrics metadata), if not set these
+	// will not be available.
+	APIClient promapi.Client
 	// FilterSpecialLabels will return the metrics with the special labels filtered.
 	// The special labels start with `__`, examples: `__name__`, `__scheme__`.
 	FilterSpecialLabels bool
@@ -45,7 +51,7 @@ func (g *gatherer) GatherSingle(ctx context.Context, query model.Query, t time.T
 		if ctx.Err() == context.Canceled {
 			return []model.MetricSeries{}, fmt.Errorf("prometheus query canceled: %w", err)
 		}
-		return []model.MetricSeries{}, fmt.Errorf("prometheus query failed: %w", err)
+		return []model.MetricSeries{}, fmt.Errorf("prometheus query failed: %w", classifyError(err))
 	}
 
 	// Translate prom values to domain.
@@ -71,7 +77,7 @@ func (g *gatherer) GatherRange(ctx context.Context, query model.Query, start, en
 		if ctx.Err() == context.Canceled {
 			return []model.MetricSeries{}, fmt.Errorf("prometheus range query canceled: %w", err)
 		}
-		return []model.MetricSeries{}, fmt.Errorf("prometheus range query failed: %w", err)
+		return []model.MetricSeries{}, fmt.Errorf("prometheus range query failed: %w", classifyError(err))
 	}
 
 	// Translate prom values to domain.
@@ -83,6 +89,72 @@ func (g *gatherer) GatherRange(ctx context.Context, query model.Query, start, en
 	return res, nil
 }
 
+var (
+	labelValuesRegexp = regexp.MustCompile(`^label_values\((.+)\)$`)
+	labelNamesRegexp  = regexp.MustCompile(`^label_names\(\)$`)
+)
+
+const discoverSeriesRange = 1 * time.Hour
+
+// DiscoverValues satisfies metric.Discoverer interface. The supported queries are:
+//	- `label_names()`: the label names.
+//	- `label_values(label)`: the values of a label.
+//	- `label_values(selector, label)`: the values of a label on the series that
+//	  match the selector in the last hour.
+func (g *gatherer) DiscoverValues(ctx context.Context, query model.Query) ([]string, error) {
+	expr := strings.TrimSpace(query.Expr)
+
+	switch {
+	case labelNamesRegexp.MatchString(expr):
+		names, err := g.cli.LabelNames(ctx)
+		if err != nil {
+			return nil, fmt.Errorf("prometheus label names query failed: %w", classifyError(err))
+		}
+		return names, nil
+
+	case labelValuesRegexp.MatchString(expr):
+		args := labelValuesRegexp.FindStringSubmatch(expr)[1]
+
+		// Only the label.
+		i := strings.LastIndex(args, ",")
+		if i < 0 {
+			vals, err := g.cli.LabelValues(ctx, strings.TrimSpace(args))
+			if err != nil {
+				return nil, fmt.Errorf("prometheus label values query failed: %w", classifyError(err))
+			}
+
+			res := make([]string, 0, len(vals))
+			for _, v := range vals {
+				res = append(res, string(v))
+			}
+			return res, nil
+		}
+
+		// Selector and label.
+		selector := strings.TrimSpace(args[:i])
+		label := prommodel.LabelName(strings.TrimSpace(args[i+1:]))
+		now := time.Now()
+		series, _, err := g.cli.Series(ctx, []string{selector}, now.Add(-1*discoverSeriesRange), now)
+		if err != nil {
+			return nil, fmt.Errorf("prometheus series query failed: %w", classifyError(err))
+		}
+
+		res := []string{}
+		seen := map[string]bool{}
+		for _, s := range series {
+			v, ok := s[label]
+			if !ok || seen[string(v)] {
+				continue
+			}
+			seen[string(v)] = true
+			res = append(res, string(v))
+		}
+		return res, nil
+	}
+
+	return nil, fmt.Errorf("prometheus discovery query not supported: %s", expr)
+}
+
 // promToModel converts a prometheus result metric to a domain model one.
 func (g *gatherer) promToModel(pm prommodel.Value) ([]model.MetricSeries, error) {
 	res := []model.MetricSeries{}
@@ -12,6 +12,7 @@ import (
 	"github.com/stretchr/testify/mock"
 
 	"github.com/slok/grafterm/internal/model"
+	"github.com/slok/grafterm/internal/service/metric"
 	"github.com/slok/grafterm/internal/service/metric/prometheus"
 )
 
@@ -254,3 +255,77 @@ func TestGathererGatherRange(t *testing.T) {
 		})
 	}
 }
+
+func TestGathererDiscoverValues(t *testing.T) {
+	tests := []struct {
+		name      string
+		query     model.Query
+		mock      func(m *mpromv1.API)
+		expValues []string
+		expErr    bool
+	}{
+		{
+			name:  "A label names query should return the label names.",
+			query: model.Query{Expr: "label_names()"},
+			mock: func(m *mpromv1.API) {
+				m.On("LabelNames", mock.Anything).Once().Return([]string{"job", "namespace"}, nil)
+			},
+			expValues: []string{"job", "namespace"},
+		},
+		{
+			name:  "A label values query with only the label should return the values of the label.",
+			query: model.Query{Expr: "label_values(namespace)"},
+			mock: func(m *mpromv1.API) {
+				m.On("LabelValues", mock.Anything, "namespace").Once().Return(prommodel.LabelValues{"default", "monitoring"}, nil)
+			},
+			expValues: []string{"default", "monitoring"},
+		},
+		{
+			name:  "A label values query with a selector should return the values of the label on the matched series.",
+			query: model.Query{Expr: `label_values(up{job="kubelet", env="prod"}, namespace)`},
+			mock: func(m *mpromv1.API) {
+				m.On("Series", mock.Anything, []string{`up{job="kubelet", env="prod"}`}, mock.Anything, mock.Anything).Once().Return([]prommodel.LabelSet{
+					{"__name__": "up", "namespace": "default"},
+					{"__name__": "up", "namespace": "monitoring"},
+					{"__name__": "up", "namespace": "default"},
+					{"__name__": "up"},
+				}, nil, nil)
+			},
+			expValues: []string{"default", "monitoring"},
+		},
+		{
+			name:  "An error from Prometheus should return an error.",
+			query: model.Query{Expr: "label_values(namespace)"},
+			mock: func(m *mpromv1.API) {
+				m.On("LabelValues", mock.Anything, "namespace").Once().Return(nil, errors.New("wanted error"))
+			},
+			expErr: true,
+		},
+		{
+			name:   "A not supported query should return an error.",
+			query:  model.Query{Expr: "up"},
+			mock:   func(m *mpromv1.API) {},
+			expErr: true,
+		},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			assert := assert.New(t)
+
+			// Mocks.
+			mapi := &mpromv1.API{}
+			test.mock(mapi)
+
+			g := prometheus.NewGatherer(prometheus.ConfigGatherer{Client: mapi})
+			gotvs, err := g.(metric.Discoverer).DiscoverValues(context.TODO(), test.query)
+
+			if test.expErr {
+				assert.Error(err)
+			} else if assert.NoError(err) {
+				assert.Equal(test.expValues, gotvs)
+				mapi.AssertExpectations(t)
+			}
+		})
+	}
+}
@@ -0,0 +1,104 @@
+package metric
+
+import (
+	"context"
+	"fmt"
+	"math/rand"
+	"time"
+)
+
+const (
+	defRetryBackoff    = 100 * time.Millisecond
+	defRetryMaxBackoff = 5 * time.Second
+)
+
+// RetryPolicy retries the failed queries that have a transient error class
+// (check ErrorClass.Transient) with an exponential backoff plus jitter.
+//
+// When the datasource asks for a wait before retrying (e.g `Retry-After`
+// HTTP header) that wait is used instead, if it's greater than the max
+// backoff or the context deadline the query is not retried.
+type RetryPolicy struct {
+	// MaxRetries is the number of times a failed query is retried.
+	MaxRetries int
+	// Backoff is the wait before the first retry, it's doubled on every retry.
+	Backoff time.Duration
+	// MaxBackoff is the maximum wait before a retry.
+	MaxBackoff time.Duration
+}
+
+func (p *RetryPolicy) defaults() {
+	if p.Backoff <= 0 {
+		p.Backoff = defRetryBackoff
+	}
+
+	if p.MaxBackoff <= 0 {
+		p.MaxBackoff = defRetryMaxBackoff
+	}
+}
+
+// Do calls the function until it succeeds, the error is not retryable or
+// the retries are exhausted.
+func (p RetryPolicy) Do(ctx context.Context, f func() error) error {
+	p.defaults()
+
+	for attempt := 0; ; attempt++ {
+		err := f()
+		if err == nil {
+			return nil
+		}
+
+		wait, ok := p.wait(ctx, attempt, err)
+		if !ok {
+			if attempt > 0 {
+				return fmt.Errorf("query failed after %d attempts: %w", attempt+1, err)
+			}
+			return err
+		}
+
+		select {
+		case <-time.After(wait):
+		case <-ctx.Done():
+			return err
+		}
+	}
+}
+
+// wait returns the wait before retrying the failed attempt, if the
+// query should not be retried it will return false.
+func (p RetryPolicy) wait(ctx context.Context, attempt int, err error) (time.Duration, bool) {
+	if attempt >= p.MaxRetries || ctx.Err() != nil || !ErrorClassOf(err).Transient() {
+		return 0, false
+	}
+
+	wait := ErrorRetryAfter(err)
+	if wait <= 0 {
+		wait = p.backoff(attempt)
+	}
+
+	if wait > p.MaxBackoff {
+		return 0, false
+	}
+
+	if deadline, ok := ctx.Deadline(); ok && time.Until(deadline) < wait {
+		return 0, false
+	}
+
+	return wait, true
+}
+
+// backoff returns the exponential backoff of the attempt with jitter, the
+// jitter is a random half of the backoff so the queries that failed at
+// the same time are not retried at the same time.
+func (p RetryPolicy) backoff(attempt int) time.Duration {
+	b := p.Backoff
+	for i := 0; i < attempt && b < p.MaxBackoff; i++ {
+		b *= 2
+	}
+	if b > p.MaxBackoff {
+		b = p.MaxBackoff
+	}
+
+	half := b / 2
+	return half + time.Duration(rand.Int63n(int64(half)+1))
+}
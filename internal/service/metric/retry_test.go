@@ -0,0 +1,96 @@
+package metric
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestRetryPolicyDo(t *testing.T) {
+	errUnavailable := NewError(ErrorClassUnavailable, errors.New("unavailable"))
+	errBadQuery := NewError(ErrorClassBadQuery, errors.New("bad query"))
+
+	tests := []struct {
+		name        string
+		policy      RetryPolicy
+		errs        []error
+		expAttempts int
+		expErr      error
+	}{
+		{
+			name:        "A successful query should not be retried.",
+			policy:      RetryPolicy{MaxRetries: 3, Backoff: time.Millisecond},
+			errs:        []error{nil},
+			expAttempts: 1,
+		},
+		{
+			name:        "A transient error should be retried until success.",
+			policy:      RetryPolicy{MaxRetries: 3, Backoff: time.Millisecond},
+			errs:        []error{errUnavailable, errUnavailable, nil},
+			expAttempts: 3,
+		},
+		{
+			name:        "A transient error should be retried until the retries are exhausted.",
+			policy:      RetryPolicy{MaxRetries: 2, Backoff: time.Millisecond},
+			errs:        []error{errUnavailable, errUnavailable, errUnavailable, nil},
+			expAttempts: 3,
+			expErr:      errUnavailable,
+		},
+		{
+			name:        "A non transient error should not be retried.",
+			policy:      RetryPolicy{MaxRetries: 3, Backoff: time.Millisecond},
+			errs:        []error{errBadQuery, nil},
+			expAttempts: 1,
+			expErr:      errBadQuery,
+		},
+		{
+			name:   "A retry after greater than the max backoff should not be retried.",
+			policy: RetryPolicy{MaxRetries: 3, Backoff: time.Millisecond, MaxBackoff: time.Second},
+			errs: []error{
+				&Error{Class: ErrorClassRateLimited, RetryAfter: time.Minute, Err: errors.New("rate limited")},
+				nil,
+			},
+			expAttempts: 1,
+			expErr:      errors.New("rate limited"),
+		},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			assert := assert.New(t)
+
+			attempts := 0
+			err := test.policy.Do(context.TODO(), func() error {
+				err := test.errs[attempts]
+				attempts++
+				return err
+			})
+
+			assert.Equal(test.expAttempts, attempts)
+			if test.expErr != nil {
+				assert.Error(err)
+				assert.Contains(err.Error(), test.expErr.Error())
+			} else {
+				assert.NoError(err)
+			}
+		})
+	}
+}
+
+func TestRetryPolicyBackoff(t *testing.T) {
+	assert := assert.New(t)
+
+	p := RetryPolicy{Backoff: 100 * time.Millisecond, MaxBackoff: time.Second}
+
+	// The backoff is doubled on every attempt with a random half as jitter.
+	for attempt, exp := range []time.Duration{100, 200, 400, 800, 1000, 1000} {
+		exp *= time.Millisecond
+		for i := 0; i < 50; i++ {
+			got := p.backoff(attempt)
+			assert.True(got >= exp/2 && got <= exp, "attempt %d backoff %s out of [%s, %s]", attempt, got, exp/2, exp)
+		}
+	}
+}
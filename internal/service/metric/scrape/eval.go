@@ -0,0 +1,280 @@
+package scrape
+
+import (
+	"fmt"
+	"math"
+	"time"
+)
+
+// lookbackDelta is the time range used to get the latest sample of the
+// series on instant selectors.
+const lookbackDelta = 5 * time.Minute
+
+// vectorSample is a sample of an instant vector.
+type vectorSample struct {
+	labels labels
+	value  float64
+}
+
+// value is the result of an expression evaluation, an scalar or an instant
+// vector.
+type value struct {
+	isScalar bool
+	scalar   float64
+	vector   []vectorSample
+}
+
+// evaluator evaluates the expressions using the storage series.
+type evaluator struct {
+	storage *storage
+}
+
+// eval evaluates the expression at a point in time.
+func (e evaluator) eval(n node, t time.Time) (value, error) {
+	switch v := n.(type) {
+	case *numberLiteral:
+		return value{isScalar: true, scalar: v.value}, nil
+
+	case *vectorSelector:
+		res := []vectorSample{}
+		for _, s := range e.storage.query(v.matchers, t.Add(-1*lookbackDelta), t) {
+			res = append(res, vectorSample{
+				labels: s.labels,
+				value:  s.samples[len(s.samples)-1].value,
+			})
+		}
+		return value{vector: res}, nil
+
+	case *matrixSelector:
+		return value{}, fmt.Errorf("range vector selectors can't be used without a function")
+
+	case *call:
+		return e.evalCall(v, t)
+
+	case *aggregation:
+		return e.evalAggregation(v, t)
+
+	case *binaryExpr:
+		return e.evalBinary(v, t)
+	}
+
+	return value{}, fmt.Errorf("unknown expression %T", n)
+}
+
+func (e evaluator) evalCall(c *call, t time.Time) (value, error) {
+	ms := c.arg.(*matrixSelector)
+
+	res := []vectorSample{}
+	for _, s := range e.storage.query(ms.selector.matchers, t.Add(-1*ms.rng), t) {
+		if len(s.samples) < 2 {
+			continue
+		}
+
+		var v float64
+		switch c.fn {
+		case "rate", "increase":
+			v = extrapolatedDelta(s.samples, t.Add(-1*ms.rng), t)
+			if c.fn == "rate" {
+				v = v / ms.rng.Seconds()
+			}
+		case "irate":
+			prev, last := s.samples[len(s.samples)-2], s.samples[len(s.samples)-1]
+			v = counterDelta([]sample{prev, last}) / last.ts.Sub(prev.ts).Seconds()
+		}
+
+		res = append(res, vectorSample{
+			labels: s.labels.without(nameLabel),
+			value:  v,
+		})
+	}
+
+	return value{vector: res}, nil
+}
+
+// extrapolatedDelta returns the increase of a counter on the range extrapolated
+// in the same way Prometheus does. The increase between the first and the
+// last samples is extrapolated to the range limits if the samples are close
+// to them (less than 1.1 times the average interval between the samples),
+// if not, it's extrapolated half of the average interval. The extrapolation
+// to the start of the range doesn't go below zero.
+func extrapolatedDelta(samples []sample, start, end time.Time) float64 {
+	first, last := samples[0], samples[len(samples)-1]
+	delta := counterDelta(samples)
+
+	sampled := last.ts.Sub(first.ts).Seconds()
+	toStart := first.ts.Sub(start).Seconds()
+	toEnd := end.Sub(last.ts).Seconds()
+	if delta > 0 && first.value >= 0 {
+		toZero := sampled * (first.value / delta)
+		if toZero < toStart {
+			toStart = toZero
+		}
+	}
+
+	avg := sampled / float64(len(samples)-1)
+	threshold := avg * 1.1
+	extrapolated := sampled
+	if toStart < threshold {
+		extrapolated += toStart
+	} else {
+		extrapolated += avg / 2
+	}
+	if toEnd < threshold {
+		extrapolated += toEnd
+	} else {
+		extrapolated += avg / 2
+	}
+
+	return delta * (extrapolated / sampled)
+}
+
+// counterDelta returns the increase of a counter, taking into account the
+// counter resets.
+func counterDelta(samples []sample) float64 {
+	delta := 0.0
+	prev := samples[0].value
+	for _, s := range samples[1:] {
+		if s.value < prev {
+			delta += s.value
+		} else {
+			delta += s.value - prev
+		}
+		prev = s.value
+	}
+
+	return delta
+}
+
+func (e evaluator) evalAggregation(a *aggregation, t time.Time) (value, error) {
+	v, err := e.eval(a.expr, t)
+	if err != nil {
+		return value{}, err
+	}
+	if v.isScalar {
+		return value{}, fmt.Errorf("%s expects an instant vector", a.op)
+	}
+
+	type group struct {
+		labels labels
+		values []float64
+	}
+	groups := map[string]*group{}
+	order := []string{}
+	for _, s := range v.vector {
+		var ls labels
+		if a.without {
+			ls = s.labels.without(append(a.grouping, nameLabel)...)
+		} else {
+			ls = s.labels.only(a.grouping...)
+		}
+
+		key := ls.key()
+		g, ok := groups[key]
+		if !ok {
+			g = &group{labels: ls}
+			groups[key] = g
+			order = append(order, key)
+		}
+		g.values = append(g.values, s.value)
+	}
+
+	res := make([]vectorSample, 0, len(order))
+	for _, key := range order {
+		g := groups[key]
+		res = append(res, vectorSample{labels: g.labels, value: aggregate(a.op, g.values)})
+	}
+
+	return value{vector: res}, nil
+}
+
+func aggregate(op string, vs []float64) float64 {
+	switch op {
+	case "count":
+		return float64(len(vs))
+	case "min", "max":
+		res := vs[0]
+		for _, v := range vs[1:] {
+			if (op == "min" && v < res) || (op == "max" && v > res) {
+				res = v
+			}
+		}
+		return res
+	}
+
+	sum := 0.0
+	for _, v := range vs {
+		sum += v
+	}
+	if op == "avg" {
+		return sum / float64(len(vs))
+	}
+
+	return sum
+}
+
+func (e evaluator) evalBinary(b *binaryExpr, t time.Time) (value, error) {
+	lhs, err := e.eval(b.lhs, t)
+	if err != nil {
+		return value{}, err
+	}
+	rhs, err := e.eval(b.rhs, t)
+	if err != nil {
+		return value{}, err
+	}
+
+	switch {
+	case lhs.isScalar && rhs.isScalar:
+		return value{isScalar: true, scalar: arithmetic(b.op, lhs.scalar, rhs.scalar)}, nil
+
+	case rhs.isScalar:
+		res := make([]vectorSample, 0, len(lhs.vector))
+		for _, s := range lhs.vector {
+			res = append(res, vectorSample{labels: s.labels.without(nameLabel), value: arithmetic(b.op, s.value, rhs.scalar)})
+		}
+		return value{vector: res}, nil
+
+	case lhs.isScalar:
+		res := make([]vectorSample, 0, len(rhs.vector))
+		for _, s := range rhs.vector {
+			res = append(res, vectorSample{labels: s.labels.without(nameLabel), value: arithmetic(b.op, lhs.scalar, s.value)})
+		}
+		return value{vector: res}, nil
+	}
+
+	// Vector to vector one-to-one matching using all the labels except
+	// the metric name.
+	rhsByKey := map[string]vectorSample{}
+	for _, s := range rhs.vector {
+		ls := s.labels.without(nameLabel)
+		rhsByKey[ls.key()] = vectorSample{labels: ls, value: s.value}
+	}
+
+	res := []vectorSample{}
+	for _, s := range lhs.vector {
+		ls := s.labels.without(nameLabel)
+		r, ok := rhsByKey[ls.key()]
+		if !ok {
+			continue
+		}
+		res = append(res, vectorSample{labels: ls, value: arithmetic(b.op, s.value, r.value)})
+	}
+
+	return value{vector: res}, nil
+}
+
+func arithmetic(op string, l, r float64) float64 {
+	switch op {
+	case "+":
+		return l + r
+	case "-":
+		return l - r
+	case "*":
+		return l * r
+	case "/":
+		return l / r
+	case "%":
+		return math.Mod(l, r)
+	}
+
+	return math.NaN()
+}
@@ -0,0 +1,212 @@
+package scrape
+
+import (
+	"bufio"
+	"fmt"
+	"io"
+	"math"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// scrapedSample is a sample of the scraped exposition.
+type scrapedSample struct {
+	labels labels
+	value  float64
+	// ts is the explicit timestamp of the sample, zero if not set.
+	ts time.Time
+}
+
+// metricMetadata is the metadata of a metric family of the exposition.
+type metricMetadata struct {
+	typ  string
+	help string
+	unit string
+}
+
+// parseExposition parses the Prometheus text exposition format, it returns
+// the samples and the metadata of the metric families.
+func parseExposition(r io.Reader) ([]scrapedSample, map[string]metricMetadata, error) {
+	samples := []scrapedSample{}
+	metadata := map[string]metricMetadata{}
+
+	sc := bufio.NewScanner(r)
+	sc.Buffer(make([]byte, 0, 64*1024), 1024*1024)
+	lineNum := 0
+	for sc.Scan() {
+		lineNum++
+		line := strings.TrimSpace(sc.Text())
+		if line == "" {
+			continue
+		}
+
+		// Comments and metadata.
+		if strings.HasPrefix(line, "#") {
+			fields := strings.SplitN(strings.TrimSpace(line[1:]), " ", 3)
+			if len(fields) < 3 {
+				continue
+			}
+			md := metadata[fields[1]]
+			switch fields[0] {
+			case "TYPE":
+				md.typ = fields[2]
+			case "HELP":
+				md.help = fields[2]
+			case "UNIT":
+				md.unit = fields[2]
+			default:
+				continue
+			}
+			metadata[fields[1]] = md
+			continue
+		}
+
+		s, err := parseSampleLine(line)
+		if err != nil {
+			return nil, nil, fmt.Errorf("invalid exposition line %d: %w", lineNum, err)
+		}
+		samples = append(samples, s)
+	}
+
+	if err := sc.Err(); err != nil {
+		return nil, nil, err
+	}
+
+	return samples, metadata, nil
+}
+
+// parseSampleLine parses a sample line, e.g: `http_requests_total{code="200"} 1027 1395066363000`.
+func parseSampleLine(line string) (scrapedSample, error) {
+	s := scrapedSample{labels: labels{}}
+
+	// Metric name.
+	i := 0
+	for i < len(line) && isNameChar(line[i], i == 0) {
+		i++
+	}
+	if i == 0 {
+		return s, fmt.Errorf("missing metric name")
+	}
+	s.labels[nameLabel] = line[:i]
+	rest := line[i:]
+
+	// Labels.
+	if strings.HasPrefix(rest, "{") {
+		ls, n, err := parseLabels(rest)
+		if err != nil {
+			return s, err
+		}
+		for k, v := range ls {
+			s.labels[k] = v
+		}
+		rest = rest[n:]
+	}
+
+	// Value and optional timestamp.
+	fields := strings.Fields(rest)
+	if len(fields) < 1 || len(fields) > 2 {
+		return s, fmt.Errorf("invalid value and timestamp")
+	}
+	v, err := parseFloat(fields[0])
+	if err != nil {
+		return s, err
+	}
+	s.value = v
+
+	if len(fields) == 2 {
+		ms, err := strconv.ParseInt(fields[1], 10, 64)
+		if err != nil {
+			return s, fmt.Errorf("invalid timestamp: %w", err)
+		}
+		s.ts = time.Unix(0, ms*int64(time.Millisecond))
+	}
+
+	return s, nil
+}
+
+// parseLabels parses the labels in the form of `{a="b",c="d"}` and returns
+// the labels and the number of bytes consumed.
+func parseLabels(s string) (labels, int, error) {
+	ls := labels{}
+	i := 1 // Skip `{`.
+	for {
+		// Skip spaces and separators.
+		for i < len(s) && (s[i] == ' ' || s[i] == ',') {
+			i++
+		}
+		if i >= len(s) {
+			return nil, 0, fmt.Errorf("unclosed labels")
+		}
+		if s[i] == '}' {
+			return ls, i + 1, nil
+		}
+
+		// Name.
+		st := i
+		for i < len(s) && isNameChar(s[i], i == st) && s[i] != ':' {
+			i++
+		}
+		name := s[st:i]
+		if name == "" || i+1 >= len(s) || s[i] != '=' || s[i+1] != '"' {
+			return nil, 0, fmt.Errorf("invalid label at position %d", st)
+		}
+		i += 2
+
+		// Quoted value with escapes.
+		var sb strings.Builder
+		closed := false
+		for i < len(s) {
+			c := s[i]
+			i++
+			if c == '"' {
+				closed = true
+				break
+			}
+			if c == '\\' && i < len(s) {
+				switch s[i] {
+				case 'n':
+					sb.WriteByte('\n')
+				default:
+					sb.WriteByte(s[i])
+				}
+				i++
+				continue
+			}
+			sb.WriteByte(c)
+		}
+		if !closed {
+			return nil, 0, fmt.Errorf("unclosed label value of %s", name)
+		}
+		ls[name] = sb.String()
+	}
+}
+
+func parseFloat(s string) (float64, error) {
+	switch s {
+	case "+Inf", "Inf":
+		return math.Inf(1), nil
+	case "-Inf":
+		return math.Inf(-1), nil
+	case "NaN":
+		return math.NaN(), nil
+	}
+
+	v, err := strconv.ParseFloat(s, 64)
+	if err != nil {
+		return 0, fmt.Errorf("invalid value: %w", err)
+	}
+
+	return v, nil
+}
+
+// isNameChar returns if the char is valid on a metric name.
+func isNameChar(c byte, first bool) bool {
+	switch {
+	case c >= 'a' && c <= 'z', c >= 'A' && c <= 'Z', c == '_', c == ':':
+		return true
+	case c >= '0' && c <= '9':
+		return !first
+	}
+	return false
+}
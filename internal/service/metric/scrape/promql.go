@@ -0,0 +1,461 @@
+package scrape
+
+import (
+	"fmt"
+	"regexp"
+	"strconv"
+	"strings"
+	"time"
+
+	prommodel "github.com/prometheus/common/model"
+)
+
+// The supported PromQL subset nodes.
+type (
+	node interface{}
+
+	numberLiteral struct {
+		value float64
+	}
+
+	vectorSelector struct {
+		name     string
+		matchers []*matcher
+	}
+
+	matrixSelector struct {
+		selector *vectorSelector
+		rng      time.Duration
+	}
+
+	call struct {
+		fn  string
+		arg node
+	}
+
+	aggregation struct {
+		op       string
+		grouping []string
+		without  bool
+		expr     node
+	}
+
+	binaryExpr struct {
+		op       string
+		lhs, rhs node
+	}
+)
+
+var (
+	aggregationOps = map[string]bool{"sum": true, "avg": true, "min": true, "max": true, "count": true}
+	rangeFunctions = map[string]bool{"rate": true, "irate": true, "increase": true}
+)
+
+// matcher is a label matcher of a selector.
+type matcher struct {
+	name  string
+	op    string
+	value string
+	re    *regexp.Regexp
+}
+
+func newMatcher(name, op, value string) (*matcher, error) {
+	m := &matcher{name: name, op: op, value: value}
+	if op == "=~" || op == "!~" {
+		re, err := regexp.Compile("^(?:" + value + ")$")
+		if err != nil {
+			return nil, fmt.Errorf("invalid regex on %s matcher: %w", name, err)
+		}
+		m.re = re
+	}
+
+	return m, nil
+}
+
+func (m *matcher) matches(v string) bool {
+	switch m.op {
+	case "=":
+		return v == m.value
+	case "!=":
+		return v != m.value
+	case "=~":
+		return m.re.MatchString(v)
+	case "!~":
+		return !m.re.MatchString(v)
+	}
+	return false
+}
+
+type tokenKind int
+
+const (
+	tokenEOF tokenKind = iota
+	tokenIdent
+	tokenNumber
+	tokenString
+	tokenDuration
+	tokenOp
+)
+
+type token struct {
+	kind tokenKind
+	val  string
+	pos  int
+}
+
+// lex splits the expression in tokens.
+func lex(expr string) ([]token, error) {
+	tokens := []token{}
+	i := 0
+	for i < len(expr) {
+		c := expr[i]
+		switch {
+		case c == ' ' || c == '\t' || c == '\n' || c == '\r':
+			i++
+
+		case isNameChar(c, true):
+			st := i
+			for i < len(expr) && isNameChar(expr[i], false) {
+				i++
+			}
+			tokens = append(tokens, token{kind: tokenIdent, val: expr[st:i], pos: st})
+
+		case (c >= '0' && c <= '9') || (c == '.' && i+1 < len(expr) && expr[i+1] >= '0' && expr[i+1] <= '9'):
+			st := i
+			for i < len(expr) && ((expr[i] >= '0' && expr[i] <= '9') || expr[i] == '.') {
+				i++
+			}
+			if i < len(expr) && (expr[i] == 'e' || expr[i] == 'E') {
+				i++
+				if i < len(expr) && (expr[i] == '+' || expr[i] == '-') {
+					i++
+				}
+				for i < len(expr) && expr[i] >= '0' && expr[i] <= '9' {
+					i++
+				}
+			}
+			tokens = append(tokens, token{kind: tokenNumber, val: expr[st:i], pos: st})
+
+		case c == '"' || c == '\'' || c == '`':
+			st := i
+			i++
+			var sb strings.Builder
+			closed := false
+			for i < len(expr) {
+				if expr[i] == c {
+					closed = true
+					i++
+					break
+				}
+				if expr[i] == '\\' && c != '`' && i+1 < len(expr) {
+					i++
+				}
+				sb.WriteByte(expr[i])
+				i++
+			}
+			if !closed {
+				return nil, fmt.Errorf("unclosed string at position %d", st)
+			}
+			tokens = append(tokens, token{kind: tokenString, val: sb.String(), pos: st})
+
+		case c == '[':
+			st := i
+			end := strings.IndexByte(expr[i:], ']')
+			if end < 0 {
+				return nil, fmt.Errorf("unclosed range at position %d", st)
+			}
+			tokens = append(tokens, token{kind: tokenDuration, val: strings.TrimSpace(expr[i+1 : i+end]), pos: st})
+			i += end + 1
+
+		default:
+			st := i
+			op := string(c)
+			if i+1 < len(expr) {
+				switch two := expr[i : i+2]; two {
+				case "!=", "=~", "!~":
+					op = two
+				}
+			}
+			if len(op) == 1 && !strings.ContainsRune("(){},+-*/%=", rune(c)) {
+				return nil, fmt.Errorf("unexpected character %q at position %d", c, st)
+			}
+			i += len(op)
+			tokens = append(tokens, token{kind: tokenOp, val: op, pos: st})
+		}
+	}
+
+	tokens = append(tokens, token{kind: tokenEOF, pos: len(expr)})
+	return tokens, nil
+}
+
+// parser is a recursive descent parser of the supported PromQL subset:
+// selectors (with `[range]`), `rate`, `irate` and `increase` functions,
+// `sum`, `avg`, `min`, `max` and `count` aggregations (with `by` and
+// `without`), and `+`, `-`, `*`, `/` and `%` arithmetic.
+type parser struct {
+	tokens []token
+	pos    int
+}
+
+// parseExpr parses a PromQL expression.
+func parseExpr(expr string) (node, error) {
+	tokens, err := lex(expr)
+	if err != nil {
+		return nil, err
+	}
+
+	p := &parser{tokens: tokens}
+	n, err := p.parseBinary(0)
+	if err != nil {
+		return nil, err
+	}
+	if t := p.peek(); t.kind != tokenEOF {
+		return nil, fmt.Errorf("unexpected %q at position %d", t.val, t.pos)
+	}
+
+	return n, nil
+}
+
+func (p *parser) peek() token {
+	return p.tokens[p.pos]
+}
+
+func (p *parser) next() token {
+	t := p.tokens[p.pos]
+	if t.kind != tokenEOF {
+		p.pos++
+	}
+	return t
+}
+
+func (p *parser) expectOp(op string) error {
+	t := p.next()
+	if t.kind != tokenOp || t.val != op {
+		return fmt.Errorf("expected %q at position %d", op, t.pos)
+	}
+	return nil
+}
+
+var binaryPrecedence = map[string]int{"+": 1, "-": 1, "*": 2, "/": 2, "%": 2}
+
+func (p *parser) parseBinary(minPrec int) (node, error) {
+	lhs, err := p.parseUnary()
+	if err != nil {
+		return nil, err
+	}
+
+	for {
+		t := p.peek()
+		prec, ok := binaryPrecedence[t.val]
+		if t.kind != tokenOp || !ok || prec <= minPrec {
+			return lhs, nil
+		}
+		p.next()
+
+		rhs, err := p.parseBinary(prec)
+		if err != nil {
+			return nil, err
+		}
+		lhs = &binaryExpr{op: t.val, lhs: lhs, rhs: rhs}
+	}
+}
+
+func (p *parser) parseUnary() (node, error) {
+	t := p.peek()
+	if t.kind == tokenOp && (t.val == "-" || t.val == "+") {
+		p.next()
+		n, err := p.parseUnary()
+		if err != nil {
+			return nil, err
+		}
+		if t.val == "+" {
+			return n, nil
+		}
+		return &binaryExpr{op: "*", lhs: &numberLiteral{value: -1}, rhs: n}, nil
+	}
+
+	return p.parsePrimary()
+}
+
+func (p *parser) parsePrimary() (node, error) {
+	t := p.peek()
+	switch {
+	case t.kind == tokenNumber:
+		p.next()
+		v, err := strconv.ParseFloat(t.val, 64)
+		if err != nil {
+			return nil, fmt.Errorf("invalid number %q at position %d", t.val, t.pos)
+		}
+		return &numberLiteral{value: v}, nil
+
+	case t.kind == tokenOp && t.val == "(":
+		p.next()
+		n, err := p.parseBinary(0)
+		if err != nil {
+			return nil, err
+		}
+		return n, p.expectOp(")")
+
+	case t.kind == tokenOp && t.val == "{":
+		return p.parseSelector("")
+
+	case t.kind == tokenIdent:
+		p.next()
+		nt := p.peek()
+		switch {
+		case aggregationOps[t.val] && (nt.val == "(" || nt.val == "by" || nt.val == "without"):
+			return p.parseAggregation(t.val)
+		case rangeFunctions[t.val] && nt.val == "(":
+			return p.parseCall(t.val)
+		case nt.kind == tokenOp && nt.val == "(":
+			return nil, fmt.Errorf("function %q not supported", t.val)
+		}
+		return p.parseSelector(t.val)
+	}
+
+	return nil, fmt.Errorf("unexpected %q at position %d", t.val, t.pos)
+}
+
+func (p *parser) parseAggregation(op string) (node, error) {
+	agg := &aggregation{op: op}
+
+	parseGrouping := func() error {
+		t := p.peek()
+		if t.kind != tokenIdent || (t.val != "by" && t.val != "without") {
+			return nil
+		}
+		p.next()
+		agg.without = t.val == "without"
+		if err := p.expectOp("("); err != nil {
+			return err
+		}
+		for {
+			t := p.next()
+			switch {
+			case t.kind == tokenOp && t.val == ")":
+				return nil
+			case t.kind == tokenOp && t.val == ",":
+			case t.kind == tokenIdent:
+				agg.grouping = append(agg.grouping, t.val)
+			default:
+				return fmt.Errorf("unexpected %q on grouping at position %d", t.val, t.pos)
+			}
+		}
+	}
+
+	// The grouping can be before or after the aggregated expression.
+	if err := parseGrouping(); err != nil {
+		return nil, err
+	}
+	if err := p.expectOp("("); err != nil {
+		return nil, err
+	}
+	expr, err := p.parseBinary(0)
+	if err != nil {
+		return nil, err
+	}
+	if err := p.expectOp(")"); err != nil {
+		return nil, err
+	}
+	agg.expr = expr
+	if err := parseGrouping(); err != nil {
+		return nil, err
+	}
+
+	return agg, nil
+}
+
+func (p *parser) parseCall(fn string) (node, error) {
+	if err := p.expectOp("("); err != nil {
+		return nil, err
+	}
+	arg, err := p.parseBinary(0)
+	if err != nil {
+		return nil, err
+	}
+	if err := p.expectOp(")"); err != nil {
+		return nil, err
+	}
+
+	if _, ok := arg.(*matrixSelector); !ok {
+		return nil, fmt.Errorf("%s expects a range vector selector", fn)
+	}
+
+	return &call{fn: fn, arg: arg}, nil
+}
+
+func (p *parser) parseSelector(name string) (node, error) {
+	vs := &vectorSelector{name: name}
+	if name != "" {
+		m, _ := newMatcher(nameLabel, "=", name)
+		vs.matchers = append(vs.matchers, m)
+	}
+
+	// Label matchers.
+	if t := p.peek(); t.kind == tokenOp && t.val == "{" {
+		p.next()
+		for {
+			t := p.next()
+			if t.kind == tokenOp && t.val == "}" {
+				break
+			}
+			if t.kind == tokenOp && t.val == "," {
+				continue
+			}
+			if t.kind != tokenIdent {
+				return nil, fmt.Errorf("unexpected %q on label matchers at position %d", t.val, t.pos)
+			}
+
+			op := p.next()
+			if op.kind != tokenOp || (op.val != "=" && op.val != "!=" && op.val != "=~" && op.val != "!~") {
+				return nil, fmt.Errorf("invalid label matcher operator at position %d", op.pos)
+			}
+			v := p.next()
+			if v.kind != tokenString {
+				return nil, fmt.Errorf("expected label matcher value at position %d", v.pos)
+			}
+
+			m, err := newMatcher(t.val, op.val, v.val)
+			if err != nil {
+				return nil, err
+			}
+			vs.matchers = append(vs.matchers, m)
+		}
+	}
+
+	if len(vs.matchers) == 0 {
+		return nil, fmt.Errorf("vector selector must contain at least one matcher")
+	}
+
+	// Range.
+	if t := p.peek(); t.kind == tokenDuration {
+		p.next()
+		d, err := prommodel.ParseDuration(t.val)
+		if err != nil {
+			return nil, fmt.Errorf("invalid range %q at position %d", t.val, t.pos)
+		}
+		return &matrixSelector{selector: vs, rng: time.Duration(d)}, nil
+	}
+
+	return vs, nil
+}
+
+// selectorNames returns the metric names of the selectors of the expression.
+func selectorNames(n node) []string {
+	switch v := n.(type) {
+	case *vectorSelector:
+		if v.name != "" {
+			return []string{v.name}
+		}
+	case *matrixSelector:
+		return selectorNames(v.selector)
+	case *call:
+		return selectorNames(v.arg)
+	case *aggregation:
+		return selectorNames(v.expr)
+	case *binaryExpr:
+		return append(selectorNames(v.lhs), selectorNames(v.rhs)...)
+	}
+
+	return nil
+}
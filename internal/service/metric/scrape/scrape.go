@@ -0,0 +1,361 @@
+package scrape
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+	"net/url"
+	"regexp"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/slok/grafterm/internal/model"
+	"github.com/slok/grafterm/internal/service/metric"
+)
+
+const (
+	defInterval  = 15 * time.Second
+	defRetention = 1 * time.Hour
+
+	instanceLabel = "instance"
+	upMetricName  = "up"
+)
+
+// ConfigGatherer is the configuration of the scrape gatherer.
+type ConfigGatherer struct {
+	// Targets are the URLs of the metrics endpoints in Prometheus text
+	// exposition format.
+	Targets []string
+	// Interval is the interval between the scrapes of the targets.
+	Interval time.Duration
+	// Retention is the time the samples are kept in memory.
+	Retention time.Duration
+	HTTPCli   *http.Client
+}
+
+func (c *ConfigGatherer) defaults() error {
+	if len(c.Targets) == 0 {
+		return fmt.Errorf("no scrape targets given")
+	}
+
+	if c.Interval <= 0 {
+		c.Interval = defInterval
+	}
+
+	if c.Retention <= 0 {
+		c.Retention = defRetention
+	}
+
+	if c.HTTPCli == nil {
+		c.HTTPCli = &http.Client{Timeout: c.Interval}
+	}
+
+	return nil
+}
+
+type gatherer struct {
+	ctx     context.Context
+	cfg     ConfigGatherer
+	targets []*url.URL
+	storage *storage
+	eval    evaluator
+
+	startOnce sync.Once
+	// scraped is closed when the first scrape has been made.
+	scraped chan struct{}
+}
+
+// NewGatherer returns a new metric gatherer that scrapes the metrics endpoints
+// of the targets periodically without the need of a Prometheus server. The
+// samples are stored in memory and the queries are a subset of PromQL.
+// The scrapes start with the first query (the ones of the not used datasources
+// never start) and stop when the context is done.
+func NewGatherer(ctx context.Context, cfg ConfigGatherer) (metric.Gatherer, error) {
+	err := cfg.defaults()
+	if err != nil {
+		return nil, err
+	}
+
+	targets := make([]*url.URL, 0, len(cfg.Targets))
+	for _, t := range cfg.Targets {
+		u, err := url.Parse(t)
+		if err != nil {
+			return nil, fmt.Errorf("invalid scrape target %q: %w", t, err)
+		}
+		targets = append(targets, u)
+	}
+
+	st := newStorage(cfg.Retention)
+	g := &gatherer{
+		ctx:     ctx,
+		cfg:     cfg,
+		targets: targets,
+		storage: st,
+		eval:    evaluator{storage: st},
+		scraped: make(chan struct{}),
+	}
+
+	return g, nil
+}
+
+// start starts the scrapes if not started and waits until the first scrape
+// has been made, so the first queries don't get empty results.
+func (g *gatherer) start(ctx context.Context) error {
+	g.startOnce.Do(func() {
+		go g.run()
+	})
+
+	select {
+	case <-g.scraped:
+		return nil
+	case <-ctx.Done():
+		return ctx.Err()
+	}
+}
+
+// run scrapes the targets every interval until the context is done, the
+// first scrape is made right away.
+func (g *gatherer) run() {
+	g.scrapeAll()
+	close(g.scraped)
+
+	ticker := time.NewTicker(g.cfg.Interval)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-g.ctx.Done():
+			return
+		case <-ticker.C:
+			g.scrapeAll()
+		}
+	}
+}
+
+func (g *gatherer) scrapeAll() {
+	now := time.Now()
+	for _, t := range g.targets {
+		up := 1.0
+		if err := g.scrape(t, now); err != nil {
+			up = 0
+		}
+		g.storage.append(labels{nameLabel: upMetricName, instanceLabel: t.Host}, now, up)
+	}
+
+	g.storage.truncate(now)
+}
+
+func (g *gatherer) scrape(target *url.URL, now time.Time) error {
+	ctx, cancel := context.WithTimeout(g.ctx, g.cfg.Interval)
+	defer cancel()
+
+	req, err := http.NewRequest(http.MethodGet, target.String(), nil)
+	if err != nil {
+		return err
+	}
+	req.Header.Set("Accept", "text/plain;version=0.0.4")
+
+	resp, err := g.cfg.HTTPCli.Do(req.WithContext(ctx))
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode != http.StatusOK {
+		return metric.NewHTTPError(resp, fmt.Errorf("scrape target returned %d status code", resp.StatusCode))
+	}
+
+	samples, metadata, err := parseExposition(resp.Body)
+	if err != nil {
+		return err
+	}
+
+	for _, s := range samples {
+		if _, ok := s.labels[instanceLabel]; !ok {
+			s.labels[instanceLabel] = target.Host
+		}
+		ts := s.ts
+		if ts.IsZero() {
+			ts = now
+		}
+		g.storage.append(s.labels, ts, s.value)
+	}
+	g.storage.setMetadata(metadata)
+
+	return nil
+}
+
+func (g *gatherer) GatherSingle(ctx context.Context, query model.Query, t time.Time) ([]model.MetricSeries, error) {
+	if err := g.start(ctx); err != nil {
+		return []model.MetricSeries{}, err
+	}
+
+	n, err := parseExpr(query.Expr)
+	if err != nil {
+		return []model.MetricSeries{}, fmt.Errorf("invalid query: %w", err)
+	}
+
+	v, err := g.eval.eval(n, t)
+	if err != nil {
+		return []model.MetricSeries{}, err
+	}
+
+	return appendValue(nil, nil, v, t), nil
+}
+
+// GatherRange evaluates the query on every step of the range, if the step
+// is not set the scrape interval will be used.
+func (g *gatherer) GatherRange(ctx context.Context, query model.Query, start, end time.Time, step time.Duration) ([]model.MetricSeries, error) {
+	if err := g.start(ctx); err != nil {
+		return []model.MetricSeries{}, err
+	}
+
+	n, err := parseExpr(query.Expr)
+	if err != nil {
+		return []model.MetricSeries{}, fmt.Errorf("invalid query: %w", err)
+	}
+
+	if step <= 0 {
+		step = g.cfg.Interval
+	}
+
+	res := []model.MetricSeries{}
+	index := map[string]int{}
+	for t := start; !t.After(end); t = t.Add(step) {
+		if err := ctx.Err(); err != nil {
+			return []model.MetricSeries{}, err
+		}
+
+		v, err := g.eval.eval(n, t)
+		if err != nil {
+			return []model.MetricSeries{}, err
+		}
+		res = appendValue(res, index, v, t)
+	}
+
+	return res, nil
+}
+
+// appendValue appends the evaluated value at a point in time to the series
+// that have the same ID, using the index to know the position of each series.
+func appendValue(res []model.MetricSeries, index map[string]int, v value, t time.Time) []model.MetricSeries {
+	if v.isScalar {
+		if len(res) == 0 {
+			res = append(res, model.MetricSeries{})
+		}
+		res[0].Metrics = append(res[0].Metrics, model.Metric{TS: t, Value: v.scalar})
+		return res
+	}
+
+	for _, s := range v.vector {
+		id := s.labels.String()
+		i, ok := index[id]
+		if !ok {
+			i = len(res)
+			if index != nil {
+				index[id] = i
+			}
+			res = append(res, model.MetricSeries{
+				ID:     id,
+				Labels: map[string]string(s.labels.without()),
+			})
+		}
+		res[i].Metrics = append(res[i].Metrics, model.Metric{TS: t, Value: s.value})
+	}
+
+	return res
+}
+
+var (
+	labelValuesRegexp = regexp.MustCompile(`^label_values\((.+)\)$`)
+	labelNamesRegexp  = regexp.MustCompile(`^label_names\(\)$`)
+)
+
+// DiscoverValues satisfies metric.Discoverer interface. The supported queries are:
+//   - `label_names()`: the label names.
+//   - `label_values(label)`: the values of a label.
+//   - `label_values(selector, label)`: the values of a label on the series that
+//     match the selector.
+func (g *gatherer) DiscoverValues(ctx context.Context, query model.Query) ([]string, error) {
+	if err := g.start(ctx); err != nil {
+		return nil, err
+	}
+
+	expr := strings.TrimSpace(query.Expr)
+
+	switch {
+	case labelNamesRegexp.MatchString(expr):
+		return g.storage.labelNames(), nil
+
+	case labelValuesRegexp.MatchString(expr):
+		args := labelValuesRegexp.FindStringSubmatch(expr)[1]
+
+		// Only the label.
+		i := strings.LastIndex(args, ",")
+		if i < 0 {
+			return g.storage.labelValues(nil, strings.TrimSpace(args)), nil
+		}
+
+		// Selector and label.
+		n, err := parseExpr(args[:i])
+		if err != nil {
+			return nil, fmt.Errorf("invalid selector: %w", err)
+		}
+		vs, ok := n.(*vectorSelector)
+		if !ok {
+			return nil, fmt.Errorf("%q is not a vector selector", strings.TrimSpace(args[:i]))
+		}
+		return g.storage.labelValues(vs.matchers, strings.TrimSpace(args[i+1:])), nil
+	}
+
+	return nil, fmt.Errorf("unsupported discovery query %q", expr)
+}
+
+// metadataSuffixes are the suffixes of the series that have the metadata on
+// the base metric name (histograms, summaries and counters).
+var metadataSuffixes = []string{"_bucket", "_sum", "_count", "_total"}
+
+// GatherMetadata satisfies metric.MetadataGatherer interface. It will get the
+// metadata of the metrics used on the query expression from the scraped
+// `# TYPE`, `# HELP` and `# UNIT` lines.
+func (g *gatherer) GatherMetadata(ctx context.Context, query model.Query) ([]model.MetricMetadata, error) {
+	if err := g.start(ctx); err != nil {
+		return nil, err
+	}
+
+	n, err := parseExpr(query.Expr)
+	if err != nil {
+		return nil, fmt.Errorf("invalid query: %w", err)
+	}
+
+	res := []model.MetricMetadata{}
+	seen := map[string]bool{}
+	for _, name := range selectorNames(n) {
+		if seen[name] {
+			continue
+		}
+		seen[name] = true
+
+		md, ok := g.storage.getMetadata(name)
+
+		if !ok {
+			for _, suffix := range metadataSuffixes {
+				if strings.HasSuffix(name, suffix) {
+					md, ok = g.storage.getMetadata(strings.TrimSuffix(name, suffix))
+					break
+				}
+			}
+		}
+
+		if ok {
+			res = append(res, model.MetricMetadata{
+				Name: name,
+				Type: md.typ,
+				Unit: md.unit,
+				Help: md.help,
+			})
+		}
+	}
+
+	return res, nil
+}
@@ -0,0 +1,337 @@
+package scrape_test
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/slok/grafterm/internal/model"
+	"github.com/slok/grafterm/internal/service/metric"
+	"github.com/slok/grafterm/internal/service/metric/scrape"
+)
+
+// testGatherer returns a gatherer that has scraped a target with the samples
+// of two counters, with explicit timestamps every minute since the base time.
+func testGatherer(t *testing.T, base time.Time) (metric.Gatherer, string) {
+	ms := func(minute int) int64 {
+		return base.Add(time.Duration(minute)*time.Minute).UnixNano() / int64(time.Millisecond)
+	}
+	exposition := fmt.Sprintf(`# HELP http_requests_total Total requests.
+# TYPE http_requests_total counter
+http_requests_total{code="200"} 0 %[1]d
+http_requests_total{code="200"} 60 %[2]d
+http_requests_total{code="200"} 180 %[3]d
+http_requests_total{code="500"} 0 %[1]d
+http_requests_total{code="500"} 30 %[2]d
+http_requests_total{code="500"} 60 %[3]d
+`, ms(0), ms(1), ms(2))
+
+	return scrapedGatherer(t, exposition)
+}
+
+// scrapedGatherer returns a gatherer that has scraped a target with the
+// exposition.
+func scrapedGatherer(t *testing.T, exposition string) (metric.Gatherer, string) {
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Write([]byte(exposition))
+	}))
+	t.Cleanup(srv.Close)
+
+	ctx, cancel := context.WithCancel(context.Background())
+	t.Cleanup(cancel)
+	g, err := scrape.NewGatherer(ctx, scrape.ConfigGatherer{
+		Targets:  []string{srv.URL + "/metrics"},
+		Interval: time.Hour,
+	})
+	require.NoError(t, err)
+
+	u, err := url.Parse(srv.URL)
+	require.NoError(t, err)
+
+	return g, u.Host
+}
+
+func TestGathererGatherSingle(t *testing.T) {
+	base := time.Now().Truncate(time.Second).Add(-10 * time.Minute)
+	g, instance := testGatherer(t, base)
+	at := base.Add(2 * time.Minute)
+
+	tests := map[string]struct {
+		expr      string
+		expSeries []model.MetricSeries
+		expErr    bool
+	}{
+		"A selector should return the latest sample of the series.": {
+			expr: `http_requests_total{code="200"}`,
+			expSeries: []model.MetricSeries{
+				{
+					ID:      fmt.Sprintf(`http_requests_total{code="200", instance=%q}`, instance),
+					Labels:  map[string]string{"__name__": "http_requests_total", "code": "200", "instance": instance},
+					Metrics: []model.Metric{{TS: at, Value: 180}},
+				},
+			},
+		},
+		"A rate aggregated by label with arithmetic should be evaluated.": {
+			expr: `sum by (code) (rate(http_requests_total{code=~"2..|5.."}[5m])) * 100`,
+			expSeries: []model.MetricSeries{
+				{ID: `{code="200"}`, Labels: map[string]string{"code": "200"}, Metrics: []model.Metric{{TS: at, Value: 60}}},
+				{ID: `{code="500"}`, Labels: map[string]string{"code": "500"}, Metrics: []model.Metric{{TS: at, Value: 20}}},
+			},
+		},
+		"A division between vectors should match the series by labels.": {
+			expr: `sum(rate(http_requests_total{code="500"}[5m])) / sum(rate(http_requests_total[5m]))`,
+			expSeries: []model.MetricSeries{
+				{ID: `{}`, Labels: map[string]string{}, Metrics: []model.Metric{{TS: at, Value: 0.25}}},
+			},
+		},
+		"A scalar expression should return a series without labels.": {
+			expr: `1 + 2 * 3`,
+			expSeries: []model.MetricSeries{
+				{Metrics: []model.Metric{{TS: at, Value: 7}}},
+			},
+		},
+		"A selector without matching series should return no series.": {
+			expr:      `http_requests_total{code="404"}`,
+			expSeries: []model.MetricSeries{},
+		},
+		"A rate without a range should error.": {
+			expr:   `rate(http_requests_total)`,
+			expErr: true,
+		},
+		"An unsupported function should error.": {
+			expr:   `histogram_quantile(0.99, http_requests_total)`,
+			expErr: true,
+		},
+	}
+
+	for name, test := range tests {
+		test := test
+		t.Run(name, func(t *testing.T) {
+			assert := assert.New(t)
+
+			gotSeries, err := g.GatherSingle(context.TODO(), model.Query{Expr: test.expr}, at)
+			if test.expErr {
+				assert.Error(err)
+			} else if assert.NoError(err) {
+				assert.ElementsMatch(test.expSeries, gotSeries)
+			}
+		})
+	}
+}
+
+func TestGathererRateIncrease(t *testing.T) {
+	base := time.Now().Truncate(time.Second).Add(-10 * time.Minute)
+	ms := func(minute int) int64 {
+		return base.Add(time.Duration(minute)*time.Minute).UnixNano() / int64(time.Millisecond)
+	}
+	g, _ := scrapedGatherer(t, fmt.Sprintf(`# TYPE requests_total counter
+requests_total{kind="steady"} 100 %[1]d
+requests_total{kind="steady"} 160 %[2]d
+requests_total{kind="steady"} 220 %[3]d
+requests_total{kind="steady"} 280 %[4]d
+requests_total{kind="reset"} 10 %[1]d
+requests_total{kind="reset"} 40 %[2]d
+requests_total{kind="reset"} 5 %[3]d
+requests_total{kind="reset"} 35 %[4]d
+`, ms(0), ms(1), ms(2), ms(3)))
+
+	tests := map[string]struct {
+		at          time.Time
+		expIncrease float64
+	}{
+		"The increase should be extrapolated to the range limits if the samples are close to them.": {
+			// 120 between 1m and 3m, extrapolated 30s on each side.
+			at:          base.Add(3*time.Minute + 30*time.Second),
+			expIncrease: 180,
+		},
+		"The increase should be extrapolated half of the samples interval if the samples are far from the range limits.": {
+			// 60 between 2m and 3m, extrapolated 30s to the start and half interval to the end.
+			at:          base.Add(4*time.Minute + 30*time.Second),
+			expIncrease: 120,
+		},
+	}
+
+	for name, test := range tests {
+		test := test
+		t.Run(name, func(t *testing.T) {
+			assert := assert.New(t)
+			require := require.New(t)
+
+			q := func(expr string) map[string]float64 {
+				series, err := g.GatherSingle(context.TODO(), model.Query{Expr: expr}, test.at)
+				require.NoError(err)
+				res := map[string]float64{}
+				for _, s := range series {
+					res[s.Labels["kind"]] = s.Metrics[0].Value
+				}
+				return res
+			}
+			rates := q(`rate(requests_total[3m])`)
+			increases := q(`increase(requests_total[3m])`)
+
+			assert.InDelta(test.expIncrease, increases["steady"], 1e-9)
+			for kind, inc := range increases {
+				assert.InDelta(inc, rates[kind]*180, 1e-9, kind)
+			}
+		})
+	}
+}
+
+func TestGathererRateIncreaseCounterReset(t *testing.T) {
+	assert := assert.New(t)
+
+	base := time.Now().Truncate(time.Second).Add(-10 * time.Minute)
+	ms := func(minute int) int64 {
+		return base.Add(time.Duration(minute)*time.Minute).UnixNano() / int64(time.Millisecond)
+	}
+	g, _ := scrapedGatherer(t, fmt.Sprintf(`# TYPE requests_total counter
+requests_total 10 %[1]d
+requests_total 40 %[2]d
+requests_total 5 %[3]d
+requests_total 35 %[4]d
+`, ms(0), ms(1), ms(2), ms(3)))
+	at := base.Add(3 * time.Minute)
+
+	// The increase with the reset is 65 (30 + 5 + 30), extrapolated to the
+	// start of the range until the counter would be zero (10 more).
+	increase, err := g.GatherSingle(context.TODO(), model.Query{Expr: `increase(requests_total[5m])`}, at)
+	if assert.NoError(err) && assert.Len(increase, 1) {
+		assert.InDelta(75, increase[0].Metrics[0].Value, 1e-9)
+	}
+
+	rate, err := g.GatherSingle(context.TODO(), model.Query{Expr: `rate(requests_total[5m])`}, at)
+	if assert.NoError(err) && assert.Len(rate, 1) {
+		assert.InDelta(75.0/300, rate[0].Metrics[0].Value, 1e-9)
+	}
+}
+
+func TestGathererGatherRange(t *testing.T) {
+	assert := assert.New(t)
+
+	base := time.Now().Truncate(time.Second).Add(-10 * time.Minute)
+	g, instance := testGatherer(t, base)
+
+	start, end := base.Add(1*time.Minute), base.Add(2*time.Minute)
+	gotSeries, err := g.GatherRange(context.TODO(), model.Query{Expr: `http_requests_total{code="500"}`}, start, end, time.Minute)
+	expSeries := []model.MetricSeries{
+		{
+			ID:     fmt.Sprintf(`http_requests_total{code="500", instance=%q}`, instance),
+			Labels: map[string]string{"__name__": "http_requests_total", "code": "500", "instance": instance},
+			Metrics: []model.Metric{
+				{TS: start, Value: 30},
+				{TS: end, Value: 60},
+			},
+		},
+	}
+	if assert.NoError(err) {
+		assert.Equal(expSeries, gotSeries)
+	}
+}
+
+func TestGathererDiscoverValues(t *testing.T) {
+	base := time.Now().Truncate(time.Second).Add(-10 * time.Minute)
+	g, instance := testGatherer(t, base)
+
+	tests := map[string]struct {
+		expr      string
+		expValues []string
+		expErr    bool
+	}{
+		"The label names should be discovered.": {
+			expr:      `label_names()`,
+			expValues: []string{"__name__", "code", "instance"},
+		},
+		"The values of a label should be discovered.": {
+			expr:      `label_values(instance)`,
+			expValues: []string{instance},
+		},
+		"The values of a label on the series of a selector should be discovered.": {
+			expr:      `label_values(http_requests_total{code!="500"}, code)`,
+			expValues: []string{"200"},
+		},
+		"An unsupported query should error.": {
+			expr:   `series(up)`,
+			expErr: true,
+		},
+	}
+
+	for name, test := range tests {
+		test := test
+		t.Run(name, func(t *testing.T) {
+			assert := assert.New(t)
+
+			gotValues, err := g.(metric.Discoverer).DiscoverValues(context.TODO(), model.Query{Expr: test.expr})
+			if test.expErr {
+				assert.Error(err)
+			} else if assert.NoError(err) {
+				assert.Equal(test.expValues, gotValues)
+			}
+		})
+	}
+}
+
+func TestGathererGatherMetadata(t *testing.T) {
+	assert := assert.New(t)
+
+	base := time.Now().Truncate(time.Second).Add(-10 * time.Minute)
+	g, _ := testGatherer(t, base)
+
+	gotmds, err := g.(metric.MetadataGatherer).GatherMetadata(context.TODO(), model.Query{Expr: `sum(rate(http_requests_total[5m])) / up`})
+	expmds := []model.MetricMetadata{
+		{Name: "http_requests_total", Type: "counter", Help: "Total requests."},
+	}
+	if assert.NoError(err) {
+		assert.Equal(expmds, gotmds)
+	}
+}
+
+func TestNewGathererWithoutTargets(t *testing.T) {
+	_, err := scrape.NewGatherer(context.TODO(), scrape.ConfigGatherer{})
+	assert.Error(t, err)
+}
+
+func TestGathererScrapes(t *testing.T) {
+	assert := assert.New(t)
+	require := require.New(t)
+
+	var scrapes int32
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		atomic.AddInt32(&scrapes, 1)
+		w.Write([]byte("requests_total 10\n"))
+	}))
+	defer srv.Close()
+
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	g, err := scrape.NewGatherer(ctx, scrape.ConfigGatherer{
+		Targets:  []string{srv.URL + "/metrics"},
+		Interval: 10 * time.Millisecond,
+	})
+	require.NoError(err)
+
+	// The targets should not be scraped until the first query.
+	time.Sleep(50 * time.Millisecond)
+	assert.Equal(int32(0), atomic.LoadInt32(&scrapes))
+
+	// The first query should wait for the first scrape.
+	got, err := g.GatherSingle(context.TODO(), model.Query{Expr: "requests_total"}, time.Now().Add(time.Second))
+	require.NoError(err)
+	if assert.Len(got, 1) {
+		assert.Equal(10.0, got[0].Metrics[0].Value)
+	}
+
+	// The scrapes should stop when the context is done.
+	cancel()
+	time.Sleep(50 * time.Millisecond)
+	stopped := atomic.LoadInt32(&scrapes)
+	time.Sleep(50 * time.Millisecond)
+	assert.Equal(stopped, atomic.LoadInt32(&scrapes))
+}
@@ -0,0 +1,248 @@
+package scrape
+
+import (
+	"fmt"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+)
+
+const nameLabel = "__name__"
+
+// labels are the labels that identify a series.
+type labels map[string]string
+
+// key returns the unique key of the labels.
+func (l labels) key() string {
+	keys := make([]string, 0, len(l))
+	for k := range l {
+		keys = append(keys, k)
+	}
+	sort.Strings(keys)
+
+	var sb strings.Builder
+	for _, k := range keys {
+		fmt.Fprintf(&sb, "%s=%q,", k, l[k])
+	}
+
+	return sb.String()
+}
+
+// without returns a copy of the labels without the names.
+func (l labels) without(names ...string) labels {
+	res := make(labels, len(l))
+	for k, v := range l {
+		res[k] = v
+	}
+	for _, n := range names {
+		delete(res, n)
+	}
+
+	return res
+}
+
+// only returns a copy of the labels with only the names.
+func (l labels) only(names ...string) labels {
+	res := labels{}
+	for _, n := range names {
+		if v, ok := l[n]; ok {
+			res[n] = v
+		}
+	}
+
+	return res
+}
+
+// String returns the labels in the form of `name{a="b"}`.
+func (l labels) String() string {
+	keys := []string{}
+	for k := range l {
+		if k != nameLabel {
+			keys = append(keys, k)
+		}
+	}
+	sort.Strings(keys)
+
+	ls := make([]string, 0, len(keys))
+	for _, k := range keys {
+		ls = append(ls, fmt.Sprintf("%s=%q", k, l[k]))
+	}
+
+	return l[nameLabel] + "{" + strings.Join(ls, ", ") + "}"
+}
+
+// sample is a value at a point in time.
+type sample struct {
+	ts    time.Time
+	value float64
+}
+
+// series is a group of ordered samples identified by labels.
+type series struct {
+	labels  labels
+	samples []sample
+}
+
+// storage is an in-memory time series storage, the samples older than the
+// retention are removed.
+type storage struct {
+	retention time.Duration
+
+	mu       sync.RWMutex
+	series   map[string]*series
+	metadata map[string]metricMetadata
+}
+
+func newStorage(retention time.Duration) *storage {
+	return &storage{
+		retention: retention,
+		series:    map[string]*series{},
+		metadata:  map[string]metricMetadata{},
+	}
+}
+
+// append appends a sample to the series of the labels, the samples that are
+// not newer than the latest sample of the series are ignored.
+func (s *storage) append(ls labels, ts time.Time, v float64) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	key := ls.key()
+	sr, ok := s.series[key]
+	if !ok {
+		sr = &series{labels: ls}
+		s.series[key] = sr
+	}
+
+	if n := len(sr.samples); n > 0 && !ts.After(sr.samples[n-1].ts) {
+		return
+	}
+	sr.samples = append(sr.samples, sample{ts: ts, value: v})
+}
+
+// setMetadata sets the metadata of the metric families.
+func (s *storage) setMetadata(md map[string]metricMetadata) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	for k, v := range md {
+		s.metadata[k] = v
+	}
+}
+
+// getMetadata returns the metadata of a metric.
+func (s *storage) getMetadata(name string) (metricMetadata, bool) {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+
+	md, ok := s.metadata[name]
+	return md, ok
+}
+
+// truncate removes the samples that are out of the retention and the series
+// without samples.
+func (s *storage) truncate(now time.Time) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	min := now.Add(-1 * s.retention)
+	for k, sr := range s.series {
+		i := sort.Search(len(sr.samples), func(i int) bool {
+			return !sr.samples[i].ts.Before(min)
+		})
+		if i >= len(sr.samples) {
+			delete(s.series, k)
+			continue
+		}
+		if i > 0 {
+			sr.samples = append([]sample{}, sr.samples[i:]...)
+		}
+	}
+}
+
+// query returns a copy of the series that match all the matchers with the
+// samples in the (start, end] time range. The series without samples in the
+// time range are not returned.
+func (s *storage) query(matchers []*matcher, start, end time.Time) []series {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+
+	res := []series{}
+	for _, sr := range s.series {
+		if !matchLabels(matchers, sr.labels) {
+			continue
+		}
+
+		from := sort.Search(len(sr.samples), func(i int) bool {
+			return sr.samples[i].ts.After(start)
+		})
+		to := sort.Search(len(sr.samples), func(i int) bool {
+			return sr.samples[i].ts.After(end)
+		})
+		if from >= to {
+			continue
+		}
+
+		res = append(res, series{
+			labels:  sr.labels,
+			samples: append([]sample{}, sr.samples[from:to]...),
+		})
+	}
+
+	return res
+}
+
+// labelValues returns the sorted values of a label on the series that match
+// all the matchers.
+func (s *storage) labelValues(matchers []*matcher, label string) []string {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+
+	seen := map[string]bool{}
+	res := []string{}
+	for _, sr := range s.series {
+		if !matchLabels(matchers, sr.labels) {
+			continue
+		}
+		v, ok := sr.labels[label]
+		if !ok || seen[v] {
+			continue
+		}
+		seen[v] = true
+		res = append(res, v)
+	}
+	sort.Strings(res)
+
+	return res
+}
+
+// labelNames returns the sorted label names of all the series.
+func (s *storage) labelNames() []string {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+
+	seen := map[string]bool{}
+	res := []string{}
+	for _, sr := range s.series {
+		for k := range sr.labels {
+			if seen[k] {
+				continue
+			}
+			seen[k] = true
+			res = append(res, k)
+		}
+	}
+	sort.Strings(res)
+
+	return res
+}
+
+func matchLabels(matchers []*matcher, ls labels) bool {
+	for _, m := range matchers {
+		if !m.matches(ls[m.name]) {
+			return false
+		}
+	}
+	return true
+}
@@ -0,0 +1,28 @@
+package metric
+
+import (
+	"math"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// minMillisTimestamp is the minimum numeric timestamp that will be
+// handled as milliseconds instead of seconds.
+const minMillisTimestamp = 1e11
+
+// ParseTimestamp parses RFC3339 timestamps and unix timestamps in seconds
+// or milliseconds, used by the datasources that read the timestamps from
+// text (e.g files or commands output).
+func ParseTimestamp(s string) (time.Time, error) {
+	s = strings.TrimSpace(s)
+
+	if v, err := strconv.ParseFloat(s, 64); err == nil {
+		if math.Abs(v) >= minMillisTimestamp {
+			return time.Unix(0, int64(v*float64(time.Millisecond))), nil
+		}
+		return time.Unix(0, int64(v*float64(time.Second))), nil
+	}
+
+	return time.Parse(time.RFC3339Nano, s)
+}
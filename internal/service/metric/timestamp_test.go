@@ -0,0 +1,53 @@
+package metric
+
+import (
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestParseTimestamp(t *testing.T) {
+	exp := time.Date(2019, 5, 19, 14, 0, 0, 0, time.UTC)
+
+	tests := []struct {
+		name   string
+		ts     string
+		expTS  time.Time
+		expErr bool
+	}{
+		{
+			name:  "RFC3339 timestamps should be parsed.",
+			ts:    "2019-05-19T14:00:00Z",
+			expTS: exp,
+		},
+		{
+			name:  "Unix timestamps in seconds should be parsed.",
+			ts:    " 1558274400 ",
+			expTS: exp,
+		},
+		{
+			name:  "Unix timestamps in milliseconds should be parsed.",
+			ts:    "1558274400000",
+			expTS: exp,
+		},
+		{
+			name:   "Invalid timestamps should error.",
+			ts:     "yesterday",
+			expErr: true,
+		},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			assert := assert.New(t)
+
+			gotTS, err := ParseTimestamp(test.ts)
+			if test.expErr {
+				assert.Error(err)
+			} else if assert.NoError(err) {
+				assert.True(test.expTS.Equal(gotTS))
+			}
+		})
+	}
+}
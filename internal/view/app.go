@@ -9,6 +9,7 @@ import (
 	"github.com/pkg/errors"
 
 	"github.com/slok/grafterm/internal/service/log"
+	"github.com/slok/grafterm/internal/view/render"
 	viewsync "github.com/slok/grafterm/internal/view/sync"
 	"github.com/slok/grafterm/internal/view/template"
 )
@@ -44,6 +45,11 @@ type App struct {
 
 	running bool
 	mu      sync.Mutex
+
+	// trMu protects the time range of the cfg that can be changed
+	// while running.
+	trMu  sync.Mutex
+	syncC chan struct{}
 }
 
 // NewApp Is the main application
@@ -54,6 +60,7 @@ func NewApp(cfg AppConfig, syncer viewsync.Syncer, logger log.Logger) *App {
 		cfg:    cfg,
 		syncer: syncer,
 		logger: logger,
+		syncC:  make(chan struct{}, 1),
 	}
 }
 
@@ -71,6 +78,13 @@ func (a *App) Run(ctx context.Context) error {
 	return a.run(ctx)
 }
 
+// RunOnce will sync the application only once, this is useful when the
+// application doesn't need to be refreshed (e.g snapshots).
+func (a *App) RunOnce(ctx context.Context) error {
+	r := a.syncRequest()
+	return a.syncer.Sync(ctx, r)
+}
+
 func (a *App) run(ctx context.Context) error {
 	// Start the sync loop. This operation blocks.
 	a.sync()
@@ -83,6 +97,7 @@ func (a *App) run(ctx context.Context) error {
 		case <-ctx.Done():
 			return nil
 		case <-tk.C:
+		case <-a.syncC:
 		}
 
 		a.sync()
@@ -110,18 +125,13 @@ func (a *App) sync() {
 }
 
 func (a *App) syncRequest() *viewsync.Request {
-	r := &viewsync.Request{
-		TimeRangeStart: a.cfg.TimeRangeStart,
-		TimeRangeEnd:   a.cfg.TimeRangeEnd,
-	}
+	a.trMu.Lock()
+	start, end := a.timeRange(time.Now().UTC())
+	a.trMu.Unlock()
 
-	// If we don't have fixed time, make the time ranges work in relative mode
-	// based on now timestamp.
-	if r.TimeRangeEnd.IsZero() {
-		r.TimeRangeEnd = time.Now().UTC()
-	}
-	if r.TimeRangeStart.IsZero() {
-		r.TimeRangeStart = r.TimeRangeEnd.Add(-1 * a.cfg.RelativeTimeRange)
+	r := &viewsync.Request{
+		TimeRangeStart: start,
+		TimeRangeEnd:   end,
 	}
 
 	// Create the template data for each sync.
@@ -137,3 +147,96 @@ func (a *App) syncData(r *viewsync.Request) template.Data {
 	}
 	return data
 }
+
+// timeRange returns the time range of the app, if we don't have fixed time,
+// the time range works in relative mode based on now timestamp.
+// Needs to be called with the time range lock acquired.
+func (a *App) timeRange(now time.Time) (start, end time.Time) {
+	start, end = a.cfg.TimeRangeStart, a.cfg.TimeRangeEnd
+	if end.IsZero() {
+		end = now
+	}
+	if start.IsZero() {
+		start = end.Add(-1 * a.cfg.RelativeTimeRange)
+	}
+
+	return start, end
+}
+
+// ChangeTimeRange changes the time range of the app and triggers a sync so
+// the new time range is rendered without waiting to the refresh interval.
+func (a *App) ChangeTimeRange(c render.TimeRangeChange) {
+	const minTimeRange = 1 * time.Minute
+
+	a.trMu.Lock()
+	now := time.Now().UTC()
+	start, end := a.timeRange(now)
+	dur := end.Sub(start)
+	live := a.cfg.TimeRangeStart.IsZero() && a.cfg.TimeRangeEnd.IsZero()
+
+	switch c.Action {
+	case render.TimeRangeZoomIn, render.TimeRangeZoomOut:
+		newDur := dur * 2
+		if c.Action == render.TimeRangeZoomIn {
+			newDur = dur / 2
+		}
+		if newDur < minTimeRange {
+			newDur = minTimeRange
+		}
+
+		// Live ranges zoom maintaining the end on now, the fixed ones
+		// maintaining the center of the range.
+		if live {
+			a.setLiveTimeRange(newDur)
+			break
+		}
+		center := start.Add(dur / 2)
+		a.setFixedTimeRange(now, center.Add(-1*newDur/2), center.Add(newDur/2))
+	case render.TimeRangePanBackward:
+		a.setFixedTimeRange(now, start.Add(-1*dur/2), end.Add(-1*dur/2))
+	case render.TimeRangePanForward:
+		// Can't go to the future.
+		if live {
+			break
+		}
+		a.setFixedTimeRange(now, start.Add(dur/2), end.Add(dur/2))
+	case render.TimeRangePreset:
+		if c.Duration <= 0 {
+			break
+		}
+		a.setLiveTimeRange(c.Duration)
+	case render.TimeRangeLive:
+		a.setLiveTimeRange(dur)
+	}
+
+	start, end = a.timeRange(now)
+	a.trMu.Unlock()
+
+	a.logger.Infof("time range changed to %s - %s", start, end)
+
+	// Trigger a sync if there isn't one already waiting.
+	select {
+	case a.syncC <- struct{}{}:
+	default:
+	}
+}
+
+// setLiveTimeRange sets a time range relative to now.
+// Needs to be called with the time range lock acquired.
+func (a *App) setLiveTimeRange(dur time.Duration) {
+	a.cfg.TimeRangeStart = time.Time{}
+	a.cfg.TimeRangeEnd = time.Time{}
+	a.cfg.RelativeTimeRange = dur
+}
+
+// setFixedTimeRange sets a fixed time range, if the time range reaches now
+// it will be set as a live time range.
+// Needs to be called with the time range lock acquired.
+func (a *App) setFixedTimeRange(now, start, end time.Time) {
+	if !end.Before(now) {
+		a.setLiveTimeRange(end.Sub(start))
+		return
+	}
+	a.cfg.TimeRangeStart = start
+	a.cfg.TimeRangeEnd = end
+}
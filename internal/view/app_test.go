@@ -0,0 +1,132 @@
+package view
+
+import (
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+
+	"github.com/slok/grafterm/internal/service/log"
+	"github.com/slok/grafterm/internal/view/render"
+)
+
+func TestAppChangeTimeRange(t *testing.T) {
+	t0 := time.Date(2019, 5, 12, 12, 0, 0, 0, time.UTC)
+	now := time.Now().UTC()
+
+	tests := map[string]struct {
+		cfg      AppConfig
+		change   render.TimeRangeChange
+		expStart time.Time
+		expEnd   time.Time
+		expRel   time.Duration
+	}{
+		"Zooming in on a live time range should halve the relative time range.": {
+			cfg:    AppConfig{RelativeTimeRange: 1 * time.Hour},
+			change: render.TimeRangeChange{Action: render.TimeRangeZoomIn},
+			expRel: 30 * time.Minute,
+		},
+		"Zooming in should not go below the minimum time range.": {
+			cfg:    AppConfig{RelativeTimeRange: 90 * time.Second},
+			change: render.TimeRangeChange{Action: render.TimeRangeZoomIn},
+			expRel: 1 * time.Minute,
+		},
+		"Zooming out on a live time range should double the relative time range.": {
+			cfg:    AppConfig{RelativeTimeRange: 1 * time.Hour},
+			change: render.TimeRangeChange{Action: render.TimeRangeZoomOut},
+			expRel: 2 * time.Hour,
+		},
+		"Zooming in on a fixed time range should halve the time range maintaining the center.": {
+			cfg: AppConfig{
+				TimeRangeStart:    t0,
+				TimeRangeEnd:      t0.Add(4 * time.Hour),
+				RelativeTimeRange: 1 * time.Hour,
+			},
+			change:   render.TimeRangeChange{Action: render.TimeRangeZoomIn},
+			expStart: t0.Add(1 * time.Hour),
+			expEnd:   t0.Add(3 * time.Hour),
+			expRel:   1 * time.Hour,
+		},
+		"Zooming out on a fixed time range should double the time range maintaining the center.": {
+			cfg: AppConfig{
+				TimeRangeStart:    t0,
+				TimeRangeEnd:      t0.Add(4 * time.Hour),
+				RelativeTimeRange: 1 * time.Hour,
+			},
+			change:   render.TimeRangeChange{Action: render.TimeRangeZoomOut},
+			expStart: t0.Add(-2 * time.Hour),
+			expEnd:   t0.Add(6 * time.Hour),
+			expRel:   1 * time.Hour,
+		},
+		"Panning backward on a fixed time range should move the time range half of its duration.": {
+			cfg: AppConfig{
+				TimeRangeStart:    t0,
+				TimeRangeEnd:      t0.Add(4 * time.Hour),
+				RelativeTimeRange: 1 * time.Hour,
+			},
+			change:   render.TimeRangeChange{Action: render.TimeRangePanBackward},
+			expStart: t0.Add(-2 * time.Hour),
+			expEnd:   t0.Add(2 * time.Hour),
+			expRel:   1 * time.Hour,
+		},
+		"Panning forward on a fixed time range should move the time range half of its duration.": {
+			cfg: AppConfig{
+				TimeRangeStart:    t0,
+				TimeRangeEnd:      t0.Add(4 * time.Hour),
+				RelativeTimeRange: 1 * time.Hour,
+			},
+			change:   render.TimeRangeChange{Action: render.TimeRangePanForward},
+			expStart: t0.Add(2 * time.Hour),
+			expEnd:   t0.Add(6 * time.Hour),
+			expRel:   1 * time.Hour,
+		},
+		"Panning forward on a live time range should not change the time range.": {
+			cfg:    AppConfig{RelativeTimeRange: 1 * time.Hour},
+			change: render.TimeRangeChange{Action: render.TimeRangePanForward},
+			expRel: 1 * time.Hour,
+		},
+		"Panning forward to now should set a live time range.": {
+			cfg: AppConfig{
+				TimeRangeStart:    now.Add(-150 * time.Minute),
+				TimeRangeEnd:      now.Add(-30 * time.Minute),
+				RelativeTimeRange: 1 * time.Hour,
+			},
+			change: render.TimeRangeChange{Action: render.TimeRangePanForward},
+			expRel: 2 * time.Hour,
+		},
+		"A preset should set a live time range with the preset duration.": {
+			cfg: AppConfig{
+				TimeRangeStart:    t0,
+				TimeRangeEnd:      t0.Add(4 * time.Hour),
+				RelativeTimeRange: 1 * time.Hour,
+			},
+			change: render.TimeRangeChange{Action: render.TimeRangePreset, Duration: 24 * time.Hour},
+			expRel: 24 * time.Hour,
+		},
+		"Live should set a live time range maintaining the duration.": {
+			cfg: AppConfig{
+				TimeRangeStart:    t0,
+				TimeRangeEnd:      t0.Add(4 * time.Hour),
+				RelativeTimeRange: 1 * time.Hour,
+			},
+			change: render.TimeRangeChange{Action: render.TimeRangeLive},
+			expRel: 4 * time.Hour,
+		},
+	}
+
+	for name, test := range tests {
+		t.Run(name, func(t *testing.T) {
+			assert := assert.New(t)
+
+			a := NewApp(test.cfg, nil, log.Dummy)
+			a.ChangeTimeRange(test.change)
+
+			assert.Equal(test.expStart, a.cfg.TimeRangeStart)
+			assert.Equal(test.expEnd, a.cfg.TimeRangeEnd)
+			assert.Equal(test.expRel, a.cfg.RelativeTimeRange)
+
+			// The change should trigger a sync.
+			assert.Len(a.syncC, 1)
+		})
+	}
+}
@@ -20,6 +20,11 @@ type Element struct {
 	Empty bool
 	// Widget is the widget to be placed.
 	Widget model.Widget
+	// Rows are the rows placed inside the element. When an element has rows
+	// it will not have a widget, it will act as a column that splits its
+	// vertical space in rows, this is used to place the widgets that are
+	// shorter than the widgets that are placed on the same row.
+	Rows []*Row
 }
 
 // Row is composed by multiple elements.
@@ -28,7 +33,8 @@ type Row struct {
 	// elements of a row are horizontally placed. also known as
 	// the X axis.
 	Elements []*Element
-	// PercentSize is the size in percentage of the total vertical axis.
+	// PercentSize is the size in percentage of the total vertical axis
+	// (of the grid or of the element if the row is a nested row).
 	PercentSize int
 }
 
@@ -42,6 +48,16 @@ type Row struct {
 // ----------------------------------------------------
 // [-element-]       [----element----]        [element]
 // ----------------------------------------------------
+//
+// The widgets that are taller than one row unit (`GridPos.H`) will make the
+// row taller, the other widgets of the row will be placed inside elements
+// with nested rows:
+//
+// ----------------------------------------------------
+// [                 ] [[--element--] [---element---]]
+// [-----element-----] [---------------------------- ]
+// [                 ] [[-------element-------]      ]
+// ----------------------------------------------------
 type Grid struct {
 	// Is the max size of the X axis. This is equal to a 100 percentage.
 	MaxWidth int
@@ -52,11 +68,26 @@ type Grid struct {
 	Rows []*Row
 }
 
+// placement is the position and size of a widget on the grid in
+// grid units (the same units that the widgets use on `GridPos`).
+type placement struct {
+	x, y, w, h int
+	widget     model.Widget
+}
+
+// area is a rectangle of the grid in grid units.
+type area struct {
+	x, y, w, h int
+}
+
 // NewAdaptiveGrid returns a grid that places the widgets in the received order
 // without checking its position (x, y) only using the size of the widgets.
 // It will adapt the rows dinamically so the widgets that are bigger than the empty
 // space on the row, will be placed in the next row an so own, on after the other
 // creating new rows until all the widgets have been placed.
+// The widgets that are taller than one row (`GridPos.H`) will use the space
+// of the next rows, so the next widgets will fill the space that is left
+// next to them.
 func NewAdaptiveGrid(maxWidth int, widgets []model.Widget) (*Grid, error) {
 	d := &Grid{
 		MaxWidth: maxWidth,
@@ -67,56 +98,92 @@ func NewAdaptiveGrid(maxWidth int, widgets []model.Widget) (*Grid, error) {
 }
 
 func (g *Grid) fillAdaptiveGrid(widgets []model.Widget) {
-	g.Rows = []*Row{}
-	filledRow := 0
-	currentRow := 0
-	for _, cfg := range widgets {
-		// Initial row check existence.
-		if len(g.Rows) <= currentRow {
-			g.Rows = append(g.Rows, &Row{})
+	ps := g.adaptivePlacements(widgets)
+
+	// Rows have been dinamically created so until we had all the widgets
+	// placed we can't be sure what is the total of the vertical axis.
+	totalHeight := 0
+	for _, p := range ps {
+		if totalHeight < p.y+p.h {
+			totalHeight = p.y + p.h
 		}
+	}
 
-		r := g.Rows[currentRow]
+	g.Rows = layoutRows(ps, area{w: g.MaxWidth, h: totalHeight})
+}
 
-		// Create the widget element.
-		e := &Element{
-			PercentSize: percent(cfg.GridPos.W, g.MaxWidth),
-			Widget:      cfg,
+// adaptivePlacements will place the widgets in order, one after the other,
+// filling the rows from left to right. When a widget doesn't fit on the
+// current row it will be placed on the next row, taking into account the
+// space used by the taller widgets from the previous rows.
+func (g *Grid) adaptivePlacements(widgets []model.Widget) []placement {
+	// used has the used horizontal spaces for every row.
+	used := [][]area{}
+	isFree := func(x, y, w, h int) (nextX int, free bool) {
+		nextX = x
+		for i := y; i < y+h && i < len(used); i++ {
+			for _, u := range used[i] {
+				if u.x < x+w && x < u.x+u.w && nextX < u.x+u.w {
+					nextX = u.x + u.w
+				}
+			}
 		}
+		return nextX, nextX == x
+	}
 
-		// To get he correct row of the widget then we need to see if
-		// the widget is from this row or next row .
+	ps := []placement{}
+	currentRow := 0
+	filledRow := 0
+	for _, cfg := range widgets {
+		p := placement{
+			w:      cfg.GridPos.W,
+			h:      widgetHeight(cfg),
+			widget: cfg,
+		}
 		// TODO(slok): check if widget is greater than grid totalX
-		if filledRow+e.PercentSize > maxWidthPercent {
-			// If there is spare space on the row, before creating a new row
-			// create an empty widget to fill the row until the end.
-			if filledRow < maxWidthPercent {
-				r.Elements = append(r.Elements, &Element{
-					Empty:       true,
-					PercentSize: maxWidthPercent - filledRow,
-				})
+		if p.w > g.MaxWidth {
+			p.w = g.MaxWidth
+		}
+
+		// Search the first free space for the widget starting from the
+		// last placed widget, if there is no space on the row, go to the
+		// next row.
+		for {
+			x := filledRow
+			placed := false
+			for x+p.w <= g.MaxWidth {
+				nextX, free := isFree(x, currentRow, p.w, p.h)
+				if free {
+					placed = true
+					break
+				}
+				x = nextX
+			}
+
+			if placed {
+				p.x = x
+				p.y = currentRow
+				filledRow = x + p.w
+				break
 			}
 
-			// Next and new row.
+			// Next row.
 			currentRow++
 			filledRow = 0
-			g.Rows = append(g.Rows, &Row{})
-			r = g.Rows[currentRow]
 		}
 
-		// Add widget to row.
-		filledRow += e.PercentSize
-		r.Elements = append(r.Elements, e)
-	}
+		// Mark the space used by the widget on all the rows it uses.
+		for len(used) < p.y+p.h {
+			used = append(used, []area{})
+		}
+		for i := p.y; i < p.y+p.h; i++ {
+			used[i] = append(used[i], area{x: p.x, w: p.w})
+		}
 
-	// Set the size of the rows, the rows have been dinamically created so until
-	// we had all the rows we can't be sure what is the total of the vertical axis,
-	// set the same vertical percent size of the rows to all the rows
-	// (e.g 4 rows of 25% or 3 rows of 33% or 10 rows of 10% ).
-	totalRows := len(g.Rows) // This is the 100%.
-	for _, row := range g.Rows {
-		row.PercentSize = percent(1, totalRows)
+		ps = append(ps, p)
 	}
+
+	return ps
 }
 
 // NewFixedGrid will place the widgets on the grid using the size and position
@@ -126,8 +193,8 @@ func (g *Grid) fillAdaptiveGrid(widgets []model.Widget) {
 func NewFixedGrid(maxWidth int, widgets []model.Widget) (*Grid, error) {
 	maxHeight := 0
 	for _, w := range widgets {
-		if maxHeight < w.GridPos.Y+1 {
-			maxHeight = w.GridPos.Y + 1
+		if maxHeight < w.GridPos.Y+widgetHeight(w) {
+			maxHeight = w.GridPos.Y + widgetHeight(w)
 		}
 	}
 
@@ -143,56 +210,193 @@ func NewFixedGrid(maxWidth int, widgets []model.Widget) (*Grid, error) {
 }
 
 func (g *Grid) fillFixedGrid(widgets []model.Widget) {
-	sortwidgets(widgets)
-	g.initRows()
-	// Create the widgets.
+	ps := make([]placement, 0, len(widgets))
 	for _, cfg := range widgets {
-		row := g.Rows[cfg.GridPos.Y]
-		row.Elements = append(row.Elements, &Element{
-			PercentSize: percent(cfg.GridPos.W, g.MaxWidth),
-			Widget:      cfg,
+		ps = append(ps, placement{
+			x:      cfg.GridPos.X,
+			y:      cfg.GridPos.Y,
+			w:      cfg.GridPos.W,
+			h:      widgetHeight(cfg),
+			widget: cfg,
+		})
+	}
+
+	g.Rows = layoutRows(ps, area{w: g.MaxWidth, h: g.MaxHeight})
+}
+
+// layoutRows returns the rows that place the widgets inside the area.
+// The rows are groups of consecutive row units where the widgets
+// overlap vertically, this way a widget that is taller than one row
+// unit will make the row taller. Row units without widgets are
+// returned as empty rows.
+func layoutRows(ps []placement, a area) []*Row {
+	sortPlacements(ps)
+
+	rows := []*Row{}
+	i := 0
+	for y := a.y; y < a.y+a.h; {
+		// Get all the widgets of the row, the row ends when the
+		// next widget starts after all the widgets of the row end.
+		rowPs := []placement{}
+		end := y + 1
+		for i < len(ps) && ps[i].y < end {
+			if e := ps[i].y + ps[i].h; e > end {
+				end = e
+			}
+			rowPs = append(rowPs, ps[i])
+			i++
+		}
+		if end > a.y+a.h {
+			end = a.y + a.h
+		}
+
+		rowArea := area{x: a.x, y: y, w: a.w, h: end - y}
+		rows = append(rows, &Row{
+			PercentSize: percent(rowArea.h, a.h),
+			Elements:    layoutElements(rowPs, rowArea),
 		})
+		y = end
+	}
+
+	return rows
+}
+
+// column is a group of widgets that will be placed horizontally on a
+// row as a single element.
+type column struct {
+	x, w int
+	ps   []placement
+	// nested marks the column as a column that has the widgets
+	// placed on nested rows.
+	nested bool
+}
+
+// layoutElements returns the elements that place the widgets of a row
+// inside the row area, filling the blank spaces between them with
+// empty elements.
+// The widgets that use all the height of the row will be placed directly,
+// the rest of the widgets that are between them will be grouped in columns
+// that will have the widgets placed in nested rows.
+func layoutElements(ps []placement, a area) []*Element {
+	cols := []*column{}
+	partials := []placement{}
+	for _, p := range ps {
+		if p.y == a.y && p.h == a.h {
+			cols = append(cols, &column{x: p.x, w: p.w, ps: []placement{p}})
+			continue
+		}
+		partials = append(partials, p)
 	}
+	sort.SliceStable(cols, func(i, j int) bool { return cols[i].x < cols[j].x })
+
+	nestedCols := []*column{}
+	if len(cols) > 0 {
+		// Group the widgets by the space between the full height widgets.
+		groups := map[int]*column{}
+		for _, p := range partials {
+			gap := 0
+			for _, c := range cols {
+				if c.x+c.w <= p.x {
+					gap++
+				}
+			}
+
+			c, ok := groups[gap]
+			if !ok {
+				c = &column{x: p.x, w: p.w, nested: true}
+				groups[gap] = c
+				nestedCols = append(nestedCols, c)
+			}
+			c.add(p)
+		}
+	} else {
+		// No full height widgets, group the widgets in columns of
+		// widgets that overlap horizontally.
+		sort.SliceStable(partials, func(i, j int) bool { return partials[i].x < partials[j].x })
+		for _, p := range partials {
+			if len(nestedCols) > 0 {
+				last := nestedCols[len(nestedCols)-1]
+				if p.x < last.x+last.w {
+					last.add(p)
+					continue
+				}
+			}
+			nestedCols = append(nestedCols, &column{x: p.x, w: p.w, ps: []placement{p}, nested: true})
+		}
 
-	// Fill the blank spaces between widgets for each row.
-	for _, row := range g.Rows {
-		rowFilled := 0
-		var rowElements []*Element
-		for _, rowElement := range row.Elements {
-			posperc := percent(rowElement.Widget.GridPos.X, g.MaxWidth)
-
-			// If what we filled is not the start point of the current
-			// widget it means that we have a blank space.
-			if rowFilled < posperc {
-				rowElements = append(rowElements, &Element{
-					Empty:       true,
-					PercentSize: posperc - rowFilled,
-				})
+		// If we can't split the widgets in smaller groups we would loop forever,
+		// in this case fallback to place the widgets using the full row height.
+		if len(nestedCols) == 1 && len(nestedCols[0].ps) > 1 {
+			for _, p := range nestedCols[0].ps {
+				cols = append(cols, &column{x: p.x, w: p.w, ps: []placement{p}})
 			}
-			rowElements = append(rowElements, rowElement)
-			rowFilled = posperc + rowElement.PercentSize
+			nestedCols = nil
 		}
+	}
+
+	cols = append(cols, nestedCols...)
+	sort.SliceStable(cols, func(i, j int) bool { return cols[i].x < cols[j].x })
+
+	// Create the elements and fill the blank spaces between them.
+	elements := []*Element{}
+	filled := 0
+	for _, c := range cols {
+		posperc := percent(c.x-a.x, a.w)
 
-		// Check if we need to fill with blank space until the end
-		// of the row.
-		if rowFilled < maxWidthPercent {
-			rowElements = append(rowElements, &Element{
+		// If what we filled is not the start point of the current
+		// column it means that we have a blank space.
+		if filled < posperc {
+			elements = append(elements, &Element{
 				Empty:       true,
-				PercentSize: maxWidthPercent - rowFilled,
+				PercentSize: posperc - filled,
 			})
 		}
 
-		row.Elements = rowElements
+		e := &Element{
+			PercentSize: percent(c.w, a.w),
+		}
+		if c.nested {
+			e.Rows = layoutRows(c.ps, area{x: c.x, y: a.y, w: c.w, h: a.h})
+		} else {
+			e.Widget = c.ps[0].widget
+		}
+		elements = append(elements, e)
+		filled = posperc + e.PercentSize
 	}
-}
 
-// initRows creates all the rows in empty state.
-func (g *Grid) initRows() {
-	for i := 0; i < g.MaxHeight; i++ {
-		g.Rows = append(g.Rows, &Row{
-			PercentSize: percent(1, g.MaxHeight),
+	// Check if we need to fill with blank space until the end
+	// of the row.
+	if filled < maxWidthPercent {
+		elements = append(elements, &Element{
+			Empty:       true,
+			PercentSize: maxWidthPercent - filled,
 		})
 	}
+
+	return elements
+}
+
+// add adds a widget to the column expanding the column horizontally
+// if required.
+func (c *column) add(p placement) {
+	end := c.x + c.w
+	if p.x < c.x {
+		c.x = p.x
+	}
+	if p.x+p.w > end {
+		end = p.x + p.w
+	}
+	c.w = end - c.x
+	c.ps = append(c.ps, p)
+}
+
+// widgetHeight returns the height of the widget in row units, by
+// default a widget uses one row.
+func widgetHeight(w model.Widget) int {
+	if w.GridPos.H <= 0 {
+		return 1
+	}
+	return w.GridPos.H
 }
 
 func percent(value, total int) int {
@@ -200,25 +404,20 @@ func percent(value, total int) int {
 	return int(math.Round(perc))
 }
 
-// sortwidgets sorts the widgets in left-right and top-down
+// sortPlacements sorts the widgets in left-right and top-down
 // order.
-func sortwidgets(widgets []model.Widget) {
-	sort.Slice(widgets, func(i, j int) bool {
-		gpi := widgets[i].GridPos
-		gpj := widgets[j].GridPos
+func sortPlacements(ps []placement) {
+	sort.SliceStable(ps, func(i, j int) bool {
+		pi := ps[i]
+		pj := ps[j]
 
 		switch {
-		case gpi.Y > gpj.Y:
+		case pi.y > pj.y:
 			return false
-		case gpi.Y < gpj.Y:
-			return true
-		case gpi.X < gpj.X:
+		case pi.y < pj.y:
 			return true
-		case gpi.X > gpj.X:
-			return false
-		// If are the same then doesn't matter, we shouldn't reach here.
 		default:
-			return true
+			return pi.x < pj.x
 		}
 	})
 }
@@ -385,6 +385,147 @@ func TestGrid(t *testing.T) {
 			},
 			expErr: false,
 		},
+		{
+			name: "On adaptive grids the widgets taller than one row should use the space of the next rows and the next widgets should be placed next to them.",
+			grid: func() (*grid.Grid, error) {
+				maxWidth := 100
+				widgets := []model.Widget{
+					model.Widget{GridPos: model.GridPos{W: 50, H: 2}},
+					model.Widget{GridPos: model.GridPos{W: 25}},
+					model.Widget{GridPos: model.GridPos{W: 25}},
+					model.Widget{GridPos: model.GridPos{W: 25, H: 1}},
+					model.Widget{GridPos: model.GridPos{W: 25, H: 1}},
+					model.Widget{GridPos: model.GridPos{W: 100}},
+				}
+
+				return grid.NewAdaptiveGrid(maxWidth, widgets)
+			},
+			exp: &grid.Grid{
+				MaxWidth: 100,
+				Rows: []*grid.Row{
+					&grid.Row{
+						PercentSize: 67,
+						Elements: []*grid.Element{
+							&grid.Element{
+								Widget:      model.Widget{GridPos: model.GridPos{W: 50, H: 2}},
+								PercentSize: 50,
+							},
+							&grid.Element{
+								PercentSize: 50,
+								Rows: []*grid.Row{
+									&grid.Row{
+										PercentSize: 50,
+										Elements: []*grid.Element{
+											&grid.Element{
+												Widget:      model.Widget{GridPos: model.GridPos{W: 25}},
+												PercentSize: 50,
+											},
+											&grid.Element{
+												Widget:      model.Widget{GridPos: model.GridPos{W: 25}},
+												PercentSize: 50,
+											},
+										},
+									},
+									&grid.Row{
+										PercentSize: 50,
+										Elements: []*grid.Element{
+											&grid.Element{
+												Widget:      model.Widget{GridPos: model.GridPos{W: 25, H: 1}},
+												PercentSize: 50,
+											},
+											&grid.Element{
+												Widget:      model.Widget{GridPos: model.GridPos{W: 25, H: 1}},
+												PercentSize: 50,
+											},
+										},
+									},
+								},
+							},
+						},
+					},
+					&grid.Row{
+						PercentSize: 33,
+						Elements: []*grid.Element{
+							&grid.Element{
+								Widget:      model.Widget{GridPos: model.GridPos{W: 100}},
+								PercentSize: 100,
+							},
+						},
+					},
+				},
+			},
+			expErr: false,
+		},
+		{
+			name: "On fixed grids the widgets taller than one row should make the row taller and the other widgets should be placed in nested rows.",
+			grid: func() (*grid.Grid, error) {
+				maxWidth := 100
+				widgets := []model.Widget{
+					model.Widget{GridPos: model.GridPos{Y: 2, X: 0, W: 100}},
+					model.Widget{GridPos: model.GridPos{Y: 1, X: 60, W: 20}},
+					model.Widget{GridPos: model.GridPos{Y: 0, X: 50, W: 50}},
+					model.Widget{GridPos: model.GridPos{Y: 0, X: 0, W: 50, H: 2}},
+				}
+
+				return grid.NewFixedGrid(maxWidth, widgets)
+			},
+			exp: &grid.Grid{
+				MaxWidth:  100,
+				MaxHeight: 3,
+				Rows: []*grid.Row{
+					&grid.Row{
+						PercentSize: 67,
+						Elements: []*grid.Element{
+							&grid.Element{
+								Widget:      model.Widget{GridPos: model.GridPos{Y: 0, X: 0, W: 50, H: 2}},
+								PercentSize: 50,
+							},
+							&grid.Element{
+								PercentSize: 50,
+								Rows: []*grid.Row{
+									&grid.Row{
+										PercentSize: 50,
+										Elements: []*grid.Element{
+											&grid.Element{
+												Widget:      model.Widget{GridPos: model.GridPos{Y: 0, X: 50, W: 50}},
+												PercentSize: 100,
+											},
+										},
+									},
+									&grid.Row{
+										PercentSize: 50,
+										Elements: []*grid.Element{
+											&grid.Element{
+												Empty:       true,
+												PercentSize: 20,
+											},
+											&grid.Element{
+												Widget:      model.Widget{GridPos: model.GridPos{Y: 1, X: 60, W: 20}},
+												PercentSize: 40,
+											},
+											&grid.Element{
+												Empty:       true,
+												PercentSize: 40,
+											},
+										},
+									},
+								},
+							},
+						},
+					},
+					&grid.Row{
+						PercentSize: 33,
+						Elements: []*grid.Element{
+							&grid.Element{
+								Widget:      model.Widget{GridPos: model.GridPos{Y: 2, X: 0, W: 100}},
+								PercentSize: 100,
+							},
+						},
+					},
+				},
+			},
+			expErr: false,
+		},
 	}
 
 	for _, test := range tests {
@@ -17,6 +17,14 @@ import (
 	"github.com/slok/grafterm/internal/view/variable"
 )
 
+const (
+	// variablesRefreshTimeout is the maximum time the variables refresh can
+	// take on a sync, with the widgets sync timeout it needs to fit in
+	// the app sync timeout.
+	variablesRefreshTimeout = 2 * time.Second
+	widgetsSyncTimeout      = 3 * time.Second
+)
+
 // DashboardCfg is the configuration required to create a Dashboard.
 type DashboardCfg struct {
 	AppRelativeTimeRange time.Duration
@@ -31,10 +39,15 @@ type DashboardCfg struct {
 // The widgets the dashboard manages at the same time are syncers also.
 func NewDashboard(ctx context.Context, cfg DashboardCfg, logger log.Logger) (viewsync.Syncer, error) {
 	// Create variablers.
-	vs, err := variable.NewVariablers(variable.FactoryConfig{
-		TimeRange: cfg.AppRelativeTimeRange,
-		Dashboard: cfg.Dashboard,
+	vs, err := variable.NewVariablers(ctx, variable.FactoryConfig{
+		TimeRange:  cfg.AppRelativeTimeRange,
+		Dashboard:  cfg.Dashboard,
+		Controller: cfg.Controller,
+		Logger:     logger,
 	})
+	if err != nil {
+		return nil, err
+	}
 
 	// Create Grid.
 	var gr *grid.Grid
@@ -57,6 +70,34 @@ func NewDashboard(ctx context.Context, cfg DashboardCfg, logger log.Logger) (vie
 		logger:     logger,
 	}
 
+	// If the renderer knows how to render variables, load the variables before
+	// the dashboard so the user can select them.
+	if vr, ok := cfg.Renderer.(render.VariablesRenderer); ok && len(cfg.Dashboard.Variables) > 0 {
+		vw, err := vr.LoadVariables(ctx)
+		if err != nil {
+			return nil, err
+		}
+		vw.OnSelect(d.selectVariable)
+		d.variablesWidget = vw
+
+		err = d.syncVariablesWidget()
+		if err != nil {
+			return nil, err
+		}
+	}
+
+	// If the renderer knows how to render the datasources health and the
+	// controller knows it, load the status before the dashboard.
+	if sr, ok := cfg.Renderer.(render.StatusRenderer); ok {
+		if _, err := cfg.Controller.GetDatasourcesHealth(); err == nil {
+			sw, err := sr.LoadStatus(ctx)
+			if err != nil {
+				return nil, err
+			}
+			d.statusWidget = sw
+		}
+	}
+
 	// Call the View to load the dashboard and return us the widgets that we will need to call.
 	renderWidgets, err := cfg.Renderer.LoadDashboard(ctx, gr)
 	if err != nil {
@@ -69,19 +110,31 @@ func NewDashboard(ctx context.Context, cfg DashboardCfg, logger log.Logger) (vie
 }
 
 type dashboard struct {
-	cfg        DashboardCfg
-	widgets    []viewsync.Syncer
-	ctrl       controller.Controller
-	variablers map[string]variable.Variabler
-	logger     log.Logger
+	cfg             DashboardCfg
+	widgets         []viewsync.Syncer
+	ctrl            controller.Controller
+	variablers      map[string]variable.Variabler
+	variablesWidget render.VariablesWidget
+	statusWidget    render.StatusWidget
+	logger          log.Logger
 }
 
 func (d *dashboard) Sync(ctx context.Context, r *viewsync.Request) error {
+	// Refresh the variables that need to be refreshed on every sync.
+	d.setVariablesTimeRange(r.TimeRangeEnd.Sub(r.TimeRangeStart))
+	d.refreshVariables(ctx)
+
 	// Add dashboard sync data.
 	r = d.syncData(r)
 
+	// Render the variables with the refreshed values.
+	err := d.syncVariablesWidget()
+	if err != nil {
+		d.logger.Errorf(err.Error())
+	}
+
 	// Create a context with timeout for widget sync operations
-	widgetCtx, cancel := context.WithTimeout(ctx, 3*time.Second)
+	widgetCtx, cancel := context.WithTimeout(ctx, widgetsSyncTimeout)
 	defer cancel()
 
 	// Sync all widgets with proper error handling and timeout
@@ -120,6 +173,12 @@ func (d *dashboard) Sync(ctx context.Context, r *viewsync.Request) error {
 		d.logger.Errorf(err.Error())
 	}
 
+	// Render the datasources health after the widgets queries.
+	err = d.syncStatusWidget()
+	if err != nil {
+		d.logger.Errorf(err.Error())
+	}
+
 	return nil
 }
 
@@ -138,6 +197,8 @@ func (d *dashboard) createWidgets(rws []render.Widget) []viewsync.Syncer {
 			w = widget.NewSinglestat(d.ctrl, v)
 		case render.GraphWidget:
 			w = widget.NewGraph(d.ctrl, v, d.logger)
+		case render.TableWidget:
+			w = widget.NewTable(d.ctrl, v, d.logger)
 		default:
 			continue
 		}
@@ -175,11 +236,58 @@ func (d *dashboard) staticData() template.Data {
 	return dashboardData
 }
 
+// refreshVariables refreshes the variables at the same time so a slow
+// variable doesn't delay the others. The refresh has its own timeout
+// so the variables don't use the time of the widgets sync.
+func (d *dashboard) refreshVariables(ctx context.Context) {
+	ctx, cancel := context.WithTimeout(ctx, variablesRefreshTimeout)
+	defer cancel()
+
+	var wg sync.WaitGroup
+	for vid, v := range d.variablers {
+		if v.Scope() != variable.ScopeSync {
+			continue
+		}
+
+		rv, ok := v.(variable.Refresher)
+		if !ok {
+			continue
+		}
+
+		wg.Add(1)
+		go func(vid string, rv variable.Refresher) {
+			defer wg.Done()
+			// On error we continue with the previous values of the variable.
+			err := rv.Refresh(ctx)
+			if err != nil {
+				d.logger.Errorf("error refreshing %s variable: %s", vid, err)
+			}
+		}(vid, rv)
+	}
+	wg.Wait()
+}
+
+// setVariablesTimeRange updates the variables that depend on the time range,
+// the range can change at any moment (e.g zoom or pan).
+func (d *dashboard) setVariablesTimeRange(timeRange time.Duration) {
+	if timeRange <= 0 {
+		return
+	}
+
+	for _, v := range d.variablers {
+		if tv, ok := v.(variable.TimeRangeSetter); ok {
+			tv.SetTimeRange(timeRange)
+		}
+	}
+}
+
 func (d *dashboard) syncData(r *viewsync.Request) *viewsync.Request {
-	// Load variablers data from the sync scope.
+	// Load variablers data from the sync scope. The repeatable variables
+	// can be selected at any moment so they are loaded on every sync.
 	data := map[string]interface{}{}
 	for vid, v := range d.variablers {
-		if v.Scope() == variable.ScopeSync {
+		_, repeatable := v.(variable.Repeatable)
+		if v.Scope() == variable.ScopeSync || repeatable {
 			data[vid] = v.GetValue()
 		}
 	}
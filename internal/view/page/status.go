@@ -0,0 +1,22 @@
+package page
+
+import "fmt"
+
+// syncStatusWidget renders the health of the datasources on the status widget.
+func (d *dashboard) syncStatusWidget() error {
+	if d.statusWidget == nil {
+		return nil
+	}
+
+	health, err := d.ctrl.GetDatasourcesHealth()
+	if err != nil {
+		return fmt.Errorf("error getting datasources health: %w", err)
+	}
+
+	err = d.statusWidget.Sync(health)
+	if err != nil {
+		return fmt.Errorf("error rendering datasources health: %w", err)
+	}
+
+	return nil
+}
@@ -0,0 +1,63 @@
+package page
+
+import (
+	"errors"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+
+	mcontroller "github.com/slok/grafterm/internal/mocks/controller"
+	mrender "github.com/slok/grafterm/internal/mocks/view/render"
+	"github.com/slok/grafterm/internal/model"
+	"github.com/slok/grafterm/internal/service/log"
+)
+
+func TestDashboardSyncStatusWidget(t *testing.T) {
+	health := []model.DatasourceHealth{
+		{DatasourceID: "prometheus", State: model.CircuitOpen, ConsecutiveFailures: 5, LastError: "timeout"},
+	}
+
+	tests := map[string]struct {
+		mock   func(mc *mcontroller.Controller, msw *mrender.StatusWidget)
+		expErr bool
+	}{
+		"The datasources health should be rendered on the status widget.": {
+			mock: func(mc *mcontroller.Controller, msw *mrender.StatusWidget) {
+				mc.On("GetDatasourcesHealth").Once().Return(health, nil)
+				msw.On("Sync", health).Once().Return(nil)
+			},
+		},
+		"An error getting the datasources health should fail.": {
+			mock: func(mc *mcontroller.Controller, msw *mrender.StatusWidget) {
+				mc.On("GetDatasourcesHealth").Once().Return(nil, errors.New("wanted error"))
+			},
+			expErr: true,
+		},
+	}
+
+	for name, test := range tests {
+		t.Run(name, func(t *testing.T) {
+			assert := assert.New(t)
+
+			// Mocks.
+			mc := &mcontroller.Controller{}
+			msw := &mrender.StatusWidget{}
+			test.mock(mc, msw)
+
+			d := &dashboard{
+				ctrl:         mc,
+				statusWidget: msw,
+				logger:       log.Dummy,
+			}
+			err := d.syncStatusWidget()
+
+			if test.expErr {
+				assert.Error(err)
+			} else {
+				assert.NoError(err)
+			}
+			mc.AssertExpectations(t)
+			msw.AssertExpectations(t)
+		})
+	}
+}
@@ -0,0 +1,97 @@
+package page
+
+import (
+	"fmt"
+
+	"github.com/slok/grafterm/internal/view/render"
+	"github.com/slok/grafterm/internal/view/variable"
+)
+
+// renderVariables returns the dashboard variables in the order they are
+// defined on the dashboard ready to be rendered.
+func (d *dashboard) renderVariables() []render.Variable {
+	vars := []render.Variable{}
+	for _, v := range d.cfg.Dashboard.Variables {
+		vr, ok := d.variablers[v.Name]
+		if !ok {
+			continue
+		}
+
+		// If the user has overridden the variable, it can't be selected.
+		if ov, ok := d.cfg.AppOverrideVariables[v.Name]; ok {
+			vars = append(vars, render.Variable{Name: v.Name, Value: ov})
+			continue
+		}
+
+		rv := render.Variable{
+			Name:  v.Name,
+			Value: vr.GetValue(),
+		}
+
+		if rvr, ok := vr.(variable.Repeatable); ok {
+			rv.Options = rvr.GetAllValues()
+			rv.Selected = rvr.GetValues()
+			rv.Multi = rvr.IsRepeatable()
+		}
+
+		vars = append(vars, rv)
+	}
+
+	return vars
+}
+
+// selectVariable selects the values of a variable, the new values will be used
+// on the next sync.
+func (d *dashboard) selectVariable(name string, values []string) error {
+	if len(values) == 0 {
+		return fmt.Errorf("at least one value is required to select on %s variable", name)
+	}
+
+	v, ok := d.variablers[name]
+	if !ok {
+		return fmt.Errorf("variable %s does not exist", name)
+	}
+
+	rv, ok := v.(variable.Repeatable)
+	if !ok {
+		return fmt.Errorf("variable %s values can't be selected", name)
+	}
+
+	// First select and then deselect so the variable is never empty.
+	rv.Select(values...)
+	deselect := []string{}
+	for _, s := range rv.GetValues() {
+		if !containsValue(values, s) {
+			deselect = append(deselect, s)
+		}
+	}
+	rv.Deselect(deselect...)
+
+	d.logger.Infof("selected %v values on %s variable", rv.GetValues(), name)
+
+	return d.syncVariablesWidget()
+}
+
+// syncVariablesWidget renders the current state of the variables
+// if the dashboard has a variables widget.
+func (d *dashboard) syncVariablesWidget() error {
+	if d.variablesWidget == nil {
+		return nil
+	}
+
+	err := d.variablesWidget.Sync(d.renderVariables())
+	if err != nil {
+		return fmt.Errorf("error syncing variables widget: %w", err)
+	}
+
+	return nil
+}
+
+func containsValue(values []string, value string) bool {
+	for _, v := range values {
+		if v == value {
+			return true
+		}
+	}
+	return false
+}
@@ -0,0 +1,152 @@
+package page
+
+import (
+	"context"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/mock"
+
+	mcontroller "github.com/slok/grafterm/internal/mocks/controller"
+	mrender "github.com/slok/grafterm/internal/mocks/view/render"
+	"github.com/slok/grafterm/internal/model"
+	"github.com/slok/grafterm/internal/service/log"
+	"github.com/slok/grafterm/internal/view/render"
+	"github.com/slok/grafterm/internal/view/variable"
+)
+
+func newTestVariablesDashboard(t *testing.T, multi bool, override map[string]string) *dashboard {
+	db := model.Dashboard{
+		Variables: []model.Variable{
+			{
+				Name:           "env",
+				VariableSource: model.VariableSource{Constant: &model.ConstantVariableSource{Value: "prod"}},
+			},
+			{
+				Name:           "ns",
+				VariableSource: model.VariableSource{Query: &model.QueryVariableSource{Multi: multi}},
+			},
+		},
+	}
+
+	mc := &mcontroller.Controller{}
+	mc.On("GetDiscoveredValues", mock.Anything, mock.Anything).Return([]string{"ns1", "ns2", "ns3"}, nil)
+
+	vs, err := variable.NewVariablers(context.TODO(), variable.FactoryConfig{
+		Dashboard:  db,
+		Controller: mc,
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	return &dashboard{
+		cfg: DashboardCfg{
+			AppOverrideVariables: override,
+			Dashboard:            db,
+		},
+		variablers: vs,
+		logger:     log.Dummy,
+	}
+}
+
+func TestDashboardRenderVariables(t *testing.T) {
+	tests := map[string]struct {
+		multi    bool
+		override map[string]string
+		expVars  []render.Variable
+	}{
+		"Variables should be rendered in the dashboard order with the options of the selectable ones.": {
+			expVars: []render.Variable{
+				{Name: "env", Value: "prod"},
+				{Name: "ns", Value: "ns1", Options: []string{"ns1", "ns2", "ns3"}, Selected: []string{"ns1"}},
+			},
+		},
+		"Multi variables should be rendered as multi.": {
+			multi: true,
+			expVars: []render.Variable{
+				{Name: "env", Value: "prod"},
+				{Name: "ns", Value: "ns1", Options: []string{"ns1", "ns2", "ns3"}, Selected: []string{"ns1"}, Multi: true},
+			},
+		},
+		"Overridden variables should be rendered with the overridden value and without options.": {
+			override: map[string]string{"ns": "custom"},
+			expVars: []render.Variable{
+				{Name: "env", Value: "prod"},
+				{Name: "ns", Value: "custom"},
+			},
+		},
+	}
+
+	for name, test := range tests {
+		t.Run(name, func(t *testing.T) {
+			d := newTestVariablesDashboard(t, test.multi, test.override)
+			assert.Equal(t, test.expVars, d.renderVariables())
+		})
+	}
+}
+
+func TestDashboardSelectVariable(t *testing.T) {
+	tests := map[string]struct {
+		multi       bool
+		variable    string
+		values      []string
+		expSelected []string
+		expValue    string
+		expErr      bool
+	}{
+		"Selecting a value on a single value variable should replace the selected value.": {
+			variable:    "ns",
+			values:      []string{"ns2"},
+			expSelected: []string{"ns2"},
+			expValue:    "ns2",
+		},
+		"Selecting multiple values on a multi variable should replace the selected values.": {
+			multi:       true,
+			variable:    "ns",
+			values:      []string{"ns3", "ns2"},
+			expSelected: []string{"ns2", "ns3"},
+			expValue:    "ns2|ns3",
+		},
+		"Selecting values on a missing variable should fail.": {
+			variable: "missing",
+			values:   []string{"ns2"},
+			expErr:   true,
+		},
+		"Selecting values on a not selectable variable should fail.": {
+			variable: "env",
+			values:   []string{"dev"},
+			expErr:   true,
+		},
+		"Selecting without values should fail.": {
+			variable: "ns",
+			expErr:   true,
+		},
+	}
+
+	for name, test := range tests {
+		t.Run(name, func(t *testing.T) {
+			assert := assert.New(t)
+
+			d := newTestVariablesDashboard(t, test.multi, nil)
+
+			// Mocks.
+			mvw := &mrender.VariablesWidget{}
+			if !test.expErr {
+				mvw.On("Sync", mock.Anything).Once().Return(nil)
+			}
+			d.variablesWidget = mvw
+
+			err := d.selectVariable(test.variable, test.values)
+
+			if test.expErr {
+				assert.Error(err)
+			} else if assert.NoError(err) {
+				rv := d.variablers[test.variable].(variable.Repeatable)
+				assert.Equal(test.expSelected, rv.GetValues())
+				assert.Equal(test.expValue, rv.GetValue())
+			}
+			mvw.AssertExpectations(t)
+		})
+	}
+}
@@ -8,6 +8,7 @@ import (
 
 	"github.com/slok/grafterm/internal/controller"
 	"github.com/slok/grafterm/internal/model"
+	"github.com/slok/grafterm/internal/service/metric"
 	"github.com/slok/grafterm/internal/view/render"
 	"github.com/slok/grafterm/internal/view/sync"
 )
@@ -19,6 +20,7 @@ type gauge struct {
 	cfg            model.Widget
 	currentColor   string
 	syncLock       syncingFlag
+	state          dataState
 }
 
 // NewGauge returns a new Gauge widget that is a syncer.
@@ -59,13 +61,16 @@ func (g *gauge) Sync(ctx context.Context, r *sync.Request) error {
 	templatedQ.Expr = r.TemplateData.Render(templatedQ.Expr)
 	m, err := g.controller.GetSingleMetric(gaugeCtx, templatedQ, r.TimeRangeEnd)
 	if err != nil {
+		// Show the failure on the widget, the returned error is the query one.
+		_ = g.state.failed(gaugeCtx, g.rendererWidget, err)
+
 		if gaugeCtx.Err() == context.DeadlineExceeded {
 			return fmt.Errorf("gauge widget timeout: %w", err)
 		}
 		if gaugeCtx.Err() == context.Canceled {
 			return fmt.Errorf("gauge widget canceled: %w", err)
 		}
-		return fmt.Errorf("error getting single instant metric: %w", err)
+		return fmt.Errorf("error getting single instant metric (%s): %w", metric.ErrorClassOf(err), err)
 	}
 
 	// calculate percent value if required.
@@ -86,7 +91,7 @@ func (g *gauge) Sync(ctx context.Context, r *sync.Request) error {
 		return fmt.Errorf("error setting value on render view widget: %w", err)
 	}
 
-	return nil
+	return g.state.synced(gaugeCtx, g.rendererWidget, nil)
 }
 
 func (g *gauge) getPercentValue(val float64) float64 {
@@ -2,12 +2,14 @@ package widget
 
 import (
 	"context"
+	"errors"
 	"sort"
 	"time"
 
 	"github.com/slok/grafterm/internal/controller"
 	"github.com/slok/grafterm/internal/model"
 	"github.com/slok/grafterm/internal/service/log"
+	"github.com/slok/grafterm/internal/service/metric"
 	"github.com/slok/grafterm/internal/service/unit"
 	"github.com/slok/grafterm/internal/view/render"
 	viewsync "github.com/slok/grafterm/internal/view/sync"
@@ -16,6 +18,7 @@ import (
 
 const (
 	graphPointQuantityRetries = 5
+	metadataTimeout           = 2 * time.Second
 )
 
 // graph is a widget that represents values in a two axis graph.
@@ -25,6 +28,13 @@ type graph struct {
 	widgetCfg      model.Widget
 	syncLock       syncingFlag
 	logger         log.Logger
+	state          dataState
+
+	// metadata are the metrics metadata of the queries, indexed by the
+	// datasource and the query expression.
+	metadata map[string][]model.MetricMetadata
+	// yAxisVR is the representation of the Y axis values set on the renderer.
+	yAxisVR model.ValueRepresentation
 }
 
 // NewGraph returns new Graph widget syncer.
@@ -36,14 +46,17 @@ func NewGraph(controller controller.Controller, rendererWidget render.GraphWidge
 		rendererWidget: rendererWidget,
 		widgetCfg:      wcfg,
 		logger:         logger,
+		metadata:       map[string][]model.MetricMetadata{},
+		yAxisVR:        wcfg.Graph.Visualization.YAxis.ValueRepresentation,
 	}
 }
 
 // metricSeries is a helper type that has the metric series and the query
 // that has been used to get them.
 type metricSeries struct {
-	query  model.Query
-	series model.MetricSeries
+	query    model.Query
+	series   model.MetricSeries
+	metadata []model.MetricMetadata
 }
 
 func (g *graph) Sync(ctx context.Context, r *viewsync.Request) error {
@@ -72,6 +85,11 @@ func (g *graph) Sync(ctx context.Context, r *viewsync.Request) error {
 	end := r.TimeRangeEnd
 	step := end.Sub(start) / time.Duration(cap)
 	allSeries := []metricSeries{}
+	vrw, inferVR := g.rendererWidget.(render.ValueRepresentationGraphWidget)
+	yAxisVR := g.widgetCfg.Graph.Visualization.YAxis.ValueRepresentation
+	units := map[string]bool{}
+	// queryErr is the error of the first failed query, shown on the widget.
+	var queryErr error
 	
 	// Create a context with timeout for metric gathering
 	metricCtx, cancel := context.WithTimeout(ctx, 2*time.Second)
@@ -85,29 +103,68 @@ func (g *graph) Sync(ctx context.Context, r *viewsync.Request) error {
 		if err != nil {
 			if metricCtx.Err() == context.DeadlineExceeded {
 				g.logger.Errorf("graph widget timeout for query '%s': %v", templatedQ.Expr, err)
+				if queryErr == nil {
+					queryErr = err
+				}
 				continue // Skip this query but continue with others
 			}
 			if metricCtx.Err() == context.Canceled {
 				g.logger.Errorf("graph widget canceled for query '%s': %v", templatedQ.Expr, err)
 				continue // Skip this query but continue with others
 			}
-			g.logger.Errorf("graph widget error for query '%s': %v", templatedQ.Expr, err)
+			g.logger.Errorf("graph widget error for query '%s' (%s): %v", templatedQ.Expr, metric.ErrorClassOf(err), err)
+			if queryErr == nil {
+				queryErr = err
+			}
 			continue // Skip this query but continue with others
 		}
 
+		// Apply the client side transformations of the query.
+		series = transformSeries(series, q.Transforms)
+
+		// Get the metadata of the query metrics to infer the representation
+		// of the values and the legends.
+		var mds []model.MetricMetadata
+		if inferVR {
+			mds = g.queryMetadata(ctx, templatedQ)
+			if u := yAxisVR.WithMetadataDefaults(templatedQ.Expr, mds).Unit; u != "" {
+				units[u] = true
+			}
+		}
+
 		// Append all received series.
 		for _, serie := range series {
 			ms := metricSeries{
-				query:  q,
-				series: serie,
+				query:    q,
+				series:   serie,
+				metadata: mds,
 			}
 			allSeries = append(allSeries, ms)
 		}
 	}
 
+	// Set the inferred representation of the values if all the queries
+	// agree on the unit.
+	if inferVR && yAxisVR.Unit == "" && len(units) == 1 {
+		for u := range units {
+			yAxisVR.Unit = u
+		}
+	}
+	if inferVR && yAxisVR != g.yAxisVR {
+		err := vrw.SetYAxisValueRepresentation(yAxisVR)
+		if err != nil {
+			g.logger.Errorf("graph widget could not set the inferred Y axis unit '%s': %v", yAxisVR.Unit, err)
+		} else {
+			g.yAxisVR = yAxisVR
+		}
+	}
+
 	// If we couldn't get any data due to timeouts, return gracefully
 	if len(allSeries) == 0 {
 		g.logger.Warnf("no data retrieved for graph widget due to timeouts or errors")
+		if err := g.state.failed(metricCtx, g.rendererWidget, queryErr); err != nil {
+			g.logger.Errorf("graph widget could not show the state: %v", err)
+		}
 		return nil
 	}
 
@@ -120,7 +177,7 @@ func (g *graph) Sync(ctx context.Context, r *viewsync.Request) error {
 
 	// Update the render view value.
 	g.rendererWidget.Sync(series)
-	return nil
+	return g.state.synced(metricCtx, g.rendererWidget, queryErr)
 }
 
 func (g *graph) sortSeries(allseries []metricSeries) []metricSeries {
@@ -259,12 +316,44 @@ func (g *graph) getWindowCapacity() int {
 	return cap
 }
 
+// queryMetadata returns the metadata of the query metrics, the metadata is
+// gathered until it's gathered successfully for each query. It has its own
+// timeout so it doesn't use the time of the data queries.
+func (g *graph) queryMetadata(ctx context.Context, query model.Query) []model.MetricMetadata {
+	key := query.DatasourceID + "/" + query.Expr
+	if mds, ok := g.metadata[key]; ok {
+		return mds
+	}
+
+	ctx, cancel := context.WithTimeout(ctx, metadataTimeout)
+	defer cancel()
+
+	mds, err := g.controller.GetMetricsMetadata(ctx, query)
+	switch {
+	// Not all the datasources support the metadata, so don't try again.
+	case errors.Is(err, metric.ErrMetadataNotSupported):
+		g.metadata[key] = nil
+	// Try again on the next sync.
+	case err != nil:
+		g.logger.Warnf("graph widget could not get the metrics metadata for query '%s': %v", query.Expr, err)
+	default:
+		g.metadata[key] = mds
+	}
+
+	return mds
+}
+
 // legend will get the correct legend based on the query legend value.
-// if this is not set, the legend will be the ID of the metric series,
-// if set it will tru rendering the template using the template data.
+// if this is not set, the legend will be the ID of the metric series (or
+// the metric name if the series doesn't have labels and the query uses
+// only one metric), if set it will tru rendering the template using the
+// template data.
 func (g *graph) legend(templateData template.Data, series metricSeries) string {
 	// If no special legend then render with the ID.
 	if series.query.Legend == "" {
+		if len(series.series.Labels) == 0 && len(series.metadata) == 1 && !renamesSeries(series.query.Transforms) {
+			return series.metadata[0].Name
+		}
 		return series.series.ID
 	}
 
@@ -2,6 +2,8 @@ package widget_test
 
 import (
 	"context"
+	"errors"
+	"fmt"
 	"regexp"
 	"testing"
 	"time"
@@ -13,6 +15,7 @@ import (
 	mrender "github.com/slok/grafterm/internal/mocks/view/render"
 	"github.com/slok/grafterm/internal/model"
 	"github.com/slok/grafterm/internal/service/log"
+	"github.com/slok/grafterm/internal/service/metric"
 	"github.com/slok/grafterm/internal/view/page/widget"
 	"github.com/slok/grafterm/internal/view/render"
 	"github.com/slok/grafterm/internal/view/sync"
@@ -482,3 +485,212 @@ func TestGraphWidget(t *testing.T) {
 		})
 	}
 }
+
+func TestGraphWidgetMetricsMetadata(t *testing.T) {
+	t1, _ := time.Parse(time.RFC3339, "2019-04-13T09:30:00+00:00")
+	t1Minus100m := t1.Add(-100 * time.Minute)
+	syncReq := &sync.Request{
+		TimeRangeEnd:   t1,
+		TimeRangeStart: t1Minus100m,
+	}
+	query := model.Query{Expr: "sum(rate(http_requests_total[5m]))"}
+	seriess := []model.MetricSeries{
+		{ID: "{}", Metrics: []model.Metric{{Value: 1, TS: t1Minus100m.Add(1 * time.Minute)}}},
+	}
+
+	tests := []struct {
+		name      string
+		yAxis     model.YAxis
+		metadata  []model.MetricMetadata
+		metaErr   error
+		metaCalls int
+		expVR     *model.ValueRepresentation
+		expLegend string
+	}{
+		{
+			name:      "A graph without unit should set the unit and the legend inferred from the metrics metadata.",
+			yAxis:     model.YAxis{ValueRepresentation: model.ValueRepresentation{Decimals: 1}},
+			metadata:  []model.MetricMetadata{{Name: "http_requests_total", Type: "counter"}},
+			expVR:     &model.ValueRepresentation{Unit: "reqps", Decimals: 1},
+			expLegend: "http_requests_total",
+		},
+		{
+			name:      "A graph with unit should not set the inferred unit.",
+			yAxis:     model.YAxis{ValueRepresentation: model.ValueRepresentation{Unit: "short"}},
+			metadata:  []model.MetricMetadata{{Name: "http_requests_total", Type: "counter"}},
+			expLegend: "http_requests_total",
+		},
+		{
+			name:      "A graph without metrics metadata should not infer the unit nor the legend.",
+			metaErr:   fmt.Errorf("wanted error: %w", metric.ErrMetadataNotSupported),
+			expLegend: "{}",
+		},
+		{
+			name:      "A graph with failed metrics metadata queries should try again on every sync.",
+			metaErr:   errors.New("wanted error"),
+			metaCalls: 2,
+			expLegend: "{}",
+		},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			assert := assert.New(t)
+
+			cfg := model.Widget{
+				WidgetSource: model.WidgetSource{
+					Graph: &model.GraphWidgetSource{
+						Queries:       []model.Query{query},
+						Visualization: model.GraphVisualization{YAxis: test.yAxis},
+					},
+				},
+			}
+
+			// Mocks.
+			mgraph := &mrender.ValueRepresentationGraphWidget{}
+			mgraph.On("GetWidgetCfg").Once().Return(cfg)
+			mgraph.On("GetGraphPointQuantity").Return(10)
+			mgraph.On("Sync", mock.Anything).Return(nil)
+			if test.expVR != nil {
+				mgraph.On("SetYAxisValueRepresentation", *test.expVR).Once().Return(nil)
+			}
+			mc := &mcontroller.Controller{}
+			mc.On("GetRangeMetrics", mock.Anything, query, mock.Anything, mock.Anything, mock.Anything).Return(seriess, nil)
+			metaCalls := test.metaCalls
+			if metaCalls == 0 {
+				metaCalls = 1
+			}
+			mc.On("GetMetricsMetadata", mock.Anything, query).Times(metaCalls).Return(test.metadata, test.metaErr)
+
+			// Sync multiple times, the metadata should be gathered only once
+			// if gathered or not supported.
+			graph := widget.NewGraph(mc, mgraph, log.Dummy)
+			for i := 0; i < 2; i++ {
+				err := graph.Sync(context.Background(), syncReq)
+				assert.NoError(err)
+			}
+
+			mc.AssertExpectations(t)
+			mgraph.AssertExpectations(t)
+			synced := mgraph.Calls[len(mgraph.Calls)-1].Arguments.Get(0).([]render.Series)
+			if assert.Len(synced, 1) {
+				assert.Equal(test.expLegend, synced[0].Label)
+				assert.Equal(rv(1), synced[0].Values[0])
+			}
+		})
+	}
+}
+
+func TestGraphWidgetTransforms(t *testing.T) {
+	t1, _ := time.Parse(time.RFC3339, "2019-04-13T09:30:00+00:00")
+	t1Minus100m := t1.Add(-100 * time.Minute)
+	syncReq := &sync.Request{
+		TimeRangeEnd:   t1,
+		TimeRangeStart: t1Minus100m,
+	}
+
+	// metrics returns a metric for every value on the first graph buckets.
+	metrics := func(vs ...float64) []model.Metric {
+		ms := []model.Metric{}
+		for i, v := range vs {
+			ms = append(ms, model.Metric{Value: v, TS: t1Minus100m.Add(time.Duration(i*10+1) * time.Minute)})
+		}
+		return ms
+	}
+	seriesByCode := []model.MetricSeries{
+		{ID: `{code="200"}`, Labels: map[string]string{"code": "200"}, Metrics: metrics(1, 1, 1)},
+		{ID: `{code="500"}`, Labels: map[string]string{"code": "500"}, Metrics: metrics(4, 5, 6)},
+	}
+
+	tests := []struct {
+		name       string
+		series     []model.MetricSeries
+		transforms []model.Transform
+		expLabels  []string
+		expValues  []*render.Value
+	}{
+		{
+			name:       "A rate transform should handle the counter resets and drop the first value.",
+			series:     []model.MetricSeries{{ID: "test", Metrics: metrics(0, 600, 1200, 600, 1800)}},
+			transforms: []model.Transform{{Rate: &model.RateTransform{}}, {Scale: &model.ScaleTransform{Factor: 60}}},
+			expLabels:  []string{"test"},
+			expValues:  []*render.Value{nil, rv(60), rv(60), rv(60), rv(120), nil, nil, nil, nil, nil},
+		},
+		{
+			name:       "A derivative transform should return the per second change.",
+			series:     []model.MetricSeries{{ID: "test", Metrics: metrics(0, 1200, 600)}},
+			transforms: []model.Transform{{Derivative: &model.DerivativeTransform{}}},
+			expLabels:  []string{"test"},
+			expValues:  []*render.Value{nil, rv(2), rv(-1), nil, nil, nil, nil, nil, nil, nil},
+		},
+		{
+			name:       "A moving average and offset transforms should be applied in order.",
+			series:     []model.MetricSeries{{ID: "test", Metrics: metrics(2, 4, 6, 8)}},
+			transforms: []model.Transform{{MovingAverage: &model.MovingAverageTransform{Window: 2}}, {Offset: &model.OffsetTransform{Value: 1}}},
+			expLabels:  []string{"test"},
+			expValues:  []*render.Value{rv(3), rv(4), rv(6), rv(8), nil, nil, nil, nil, nil, nil},
+		},
+		{
+			name:       "A cumulative sum transform should sum the previous values.",
+			series:     []model.MetricSeries{{ID: "test", Metrics: metrics(1, 2, 3)}},
+			transforms: []model.Transform{{CumulativeSum: &model.CumulativeSumTransform{}}},
+			expLabels:  []string{"test"},
+			expValues:  []*render.Value{rv(1), rv(3), rv(6), nil, nil, nil, nil, nil, nil, nil},
+		},
+		{
+			name:   "A topk transform should keep the series with the highest average and rename them.",
+			series: seriesByCode,
+			transforms: []model.Transform{
+				{TopK: &model.TopKTransform{K: 1}},
+				{RenameByRegex: &model.RenameByRegexTransform{CompiledRegex: regexp.MustCompile(`\{code="(.*)"\}`), Replacement: "code $1"}},
+			},
+			expLabels: []string{"code 500"},
+			expValues: []*render.Value{rv(4), rv(5), rv(6), nil, nil, nil, nil, nil, nil, nil},
+		},
+		{
+			name:       "A bottomk transform should keep the series with the lowest average.",
+			series:     seriesByCode,
+			transforms: []model.Transform{{BottomK: &model.TopKTransform{K: 1}}},
+			expLabels:  []string{`{code="200"}`},
+			expValues:  []*render.Value{rv(1), rv(1), rv(1), nil, nil, nil, nil, nil, nil, nil},
+		},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			assert := assert.New(t)
+
+			query := model.Query{Expr: "test", Transforms: test.transforms}
+			cfg := model.Widget{
+				WidgetSource: model.WidgetSource{
+					Graph: &model.GraphWidgetSource{Queries: []model.Query{query}},
+				},
+			}
+
+			// Mocks.
+			mgraph := &mrender.GraphWidget{}
+			mgraph.On("GetWidgetCfg").Once().Return(cfg)
+			mgraph.On("GetGraphPointQuantity").Return(10)
+			mgraph.On("Sync", mock.Anything).Return(nil)
+			mc := &mcontroller.Controller{}
+			mc.On("GetRangeMetrics", mock.Anything, mock.Anything, mock.Anything, mock.Anything, mock.Anything).Return(test.series, nil)
+
+			graph := widget.NewGraph(mc, mgraph, log.Dummy)
+			err := graph.Sync(context.Background(), syncReq)
+			assert.NoError(err)
+
+			synced := mgraph.Calls[len(mgraph.Calls)-1].Arguments.Get(0).([]render.Series)
+			gotLabels := []string{}
+			for _, s := range synced {
+				gotLabels = append(gotLabels, s.Label)
+			}
+			if assert.Equal(test.expLabels, gotLabels) {
+				assert.Equal(test.expValues, synced[0].Values)
+			}
+
+			// The gathered series should not be modified.
+			assert.Equal(`{code="200"}`, seriesByCode[0].ID)
+			assert.Len(seriesByCode, 2)
+		})
+	}
+}
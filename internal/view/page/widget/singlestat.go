@@ -8,6 +8,7 @@ import (
 
 	"github.com/slok/grafterm/internal/controller"
 	"github.com/slok/grafterm/internal/model"
+	"github.com/slok/grafterm/internal/service/metric"
 	"github.com/slok/grafterm/internal/service/unit"
 	"github.com/slok/grafterm/internal/view/render"
 	"github.com/slok/grafterm/internal/view/sync"
@@ -26,6 +27,7 @@ type singlestat struct {
 	currentColor   string
 	cfg            model.Widget
 	syncLock       syncingFlag
+	state          dataState
 }
 
 // NewSinglestat returns a new Singlestat widget syncer.
@@ -66,13 +68,16 @@ func (s *singlestat) Sync(ctx context.Context, r *sync.Request) error {
 	templatedQ.Expr = r.TemplateData.Render(templatedQ.Expr)
 	m, err := s.controller.GetSingleMetric(statCtx, templatedQ, r.TimeRangeEnd)
 	if err != nil {
+		// Show the failure on the widget, the returned error is the query one.
+		_ = s.state.failed(statCtx, s.rendererWidget, err)
+
 		if statCtx.Err() == context.DeadlineExceeded {
 			return fmt.Errorf("singlestat widget timeout: %w", err)
 		}
 		if statCtx.Err() == context.Canceled {
 			return fmt.Errorf("singlestat widget canceled: %w", err)
 		}
-		return fmt.Errorf("error getting single instant metric: %w", err)
+		return fmt.Errorf("error getting single instant metric (%s): %w", metric.ErrorClassOf(err), err)
 	}
 
 	// Change the widget color if required.
@@ -91,7 +96,7 @@ func (s *singlestat) Sync(ctx context.Context, r *sync.Request) error {
 		return fmt.Errorf("error setting value on render view widget: %w", err)
 	}
 
-	return nil
+	return s.state.synced(statCtx, s.rendererWidget, nil)
 }
 
 func (s *singlestat) changeWidgetColor(val float64) error {
@@ -2,15 +2,19 @@ package widget_test
 
 import (
 	"context"
+	"errors"
 	"testing"
 
 	"github.com/stretchr/testify/assert"
 	"github.com/stretchr/testify/mock"
 
+	"github.com/slok/grafterm/internal/controller"
 	mcontroller "github.com/slok/grafterm/internal/mocks/controller"
 	mrender "github.com/slok/grafterm/internal/mocks/view/render"
 	"github.com/slok/grafterm/internal/model"
+	"github.com/slok/grafterm/internal/service/metric"
 	"github.com/slok/grafterm/internal/view/page/widget"
+	"github.com/slok/grafterm/internal/view/render"
 	"github.com/slok/grafterm/internal/view/sync"
 	"github.com/slok/grafterm/internal/view/template"
 )
@@ -160,3 +164,89 @@ func TestSinglestatWidget(t *testing.T) {
 		})
 	}
 }
+
+func TestSinglestatWidgetState(t *testing.T) {
+	errBadQuery := metric.NewError(metric.ErrorClassBadQuery, errors.New("wanted error"))
+	errTimeout := metric.NewError(metric.ErrorClassTimeout, errors.New("wanted error"))
+
+	// Every step is a sync of the widget.
+	steps := []struct {
+		name     string
+		metric   *model.Metric
+		err      error
+		expState *render.WidgetState
+		expStale bool
+	}{
+		{
+			name:     "A failed query should show the error.",
+			err:      errBadQuery,
+			expState: &render.WidgetState{Status: render.WidgetStatusError, Message: "bad query error"},
+		},
+		{
+			name:     "A successful query should clear the error.",
+			metric:   &model.Metric{Value: 1},
+			expState: &render.WidgetState{Status: render.WidgetStatusOK},
+		},
+		{
+			name:     "A query without data should show the no data since the last data.",
+			err:      controller.ErrNoData,
+			expState: &render.WidgetState{Status: render.WidgetStatusNoData, Message: "no data"},
+			expStale: true,
+		},
+		{
+			name: "The same state should not be shown again.",
+			err:  controller.ErrNoData,
+		},
+		{
+			name:     "A timed out query should show the timeout since the last data.",
+			err:      errTimeout,
+			expState: &render.WidgetState{Status: render.WidgetStatusTimeout, Message: "timeout"},
+			expStale: true,
+		},
+	}
+
+	cfg := model.Widget{
+		WidgetSource: model.WidgetSource{
+			Singlestat: &model.SinglestatWidgetSource{
+				ValueText: "{{ .value }}",
+			},
+		},
+	}
+	msstat := &mrender.SinglestatWidget{}
+	msstat.On("GetWidgetCfg").Once().Return(cfg)
+	mc := &mcontroller.Controller{}
+	singlestat := widget.NewSinglestat(mc, msstat)
+
+	for _, step := range steps {
+		t.Run(step.name, func(t *testing.T) {
+			assert := assert.New(t)
+
+			// Mocks.
+			mc.ExpectedCalls = nil
+			msstat.ExpectedCalls = nil
+			msstat.Calls = nil
+			mc.On("GetSingleMetric", mock.Anything, mock.Anything, mock.Anything).Once().Return(step.metric, step.err)
+			if step.metric != nil {
+				msstat.On("Sync", "1").Once().Return(nil)
+			}
+			if step.expState != nil {
+				exp := *step.expState
+				msstat.On("SetState", mock.MatchedBy(func(s render.WidgetState) bool {
+					return s.Status == exp.Status && s.Message == exp.Message && s.StaleSince.IsZero() != step.expStale
+				})).Once().Return(nil)
+			}
+
+			err := singlestat.Sync(context.Background(), &sync.Request{})
+
+			if step.err != nil {
+				assert.Error(err)
+			} else {
+				assert.NoError(err)
+			}
+			msstat.AssertExpectations(t)
+			if step.expState == nil {
+				msstat.AssertNotCalled(t, "SetState", mock.Anything)
+			}
+		})
+	}
+}
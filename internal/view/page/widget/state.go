@@ -0,0 +1,81 @@
+package widget
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"time"
+
+	"github.com/slok/grafterm/internal/controller"
+	"github.com/slok/grafterm/internal/service/metric"
+	"github.com/slok/grafterm/internal/view/render"
+)
+
+// dataState tracks the state of the data rendered by a widget between syncs,
+// so the widget shows its failed queries and since when it's stale. The
+// state is only rendered when it changes.
+type dataState struct {
+	state render.WidgetState
+	// lastData is the time of the latest sync that rendered data.
+	lastData time.Time
+}
+
+// synced renders the state of a sync that rendered data, the error is the
+// error of the queries that failed, if any.
+func (d *dataState) synced(ctx context.Context, w render.StateWidget, err error) error {
+	d.lastData = time.Now()
+
+	state := render.WidgetState{Status: render.WidgetStatusOK}
+	if err != nil {
+		state.Status, state.Message = errorStatus(ctx, err)
+	}
+
+	return d.set(w, state)
+}
+
+// failed renders the state of a sync that couldn't render data, if there
+// is no error the queries didn't return data. The canceled syncs don't
+// change the state.
+func (d *dataState) failed(ctx context.Context, w render.StateWidget, err error) error {
+	if ctx.Err() == context.Canceled {
+		return nil
+	}
+
+	state := render.WidgetState{
+		Status:     render.WidgetStatusNoData,
+		Message:    "no data",
+		StaleSince: d.lastData,
+	}
+	if err != nil {
+		state.Status, state.Message = errorStatus(ctx, err)
+	}
+
+	return d.set(w, state)
+}
+
+func (d *dataState) set(w render.StateWidget, state render.WidgetState) error {
+	if state == d.state {
+		return nil
+	}
+
+	err := w.SetState(state)
+	if err != nil {
+		return fmt.Errorf("error setting state on render view widget: %w", err)
+	}
+	d.state = state
+
+	return nil
+}
+
+// errorStatus returns the status and the message of a failed query.
+func errorStatus(ctx context.Context, err error) (render.WidgetStatus, string) {
+	class := metric.ErrorClassOf(err)
+	switch {
+	case errors.Is(err, controller.ErrNoData):
+		return render.WidgetStatusNoData, "no data"
+	case ctx.Err() == context.DeadlineExceeded, class == metric.ErrorClassTimeout:
+		return render.WidgetStatusTimeout, "timeout"
+	}
+
+	return render.WidgetStatusError, fmt.Sprintf("%s error", class)
+}
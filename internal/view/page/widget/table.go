@@ -0,0 +1,311 @@
+package widget
+
+import (
+	"context"
+	"fmt"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/slok/grafterm/internal/controller"
+	"github.com/slok/grafterm/internal/model"
+	"github.com/slok/grafterm/internal/service/log"
+	"github.com/slok/grafterm/internal/service/metric"
+	"github.com/slok/grafterm/internal/service/unit"
+	"github.com/slok/grafterm/internal/view/render"
+	"github.com/slok/grafterm/internal/view/sync"
+)
+
+const (
+	defTableValueTitle = "Value"
+	tableNoValueText   = "-"
+)
+
+// table is a widget that represents the metric series in rows and columns.
+type table struct {
+	controller     controller.Controller
+	rendererWidget render.TableWidget
+	cfg            model.Widget
+	syncLock       syncingFlag
+	logger         log.Logger
+	state          dataState
+}
+
+// NewTable returns a new Table widget syncer.
+func NewTable(controller controller.Controller, rendererWidget render.TableWidget, logger log.Logger) sync.Syncer {
+	cfg := rendererWidget.GetWidgetCfg()
+
+	// Sort the thresholds of all the columns. Optimization so we don't have to sort
+	// every time we calculate a color.
+	for _, q := range cfg.Table.Queries {
+		sort.Slice(q.Thresholds, func(i, j int) bool {
+			return q.Thresholds[i].StartValue < q.Thresholds[j].StartValue
+		})
+	}
+
+	return &table{
+		controller:     controller,
+		rendererWidget: rendererWidget,
+		cfg:            cfg,
+		logger:         logger,
+	}
+}
+
+// tableRow is a helper type that has the labels that identify the row
+// and the values of each query (value column) of the row.
+type tableRow struct {
+	labels map[string]string
+	values []*float64
+}
+
+func (t *table) Sync(ctx context.Context, r *sync.Request) error {
+	// If already syncing ignore call.
+	if t.syncLock.Get() {
+		return nil
+	}
+	// If didn't changed the value means some other sync process
+	// already entered before us.
+	if !t.syncLock.Set(true) {
+		return nil
+	}
+	defer t.syncLock.Set(false)
+
+	// Create context with timeout for table metric gathering.
+	tableCtx, cancel := context.WithTimeout(ctx, 2*time.Second)
+	defer cancel()
+
+	// Gather the metrics of all the queries.
+	queries := t.cfg.Table.Queries
+	allSeries := make([][]model.MetricSeries, len(queries))
+	gathered := false
+	// queryErr is the error of the first failed query, shown on the widget.
+	var queryErr error
+	for i, q := range queries {
+		templatedQ := q.Query
+		templatedQ.Expr = r.TemplateData.Render(templatedQ.Expr)
+
+		series, err := t.controller.GetSingleMetrics(tableCtx, templatedQ, r.TimeRangeEnd)
+		if err != nil {
+			t.logger.Errorf("table widget error for query '%s' (%s): %v", templatedQ.Expr, metric.ErrorClassOf(err), err)
+			if queryErr == nil {
+				queryErr = err
+			}
+			continue // Skip this query but continue with others.
+		}
+		allSeries[i] = series
+		gathered = true
+	}
+
+	// If we couldn't get any data, return gracefully.
+	if !gathered {
+		t.logger.Warnf("no data retrieved for table widget due to timeouts or errors")
+		if err := t.state.failed(tableCtx, t.rendererWidget, queryErr); err != nil {
+			t.logger.Errorf("table widget could not show the state: %v", err)
+		}
+		return nil
+	}
+
+	columns := t.labelColumns(allSeries)
+	rows := t.createRows(columns, allSeries)
+
+	tbl, err := t.transformToRenderable(r, columns, rows)
+	if err != nil {
+		return fmt.Errorf("error creating table: %w", err)
+	}
+
+	err = t.rendererWidget.Sync(tbl)
+	if err != nil {
+		return fmt.Errorf("error setting value on render view widget: %w", err)
+	}
+
+	return t.state.synced(tableCtx, t.rendererWidget, queryErr)
+}
+
+// labelColumns returns the label columns of the table, if the widget
+// doesn't have them configured, it will use all the labels of the
+// metric series sorted by name.
+func (t *table) labelColumns(allSeries [][]model.MetricSeries) []model.TableColumn {
+	if len(t.cfg.Table.Columns) > 0 {
+		return t.cfg.Table.Columns
+	}
+
+	labels := map[string]struct{}{}
+	for _, series := range allSeries {
+		for _, s := range series {
+			for k := range s.Labels {
+				labels[k] = struct{}{}
+			}
+		}
+	}
+
+	columns := []model.TableColumn{}
+	for k := range labels {
+		columns = append(columns, model.TableColumn{Label: k})
+	}
+	sort.Slice(columns, func(i, j int) bool { return columns[i].Label < columns[j].Label })
+
+	return columns
+}
+
+// createRows will merge the metric series of all the queries in rows, the metric
+// series of the different queries that have the same label column values will
+// be placed on the same row.
+func (t *table) createRows(columns []model.TableColumn, allSeries [][]model.MetricSeries) []*tableRow {
+	rows := []*tableRow{}
+	rowsByKey := map[string]*tableRow{}
+	for i, series := range allSeries {
+		for _, s := range series {
+			if len(s.Metrics) == 0 {
+				continue
+			}
+
+			key := rowKey(columns, s.Labels)
+			row, ok := rowsByKey[key]
+			if !ok {
+				row = &tableRow{
+					labels: s.Labels,
+					values: make([]*float64, len(allSeries)),
+				}
+				rowsByKey[key] = row
+				rows = append(rows, row)
+			}
+
+			v := s.Metrics[len(s.Metrics)-1].Value
+			row.values[i] = &v
+		}
+	}
+
+	return rows
+}
+
+func (t *table) transformToRenderable(r *sync.Request, columns []model.TableColumn, rows []*tableRow) (render.Table, error) {
+	queries := t.cfg.Table.Queries
+
+	// Headers.
+	headers := []string{}
+	for _, c := range columns {
+		title := c.Title
+		if title == "" {
+			title = c.Label
+		}
+		headers = append(headers, title)
+	}
+	for i, q := range queries {
+		headers = append(headers, t.valueColumnTitle(r, i, q))
+	}
+
+	// Value formatters.
+	formatters := make([]unit.Formatter, len(queries))
+	for i, q := range queries {
+		f, err := unit.NewUnitFormatter(q.Unit)
+		if err != nil {
+			return render.Table{}, fmt.Errorf("error creating unit formatter: %w", err)
+		}
+		formatters[i] = f
+	}
+
+	t.sortRows(headers, columns, rows)
+
+	// Cells.
+	var colorman widgetColorManager
+	tableRows := [][]render.TableCell{}
+	for _, row := range rows {
+		cells := []render.TableCell{}
+		for _, c := range columns {
+			cells = append(cells, render.TableCell{Text: row.labels[c.Label]})
+		}
+
+		for i, q := range queries {
+			v := row.values[i]
+			if v == nil {
+				cells = append(cells, render.TableCell{Text: tableNoValueText})
+				continue
+			}
+
+			cell := render.TableCell{Text: formatters[i](*v, q.Decimals)}
+			if len(q.Thresholds) > 0 {
+				color, err := colorman.GetColorFromThresholds(q.Thresholds, *v)
+				if err != nil {
+					return render.Table{}, fmt.Errorf("error getting threshold color: %w", err)
+				}
+				cell.Color = color
+			}
+			cells = append(cells, cell)
+		}
+
+		tableRows = append(tableRows, cells)
+	}
+
+	return render.Table{
+		Headers: headers,
+		Rows:    tableRows,
+	}, nil
+}
+
+// valueColumnTitle returns the title of a value column, the title will be the
+// legend of the query.
+func (t *table) valueColumnTitle(r *sync.Request, i int, q model.TableQuery) string {
+	if q.Legend != "" {
+		return r.TemplateData.Render(q.Legend)
+	}
+
+	if len(t.cfg.Table.Queries) == 1 {
+		return defTableValueTitle
+	}
+
+	return fmt.Sprintf("%s #%d", defTableValueTitle, i+1)
+}
+
+// sortRows sorts the rows based on the sort column. If the sort column is not set
+// or is not on the table the rows will be sorted by the label columns. The rows
+// without value on the sort column will be placed at the end.
+func (t *table) sortRows(headers []string, columns []model.TableColumn, rows []*tableRow) {
+	sortCfg := t.cfg.Table.Sort
+
+	// Search the column index.
+	idx := -1
+	for i, h := range headers {
+		if sortCfg.Column == "" {
+			break
+		}
+
+		if h == sortCfg.Column || (i < len(columns) && columns[i].Label == sortCfg.Column) {
+			idx = i
+			break
+		}
+	}
+
+	sort.SliceStable(rows, func(i, j int) bool {
+		a, b := rows[i], rows[j]
+		if sortCfg.Descending {
+			a, b = b, a
+		}
+
+		switch {
+		// Value column.
+		case idx >= len(columns):
+			vIdx := idx - len(columns)
+			va, vb := rows[i].values[vIdx], rows[j].values[vIdx]
+			if va == nil || vb == nil {
+				return va != nil
+			}
+			return *a.values[vIdx] < *b.values[vIdx]
+		// Label column.
+		case idx >= 0:
+			label := columns[idx].Label
+			return a.labels[label] < b.labels[label]
+		default:
+			return rowKey(columns, a.labels) < rowKey(columns, b.labels)
+		}
+	})
+}
+
+// rowKey returns the key that identifies a row using the values of the
+// label columns.
+func rowKey(columns []model.TableColumn, labels map[string]string) string {
+	values := make([]string, 0, len(columns))
+	for _, c := range columns {
+		values = append(values, labels[c.Label])
+	}
+	return strings.Join(values, "\x00")
+}
@@ -0,0 +1,269 @@
+package widget_test
+
+import (
+	"context"
+	"errors"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/mock"
+
+	mcontroller "github.com/slok/grafterm/internal/mocks/controller"
+	mrender "github.com/slok/grafterm/internal/mocks/view/render"
+	"github.com/slok/grafterm/internal/model"
+	"github.com/slok/grafterm/internal/service/log"
+	"github.com/slok/grafterm/internal/service/metric"
+	"github.com/slok/grafterm/internal/view/page/widget"
+	"github.com/slok/grafterm/internal/view/render"
+	"github.com/slok/grafterm/internal/view/sync"
+	"github.com/slok/grafterm/internal/view/template"
+)
+
+func TestTableWidget(t *testing.T) {
+	tests := []struct {
+		name              string
+		cfg               model.Widget
+		syncReq           *sync.Request
+		controllerMetrics map[string][]model.MetricSeries
+		expTable          render.Table
+		expErr            bool
+	}{
+		{
+			name:    "A table without columns should use all the labels as columns sorted by name.",
+			syncReq: &sync.Request{},
+			cfg: model.Widget{
+				WidgetSource: model.WidgetSource{
+					Table: &model.TableWidgetSource{
+						Queries: []model.TableQuery{
+							{Query: model.Query{Expr: "q1"}},
+						},
+					},
+				},
+			},
+			controllerMetrics: map[string][]model.MetricSeries{
+				"q1": {
+					{Labels: map[string]string{"pod": "p2", "code": "200"}, Metrics: []model.Metric{{Value: 2}}},
+					{Labels: map[string]string{"pod": "p1", "code": "200"}, Metrics: []model.Metric{{Value: 1}}},
+				},
+			},
+			expTable: render.Table{
+				Headers: []string{"code", "pod", "Value"},
+				Rows: [][]render.TableCell{
+					{{Text: "200"}, {Text: "p1"}, {Text: "1"}},
+					{{Text: "200"}, {Text: "p2"}, {Text: "2"}},
+				},
+			},
+		},
+		{
+			name: "A table with multiple queries should merge the series with the same label column values on the same row.",
+			syncReq: &sync.Request{
+				TemplateData: template.Data(map[string]interface{}{"kind": "errors"}),
+			},
+			cfg: model.Widget{
+				WidgetSource: model.WidgetSource{
+					Table: &model.TableWidgetSource{
+						Columns: []model.TableColumn{
+							{Label: "pod", Title: "Pod"},
+						},
+						Queries: []model.TableQuery{
+							{Query: model.Query{Expr: "q1", Legend: "Requests"}},
+							{Query: model.Query{Expr: "q2", Legend: "{{ .kind }}"}},
+						},
+					},
+				},
+			},
+			controllerMetrics: map[string][]model.MetricSeries{
+				"q1": {
+					{Labels: map[string]string{"pod": "p1", "code": "200"}, Metrics: []model.Metric{{Value: 10}}},
+					{Labels: map[string]string{"pod": "p2", "code": "200"}, Metrics: []model.Metric{{Value: 20}}},
+				},
+				"q2": {
+					{Labels: map[string]string{"pod": "p2", "code": "500"}, Metrics: []model.Metric{{Value: 5}}},
+				},
+			},
+			expTable: render.Table{
+				Headers: []string{"Pod", "Requests", "errors"},
+				Rows: [][]render.TableCell{
+					{{Text: "p1"}, {Text: "10"}, {Text: "-"}},
+					{{Text: "p2"}, {Text: "20"}, {Text: "5"}},
+				},
+			},
+		},
+		{
+			name:    "A table with units and thresholds should format and color the value cells.",
+			syncReq: &sync.Request{},
+			cfg: model.Widget{
+				WidgetSource: model.WidgetSource{
+					Table: &model.TableWidgetSource{
+						Columns: []model.TableColumn{
+							{Label: "job"},
+						},
+						Queries: []model.TableQuery{
+							{
+								Query:               model.Query{Expr: "q1"},
+								ValueRepresentation: model.ValueRepresentation{Unit: "percent", Decimals: 1},
+								Thresholds: []model.Threshold{
+									{StartValue: 90, Color: "#ff0000"},
+									{StartValue: 0, Color: "#00ff00"},
+								},
+							},
+						},
+					},
+				},
+			},
+			controllerMetrics: map[string][]model.MetricSeries{
+				"q1": {
+					{Labels: map[string]string{"job": "a"}, Metrics: []model.Metric{{Value: 95.12}}},
+					{Labels: map[string]string{"job": "b"}, Metrics: []model.Metric{{Value: 42}}},
+				},
+			},
+			expTable: render.Table{
+				Headers: []string{"job", "Value"},
+				Rows: [][]render.TableCell{
+					{{Text: "a"}, {Text: "95.1%", Color: "#ff0000"}},
+					{{Text: "b"}, {Text: "42.0%", Color: "#00ff00"}},
+				},
+			},
+		},
+		{
+			name:    "A table sorted descending by a value column should sort the rows by value and place the missing values at the end.",
+			syncReq: &sync.Request{},
+			cfg: model.Widget{
+				WidgetSource: model.WidgetSource{
+					Table: &model.TableWidgetSource{
+						Columns: []model.TableColumn{
+							{Label: "pod"},
+						},
+						Queries: []model.TableQuery{
+							{Query: model.Query{Expr: "q1", Legend: "CPU"}},
+							{Query: model.Query{Expr: "q2", Legend: "Memory"}},
+						},
+						Sort: model.TableSort{
+							Column:     "Memory",
+							Descending: true,
+						},
+					},
+				},
+			},
+			controllerMetrics: map[string][]model.MetricSeries{
+				"q1": {
+					{Labels: map[string]string{"pod": "p1"}, Metrics: []model.Metric{{Value: 1}}},
+					{Labels: map[string]string{"pod": "p2"}, Metrics: []model.Metric{{Value: 2}}},
+					{Labels: map[string]string{"pod": "p3"}, Metrics: []model.Metric{{Value: 3}}},
+				},
+				"q2": {
+					{Labels: map[string]string{"pod": "p1"}, Metrics: []model.Metric{{Value: 300}}},
+					{Labels: map[string]string{"pod": "p3"}, Metrics: []model.Metric{{Value: 100}}},
+				},
+			},
+			expTable: render.Table{
+				Headers: []string{"pod", "CPU", "Memory"},
+				Rows: [][]render.TableCell{
+					{{Text: "p1"}, {Text: "1"}, {Text: "300"}},
+					{{Text: "p3"}, {Text: "3"}, {Text: "100"}},
+					{{Text: "p2"}, {Text: "2"}, {Text: "-"}},
+				},
+			},
+		},
+		{
+			name:    "A table sorted by a label column should sort the rows by the label value.",
+			syncReq: &sync.Request{},
+			cfg: model.Widget{
+				WidgetSource: model.WidgetSource{
+					Table: &model.TableWidgetSource{
+						Columns: []model.TableColumn{
+							{Label: "pod"},
+							{Label: "node", Title: "Node"},
+						},
+						Queries: []model.TableQuery{
+							{Query: model.Query{Expr: "q1"}},
+						},
+						Sort: model.TableSort{
+							Column: "node",
+						},
+					},
+				},
+			},
+			controllerMetrics: map[string][]model.MetricSeries{
+				"q1": {
+					{Labels: map[string]string{"pod": "p1", "node": "n2"}, Metrics: []model.Metric{{Value: 1}}},
+					{Labels: map[string]string{"pod": "p2", "node": "n1"}, Metrics: []model.Metric{{Value: 2}}},
+				},
+			},
+			expTable: render.Table{
+				Headers: []string{"pod", "Node", "Value"},
+				Rows: [][]render.TableCell{
+					{{Text: "p2"}, {Text: "n1"}, {Text: "2"}},
+					{{Text: "p1"}, {Text: "n2"}, {Text: "1"}},
+				},
+			},
+		},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			assert := assert.New(t)
+
+			// Mocks.
+			mtable := &mrender.TableWidget{}
+			mtable.On("GetWidgetCfg").Once().Return(test.cfg)
+			mtable.On("Sync", test.expTable).Return(nil)
+
+			mc := &mcontroller.Controller{}
+			for _, q := range test.cfg.Table.Queries {
+				q := q
+				mc.On("GetSingleMetrics", mock.Anything, mock.MatchedBy(func(mq model.Query) bool {
+					return mq.Expr == q.Expr
+				}), mock.Anything).Return(test.controllerMetrics[q.Expr], nil)
+			}
+
+			table := widget.NewTable(mc, mtable, log.Dummy)
+			err := table.Sync(context.Background(), test.syncReq)
+
+			if test.expErr {
+				assert.Error(err)
+			} else if assert.NoError(err) {
+				mc.AssertExpectations(t)
+				mtable.AssertExpectations(t)
+			}
+		})
+	}
+}
+
+func TestTableWidgetState(t *testing.T) {
+	assert := assert.New(t)
+
+	cfg := model.Widget{
+		WidgetSource: model.WidgetSource{
+			Table: &model.TableWidgetSource{
+				Queries: []model.TableQuery{
+					{Query: model.Query{Expr: "q1"}},
+				},
+			},
+		},
+	}
+	errAuth := metric.NewError(metric.ErrorClassAuth, errors.New("wanted error"))
+
+	// Mocks.
+	mtable := &mrender.TableWidget{}
+	mtable.On("GetWidgetCfg").Once().Return(cfg)
+	mtable.On("Sync", mock.Anything).Once().Return(nil)
+	mtable.On("SetState", render.WidgetState{Status: render.WidgetStatusError, Message: "auth error"}).Once().Return(nil)
+	mtable.On("SetState", render.WidgetState{Status: render.WidgetStatusOK}).Once().Return(nil)
+
+	mc := &mcontroller.Controller{}
+	mc.On("GetSingleMetrics", mock.Anything, mock.Anything, mock.Anything).Once().Return(nil, errAuth)
+	mc.On("GetSingleMetrics", mock.Anything, mock.Anything, mock.Anything).Once().Return([]model.MetricSeries{
+		{Labels: map[string]string{"pod": "pod-1"}, Metrics: []model.Metric{{Value: 1}}},
+	}, nil)
+
+	// A failed query should show the error and the next successful one clear it.
+	table := widget.NewTable(mc, mtable, log.Dummy)
+	for i := 0; i < 2; i++ {
+		err := table.Sync(context.Background(), &sync.Request{})
+		assert.NoError(err)
+	}
+
+	mc.AssertExpectations(t)
+	mtable.AssertExpectations(t)
+}
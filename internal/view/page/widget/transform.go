@@ -0,0 +1,184 @@
+package widget
+
+import (
+	"sort"
+
+	"github.com/slok/grafterm/internal/model"
+)
+
+// transformSeries applies the transforms in order to the series, the
+// series are not modified, a copy with the transformed series is returned.
+func transformSeries(series []model.MetricSeries, transforms []model.Transform) []model.MetricSeries {
+	if len(transforms) == 0 {
+		return series
+	}
+
+	res := make([]model.MetricSeries, 0, len(series))
+	for _, s := range series {
+		s.Metrics = append([]model.Metric{}, s.Metrics...)
+		res = append(res, s)
+	}
+
+	for _, t := range transforms {
+		switch {
+		case t.Rate != nil:
+			res = mapMetrics(res, rate)
+		case t.Derivative != nil:
+			res = mapMetrics(res, derivative)
+		case t.MovingAverage != nil:
+			window := t.MovingAverage.Window
+			res = mapMetrics(res, func(ms []model.Metric) []model.Metric { return movingAverage(ms, window) })
+		case t.CumulativeSum != nil:
+			res = mapMetrics(res, cumulativeSum)
+		case t.Scale != nil:
+			factor := t.Scale.Factor
+			res = mapMetrics(res, func(ms []model.Metric) []model.Metric {
+				return mapValues(ms, func(v float64) float64 { return v * factor })
+			})
+		case t.Offset != nil:
+			offset := t.Offset.Value
+			res = mapMetrics(res, func(ms []model.Metric) []model.Metric {
+				return mapValues(ms, func(v float64) float64 { return v + offset })
+			})
+		case t.TopK != nil:
+			res = topk(res, t.TopK.K, true)
+		case t.BottomK != nil:
+			res = topk(res, t.BottomK.K, false)
+		case t.RenameByRegex != nil:
+			res = renameByRegex(res, t.RenameByRegex)
+		}
+	}
+
+	return res
+}
+
+func mapMetrics(series []model.MetricSeries, fn func([]model.Metric) []model.Metric) []model.MetricSeries {
+	for i, s := range series {
+		series[i].Metrics = fn(s.Metrics)
+	}
+	return series
+}
+
+func mapValues(ms []model.Metric, fn func(float64) float64) []model.Metric {
+	for i, m := range ms {
+		ms[i].Value = fn(m.Value)
+	}
+	return ms
+}
+
+// rate returns the per second rate of a counter, if the value decreases
+// the counter has been reset and the value is the increase since the reset.
+// The first metric is dropped.
+func rate(ms []model.Metric) []model.Metric {
+	res := []model.Metric{}
+	for i := 1; i < len(ms); i++ {
+		secs := ms[i].TS.Sub(ms[i-1].TS).Seconds()
+		if secs <= 0 {
+			continue
+		}
+		inc := ms[i].Value - ms[i-1].Value
+		if inc < 0 {
+			inc = ms[i].Value
+		}
+		res = append(res, model.Metric{TS: ms[i].TS, Value: inc / secs})
+	}
+
+	return res
+}
+
+// derivative returns the per second change of the values, the first metric
+// is dropped.
+func derivative(ms []model.Metric) []model.Metric {
+	res := []model.Metric{}
+	for i := 1; i < len(ms); i++ {
+		secs := ms[i].TS.Sub(ms[i-1].TS).Seconds()
+		if secs <= 0 {
+			continue
+		}
+		res = append(res, model.Metric{TS: ms[i].TS, Value: (ms[i].Value - ms[i-1].Value) / secs})
+	}
+
+	return res
+}
+
+// movingAverage returns the average of the window of the latest values,
+// the first values use the available values.
+func movingAverage(ms []model.Metric, window int) []model.Metric {
+	res := make([]model.Metric, 0, len(ms))
+	sum := 0.0
+	for i, m := range ms {
+		sum += m.Value
+		if i >= window {
+			sum -= ms[i-window].Value
+		}
+		n := i + 1
+		if n > window {
+			n = window
+		}
+		res = append(res, model.Metric{TS: m.TS, Value: sum / float64(n)})
+	}
+
+	return res
+}
+
+// cumulativeSum returns the sum of the values until every metric.
+func cumulativeSum(ms []model.Metric) []model.Metric {
+	sum := 0.0
+	return mapValues(ms, func(v float64) float64 {
+		sum += v
+		return sum
+	})
+}
+
+// topk returns the k series with the highest average, or the lowest if
+// highest is false.
+func topk(series []model.MetricSeries, k int, highest bool) []model.MetricSeries {
+	if len(series) <= k {
+		return series
+	}
+
+	avgs := make(map[string]float64, len(series))
+	for _, s := range series {
+		sum := 0.0
+		for _, m := range s.Metrics {
+			sum += m.Value
+		}
+		if len(s.Metrics) > 0 {
+			avgs[s.ID] = sum / float64(len(s.Metrics))
+		}
+	}
+
+	sort.SliceStable(series, func(i, j int) bool {
+		if highest {
+			return avgs[series[i].ID] > avgs[series[j].ID]
+		}
+		return avgs[series[i].ID] < avgs[series[j].ID]
+	})
+
+	return series[:k]
+}
+
+// renameByRegex replaces the IDs of the series that match the regex.
+func renameByRegex(series []model.MetricSeries, t *model.RenameByRegexTransform) []model.MetricSeries {
+	if t.CompiledRegex == nil {
+		return series
+	}
+
+	for i, s := range series {
+		if t.CompiledRegex.MatchString(s.ID) {
+			series[i].ID = t.CompiledRegex.ReplaceAllString(s.ID, t.Replacement)
+		}
+	}
+
+	return series
+}
+
+// renamesSeries returns true if any of the transforms renames the series.
+func renamesSeries(transforms []model.Transform) bool {
+	for _, t := range transforms {
+		if t.RenameByRegex != nil {
+			return true
+		}
+	}
+	return false
+}
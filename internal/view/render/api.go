@@ -2,6 +2,8 @@ package render
 
 import (
 	"context"
+	"fmt"
+	"time"
 
 	"github.com/slok/grafterm/internal/model"
 	"github.com/slok/grafterm/internal/view/grid"
@@ -19,10 +21,60 @@ type Widget interface {
 	GetWidgetCfg() model.Widget
 }
 
+// WidgetStatus is the status of the data rendered by a widget.
+type WidgetStatus int
+
+const (
+	// WidgetStatusOK is the status of a widget that rendered the data of
+	// all its queries.
+	WidgetStatusOK WidgetStatus = iota
+	// WidgetStatusError is the status of a widget with failed queries.
+	WidgetStatusError
+	// WidgetStatusTimeout is the status of a widget with timed out queries.
+	WidgetStatusTimeout
+	// WidgetStatusNoData is the status of a widget whose queries didn't
+	// return any data.
+	WidgetStatusNoData
+)
+
+// WidgetState is the state of the data rendered by a widget.
+type WidgetState struct {
+	Status WidgetStatus
+	// Message is the short description of the problem (e.g: timeout, no data...).
+	Message string
+	// StaleSince is the time of the latest sync that rendered data when the
+	// widget is showing old data, zero if the widget is not stale or never
+	// rendered data.
+	StaleSince time.Time
+}
+
+// String returns the description of the state to be rendered with the
+// widget, empty if the state is OK.
+func (w WidgetState) String() string {
+	if w.Status == WidgetStatusOK && w.Message == "" {
+		return ""
+	}
+
+	if w.StaleSince.IsZero() {
+		return w.Message
+	}
+
+	return fmt.Sprintf("%s, stale since %s", w.Message, w.StaleSince.Local().Format("15:04:05"))
+}
+
+// StateWidget knows how to render the state of the data of a widget, the
+// widgets show the failures of their queries and since when they are stale.
+type StateWidget interface {
+	// SetState renders the state of the widget data, an OK state clears
+	// the previous state.
+	SetState(state WidgetState) error
+}
+
 // GaugeWidget knows how to render a Gauge kind widget that can be in percent
 // or not and supports color changes.
 type GaugeWidget interface {
 	Widget
+	StateWidget
 	Sync(isPercent bool, value float64) error
 	SetColor(hexColor string) error
 }
@@ -31,6 +83,7 @@ type GaugeWidget interface {
 // and supports changing color.
 type SinglestatWidget interface {
 	Widget
+	StateWidget
 	Sync(text string) error
 	SetColor(hexColor string) error
 }
@@ -55,6 +108,7 @@ type Series struct {
 // a two axis space using lines, dots... depending on the render implementation.
 type GraphWidget interface {
 	Widget
+	StateWidget
 	// GetGraphPointQuantity will return the number of points the graph can display
 	// on the X axis at this given moment (is a best effort, when updating the graph
 	// could have changed the size).
@@ -62,3 +116,121 @@ type GraphWidget interface {
 	// Sync will sync the different series on the graph.
 	Sync(series []Series) error
 }
+
+// ValueRepresentationGraphWidget is a GraphWidget that can change the
+// representation of the Y axis values after being created, this is used to
+// set the representation inferred from the metrics when the widget
+// configuration doesn't set one.
+type ValueRepresentationGraphWidget interface {
+	GraphWidget
+	// SetYAxisValueRepresentation sets the representation of the Y axis values.
+	SetYAxisValueRepresentation(vr model.ValueRepresentation) error
+}
+
+// TableCell is a cell of a table.
+type TableCell struct {
+	Text string
+	// Color is the color of the cell text, if empty it will
+	// use the default color.
+	Color string
+}
+
+// Table is the data of a table that can be rendered.
+type Table struct {
+	// Headers are the titles of the table columns.
+	Headers []string
+	// Rows are the rows of the table, each row has one cell per
+	// column header.
+	Rows [][]TableCell
+}
+
+// TableWidget knows how to render a Table kind widget that renders rows of
+// cells in columns and supports changing the color of the cells.
+type TableWidget interface {
+	Widget
+	StateWidget
+	// Sync will sync the table rows and columns.
+	Sync(table Table) error
+}
+
+// Variable is a dashboard variable that can be rendered.
+type Variable struct {
+	Name string
+	// Value is the value of the variable.
+	Value string
+	// Options are the values that can be selected, if the variable doesn't
+	// have options it can't be selected.
+	Options []string
+	// Selected are the selected options.
+	Selected []string
+	// Multi means that multiple options can be selected at the same time.
+	Multi bool
+}
+
+// VariablesWidget knows how to render the variables of a dashboard and let
+// the user select the values of the variables.
+type VariablesWidget interface {
+	// Sync renders the variables.
+	Sync(vars []Variable) error
+	// OnSelect sets the function that will be called when the user selects
+	// the values of a variable.
+	OnSelect(f func(name string, values []string) error)
+}
+
+// VariablesRenderer is a Renderer that knows how to render the variables of
+// a dashboard.
+type VariablesRenderer interface {
+	Renderer
+	// LoadVariables returns the widget that will render the variables, it
+	// needs to be called before loading the dashboard.
+	LoadVariables(ctx context.Context) (VariablesWidget, error)
+}
+
+// StatusWidget knows how to render the health status of the datasources.
+type StatusWidget interface {
+	// Sync renders the health of the datasources.
+	Sync(health []model.DatasourceHealth) error
+}
+
+// StatusRenderer is a Renderer that knows how to render the health status of
+// the datasources.
+type StatusRenderer interface {
+	Renderer
+	// LoadStatus returns the widget that will render the datasources health,
+	// it needs to be called before loading the dashboard.
+	LoadStatus(ctx context.Context) (StatusWidget, error)
+}
+
+// TimeRangeAction is the kind of change that can be made on the time range.
+type TimeRangeAction int
+
+const (
+	// TimeRangeZoomIn halves the time range.
+	TimeRangeZoomIn TimeRangeAction = iota
+	// TimeRangeZoomOut doubles the time range.
+	TimeRangeZoomOut
+	// TimeRangePanBackward moves the time range half of its duration to the past.
+	TimeRangePanBackward
+	// TimeRangePanForward moves the time range half of its duration to the future.
+	TimeRangePanForward
+	// TimeRangePreset sets a live time range with the duration of the change.
+	TimeRangePreset
+	// TimeRangeLive sets a live time range maintaining the current duration.
+	TimeRangeLive
+)
+
+// TimeRangeChange is a change on the time range requested by the user.
+type TimeRangeChange struct {
+	Action TimeRangeAction
+	// Duration is the duration of the time range, only used by presets.
+	Duration time.Duration
+}
+
+// TimeRangeRenderer is a Renderer that lets the user change the time range
+// of the dashboard.
+type TimeRangeRenderer interface {
+	Renderer
+	// OnTimeRangeChange sets the function that will be called when the user
+	// wants to change the time range.
+	OnTimeRangeChange(f func(change TimeRangeChange))
+}
@@ -0,0 +1,173 @@
+package snapshot
+
+import (
+	"bufio"
+	"fmt"
+	"io"
+	"strings"
+	"unicode/utf8"
+
+	"github.com/lucasb-eyer/go-colorful"
+)
+
+// area is a rectangle of the canvas.
+type area struct {
+	x, y, w, h int
+}
+
+// inner returns the area inside the borders of the area.
+func (a area) inner() area {
+	in := area{x: a.x + 1, y: a.y + 1, w: a.w - 2, h: a.h - 2}
+	if in.w < 0 {
+		in.w = 0
+	}
+	if in.h < 0 {
+		in.h = 0
+	}
+	return in
+}
+
+// cell is a character of the canvas with its color, an empty
+// color means the default color of the terminal.
+type cell struct {
+	r     rune
+	color string
+}
+
+// canvas is a fixed size characters buffer.
+type canvas struct {
+	w, h  int
+	cells [][]cell
+}
+
+func newCanvas(w, h int) *canvas {
+	cells := make([][]cell, h)
+	for y := range cells {
+		cells[y] = make([]cell, w)
+		for x := range cells[y] {
+			cells[y][x] = cell{r: ' '}
+		}
+	}
+
+	return &canvas{
+		w:     w,
+		h:     h,
+		cells: cells,
+	}
+}
+
+// set sets a character on the canvas, the characters outside
+// the canvas are ignored.
+func (c *canvas) set(x, y int, r rune, color string) {
+	if x < 0 || y < 0 || x >= c.w || y >= c.h {
+		return
+	}
+	c.cells[y][x] = cell{r: r, color: color}
+}
+
+// get returns the character of the canvas.
+func (c *canvas) get(x, y int) rune {
+	if x < 0 || y < 0 || x >= c.w || y >= c.h {
+		return 0
+	}
+	return c.cells[y][x].r
+}
+
+// text writes a text on the canvas trimmed to the max width and returns
+// the number of written characters.
+func (c *canvas) text(x, y, maxWidth int, txt, color string) int {
+	n := 0
+	for _, r := range txt {
+		if n >= maxWidth {
+			break
+		}
+		c.set(x+n, y, r, color)
+		n++
+	}
+	return n
+}
+
+// textCenter writes a text centered on the line of the area.
+func (c *canvas) textCenter(a area, y int, txt, color string) {
+	l := utf8.RuneCountInString(txt)
+	x := a.x
+	if l < a.w {
+		x += (a.w - l) / 2
+	}
+	c.text(x, y, a.w, txt, color)
+}
+
+// box draws a box with a light line around the area and the title on
+// the top border.
+func (c *canvas) box(a area, title string) {
+	if a.w < 2 || a.h < 2 {
+		return
+	}
+
+	right, bottom := a.x+a.w-1, a.y+a.h-1
+	for x := a.x + 1; x < right; x++ {
+		c.set(x, a.y, '─', "")
+		c.set(x, bottom, '─', "")
+	}
+	for y := a.y + 1; y < bottom; y++ {
+		c.set(a.x, y, '│', "")
+		c.set(right, y, '│', "")
+	}
+	c.set(a.x, a.y, '┌', "")
+	c.set(right, a.y, '┐', "")
+	c.set(a.x, bottom, '└', "")
+	c.set(right, bottom, '┘', "")
+
+	c.text(a.x+1, a.y, a.w-2, title, "")
+}
+
+// write writes the canvas lines, if ansi is enabled the colors
+// will be written using ANSI 24 bit color escape sequences.
+func (c *canvas) write(w io.Writer, ansi bool) error {
+	bw := bufio.NewWriter(w)
+	for _, line := range c.cells {
+		// Ignore the trailing spaces.
+		end := len(line)
+		for end > 0 && line[end-1].r == ' ' {
+			end--
+		}
+
+		var sb strings.Builder
+		current := ""
+		for _, cl := range line[:end] {
+			if ansi && cl.color != current {
+				sb.WriteString(ansiColor(cl.color))
+				current = cl.color
+			}
+			sb.WriteRune(cl.r)
+		}
+		if ansi && current != "" {
+			sb.WriteString(ansiReset)
+		}
+
+		_, err := fmt.Fprintln(bw, sb.String())
+		if err != nil {
+			return err
+		}
+	}
+
+	return bw.Flush()
+}
+
+const ansiReset = "\x1b[0m"
+
+// ansiColor returns the ANSI escape sequence of a hex color, an
+// empty or invalid color resets to the default color.
+func ansiColor(hex string) string {
+	if hex == "" {
+		return ansiReset
+	}
+
+	c, err := colorful.Hex(hex)
+	if err != nil {
+		return ansiReset
+	}
+	r, g, b := c.RGB255()
+
+	return fmt.Sprintf("\x1b[38;2;%d;%d;%dm", r, g, b)
+}
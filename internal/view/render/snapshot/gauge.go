@@ -0,0 +1,108 @@
+package snapshot
+
+import (
+	"fmt"
+	"math"
+	"strings"
+	"sync"
+	"unicode/utf8"
+
+	"github.com/slok/grafterm/internal/model"
+	"github.com/slok/grafterm/internal/view/render"
+)
+
+const (
+	gaugeFilledChar = '█'
+	gaugeEmptyChar  = '░'
+	gaugeEmptyColor = "#585858"
+)
+
+// gauge satisfies render.GaugeWidget interface.
+// It renders the gauge as a horizontal bar.
+type gauge struct {
+	cfg  model.Widget
+	area area
+
+	mu        sync.Mutex
+	synced    bool
+	isPercent bool
+	value     float64
+	color     string
+	state     render.WidgetState
+}
+
+func newGauge(cfg model.Widget, a area) *gauge {
+	return &gauge{
+		cfg:  cfg,
+		area: a,
+	}
+}
+
+func (g *gauge) GetWidgetCfg() model.Widget {
+	return g.cfg
+}
+
+func (g *gauge) Sync(isPercent bool, value float64) error {
+	g.mu.Lock()
+	defer g.mu.Unlock()
+	g.synced = true
+	g.isPercent = isPercent
+	g.value = value
+	return nil
+}
+
+func (g *gauge) SetColor(hexColor string) error {
+	g.mu.Lock()
+	defer g.mu.Unlock()
+	g.color = hexColor
+	return nil
+}
+
+func (g *gauge) SetState(state render.WidgetState) error {
+	g.mu.Lock()
+	defer g.mu.Unlock()
+	g.state = state
+	return nil
+}
+
+func (g *gauge) draw(c *canvas) {
+	g.mu.Lock()
+	defer g.mu.Unlock()
+
+	c.stateBox(g.area, g.cfg.Title, g.state)
+	in := g.area.inner()
+	if !g.synced {
+		c.stateCenter(in, g.state)
+		return
+	}
+
+	// Get the filled ratio of the bar.
+	var ratio float64
+	var label string
+	if g.isPercent {
+		ratio = g.value / 100
+		label = fmt.Sprintf("%d%%", int(g.value))
+	} else {
+		max := float64(g.cfg.Gauge.Max)
+		if max < g.value {
+			max = g.value
+		}
+		if max != 0 {
+			ratio = g.value / max
+		}
+		label = fmt.Sprintf("%d/%d", int(g.value), int(max))
+	}
+	ratio = math.Max(0, math.Min(1, ratio))
+
+	barWidth := in.w - utf8.RuneCountInString(label) - 1
+	if barWidth <= 0 {
+		c.textCenter(in, in.y+in.h/2, label, g.color)
+		return
+	}
+	filled := int(math.Round(ratio * float64(barWidth)))
+
+	y := in.y + in.h/2
+	c.text(in.x, y, filled, strings.Repeat(string(gaugeFilledChar), filled), g.color)
+	c.text(in.x+filled, y, barWidth-filled, strings.Repeat(string(gaugeEmptyChar), barWidth-filled), gaugeEmptyColor)
+	c.text(in.x+barWidth+1, y, in.w-barWidth-1, label, g.color)
+}
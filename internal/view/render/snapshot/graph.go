@@ -0,0 +1,381 @@
+package snapshot
+
+import (
+	"math"
+	"sync"
+	"unicode/utf8"
+
+	"github.com/slok/grafterm/internal/model"
+	"github.com/slok/grafterm/internal/service/unit"
+	"github.com/slok/grafterm/internal/view/render"
+)
+
+const (
+	legendCharacter   = `⠤⠤`
+	legendRightPerc   = 20
+	yLabelsWidth      = 9
+	axesColor         = "#808080"
+	xAxisLabelsColor  = "#a8a8a8"
+	brailleBase       = 0x2800
+	brailleDotsWidth  = 2
+	brailleDotsHeight = 4
+)
+
+// brailleDots are the bits of the braille dots by position [x][y].
+var brailleDots = [brailleDotsWidth][brailleDotsHeight]rune{
+	{0x01, 0x02, 0x04, 0x40},
+	{0x08, 0x10, 0x20, 0x80},
+}
+
+// graph satisfies render.GraphWidget interface.
+// It renders the series as lines using braille characters.
+type graph struct {
+	cfg  model.Widget
+	area area
+
+	mu        sync.Mutex
+	formatter func(float64) string
+	series    []render.Series
+	state     render.WidgetState
+}
+
+func newGraph(cfg model.Widget, a area) (*graph, error) {
+	f, err := valueFormatter(cfg.Graph.Visualization.YAxis.ValueRepresentation)
+	if err != nil {
+		return nil, err
+	}
+
+	return &graph{
+		cfg:       cfg,
+		area:      a,
+		formatter: f,
+	}, nil
+}
+
+// valueFormatter returns the Y axis values formatter based on the value
+// representation.
+func valueFormatter(vr model.ValueRepresentation) (func(float64) string, error) {
+	axisUnit := vr.Unit
+	axisDecimals := vr.Decimals
+
+	f, err := unit.NewUnitFormatter(axisUnit)
+	if err != nil {
+		return nil, err
+	}
+
+	// If units by default use default decimals.
+	if axisUnit == "" && axisDecimals == 0 {
+		axisDecimals = 2
+	}
+
+	return func(value float64) string {
+		return f(value, axisDecimals)
+	}, nil
+}
+
+func (g *graph) GetWidgetCfg() model.Widget {
+	return g.cfg
+}
+
+func (g *graph) SetYAxisValueRepresentation(vr model.ValueRepresentation) error {
+	f, err := valueFormatter(vr)
+	if err != nil {
+		return err
+	}
+
+	g.mu.Lock()
+	defer g.mu.Unlock()
+	g.formatter = f
+	return nil
+}
+
+func (g *graph) Sync(series []render.Series) error {
+	g.mu.Lock()
+	defer g.mu.Unlock()
+	g.series = series
+	return nil
+}
+
+func (g *graph) SetState(state render.WidgetState) error {
+	g.mu.Lock()
+	defer g.mu.Unlock()
+	g.state = state
+	return nil
+}
+
+func (g *graph) GetGraphPointQuantity() int {
+	return g.plotArea(g.chartArea()).w * brailleDotsWidth
+}
+
+// chartArea returns the area of the widget used by the chart (the
+// rest is used by the legend if on the right side).
+func (g *graph) chartArea() area {
+	in := g.area.inner()
+	legend := g.cfg.Graph.Visualization.Legend
+	if !legend.Disable && legend.RightSide {
+		in.w -= in.w * legendRightPerc / 100
+	}
+	return in
+}
+
+// plotArea returns the area where the series are drawn inside the
+// chart area (without the axes and their labels).
+func (g *graph) plotArea(ch area) area {
+	p := area{
+		x: ch.x + yLabelsWidth + 1,
+		y: ch.y,
+		w: ch.w - yLabelsWidth - 1,
+		h: ch.h - 2,
+	}
+	if p.w < 0 {
+		p.w = 0
+	}
+	if p.h < 0 {
+		p.h = 0
+	}
+	return p
+}
+
+func (g *graph) draw(c *canvas) {
+	g.mu.Lock()
+	defer g.mu.Unlock()
+
+	c.stateBox(g.area, g.cfg.Title, g.state)
+
+	ch := g.chartArea()
+	in := g.area.inner()
+
+	// Draw the legend and get the space left for the chart.
+	legend := g.cfg.Graph.Visualization.Legend
+	switch {
+	case legend.Disable:
+	case legend.RightSide:
+		g.drawRightLegend(c, area{x: ch.x + ch.w + 1, y: in.y, w: in.w - ch.w - 1, h: in.h})
+	default:
+		lines := g.drawBottomLegend(c, in)
+		ch.h -= lines
+	}
+
+	p := g.plotArea(ch)
+	if p.w <= 0 || p.h <= 0 {
+		return
+	}
+
+	min, max, ok := g.valuesRange()
+	g.drawAxes(c, ch, p, min, max, ok)
+	if !ok {
+		c.stateCenter(p, g.state)
+		return
+	}
+
+	// Draw the lines of the series using braille dots.
+	dotsW, dotsH := p.w*brailleDotsWidth, p.h*brailleDotsHeight
+	dots := make([][]rune, p.h)
+	colors := make([][]string, p.h)
+	for y := range dots {
+		dots[y] = make([]rune, p.w)
+		colors[y] = make([]string, p.w)
+	}
+	setDot := func(x, y int, color string) {
+		if x < 0 || y < 0 || x >= dotsW || y >= dotsH {
+			return
+		}
+		cx, cy := x/brailleDotsWidth, y/brailleDotsHeight
+		dots[cy][cx] |= brailleDots[x%brailleDotsWidth][y%brailleDotsHeight]
+		colors[cy][cx] = color
+	}
+
+	for _, s := range g.series {
+		toDot := func(i int, v render.Value) (int, int) {
+			x := 0
+			if len(s.Values) > 1 {
+				x = i * (dotsW - 1) / (len(s.Values) - 1)
+			}
+			y := dotsH - 1 - int(math.Round((float64(v)-min)/(max-min)*float64(dotsH-1)))
+			return x, y
+		}
+
+		for i, v := range s.Values {
+			if !drawable(v) {
+				continue
+			}
+			x0, y0 := toDot(i, *v)
+			if i+1 >= len(s.Values) || !drawable(s.Values[i+1]) {
+				setDot(x0, y0, s.Color)
+				continue
+			}
+			x1, y1 := toDot(i+1, *s.Values[i+1])
+			drawLine(x0, y0, x1, y1, func(x, y int) { setDot(x, y, s.Color) })
+		}
+	}
+
+	for y := range dots {
+		for x, d := range dots[y] {
+			if d != 0 {
+				c.set(p.x+x, p.y+y, brailleBase+d, colors[y][x])
+			}
+		}
+	}
+}
+
+// valuesRange returns the min and max values of all the series.
+func (g *graph) valuesRange() (min, max float64, ok bool) {
+	min, max = math.Inf(1), math.Inf(-1)
+	for _, s := range g.series {
+		for _, v := range s.Values {
+			if !drawable(v) {
+				continue
+			}
+			min = math.Min(min, float64(*v))
+			max = math.Max(max, float64(*v))
+			ok = true
+		}
+	}
+
+	if ok && min == max {
+		min--
+		max++
+	}
+
+	return min, max, ok
+}
+
+// drawable returns true if the value can be drawn, the missing, NaN
+// (e.g Prometheus 0/0 ratios) and infinite values are not drawn.
+func drawable(v *render.Value) bool {
+	return v != nil && !math.IsNaN(float64(*v)) && !math.IsInf(float64(*v), 0)
+}
+
+// drawAxes draws the axes with the labels of the min, middle and
+// max values and the labels of the X axis.
+func (g *graph) drawAxes(c *canvas, ch, p area, min, max float64, hasValues bool) {
+	axisX, axisY := p.x-1, p.y+p.h
+	for y := p.y; y < axisY; y++ {
+		c.set(axisX, y, '│', axesColor)
+	}
+	c.set(axisX, axisY, '└', axesColor)
+	for x := p.x; x < p.x+p.w; x++ {
+		c.set(x, axisY, '─', axesColor)
+	}
+
+	if !hasValues {
+		return
+	}
+
+	// Y labels aligned to the right of the axis.
+	yLabel := func(y int, v float64) {
+		l := g.formatter(v)
+		n := utf8.RuneCountInString(l)
+		x := axisX - n
+		if x < ch.x {
+			x = ch.x
+		}
+		c.text(x, y, axisX-x, l, "")
+	}
+	yLabel(p.y, max)
+	if p.h > 2 {
+		yLabel(p.y+(p.h-1)/2, min+(max-min)/2)
+	}
+	yLabel(p.y+p.h-1, min)
+
+	// X labels at the start, the middle and the end.
+	if len(g.series) == 0 || len(g.series[0].XLabels) == 0 {
+		return
+	}
+	labels := g.series[0].XLabels
+	labelsY := axisY + 1
+	first, last := labels[0], labels[len(labels)-1]
+	c.text(p.x, labelsY, p.w, first, xAxisLabelsColor)
+	lastX := p.x + p.w - utf8.RuneCountInString(last)
+	if lastX > p.x+utf8.RuneCountInString(first) {
+		c.text(lastX, labelsY, p.x+p.w-lastX, last, xAxisLabelsColor)
+	}
+	middle := labels[len(labels)/2]
+	middleX := p.x + (p.w-utf8.RuneCountInString(middle))/2
+	if middleX > p.x+utf8.RuneCountInString(first)+1 && middleX+utf8.RuneCountInString(middle)+1 < lastX {
+		c.text(middleX, labelsY, utf8.RuneCountInString(middle), middle, xAxisLabelsColor)
+	}
+}
+
+// drawBottomLegend draws the legend at the bottom of the area and
+// returns the number of lines used.
+func (g *graph) drawBottomLegend(c *canvas, a area) int {
+	// Split the legend entries in lines.
+	lines := [][]render.Series{{}}
+	width := 0
+	for _, s := range g.series {
+		l := utf8.RuneCountInString(legendCharacter) + 1 + utf8.RuneCountInString(s.Label) + 2
+		if width > 0 && width+l > a.w {
+			lines = append(lines, []render.Series{})
+			width = 0
+		}
+		lines[len(lines)-1] = append(lines[len(lines)-1], s)
+		width += l
+	}
+
+	// Don't use more than a third of the widget.
+	maxLines := a.h / 3
+	if maxLines < 1 {
+		maxLines = 1
+	}
+	if len(lines) > maxLines {
+		lines = lines[:maxLines]
+	}
+
+	y := a.y + a.h - len(lines)
+	for i, line := range lines {
+		x := a.x
+		for _, s := range line {
+			x += c.text(x, y+i, a.x+a.w-x, legendCharacter+" "+s.Label+"  ", s.Color)
+		}
+	}
+
+	return len(lines)
+}
+
+// drawRightLegend draws the legend on the area, one series per line.
+func (g *graph) drawRightLegend(c *canvas, a area) {
+	for i, s := range g.series {
+		if i >= a.h {
+			return
+		}
+		c.text(a.x, a.y+i, a.w, legendCharacter+" "+s.Label, s.Color)
+	}
+}
+
+// drawLine calls set for every point of the line between the two points
+// using Bresenham's line algorithm.
+func drawLine(x0, y0, x1, y1 int, set func(x, y int)) {
+	dx, dy := abs(x1-x0), -abs(y1-y0)
+	sx, sy := 1, 1
+	if x0 > x1 {
+		sx = -1
+	}
+	if y0 > y1 {
+		sy = -1
+	}
+
+	e := dx + dy
+	for {
+		set(x0, y0)
+		if x0 == x1 && y0 == y1 {
+			return
+		}
+		e2 := 2 * e
+		if e2 >= dy {
+			e += dy
+			x0 += sx
+		}
+		if e2 <= dx {
+			e += dx
+			y0 += sy
+		}
+	}
+}
+
+func abs(v int) int {
+	if v < 0 {
+		return -v
+	}
+	return v
+}
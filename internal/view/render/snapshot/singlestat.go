@@ -0,0 +1,65 @@
+package snapshot
+
+import (
+	"sync"
+
+	"github.com/slok/grafterm/internal/model"
+	"github.com/slok/grafterm/internal/view/render"
+)
+
+// singlestat satisfies render.SinglestatWidget interface.
+// It renders the text centered on the widget.
+type singlestat struct {
+	cfg  model.Widget
+	area area
+
+	mu    sync.Mutex
+	text  string
+	color string
+	state render.WidgetState
+}
+
+func newSinglestat(cfg model.Widget, a area) *singlestat {
+	return &singlestat{
+		cfg:  cfg,
+		area: a,
+	}
+}
+
+func (s *singlestat) GetWidgetCfg() model.Widget {
+	return s.cfg
+}
+
+func (s *singlestat) Sync(text string) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.text = text
+	return nil
+}
+
+func (s *singlestat) SetColor(hexColor string) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.color = hexColor
+	return nil
+}
+
+func (s *singlestat) SetState(state render.WidgetState) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.state = state
+	return nil
+}
+
+func (s *singlestat) draw(c *canvas) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	c.stateBox(s.area, s.cfg.Title, s.state)
+	in := s.area.inner()
+	if s.text == "" {
+		c.stateCenter(in, s.state)
+		return
+	}
+	c.textCenter(in, in.y+in.h/2, s.text, s.color)
+}
@@ -0,0 +1,177 @@
+package snapshot
+
+import (
+	"context"
+	"fmt"
+	"io"
+
+	"github.com/slok/grafterm/internal/model"
+	"github.com/slok/grafterm/internal/service/log"
+	graftermgrid "github.com/slok/grafterm/internal/view/grid"
+	"github.com/slok/grafterm/internal/view/render"
+)
+
+const (
+	defWidth  = 160
+	defHeight = 50
+	// variablesHeight is the number of lines used by the variables.
+	variablesHeight = 1
+)
+
+// drawer is an internal interface that all widgets from the snapshot
+// render engine implementation need to implement, this way the renderer
+// can draw the widgets on the canvas.
+type drawer interface {
+	draw(c *canvas)
+}
+
+// Config is the configuration of the Renderer.
+type Config struct {
+	// Width is the number of columns of the snapshot.
+	Width int
+	// Height is the number of lines of the snapshot.
+	Height int
+	// ANSI will render the colors using ANSI escape sequences.
+	ANSI   bool
+	Logger log.Logger
+}
+
+func (c *Config) defaults() {
+	if c.Width <= 0 {
+		c.Width = defWidth
+	}
+	if c.Height <= 0 {
+		c.Height = defHeight
+	}
+	if c.Logger == nil {
+		c.Logger = log.Dummy
+	}
+}
+
+// Renderer satisfies render.Renderer interface. It renders the dashboard
+// in a fixed size characters buffer that can be written as text, this is
+// useful to render dashboards without a terminal (e.g CI, tickets...).
+type Renderer struct {
+	cfg       Config
+	widgets   []render.Widget
+	variables *variables
+	logger    log.Logger
+}
+
+// NewRenderer returns a new snapshot renderer.
+func NewRenderer(cfg Config) *Renderer {
+	cfg.defaults()
+
+	return &Renderer{
+		cfg:    cfg,
+		logger: cfg.Logger,
+	}
+}
+
+// LoadDashboard satisfies render.Renderer interface.
+func (r *Renderer) LoadDashboard(_ context.Context, gr *graftermgrid.Grid) ([]render.Widget, error) {
+	a := area{w: r.cfg.Width, h: r.cfg.Height}
+
+	// If we have variables place them on top of the dashboard.
+	if r.variables != nil {
+		r.variables.area = area{w: a.w, h: variablesHeight}
+		a.y += variablesHeight
+		a.h -= variablesHeight
+	}
+
+	r.widgets = []render.Widget{}
+	r.layoutRows(gr.Rows, a)
+
+	return r.widgets, nil
+}
+
+// LoadVariables satisfies render.VariablesRenderer interface.
+func (r *Renderer) LoadVariables(_ context.Context) (render.VariablesWidget, error) {
+	r.variables = &variables{}
+	return r.variables, nil
+}
+
+// Close satisfies render.Renderer interface.
+func (r *Renderer) Close() {}
+
+// Render writes the current state of the dashboard.
+func (r *Renderer) Render(w io.Writer) error {
+	c := newCanvas(r.cfg.Width, r.cfg.Height)
+
+	if r.variables != nil {
+		r.variables.draw(c)
+	}
+
+	for _, widget := range r.widgets {
+		widget.(drawer).draw(c)
+	}
+
+	return c.write(w, r.cfg.ANSI)
+}
+
+// layoutRows places the rows on the area, the rows can be nested inside
+// elements so this is called recursively by the elements that have rows.
+func (r *Renderer) layoutRows(rows []*graftermgrid.Row, a area) {
+	filled := 0
+	for i, row := range rows {
+		start := a.y + a.h*filled/100
+		filled += row.PercentSize
+		end := a.y + a.h*filled/100
+		if i == len(rows)-1 || end > a.y+a.h {
+			end = a.y + a.h
+		}
+		if end <= start {
+			r.logger.Warnf("ignoring grid row, there is no space left on the grid")
+			continue
+		}
+
+		r.layoutElements(row.Elements, area{x: a.x, y: start, w: a.w, h: end - start})
+	}
+}
+
+// layoutElements creates the rendering widgets of the row and places them
+// on the row area.
+func (r *Renderer) layoutElements(elements []*graftermgrid.Element, a area) {
+	filled := 0
+	for i, element := range elements {
+		start := a.x + a.w*filled/100
+		filled += element.PercentSize
+		end := a.x + a.w*filled/100
+		if i == len(elements)-1 || end > a.x+a.w {
+			end = a.x + a.w
+		}
+		if end <= start {
+			r.logger.Warnf("ignoring grid element, there is no space left on the row")
+			continue
+		}
+		elementArea := area{x: start, y: a.y, w: end - start, h: a.h}
+
+		switch {
+		case element.Empty:
+		case len(element.Rows) > 0:
+			r.layoutRows(element.Rows, elementArea)
+		default:
+			widget, err := r.newWidget(element.Widget, elementArea)
+			if err != nil {
+				r.logger.Errorf("error creating widget: %s", err)
+				continue
+			}
+			r.widgets = append(r.widgets, widget)
+		}
+	}
+}
+
+func (r *Renderer) newWidget(cfg model.Widget, a area) (render.Widget, error) {
+	switch {
+	case cfg.Gauge != nil:
+		return newGauge(cfg, a), nil
+	case cfg.Singlestat != nil:
+		return newSinglestat(cfg, a), nil
+	case cfg.Graph != nil:
+		return newGraph(cfg, a)
+	case cfg.Table != nil:
+		return newTable(cfg, a), nil
+	}
+
+	return nil, fmt.Errorf("%s widget kind is not supported", cfg.Title)
+}
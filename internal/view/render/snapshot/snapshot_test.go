@@ -0,0 +1,184 @@
+package snapshot_test
+
+import (
+	"bytes"
+	"context"
+	"math"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/slok/grafterm/internal/model"
+	"github.com/slok/grafterm/internal/view/grid"
+	"github.com/slok/grafterm/internal/view/render"
+	"github.com/slok/grafterm/internal/view/render/snapshot"
+)
+
+func TestRendererRender(t *testing.T) {
+	tests := []struct {
+		name      string
+		cfg       snapshot.Config
+		widgets   []model.Widget
+		variables []render.Variable
+		sync      func(t *testing.T, ws []render.Widget)
+		expOut    string
+	}{
+		{
+			name: "Singlestat, gauge and table widgets should be rendered on their grid positions.",
+			cfg:  snapshot.Config{Width: 40, Height: 8},
+			widgets: []model.Widget{
+				{Title: "stat", GridPos: model.GridPos{W: 50}, WidgetSource: model.WidgetSource{Singlestat: &model.SinglestatWidgetSource{}}},
+				{Title: "gauge", GridPos: model.GridPos{W: 50}, WidgetSource: model.WidgetSource{Gauge: &model.GaugeWidgetSource{}}},
+				{Title: "table", GridPos: model.GridPos{W: 100}, WidgetSource: model.WidgetSource{Table: &model.TableWidgetSource{}}},
+			},
+			sync: func(t *testing.T, ws []render.Widget) {
+				require.NoError(t, ws[0].(render.SinglestatWidget).Sync("42"))
+				require.NoError(t, ws[1].(render.GaugeWidget).Sync(true, 50))
+				require.NoError(t, ws[2].(render.TableWidget).Sync(render.Table{
+					Headers: []string{"pod", "Value"},
+					Rows: [][]render.TableCell{
+						{{Text: "pod-1"}, {Text: "1"}},
+					},
+				}))
+			},
+			expOut: `
+┌stat──────────────┐┌gauge─────────────┐
+│                  ││                  │
+│        42        ││███████░░░░░░░ 50%│
+└──────────────────┘└──────────────────┘
+┌table─────────────────────────────────┐
+│pod    Value                          │
+│pod-1  1                              │
+└──────────────────────────────────────┘
+`,
+		},
+		{
+			name: "Variables should be rendered on top of the dashboard.",
+			cfg:  snapshot.Config{Width: 30, Height: 4},
+			widgets: []model.Widget{
+				{Title: "stat", GridPos: model.GridPos{W: 100}, WidgetSource: model.WidgetSource{Singlestat: &model.SinglestatWidgetSource{}}},
+			},
+			variables: []render.Variable{
+				{Name: "env", Value: "prod"},
+				{Name: "ns", Value: "a|b", Selected: []string{"a", "b"}},
+			},
+			sync: func(t *testing.T, ws []render.Widget) {
+				require.NoError(t, ws[0].(render.SinglestatWidget).Sync("UP"))
+			},
+			expOut: `
+env: prod  |  ns: a, b
+┌stat────────────────────────┐
+│             UP             │
+└────────────────────────────┘
+`,
+		},
+		{
+			name: "Graphs should render the series, the axes and the legend.",
+			cfg:  snapshot.Config{Width: 30, Height: 7},
+			widgets: []model.Widget{
+				{Title: "graph", GridPos: model.GridPos{W: 100}, WidgetSource: model.WidgetSource{Graph: &model.GraphWidgetSource{}}},
+			},
+			sync: func(t *testing.T, ws []render.Widget) {
+				g := ws[0].(render.GraphWidget)
+				require.Equal(t, 36, g.GetGraphPointQuantity())
+
+				values := make([]*render.Value, 36)
+				for i := range values {
+					v := render.Value(i)
+					values[i] = &v
+				}
+				require.NoError(t, g.Sync([]render.Series{
+					{Label: "up", Color: "#ff0000", Values: values, XLabels: []string{"10:00", "10:30", "11:00"}},
+				}))
+			},
+			expOut: `
+┌graph───────────────────────┐
+│    35.00│         ⣀⣀⡠⠤⠤⠒⠒⠊⠉│
+│     0.00│⣀⡠⠤⠤⠒⠒⠊⠉⠉         │
+│         └──────────────────│
+│          10:00        11:00│
+│⠤⠤ up                       │
+└────────────────────────────┘
+`,
+		},
+		{
+			name: "Graphs should not render the NaN and infinite values.",
+			cfg:  snapshot.Config{Width: 30, Height: 7},
+			widgets: []model.Widget{
+				{Title: "graph", GridPos: model.GridPos{W: 100}, WidgetSource: model.WidgetSource{Graph: &model.GraphWidgetSource{}}},
+			},
+			sync: func(t *testing.T, ws []render.Widget) {
+				g := ws[0].(render.GraphWidget)
+				values := make([]*render.Value, 36)
+				for i := range values {
+					v := render.Value(i)
+					switch i {
+					case 12:
+						v = render.Value(math.NaN())
+					case 24:
+						v = render.Value(math.Inf(1))
+					}
+					values[i] = &v
+				}
+				require.NoError(t, g.Sync([]render.Series{
+					{Label: "ratio", Color: "#ff0000", Values: values, XLabels: []string{"10:00", "10:30", "11:00"}},
+				}))
+			},
+			expOut: `
+┌graph───────────────────────┐
+│    35.00│         ⣀⣀⡠⠠⠤⠒⠒⠊⠉│
+│     0.00│⣀⡠⠤⠤⠒⠒⠈⠉⠉         │
+│         └──────────────────│
+│          10:00        11:00│
+│⠤⠤ ratio                    │
+└────────────────────────────┘
+`,
+		},
+		{
+			name: "Widgets without data should render their state.",
+			cfg:  snapshot.Config{Width: 40, Height: 4},
+			widgets: []model.Widget{
+				{Title: "stat", GridPos: model.GridPos{W: 50}, WidgetSource: model.WidgetSource{Singlestat: &model.SinglestatWidgetSource{}}},
+				{Title: "gauge", GridPos: model.GridPos{W: 50}, WidgetSource: model.WidgetSource{Gauge: &model.GaugeWidgetSource{}}},
+			},
+			sync: func(t *testing.T, ws []render.Widget) {
+				require.NoError(t, ws[0].(render.SinglestatWidget).SetState(render.WidgetState{Status: render.WidgetStatusError, Message: "auth error"}))
+				require.NoError(t, ws[1].(render.GaugeWidget).SetState(render.WidgetState{Status: render.WidgetStatusTimeout, Message: "timeout"}))
+			},
+			expOut: `
+┌stat [auth error]─┐┌gauge [timeout]───┐
+│                  ││                  │
+│    auth error    ││     timeout      │
+└──────────────────┘└──────────────────┘
+`,
+		},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			assert := assert.New(t)
+			require := require.New(t)
+
+			r := snapshot.NewRenderer(test.cfg)
+			if len(test.variables) > 0 {
+				vw, err := r.LoadVariables(context.TODO())
+				require.NoError(err)
+				require.NoError(vw.Sync(test.variables))
+			}
+
+			gr, err := grid.NewAdaptiveGrid(100, test.widgets)
+			require.NoError(err)
+			ws, err := r.LoadDashboard(context.TODO(), gr)
+			require.NoError(err)
+			test.sync(t, ws)
+
+			var b bytes.Buffer
+			err = r.Render(&b)
+			if assert.NoError(err) {
+				assert.Equal(strings.TrimPrefix(test.expOut, "\n"), b.String())
+			}
+		})
+	}
+}
@@ -0,0 +1,39 @@
+package snapshot
+
+import (
+	"fmt"
+
+	"github.com/slok/grafterm/internal/view/render"
+)
+
+const (
+	stateErrorColor  = "#E24D42"
+	stateNoDataColor = "#EAB839"
+)
+
+// stateBox draws the box of a widget, if the widget is not in a correct
+// state the state will be shown on the title.
+func (c *canvas) stateBox(a area, title string, state render.WidgetState) {
+	c.box(a, title)
+	if state.Status == render.WidgetStatusOK || a.w < 2 || a.h < 2 {
+		return
+	}
+
+	c.text(a.x+1, a.y, a.w-2, fmt.Sprintf("%s [%s]", title, state), stateColor(state))
+}
+
+// stateCenter draws the state message centered on the area, used by the
+// widgets that don't have data to draw.
+func (c *canvas) stateCenter(a area, state render.WidgetState) {
+	if state.Status == render.WidgetStatusOK {
+		return
+	}
+	c.textCenter(a, a.y+a.h/2, state.Message, stateColor(state))
+}
+
+func stateColor(state render.WidgetState) string {
+	if state.Status == render.WidgetStatusNoData {
+		return stateNoDataColor
+	}
+	return stateErrorColor
+}
@@ -0,0 +1,105 @@
+package snapshot
+
+import (
+	"sync"
+	"unicode/utf8"
+
+	"github.com/slok/grafterm/internal/model"
+	"github.com/slok/grafterm/internal/view/render"
+)
+
+const (
+	tableHeaderColor   = "#a8a8a8"
+	tableColumnPadding = 2
+)
+
+// table satisfies render.TableWidget interface.
+type table struct {
+	cfg  model.Widget
+	area area
+
+	mu    sync.Mutex
+	tbl   render.Table
+	state render.WidgetState
+}
+
+func newTable(cfg model.Widget, a area) *table {
+	return &table{
+		cfg:  cfg,
+		area: a,
+	}
+}
+
+func (t *table) GetWidgetCfg() model.Widget {
+	return t.cfg
+}
+
+func (t *table) Sync(tbl render.Table) error {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	t.tbl = tbl
+	return nil
+}
+
+func (t *table) SetState(state render.WidgetState) error {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	t.state = state
+	return nil
+}
+
+func (t *table) draw(c *canvas) {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+
+	c.stateBox(t.area, t.cfg.Title, t.state)
+	in := t.area.inner()
+	if len(t.tbl.Headers) == 0 {
+		c.stateCenter(in, t.state)
+		return
+	}
+
+	// Get the width of each column based on the largest text of the column.
+	widths := make([]int, len(t.tbl.Headers))
+	for i, h := range t.tbl.Headers {
+		widths[i] = utf8.RuneCountInString(h)
+	}
+	for _, row := range t.tbl.Rows {
+		for i, cl := range row {
+			if i < len(widths) && widths[i] < utf8.RuneCountInString(cl.Text) {
+				widths[i] = utf8.RuneCountInString(cl.Text)
+			}
+		}
+	}
+
+	// writeRow writes a row of the table on a line of the widget.
+	writeRow := func(y int, cells []render.TableCell, color string) {
+		x := in.x
+		for i, cl := range cells {
+			if i >= len(widths) || x >= in.x+in.w {
+				break
+			}
+			cellColor := color
+			if cl.Color != "" {
+				cellColor = cl.Color
+			}
+			c.text(x, y, in.x+in.w-x, cl.Text, cellColor)
+			x += widths[i] + tableColumnPadding
+		}
+	}
+
+	headers := make([]render.TableCell, 0, len(t.tbl.Headers))
+	for _, h := range t.tbl.Headers {
+		headers = append(headers, render.TableCell{Text: h})
+	}
+	if in.h > 0 {
+		writeRow(in.y, headers, tableHeaderColor)
+	}
+	for i, row := range t.tbl.Rows {
+		y := in.y + 1 + i
+		if y >= in.y+in.h {
+			break
+		}
+		writeRow(y, row, "")
+	}
+}
@@ -0,0 +1,48 @@
+package snapshot
+
+import (
+	"strings"
+	"sync"
+
+	"github.com/slok/grafterm/internal/view/render"
+)
+
+const variablesLabelColor = "#a8a8a8"
+
+// variables satisfies render.VariablesWidget interface.
+// It renders the values of the variables on a single line, the
+// values can't be selected.
+type variables struct {
+	area area
+
+	mu   sync.Mutex
+	vars []render.Variable
+}
+
+func (v *variables) Sync(vars []render.Variable) error {
+	v.mu.Lock()
+	defer v.mu.Unlock()
+	v.vars = vars
+	return nil
+}
+
+func (v *variables) OnSelect(f func(name string, values []string) error) {}
+
+func (v *variables) draw(c *canvas) {
+	v.mu.Lock()
+	defer v.mu.Unlock()
+
+	x := v.area.x
+	for i, vr := range v.vars {
+		if i > 0 {
+			x += c.text(x, v.area.y, v.area.x+v.area.w-x, "  |  ", "")
+		}
+
+		value := vr.Value
+		if len(vr.Selected) > 0 {
+			value = strings.Join(vr.Selected, ", ")
+		}
+		x += c.text(x, v.area.y, v.area.x+v.area.w-x, vr.Name+": ", variablesLabelColor)
+		x += c.text(x, v.area.y, v.area.x+v.area.w-x, value, "")
+	}
+}
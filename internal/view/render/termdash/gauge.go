@@ -2,9 +2,7 @@ package termdash
 
 import (
 	"github.com/mum4k/termdash/cell"
-	"github.com/mum4k/termdash/container"
 	"github.com/mum4k/termdash/container/grid"
-	"github.com/mum4k/termdash/linestyle"
 	"github.com/mum4k/termdash/widgets/donut"
 
 	"github.com/slok/grafterm/internal/model"
@@ -13,6 +11,7 @@ import (
 // gauge satisfies render.GaugeWidget interface.
 type gauge struct {
 	cfg model.Widget
+	*borderState
 
 	widget  *donut.Donut
 	element grid.Element
@@ -26,15 +25,14 @@ func newGauge(cfg model.Widget) (*gauge, error) {
 	}
 
 	// Create the element using the new widget.
-	element := grid.Widget(donut,
-		container.Border(linestyle.Light),
-		container.BorderTitle(cfg.Title),
-	)
+	state := newBorderState(cfg.Title)
+	element := grid.Widget(donut, state.containerOptions()...)
 
 	return &gauge{
-		widget:  donut,
-		cfg:     cfg,
-		element: element,
+		widget:      donut,
+		cfg:         cfg,
+		borderState: state,
+		element:     element,
 	}, nil
 }
 
@@ -81,10 +79,7 @@ func (g *gauge) SetColor(hexColor string) error {
 	g.widget = d
 
 	// Recreate the grid element with the new widget to ensure consistency.
-	g.element = grid.Widget(g.widget,
-		container.Border(linestyle.Light),
-		container.BorderTitle(g.cfg.Title),
-	)
+	g.element = grid.Widget(g.widget, g.containerOptions()...)
 
 	return nil
 }
@@ -3,11 +3,11 @@ package termdash
 import (
 	"fmt"
 	"math"
+	"sync"
 
 	"github.com/mum4k/termdash/cell"
 	"github.com/mum4k/termdash/container"
 	"github.com/mum4k/termdash/container/grid"
-	"github.com/mum4k/termdash/linestyle"
 	"github.com/mum4k/termdash/widgets/linechart"
 	"github.com/mum4k/termdash/widgets/text"
 
@@ -33,17 +33,28 @@ const (
 // graph satisfies render.GraphWidget interface.
 type graph struct {
 	cfg model.Widget
+	*borderState
 
 	widgetGraph  *linechart.LineChart
 	widgetLegend *text.Text
 	element      grid.Element
+
+	// formatter is the Y axis values formatter, it can be changed after
+	// creating the graph so it's protected with a lock.
+	formatter   linechart.ValueFormatter
+	formatterMu sync.RWMutex
 }
 
 func newGraph(cfg model.Widget) (*graph, error) {
-	vf, err := termdashValueFormatter(cfg)
+	vf, err := termdashValueFormatter(cfg.Graph.Visualization.YAxis.ValueRepresentation)
 	if err != nil {
 		return nil, err
 	}
+	g := &graph{
+		cfg:         cfg,
+		borderState: newBorderState(cfg.Title),
+		formatter:   vf,
+	}
 
 	// Create the Graphwidget.
 	// TODO(slok): Allow configuring the color of the axis.
@@ -52,7 +63,7 @@ func newGraph(cfg model.Widget) (*graph, error) {
 		linechart.YLabelCellOpts(cell.FgColor(cell.ColorNumber(yAxisLabelsColor))),
 		linechart.XLabelCellOpts(cell.FgColor(cell.ColorNumber(xAxisLabelsColor))),
 		linechart.YAxisAdaptive(),
-		linechart.YAxisFormattedValues(vf),
+		linechart.YAxisFormattedValues(g.formatValue),
 	)
 	if err != nil {
 		return nil, err
@@ -68,21 +79,20 @@ func newGraph(cfg model.Widget) (*graph, error) {
 		}
 	}
 
-	element = elementFromGraphAndLegend(cfg, lc, txt)
+	element = elementFromGraphAndLegend(cfg, g.borderState, lc, txt)
 
-	return &graph{
-		widgetGraph:  lc,
-		widgetLegend: txt,
-		cfg:          cfg,
-		element:      element,
-	}, nil
+	g.widgetGraph = lc
+	g.widgetLegend = txt
+	g.element = element
+
+	return g, nil
 }
 
 // termdashValueFormatter will get a termdashValueFormatter based
-// on the widget configuration.
-func termdashValueFormatter(cfg model.Widget) (linechart.ValueFormatter, error) {
-	axisUnit := cfg.Graph.Visualization.YAxis.Unit
-	axisDecimals := cfg.Graph.Visualization.YAxis.Decimals
+// on the value representation.
+func termdashValueFormatter(vr model.ValueRepresentation) (linechart.ValueFormatter, error) {
+	axisUnit := vr.Unit
+	axisDecimals := vr.Decimals
 
 	f, err := unit.NewUnitFormatter(axisUnit)
 	if err != nil {
@@ -100,7 +110,7 @@ func termdashValueFormatter(cfg model.Widget) (linechart.ValueFormatter, error)
 	}, nil
 }
 
-func elementFromGraphAndLegend(cfg model.Widget, graph *linechart.LineChart, legend *text.Text) grid.Element {
+func elementFromGraphAndLegend(cfg model.Widget, state *borderState, graph *linechart.LineChart, legend *text.Text) grid.Element {
 	graphElement := grid.Widget(graph)
 
 	elements := []grid.Element{}
@@ -134,11 +144,7 @@ func elementFromGraphAndLegend(cfg model.Widget, graph *linechart.LineChart, leg
 		}
 	}
 
-	opts := []container.Option{
-		container.Border(linestyle.Light),
-		container.BorderTitle(cfg.Title),
-	}
-	element := grid.RowHeightPercWithOpts(fullPerc, opts, elements...)
+	element := grid.RowHeightPercWithOpts(fullPerc, state.containerOptions(), elements...)
 
 	return element
 }
@@ -235,6 +241,25 @@ func (g *graph) syncLegend(series render.Series, color cell.Color) error {
 	return nil
 }
 
+// formatValue formats the Y axis values using the current formatter.
+func (g *graph) formatValue(value float64) string {
+	g.formatterMu.RLock()
+	defer g.formatterMu.RUnlock()
+	return g.formatter(value)
+}
+
+func (g *graph) SetYAxisValueRepresentation(vr model.ValueRepresentation) error {
+	vf, err := termdashValueFormatter(vr)
+	if err != nil {
+		return err
+	}
+
+	g.formatterMu.Lock()
+	defer g.formatterMu.Unlock()
+	g.formatter = vf
+	return nil
+}
+
 func (g *graph) GetGraphPointQuantity() int {
 	return g.widgetGraph.ValueCapacity()
 }
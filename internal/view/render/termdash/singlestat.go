@@ -2,9 +2,7 @@ package termdash
 
 import (
 	"github.com/mum4k/termdash/cell"
-	"github.com/mum4k/termdash/container"
 	"github.com/mum4k/termdash/container/grid"
-	"github.com/mum4k/termdash/linestyle"
 	"github.com/mum4k/termdash/widgets/segmentdisplay"
 
 	"github.com/slok/grafterm/internal/model"
@@ -14,6 +12,7 @@ import (
 type singlestat struct {
 	cfg   model.Widget
 	color cell.Color
+	*borderState
 
 	widget  *segmentdisplay.SegmentDisplay
 	element grid.Element
@@ -27,16 +26,15 @@ func newSinglestat(cfg model.Widget) (*singlestat, error) {
 	}
 
 	// Create the element using the new widget.
-	element := grid.Widget(sd,
-		container.Border(linestyle.Light),
-		container.BorderTitle(cfg.Title),
-	)
+	state := newBorderState(cfg.Title)
+	element := grid.Widget(sd, state.containerOptions()...)
 
 	return &singlestat{
-		widget:  sd,
-		color:   cell.ColorWhite,
-		cfg:     cfg,
-		element: element,
+		widget:      sd,
+		color:       cell.ColorWhite,
+		cfg:         cfg,
+		borderState: state,
+		element:     element,
 	}, nil
 }
 
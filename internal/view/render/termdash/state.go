@@ -0,0 +1,94 @@
+package termdash
+
+import (
+	"fmt"
+	"sync"
+	"sync/atomic"
+
+	"github.com/mum4k/termdash/cell"
+	"github.com/mum4k/termdash/container"
+	"github.com/mum4k/termdash/linestyle"
+
+	"github.com/slok/grafterm/internal/view/render"
+)
+
+const (
+	widgetErrorColor  = "#E24D42"
+	widgetNoDataColor = "#EAB839"
+)
+
+// borderStateIDs is used to give a unique container ID to every widget
+// that shows its state on the border.
+var borderStateIDs uint64
+
+// containerSetter is an internal interface that the widgets that need to
+// update their container after the dashboard has been loaded implement.
+type containerSetter interface {
+	setContainer(c *container.Container)
+}
+
+// borderState shows the state of a widget on its container border, the
+// title gets the state message and the border gets the state color.
+// It satisfies render.StateWidget interface.
+type borderState struct {
+	id    string
+	title string
+
+	mu        sync.Mutex
+	container *container.Container
+}
+
+func newBorderState(title string) *borderState {
+	id := atomic.AddUint64(&borderStateIDs, 1)
+	return &borderState{
+		id:    fmt.Sprintf("widget-%d", id),
+		title: title,
+	}
+}
+
+// containerOptions returns the options of the container that the state
+// will be rendered on.
+func (b *borderState) containerOptions() []container.Option {
+	return []container.Option{
+		container.ID(b.id),
+		container.Border(linestyle.Light),
+		container.BorderTitle(b.title),
+	}
+}
+
+func (b *borderState) setContainer(c *container.Container) {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	b.container = c
+}
+
+func (b *borderState) SetState(state render.WidgetState) error {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	// Not placed on the dashboard yet.
+	if b.container == nil {
+		return nil
+	}
+
+	title := b.title
+	color := cell.ColorDefault
+	if state.Status != render.WidgetStatusOK {
+		title = fmt.Sprintf("%s [%s]", b.title, state)
+
+		hexColor := widgetErrorColor
+		if state.Status == render.WidgetStatusNoData {
+			hexColor = widgetNoDataColor
+		}
+		var err error
+		color, err = colorHexToTermdash(hexColor)
+		if err != nil {
+			return err
+		}
+	}
+
+	return b.container.Update(b.id,
+		container.BorderTitle(title),
+		container.BorderColor(color),
+	)
+}
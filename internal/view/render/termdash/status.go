@@ -0,0 +1,116 @@
+package termdash
+
+import (
+	"fmt"
+	"sync"
+	"time"
+
+	"github.com/mum4k/termdash/cell"
+	"github.com/mum4k/termdash/container"
+	"github.com/mum4k/termdash/linestyle"
+	"github.com/mum4k/termdash/widgets/text"
+
+	"github.com/slok/grafterm/internal/model"
+)
+
+const (
+	statusBarTitle       = "Datasources"
+	statusBarHeight      = 3
+	statusClosedColor    = "#7EB26D"
+	statusOpenColor      = "#E24D42"
+	statusHalfOpenColor  = "#EAB839"
+	statusMaxErrorLength = 60
+)
+
+// statusBar satisfies render.StatusWidget interface.
+// It renders the health of the datasources on a single line.
+type statusBar struct {
+	widget *text.Text
+
+	mu     sync.Mutex
+	health []model.DatasourceHealth
+}
+
+func newStatusBar() (*statusBar, error) {
+	txt, err := text.New()
+	if err != nil {
+		return nil, err
+	}
+
+	return &statusBar{
+		widget: txt,
+	}, nil
+}
+
+// containerOptions returns the options to place the bar on a container.
+func (s *statusBar) containerOptions() []container.Option {
+	return []container.Option{
+		container.Border(linestyle.Light),
+		container.BorderTitle(statusBarTitle),
+		container.PlaceWidget(s.widget),
+	}
+}
+
+func (s *statusBar) Sync(health []model.DatasourceHealth) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	s.health = health
+	return s.draw(time.Now())
+}
+
+// draw renders the bar, needs to be called with the lock acquired.
+func (s *statusBar) draw(now time.Time) error {
+	s.widget.Reset()
+
+	if len(s.health) == 0 {
+		return s.widget.Write("waiting for queries", text.WriteCellOpts(cell.FgColor(cell.ColorNumber(variablesHintColor))))
+	}
+
+	for i, h := range s.health {
+		if i > 0 {
+			err := s.widget.Write("  |  ", text.WriteCellOpts(cell.FgColor(cell.ColorNumber(variablesHintColor))))
+			if err != nil {
+				return err
+			}
+		}
+
+		hexColor, txt := statusText(h, now)
+		color, err := colorHexToTermdash(hexColor)
+		if err != nil {
+			return err
+		}
+
+		err = s.widget.Write("● ", text.WriteCellOpts(cell.FgColor(color)))
+		if err != nil {
+			return err
+		}
+		err = s.widget.Write(txt)
+		if err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+// statusText returns the color and the text that represent the health of
+// a datasource.
+func statusText(h model.DatasourceHealth, now time.Time) (string, string) {
+	switch h.State {
+	case model.CircuitOpen:
+		retry := h.RetryAt.Sub(now).Round(time.Second)
+		if retry < 0 {
+			retry = 0
+		}
+		lastErr := h.LastError
+		if len(lastErr) > statusMaxErrorLength {
+			lastErr = lastErr[:statusMaxErrorLength] + "…"
+		}
+		return statusOpenColor, fmt.Sprintf("%s: down after %d failures, retry in %s (%s)", h.DatasourceID, h.ConsecutiveFailures, retry, lastErr)
+	case model.CircuitHalfOpen:
+		return statusHalfOpenColor, fmt.Sprintf("%s: probing", h.DatasourceID)
+	}
+
+	return statusClosedColor, fmt.Sprintf("%s: ok", h.DatasourceID)
+}
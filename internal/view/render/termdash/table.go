@@ -0,0 +1,121 @@
+package termdash
+
+import (
+	"strings"
+	"unicode/utf8"
+
+	"github.com/mum4k/termdash/cell"
+	"github.com/mum4k/termdash/container/grid"
+	"github.com/mum4k/termdash/widgets/text"
+
+	"github.com/slok/grafterm/internal/model"
+	"github.com/slok/grafterm/internal/view/render"
+)
+
+const (
+	tableHeaderColor   = 248
+	tableColumnPadding = 2
+)
+
+// table satisfies render.TableWidget interface.
+type table struct {
+	cfg model.Widget
+	*borderState
+
+	widget  *text.Text
+	element grid.Element
+}
+
+func newTable(cfg model.Widget) (*table, error) {
+	// Create the widget.
+	txt, err := text.New()
+	if err != nil {
+		return nil, err
+	}
+
+	// Create the element using the new widget.
+	state := newBorderState(cfg.Title)
+	element := grid.Widget(txt, state.containerOptions()...)
+
+	return &table{
+		widget:      txt,
+		cfg:         cfg,
+		borderState: state,
+		element:     element,
+	}, nil
+}
+
+func (t *table) getElement() grid.Element {
+	return t.element
+}
+
+func (t *table) GetWidgetCfg() model.Widget {
+	return t.cfg
+}
+
+func (t *table) Sync(tbl render.Table) error {
+	// Get the width of each column based on the largest text of the column.
+	widths := make([]int, len(tbl.Headers))
+	for i, h := range tbl.Headers {
+		widths[i] = utf8.RuneCountInString(h)
+	}
+	for _, row := range tbl.Rows {
+		for i, c := range row {
+			if i < len(widths) && widths[i] < utf8.RuneCountInString(c.Text) {
+				widths[i] = utf8.RuneCountInString(c.Text)
+			}
+		}
+	}
+
+	// Reset table on each sync.
+	t.widget.Reset()
+
+	// Write the headers.
+	for i, h := range tbl.Headers {
+		err := t.widget.Write(padCellText(h, widths[i]), text.WriteCellOpts(cell.FgColor(cell.ColorNumber(tableHeaderColor))))
+		if err != nil {
+			return err
+		}
+	}
+	err := t.widget.Write("\n")
+	if err != nil {
+		return err
+	}
+
+	// Write the rows.
+	for _, row := range tbl.Rows {
+		for i, c := range row {
+			if i >= len(widths) {
+				break
+			}
+
+			opts := []text.WriteOption{}
+			if c.Color != "" {
+				color, err := colorHexToTermdash(c.Color)
+				if err != nil {
+					return err
+				}
+				opts = append(opts, text.WriteCellOpts(cell.FgColor(color)))
+			}
+
+			err := t.widget.Write(padCellText(c.Text, widths[i]), opts...)
+			if err != nil {
+				return err
+			}
+		}
+
+		err := t.widget.Write("\n")
+		if err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+// padCellText returns the text of a cell filled with spaces so all the
+// cells of the same column have the same width.
+func padCellText(txt string, width int) string {
+	padding := width - utf8.RuneCountInString(txt) + tableColumnPadding
+	return txt + strings.Repeat(" ", padding)
+}
@@ -0,0 +1,67 @@
+package termdash
+
+import (
+	"time"
+
+	"github.com/mum4k/termdash/keyboard"
+	"github.com/mum4k/termdash/terminal/terminalapi"
+
+	"github.com/slok/grafterm/internal/view/render"
+)
+
+// timeRangePresets are the live time range presets by key.
+var timeRangePresets = map[keyboard.Key]time.Duration{
+	'1': 5 * time.Minute,
+	'2': 1 * time.Hour,
+	'3': 6 * time.Hour,
+	'4': 24 * time.Hour,
+	'5': 7 * 24 * time.Hour,
+}
+
+// OnTimeRangeChange satisfies render.TimeRangeRenderer interface.
+func (t *termDashboard) OnTimeRangeChange(f func(change render.TimeRangeChange)) {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	t.onTimeRangeChange = f
+}
+
+// timeRangeKeyboard handles the time range keys and returns true if the
+// event has been used:
+//   - '+'/'=': Zoom in.
+//   - '-': Zoom out.
+//   - Left/Right arrows: Pan backward/forward.
+//   - '1'-'5': 5m, 1h, 6h, 24h and 7d presets.
+//   - 'l': Live time range.
+func (t *termDashboard) timeRangeKeyboard(k *terminalapi.Keyboard) bool {
+	var c render.TimeRangeChange
+	switch k.Key {
+	case '+', '=':
+		c.Action = render.TimeRangeZoomIn
+	case '-':
+		c.Action = render.TimeRangeZoomOut
+	case keyboard.KeyArrowLeft:
+		c.Action = render.TimeRangePanBackward
+	case keyboard.KeyArrowRight:
+		c.Action = render.TimeRangePanForward
+	case 'l', 'L':
+		c.Action = render.TimeRangeLive
+	default:
+		d, ok := timeRangePresets[k.Key]
+		if !ok {
+			return false
+		}
+		c.Action = render.TimeRangePreset
+		c.Duration = d
+	}
+
+	t.mu.Lock()
+	f := t.onTimeRangeChange
+	t.mu.Unlock()
+	if f == nil {
+		return false
+	}
+
+	f(c)
+
+	return true
+}
@@ -0,0 +1,347 @@
+package termdash
+
+import (
+	"strings"
+	"sync"
+
+	"github.com/mum4k/termdash/cell"
+	"github.com/mum4k/termdash/container"
+	"github.com/mum4k/termdash/keyboard"
+	"github.com/mum4k/termdash/linestyle"
+	"github.com/mum4k/termdash/terminal/terminalapi"
+	"github.com/mum4k/termdash/widgets/text"
+
+	"github.com/slok/grafterm/internal/service/log"
+	"github.com/slok/grafterm/internal/view/render"
+)
+
+const (
+	variablesBarTitle    = "Variables"
+	variablesBarHeight   = 3
+	variablesLabelColor  = 248
+	variablesHintColor   = 8
+	variablesFocusColor  = 4
+	pickerOptionsContext = 2
+)
+
+// variablesBar satisfies render.VariablesWidget interface.
+// It renders the variables of the dashboard on a single line and lets
+// the user select the values of the variables using the keyboard:
+//   - Tab: Focus the next variable that can be selected.
+//   - Enter: Open the picker of the focused variable.
+//   - Esc: Unfocus the variable.
+//
+// When the picker is open:
+//   - Left/Right arrows: Move over the options.
+//   - Space: Toggle the option (select if the variable is not multi).
+//   - Enter: Apply the selected options.
+//   - Esc: Close the picker without applying.
+type variablesBar struct {
+	widget   *text.Text
+	onSelect func(name string, values []string) error
+	logger   log.Logger
+
+	mu      sync.Mutex
+	vars    []render.Variable
+	focused int
+	picker  *variablePicker
+}
+
+// variablePicker is the state of a variable values selection.
+type variablePicker struct {
+	variable render.Variable
+	cursor   int
+	selected map[string]bool
+}
+
+func newVariablesBar(logger log.Logger) (*variablesBar, error) {
+	txt, err := text.New()
+	if err != nil {
+		return nil, err
+	}
+
+	return &variablesBar{
+		widget:  txt,
+		logger:  logger,
+		focused: -1,
+	}, nil
+}
+
+// containerOptions returns the options to place the bar on a container.
+func (v *variablesBar) containerOptions() []container.Option {
+	return []container.Option{
+		container.Border(linestyle.Light),
+		container.BorderTitle(variablesBarTitle),
+		container.PlaceWidget(v.widget),
+	}
+}
+
+func (v *variablesBar) OnSelect(f func(name string, values []string) error) {
+	v.mu.Lock()
+	defer v.mu.Unlock()
+	v.onSelect = f
+}
+
+func (v *variablesBar) Sync(vars []render.Variable) error {
+	v.mu.Lock()
+	defer v.mu.Unlock()
+
+	// Maintain the focused variable.
+	focusedName := ""
+	if v.focused >= 0 && v.focused < len(v.vars) {
+		focusedName = v.vars[v.focused].Name
+	}
+	v.focused = -1
+	for i, vr := range vars {
+		if vr.Name == focusedName {
+			v.focused = i
+		}
+	}
+	v.vars = vars
+
+	return v.draw()
+}
+
+// keyboard handles the keyboard events and returns true if the event
+// has been used by the bar.
+func (v *variablesBar) keyboard(k *terminalapi.Keyboard) bool {
+	v.mu.Lock()
+
+	var apply func() error
+	used := true
+	if v.picker != nil {
+		apply = v.pickerKeyboard(k)
+	} else {
+		used = v.focusKeyboard(k)
+	}
+
+	err := v.draw()
+	if err != nil {
+		v.logger.Errorf("error rendering variables: %s", err)
+	}
+	v.mu.Unlock()
+
+	// Apply the selection without the lock, the selection will
+	// sync the bar again.
+	if apply != nil {
+		err := apply()
+		if err != nil {
+			v.logger.Errorf("error selecting variable values: %s", err)
+		}
+	}
+
+	return used
+}
+
+// focusKeyboard handles the keys when the picker is closed.
+func (v *variablesBar) focusKeyboard(k *terminalapi.Keyboard) bool {
+	switch {
+	case k.Key == keyboard.KeyTab:
+		next := v.nextSelectable(v.focused)
+		if next < 0 {
+			return false
+		}
+		v.focused = next
+	case k.Key == keyboard.KeyEnter && v.focused >= 0:
+		vr := v.vars[v.focused]
+		p := &variablePicker{
+			variable: vr,
+			selected: map[string]bool{},
+		}
+		for _, s := range vr.Selected {
+			p.selected[s] = true
+		}
+
+		// Start the cursor on the first selected option.
+		for i, o := range vr.Options {
+			if p.selected[o] {
+				p.cursor = i
+				break
+			}
+		}
+		v.picker = p
+	case k.Key == keyboard.KeyEsc && v.focused >= 0:
+		v.focused = -1
+	default:
+		return false
+	}
+
+	return true
+}
+
+// pickerKeyboard handles the keys when the picker is open, if the selection
+// needs to be applied it will return the function that applies it.
+func (v *variablesBar) pickerKeyboard(k *terminalapi.Keyboard) func() error {
+	p := v.picker
+	opts := p.variable.Options
+
+	switch k.Key {
+	case keyboard.KeyArrowLeft:
+		if p.cursor > 0 {
+			p.cursor--
+		}
+	case keyboard.KeyArrowRight:
+		if p.cursor < len(opts)-1 {
+			p.cursor++
+		}
+	case keyboard.KeySpace:
+		p.toggle()
+	case keyboard.KeyEsc:
+		v.picker = nil
+	case keyboard.KeyEnter:
+		// On single value variables enter selects the option under the cursor.
+		if !p.variable.Multi {
+			p.toggle()
+		}
+		v.picker = nil
+
+		values := []string{}
+		for _, o := range opts {
+			if p.selected[o] {
+				values = append(values, o)
+			}
+		}
+		name := p.variable.Name
+		onSelect := v.onSelect
+		if onSelect == nil || len(values) == 0 {
+			return nil
+		}
+		return func() error { return onSelect(name, values) }
+	}
+
+	return nil
+}
+
+func (p *variablePicker) toggle() {
+	if len(p.variable.Options) == 0 {
+		return
+	}
+
+	opt := p.variable.Options[p.cursor]
+	if !p.variable.Multi {
+		p.selected = map[string]bool{opt: true}
+		return
+	}
+	p.selected[opt] = !p.selected[opt]
+}
+
+// nextSelectable returns the index of the next variable that can be selected
+// after the received index, -1 if there is none.
+func (v *variablesBar) nextSelectable(from int) int {
+	for i := 1; i <= len(v.vars); i++ {
+		idx := (from + i) % len(v.vars)
+		if len(v.vars[idx].Options) > 0 {
+			return idx
+		}
+	}
+	return -1
+}
+
+// draw renders the bar, needs to be called with the lock acquired.
+func (v *variablesBar) draw() error {
+	v.widget.Reset()
+
+	if v.picker != nil {
+		return v.drawPicker()
+	}
+
+	for i, vr := range v.vars {
+		if i > 0 {
+			err := v.write("  |  ", text.WriteCellOpts(cell.FgColor(cell.ColorNumber(variablesHintColor))))
+			if err != nil {
+				return err
+			}
+		}
+
+		err := v.write(vr.Name+": ", text.WriteCellOpts(cell.FgColor(cell.ColorNumber(variablesLabelColor))))
+		if err != nil {
+			return err
+		}
+
+		value := vr.Value
+		if len(vr.Selected) > 0 {
+			value = strings.Join(vr.Selected, ", ")
+		}
+
+		opts := []text.WriteOption{}
+		if i == v.focused {
+			opts = append(opts, text.WriteCellOpts(cell.BgColor(cell.ColorNumber(variablesFocusColor))))
+		}
+		err = v.write(value, opts...)
+		if err != nil {
+			return err
+		}
+	}
+
+	hint := "  (tab: select variable)"
+	if v.focused >= 0 {
+		hint = "  (tab: next, enter: open, esc: exit)"
+	}
+	if v.nextSelectable(-1) < 0 {
+		hint = ""
+	}
+
+	return v.write(hint, text.WriteCellOpts(cell.FgColor(cell.ColorNumber(variablesHintColor))))
+}
+
+// drawPicker renders the options of the variable that is being selected, the
+// options will be rendered starting close to the cursor so the cursor is visible.
+func (v *variablesBar) drawPicker() error {
+	p := v.picker
+
+	err := v.write(p.variable.Name+": ", text.WriteCellOpts(cell.FgColor(cell.ColorNumber(variablesLabelColor))))
+	if err != nil {
+		return err
+	}
+
+	start := p.cursor - pickerOptionsContext
+	if start < 0 {
+		start = 0
+	}
+	if start > 0 {
+		err := v.write("< ", text.WriteCellOpts(cell.FgColor(cell.ColorNumber(variablesHintColor))))
+		if err != nil {
+			return err
+		}
+	}
+
+	for i := start; i < len(p.variable.Options); i++ {
+		opt := p.variable.Options[i]
+
+		mark := "[ ] "
+		if p.selected[opt] {
+			mark = "[x] "
+		}
+		if !p.variable.Multi {
+			mark = "( ) "
+			if p.selected[opt] {
+				mark = "(*) "
+			}
+		}
+
+		opts := []text.WriteOption{}
+		if i == p.cursor {
+			opts = append(opts, text.WriteCellOpts(cell.BgColor(cell.ColorNumber(variablesFocusColor))))
+		}
+		err := v.write(mark+opt, opts...)
+		if err != nil {
+			return err
+		}
+
+		err = v.write("  ")
+		if err != nil {
+			return err
+		}
+	}
+
+	hint := "(left/right: move, space: toggle, enter: apply, esc: cancel)"
+	return v.write(hint, text.WriteCellOpts(cell.FgColor(cell.ColorNumber(variablesHintColor))))
+}
+
+// write writes the text on the widget ignoring the empty texts.
+func (v *variablesBar) write(txt string, opts ...text.WriteOption) error {
+	if txt == "" {
+		return nil
+	}
+	return v.widget.Write(txt, opts...)
+}
@@ -2,6 +2,7 @@ package termdash
 
 import (
 	"context"
+	"sync"
 	"time"
 
 	"github.com/mum4k/termdash"
@@ -32,9 +33,14 @@ type elementer interface {
 
 // View is what renders the metrics.
 type termDashboard struct {
-	widgets []render.Widget
-	logger  log.Logger
-	cancel  func()
+	widgets   []render.Widget
+	variables *variablesBar
+	status    *statusBar
+	logger    log.Logger
+	cancel    func()
+
+	mu                sync.Mutex
+	onTimeRangeChange func(change render.TimeRangeChange)
 
 	// Term fields.
 	terminal *termbox.Terminal
@@ -74,18 +80,56 @@ func (t *termDashboard) LoadDashboard(ctx context.Context, gr *graftermgrid.Grid
 		return []render.Widget{}, err
 	}
 
+	// If we have the datasources status place it on top of the dashboard.
+	if t.status != nil {
+		gridOpts = []container.Option{
+			container.SplitHorizontal(
+				container.Top(t.status.containerOptions()...),
+				container.Bottom(gridOpts...),
+				container.SplitFixed(statusBarHeight),
+			),
+		}
+	}
+
+	// If we have variables place them on top of the dashboard.
+	if t.variables != nil {
+		gridOpts = []container.Option{
+			container.SplitHorizontal(
+				container.Top(t.variables.containerOptions()...),
+				container.Bottom(gridOpts...),
+				container.SplitFixed(variablesBarHeight),
+			),
+		}
+	}
+
 	err = c.Update(rootID, gridOpts...)
 	if err != nil {
 		return []render.Widget{}, err
 	}
 
+	// Let the widgets update their containers (e.g: to show their state).
+	for _, w := range t.widgets {
+		if cs, ok := w.(containerSetter); ok {
+			cs.setContainer(c)
+		}
+	}
+
 	go func() {
-		quitter := func(k *terminalapi.Keyboard) {
+		keyboardHandler := func(k *terminalapi.Keyboard) {
+			// The variables have priority over the other keys (e.g: when selecting values).
+			if t.variables != nil && t.variables.keyboard(k) {
+				return
+			}
+
+			if t.timeRangeKeyboard(k) {
+				return
+			}
+
 			if k.Key == 'q' || k.Key == 'Q' || k.Key == keyboard.KeyEsc {
 				t.cancel()
 			}
 		}
-		if err := termdash.Run(ctx, t.terminal, c, termdash.KeyboardSubscriber(quitter), termdash.RedrawInterval(redrawInterval)); err != nil {
+		if err := termdash.Run(ctx, t.terminal, c, termdash.KeyboardSubscriber(keyboardHandler), termdash.RedrawInterval(redrawInterval)); err != nil {
 			t.logger.Errorf("error running termdash terminal: %s", err)
 			// TODO(slok): exit on error.
 		}
@@ -94,55 +138,45 @@ func (t *termDashboard) LoadDashboard(ctx context.Context, gr *graftermgrid.Grid
 	return t.widgets, nil
 }
 
+// LoadVariables satisfies render.VariablesRenderer interface.
+func (t *termDashboard) LoadVariables(_ context.Context) (render.VariablesWidget, error) {
+	vb, err := newVariablesBar(t.logger)
+	if err != nil {
+		return nil, err
+	}
+	t.variables = vb
+
+	return vb, nil
+}
+
+// LoadStatus satisfies render.StatusRenderer interface.
+func (t *termDashboard) LoadStatus(_ context.Context) (render.StatusWidget, error) {
+	sb, err := newStatusBar()
+	if err != nil {
+		return nil, err
+	}
+	t.status = sb
+
+	return sb, nil
+}
+
 func (t *termDashboard) gridLayout(gr *graftermgrid.Grid) ([]container.Option, error) {
 	builder := grid.New()
 
-	// Create the rendering widgets.
-	rowsElements := [][]grid.Element{}
-	for _, row := range gr.Rows {
-		rowElements := []grid.Element{}
-		totalFilled := 0
-		for _, rowElement := range row.Elements {
-			cfg := rowElement.Widget
-
-			// New widget.
-			var element grid.Element
-			if !rowElement.Empty {
-				widget, err := t.newWidget(cfg)
-				if err != nil {
-					t.logger.Errorf("error creating widget: %s", err)
-					continue
-				}
-				// Add widget to the tracked widgets so the app can control them.
-				t.widgets = append(t.widgets, widget)
-
-				// Get the grid.Element from our widget and place on the grid.
-				element = widget.(elementer).getElement()
-			}
-
-			// Fix the size on the last element.
-			// Termdash does not allow a column greater than 99, we have
-			// used percents (0-100), so we remove a 1% from the last element.
-			// Ugly but makes easy to work with % and is difficult for the
-			// eye to notice of the 1%.
-			elementPerc := rowElement.PercentSize
-			if totalFilled+elementPerc >= 100 {
-				elementPerc--
-			}
-			totalFilled += elementPerc
+	// Add rows.
+	builder.Add(t.rowsLayout(gr.Rows)...)
 
-			// Place it on the row.
-			element = grid.ColWidthPerc(elementPerc, element)
-			rowElements = append(rowElements, element)
-		}
-		rowsElements = append(rowsElements, rowElements)
-	}
+	// Get the layout from the grid.
+	return builder.Build()
+}
 
-	// Add rows to grid.
+// rowsLayout creates the rendering widgets of the rows and returns the
+// rows as grid elements, the rows can be nested inside grid elements
+// so this is called recursively by the elements that have rows.
+func (t *termDashboard) rowsLayout(rows []*graftermgrid.Row) []grid.Element {
 	var gridElements []grid.Element
 	totalFilled := 0
-	for i, row := range gr.Rows {
-		rowElements := rowsElements[i]
+	for _, row := range rows {
 		rowPerc := row.PercentSize
 		// Fix the size on the last element.
 		// Termdash does not allow a rows greater than 99, we have
@@ -150,20 +184,73 @@ func (t *termDashboard) gridLayout(gr *graftermgrid.Grid) ([]container.Option, e
 		// Ugly but makes easy to work with % and is difficult for the
 		// eye to notice of the 1%.
 		if totalFilled+rowPerc >= 100 {
-			rowPerc--
+			rowPerc = 99 - totalFilled
+		}
+		if rowPerc <= 0 {
+			t.logger.Warnf("ignoring grid row, there is no space left on the grid")
+			continue
 		}
 		totalFilled += rowPerc
 
 		// Place the row.
+		rowElements := t.rowElementsLayout(row)
 		rowElement := grid.RowHeightPerc(rowPerc, rowElements...)
 		gridElements = append(gridElements, rowElement)
 	}
 
-	// Add rows.
-	builder.Add(gridElements...)
+	return gridElements
+}
 
-	// Get the layout from the grid.
-	return builder.Build()
+// rowElementsLayout creates the rendering widgets of the row and returns
+// them placed on the row as grid columns.
+func (t *termDashboard) rowElementsLayout(row *graftermgrid.Row) []grid.Element {
+	rowElements := []grid.Element{}
+	totalFilled := 0
+	for _, rowElement := range row.Elements {
+		cfg := rowElement.Widget
+
+		// Fix the size on the last element.
+		// Termdash does not allow a column greater than 99, we have
+		// used percents (0-100), so we remove a 1% from the last element.
+		// Ugly but makes easy to work with % and is difficult for the
+		// eye to notice of the 1%.
+		// Check the space before creating the widget so the widgets that
+		// don't fit are not tracked nor synced.
+		elementPerc := rowElement.PercentSize
+		if totalFilled+elementPerc >= 100 {
+			elementPerc = 99 - totalFilled
+		}
+		if elementPerc <= 0 {
+			t.logger.Warnf("ignoring grid element, there is no space left on the row")
+			continue
+		}
+
+		// New widget (empty elements use a nil element as placeholder).
+		subElements := []grid.Element{nil}
+		switch {
+		case rowElement.Empty:
+		// Elements with nested rows are columns split in rows.
+		case len(rowElement.Rows) > 0:
+			subElements = t.rowsLayout(rowElement.Rows)
+		default:
+			widget, err := t.newWidget(cfg)
+			if err != nil {
+				t.logger.Errorf("error creating widget: %s", err)
+				continue
+			}
+			// Add widget to the tracked widgets so the app can control them.
+			t.widgets = append(t.widgets, widget)
+
+			// Get the grid.Element from our widget and place on the grid.
+			subElements = []grid.Element{widget.(elementer).getElement()}
+		}
+		totalFilled += elementPerc
+
+		// Place it on the row.
+		rowElements = append(rowElements, grid.ColWidthPerc(elementPerc, subElements...))
+	}
+
+	return rowElements
 }
 
 func (t *termDashboard) newWidget(widgetcfg model.Widget) (render.Widget, error) {
@@ -177,6 +264,8 @@ func (t *termDashboard) newWidget(widgetcfg model.Widget) (render.Widget, error)
 		widget, err = newSinglestat(widgetcfg)
 	case widgetcfg.Graph != nil:
 		widget, err = newGraph(widgetcfg)
+	case widgetcfg.Table != nil:
+		widget, err = newTable(widgetcfg)
 	}
 
 	return widget, err
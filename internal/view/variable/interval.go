@@ -1,44 +1,65 @@
 package variable
 
 import (
+	"sync"
 	"time"
 
 	"github.com/slok/grafterm/internal/model"
 	"github.com/slok/grafterm/internal/service/unit"
 )
 
+const defIntervalSteps = 50
+
 type intervalVariabler struct {
+	cfg   model.Variable
+	steps int
+
+	mu          sync.RWMutex
 	intervalStr string
-	cfg         model.Variable
 }
 
 // NewIntervalVariabler returns a new variabler that knows how to set
 // variables based on the interval, at this moment it only returns
-// autoinverval so is not repeatable.
+// autoinverval so is not repeatable. The interval is calculated again
+// when the time range changes.
 // TODO(slok): make repeatable and allow selecting multiple intervals.
-func NewIntervalVariabler(timeRange time.Duration, cfg model.Variable) Variabler {
+func NewIntervalVariabler(timeRange time.Duration, cfg model.Variable) TimeRangeSetter {
 	// Set default auto interval if not 0.
-	steps := 50
+	steps := defIntervalSteps
 	if cfg.Interval.Steps != 0 {
 		steps = cfg.Interval.Steps
 	}
-	dur := unit.NearestDurationFromSteps(timeRange, steps)
-	durStr := unit.DurationToSimpleString(dur)
 
-	return &intervalVariabler{
-		cfg:         cfg,
-		intervalStr: durStr,
+	i := &intervalVariabler{
+		cfg:   cfg,
+		steps: steps,
 	}
+	i.SetTimeRange(timeRange)
+
+	return i
 }
 
-func (i intervalVariabler) Scope() Scope {
-	return ScopeDashboard
+// Scope satisfies Variabler interface. The interval changes with the time
+// range so it's loaded on every sync.
+func (i *intervalVariabler) Scope() Scope {
+	return ScopeSync
 }
 
-func (i intervalVariabler) IsRepeatable() bool {
+func (i *intervalVariabler) IsRepeatable() bool {
 	return false
 }
 
-func (i intervalVariabler) GetValue() string {
+func (i *intervalVariabler) GetValue() string {
+	i.mu.RLock()
+	defer i.mu.RUnlock()
 	return i.intervalStr
 }
+
+// SetTimeRange satisfies TimeRangeSetter interface.
+func (i *intervalVariabler) SetTimeRange(timeRange time.Duration) {
+	dur := unit.NearestDurationFromSteps(timeRange, i.steps)
+
+	i.mu.Lock()
+	defer i.mu.Unlock()
+	i.intervalStr = unit.DurationToSimpleString(dur)
+}
@@ -0,0 +1,53 @@
+package variable_test
+
+import (
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+
+	"github.com/slok/grafterm/internal/model"
+	"github.com/slok/grafterm/internal/view/variable"
+)
+
+func TestIntervalVariablerSetTimeRange(t *testing.T) {
+	tests := []struct {
+		name         string
+		timeRange    time.Duration
+		newTimeRange time.Duration
+		expValue     string
+		expNewValue  string
+	}{
+		{
+			name:         "Zooming in should make the interval smaller.",
+			timeRange:    2 * time.Hour,
+			newTimeRange: time.Hour,
+			expValue:     "2m",
+			expNewValue:  "1m",
+		},
+		{
+			name:         "Zooming out should make the interval bigger.",
+			timeRange:    time.Hour,
+			newTimeRange: 10 * time.Hour,
+			expValue:     "1m",
+			expNewValue:  "10m",
+		},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			assert := assert.New(t)
+
+			cfg := model.Variable{
+				Name:           "interval",
+				VariableSource: model.VariableSource{Interval: &model.IntervalVariableSource{Steps: 60}},
+			}
+			v := variable.NewIntervalVariabler(test.timeRange, cfg)
+			assert.Equal(test.expValue, v.GetValue())
+			assert.Equal(variable.ScopeSync, v.Scope())
+
+			v.SetTimeRange(test.newTimeRange)
+			assert.Equal(test.expNewValue, v.GetValue())
+		})
+	}
+}
@@ -0,0 +1,274 @@
+package variable
+
+import (
+	"context"
+	"fmt"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/slok/grafterm/internal/controller"
+	"github.com/slok/grafterm/internal/model"
+	"github.com/slok/grafterm/internal/service/log"
+)
+
+const (
+	defQueryTimeout       = 5 * time.Second
+	maxDiscoveryRetryWait = 5 * time.Minute
+	multiValueSep         = "|"
+)
+
+type queryVariabler struct {
+	cfg    model.Variable
+	ctrl   controller.Controller
+	logger log.Logger
+
+	mu       sync.RWMutex
+	values   []string
+	selected []string
+	// discovered is true once the values have been discovered.
+	discovered bool
+}
+
+// newQueryVariabler returns a new variabler that knows how to set variables
+// based on the values discovered from a datasource. The values need to be
+// discovered before using it and will be refreshed on every sync if the
+// variable is configured to do so.
+// By default the first discovered value will be selected, the selected
+// values can be changed at any moment, if the variable is multi it will be
+// repeatable and multiple values can be selected at the same time.
+func newQueryVariabler(ctrl controller.Controller, cfg model.Variable, logger log.Logger) *queryVariabler {
+	return &queryVariabler{
+		cfg:    cfg,
+		ctrl:   ctrl,
+		logger: logger,
+	}
+}
+
+// Scope satisfies Variabler interface. Until the values are discovered the
+// variable has the sync scope so its value is loaded on every sync.
+func (q *queryVariabler) Scope() Scope {
+	q.mu.RLock()
+	discovered := q.discovered
+	q.mu.RUnlock()
+
+	if q.cfg.Query.RefreshOnSync || !discovered {
+		return ScopeSync
+	}
+	return ScopeDashboard
+}
+
+func (q *queryVariabler) IsRepeatable() bool {
+	return q.cfg.Query.Multi
+}
+
+func (q *queryVariabler) GetValue() string {
+	q.mu.RLock()
+	defer q.mu.RUnlock()
+	return strings.Join(q.selected, multiValueSep)
+}
+
+// Select satisfies Repeatable interface. The values that are not discovered
+// values will be ignored. If the variable is not repeatable the first
+// value will replace the selected value.
+func (q *queryVariabler) Select(values ...string) {
+	q.mu.Lock()
+	defer q.mu.Unlock()
+
+	for _, v := range values {
+		if !contains(q.values, v) {
+			continue
+		}
+
+		if !q.cfg.Query.Multi {
+			q.selected = []string{v}
+			return
+		}
+
+		if !contains(q.selected, v) {
+			q.selected = append(q.selected, v)
+		}
+	}
+
+	// Maintain the order of the discovered values.
+	q.selected = filterByValues(q.values, q.selected)
+}
+
+// Deselect satisfies Repeatable interface. A variable always has at least one
+// value selected, so deselecting all the values will not have effect.
+func (q *queryVariabler) Deselect(values ...string) {
+	q.mu.Lock()
+	defer q.mu.Unlock()
+
+	selected := []string{}
+	for _, v := range q.selected {
+		if !contains(values, v) {
+			selected = append(selected, v)
+		}
+	}
+
+	if len(selected) > 0 {
+		q.selected = selected
+	}
+}
+
+// GetValues satisfies Repeatable interface.
+func (q *queryVariabler) GetValues() []string {
+	q.mu.RLock()
+	defer q.mu.RUnlock()
+	return append([]string{}, q.selected...)
+}
+
+// GetAllValues satisfies Repeatable interface.
+func (q *queryVariabler) GetAllValues() []string {
+	q.mu.RLock()
+	defer q.mu.RUnlock()
+	return append([]string{}, q.values...)
+}
+
+// Refresh satisfies Refresher interface. The variables that don't have the
+// values discovered yet are not refreshed, their discovery is retried in
+// the background.
+func (q *queryVariabler) Refresh(ctx context.Context) error {
+	q.mu.RLock()
+	discovered := q.discovered
+	q.mu.RUnlock()
+
+	if !discovered {
+		return nil
+	}
+
+	return q.discover(ctx)
+}
+
+// discover discovers the values of the variable from the datasource.
+func (q *queryVariabler) discover(ctx context.Context) error {
+	ctx, cancel := context.WithTimeout(ctx, defQueryTimeout)
+	defer cancel()
+
+	vs, err := q.ctrl.GetDiscoveredValues(ctx, q.cfg.Query.Query)
+	if err != nil {
+		return fmt.Errorf("error discovering %s variable values: %w", q.cfg.Name, err)
+	}
+	vs = q.filterValues(vs)
+	sortValues(q.cfg.Query.Sort, vs)
+
+	q.mu.Lock()
+	defer q.mu.Unlock()
+
+	q.values = vs
+	q.discovered = true
+
+	// Maintain the selected values if still present, if not, select the first one.
+	q.selected = filterByValues(vs, q.selected)
+	if len(q.selected) == 0 && len(vs) > 0 {
+		q.selected = []string{vs[0]}
+	}
+
+	return nil
+}
+
+// retryDiscovery retries the discovery of the values in the background until
+// they are discovered or the context is done, the wait between the retries
+// is doubled on every failed retry. This way a datasource that is down
+// doesn't delay the syncs of the dashboard.
+func (q *queryVariabler) retryDiscovery(ctx context.Context, wait time.Duration) {
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-time.After(wait):
+		}
+
+		err := q.discover(ctx)
+		if err == nil {
+			return
+		}
+		q.logger.Errorf("error retrying the discovery of %s variable values: %s", q.cfg.Name, err)
+
+		wait *= 2
+		if wait > maxDiscoveryRetryWait {
+			wait = maxDiscoveryRetryWait
+		}
+	}
+}
+
+// filterValues will remove the duplicated values and the ones that don't
+// match the regex of the variable. If the regex has a capture group the
+// value will be replaced by the first captured group.
+func (q *queryVariabler) filterValues(vs []string) []string {
+	re := q.cfg.Query.CompiledRegex
+
+	res := []string{}
+	seen := map[string]bool{}
+	for _, v := range vs {
+		if re != nil {
+			match := re.FindStringSubmatch(v)
+			if match == nil {
+				continue
+			}
+			if len(match) > 1 {
+				v = match[1]
+			}
+		}
+
+		if v == "" || seen[v] {
+			continue
+		}
+		seen[v] = true
+		res = append(res, v)
+	}
+
+	return res
+}
+
+// sortValues sorts the values in place based on the sort mode.
+func sortValues(mode model.VariableSort, vs []string) {
+	switch mode {
+	case model.VariableSortAlphabetical:
+		sort.Strings(vs)
+	case model.VariableSortAlphabeticalDesc:
+		sort.Sort(sort.Reverse(sort.StringSlice(vs)))
+	case model.VariableSortNumerical, model.VariableSortNumericalDesc:
+		desc := mode == model.VariableSortNumericalDesc
+		sort.SliceStable(vs, func(i, j int) bool {
+			fi, erri := strconv.ParseFloat(vs[i], 64)
+			fj, errj := strconv.ParseFloat(vs[j], 64)
+			switch {
+			// Not numbers at the end.
+			case erri != nil || errj != nil:
+				if erri != nil && errj != nil {
+					return vs[i] < vs[j]
+				}
+				return erri == nil
+			case desc:
+				return fi > fj
+			default:
+				return fi < fj
+			}
+		})
+	}
+}
+
+// filterByValues returns the values that are on the filter using the order
+// of the values.
+func filterByValues(values, filter []string) []string {
+	res := []string{}
+	for _, v := range values {
+		if contains(filter, v) {
+			res = append(res, v)
+		}
+	}
+	return res
+}
+
+func contains(values []string, value string) bool {
+	for _, v := range values {
+		if v == value {
+			return true
+		}
+	}
+	return false
+}
@@ -0,0 +1,243 @@
+package variable_test
+
+import (
+	"context"
+	"errors"
+	"regexp"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/mock"
+	"github.com/stretchr/testify/require"
+
+	"github.com/slok/grafterm/internal/controller"
+	mcontroller "github.com/slok/grafterm/internal/mocks/controller"
+	"github.com/slok/grafterm/internal/model"
+	"github.com/slok/grafterm/internal/view/variable"
+)
+
+// newQueryVariabler returns the query variabler of the variable created
+// by the variablers factory.
+func newQueryVariabler(ctx context.Context, t *testing.T, ctrl controller.Controller, cfg model.Variable) variable.Repeatable {
+	vs, err := variable.NewVariablers(ctx, variable.FactoryConfig{
+		Dashboard:  model.Dashboard{Variables: []model.Variable{cfg}},
+		Controller: ctrl,
+	})
+	require.NoError(t, err)
+
+	return vs[cfg.Name].(variable.Repeatable)
+}
+
+func TestQueryVariabler(t *testing.T) {
+	tests := []struct {
+		name             string
+		cfg              model.QueryVariableSource
+		controllerValues []string
+		controllerErr    error
+		expValue         string
+		expScope         variable.Scope
+	}{
+		{
+			name:          "An error discovering the values should start the variable without values.",
+			controllerErr: errors.New("wanted error"),
+			expValue:      "",
+			expScope:      variable.ScopeSync,
+		},
+		{
+			name:             "Without sort the first value of the datasource should be selected.",
+			cfg:              model.QueryVariableSource{Sort: model.VariableSortNone},
+			controllerValues: []string{"prod", "dev", "staging"},
+			expValue:         "prod",
+			expScope:         variable.ScopeDashboard,
+		},
+		{
+			name:             "Refreshing on sync should have the sync scope.",
+			cfg:              model.QueryVariableSource{RefreshOnSync: true},
+			controllerValues: []string{"prod", "dev", "staging"},
+			expValue:         "prod",
+			expScope:         variable.ScopeSync,
+		},
+		{
+			name:             "Alphabetical sort should select the first value in alphabetical order.",
+			cfg:              model.QueryVariableSource{Sort: model.VariableSortAlphabetical},
+			controllerValues: []string{"prod", "dev", "staging"},
+			expValue:         "dev",
+		},
+		{
+			name:             "Alphabetical desc sort should select the first value in reverse alphabetical order.",
+			cfg:              model.QueryVariableSource{Sort: model.VariableSortAlphabeticalDesc},
+			controllerValues: []string{"prod", "dev", "staging"},
+			expValue:         "staging",
+		},
+		{
+			name:             "Numerical sort should select the lowest number.",
+			cfg:              model.QueryVariableSource{Sort: model.VariableSortNumerical},
+			controllerValues: []string{"abc", "100", "9", "25"},
+			expValue:         "9",
+		},
+		{
+			name:             "Numerical desc sort should select the highest number.",
+			cfg:              model.QueryVariableSource{Sort: model.VariableSortNumericalDesc},
+			controllerValues: []string{"abc", "100", "9", "25"},
+			expValue:         "100",
+		},
+		{
+			name: "Regex should filter the values.",
+			cfg: model.QueryVariableSource{
+				CompiledRegex: regexp.MustCompile("^s"),
+				Sort:          model.VariableSortAlphabetical,
+			},
+			controllerValues: []string{"prod", "dev", "staging", "sandbox"},
+			expValue:         "sandbox",
+		},
+		{
+			name: "Regex with a capture group should use the first group as the value.",
+			cfg: model.QueryVariableSource{
+				CompiledRegex: regexp.MustCompile("^ns-(.+)$"),
+			},
+			controllerValues: []string{"kube-system", "ns-monitoring", "ns-apps"},
+			expValue:         "monitoring",
+		},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			assert := assert.New(t)
+
+			cfg := model.Variable{
+				Name:           "test",
+				VariableSource: model.VariableSource{Query: &test.cfg},
+			}
+
+			// Mocks.
+			mc := &mcontroller.Controller{}
+			mc.On("GetDiscoveredValues", mock.Anything, mock.Anything).Once().Return(test.controllerValues, test.controllerErr)
+
+			ctx, cancel := context.WithCancel(context.Background())
+			defer cancel()
+			v := newQueryVariabler(ctx, t, mc, cfg)
+
+			assert.Equal(test.expValue, v.GetValue())
+			assert.Equal(test.expScope, v.Scope())
+			mc.AssertExpectations(t)
+		})
+	}
+}
+
+func TestQueryVariablerRefresh(t *testing.T) {
+	tests := []struct {
+		name     string
+		first    []string
+		second   []string
+		expValue string
+	}{
+		{
+			name:     "Refreshing should maintain the selected value if still present.",
+			first:    []string{"prod", "dev"},
+			second:   []string{"staging", "prod"},
+			expValue: "prod",
+		},
+		{
+			name:     "Refreshing should select the first value if the selected value is not present anymore.",
+			first:    []string{"prod", "dev"},
+			second:   []string{"staging", "dev"},
+			expValue: "staging",
+		},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			assert := assert.New(t)
+
+			cfg := model.Variable{
+				Name:           "test",
+				VariableSource: model.VariableSource{Query: &model.QueryVariableSource{}},
+			}
+
+			// Mocks.
+			mc := &mcontroller.Controller{}
+			mc.On("GetDiscoveredValues", mock.Anything, mock.Anything).Once().Return(test.first, nil)
+			mc.On("GetDiscoveredValues", mock.Anything, mock.Anything).Once().Return(test.second, nil)
+
+			v := newQueryVariabler(context.TODO(), t, mc, cfg)
+
+			err := v.(variable.Refresher).Refresh(context.TODO())
+			if assert.NoError(err) {
+				assert.Equal(test.expValue, v.GetValue())
+				mc.AssertExpectations(t)
+			}
+		})
+	}
+}
+
+func TestQueryVariablerSelect(t *testing.T) {
+	tests := []struct {
+		name        string
+		multi       bool
+		selectVals  []string
+		deselect    []string
+		expSelected []string
+		expValue    string
+	}{
+		{
+			name:        "Selecting a value on a single variable should replace the selected value.",
+			selectVals:  []string{"staging"},
+			expSelected: []string{"staging"},
+			expValue:    "staging",
+		},
+		{
+			name:        "Selecting a value that is not discovered should be ignored.",
+			selectVals:  []string{"unknown"},
+			expSelected: []string{"prod"},
+			expValue:    "prod",
+		},
+		{
+			name:        "Selecting values on a multi variable should add the values in the discovered order.",
+			multi:       true,
+			selectVals:  []string{"staging", "dev"},
+			expSelected: []string{"prod", "dev", "staging"},
+			expValue:    "prod|dev|staging",
+		},
+		{
+			name:        "Deselecting values on a multi variable should remove the values.",
+			multi:       true,
+			selectVals:  []string{"staging", "dev"},
+			deselect:    []string{"prod", "staging"},
+			expSelected: []string{"dev"},
+			expValue:    "dev",
+		},
+		{
+			name:        "Deselecting all the values should maintain the selected values.",
+			multi:       true,
+			selectVals:  []string{"dev"},
+			deselect:    []string{"prod", "dev"},
+			expSelected: []string{"prod", "dev"},
+			expValue:    "prod|dev",
+		},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			assert := assert.New(t)
+
+			cfg := model.Variable{
+				Name:           "test",
+				VariableSource: model.VariableSource{Query: &model.QueryVariableSource{Multi: test.multi}},
+			}
+
+			// Mocks.
+			mc := &mcontroller.Controller{}
+			mc.On("GetDiscoveredValues", mock.Anything, mock.Anything).Once().Return([]string{"prod", "dev", "staging"}, nil)
+
+			v := newQueryVariabler(context.TODO(), t, mc, cfg)
+
+			v.Select(test.selectVals...)
+			v.Deselect(test.deselect...)
+
+			assert.Equal(test.multi, v.IsRepeatable())
+			assert.Equal(test.expSelected, v.GetValues())
+			assert.Equal(test.expValue, v.GetValue())
+			assert.Equal([]string{"prod", "dev", "staging"}, v.GetAllValues())
+		})
+	}
+}
@@ -1,9 +1,12 @@
 package variable
 
 import (
+	"context"
 	"time"
 
+	"github.com/slok/grafterm/internal/controller"
 	"github.com/slok/grafterm/internal/model"
+	"github.com/slok/grafterm/internal/service/log"
 )
 
 // Scope is the scope of the variable
@@ -36,14 +39,49 @@ type Repeatable interface {
 	GetAllValues() []string
 }
 
+// Refresher is a variabler that knows how to refresh its values.
+type Refresher interface {
+	Variabler
+	Refresh(ctx context.Context) error
+}
+
+const defDiscoveryRetryWait = 10 * time.Second
+
+// TimeRangeSetter is a variabler whose value depends on the time range of
+// the dashboard.
+type TimeRangeSetter interface {
+	Variabler
+	SetTimeRange(timeRange time.Duration)
+}
+
 // FactoryConfig is the configuration required by the variabler factory.
 type FactoryConfig struct {
-	TimeRange time.Duration
-	Dashboard model.Dashboard
+	TimeRange  time.Duration
+	Dashboard  model.Dashboard
+	Controller controller.Controller
+	Logger     log.Logger
+	// DiscoveryRetryWait is the time waited before retrying the failed
+	// discovery of the query variables values, doubled on every retry.
+	DiscoveryRetryWait time.Duration
+}
+
+func (c *FactoryConfig) defaults() {
+	if c.Logger == nil {
+		c.Logger = log.Dummy
+	}
+
+	if c.DiscoveryRetryWait <= 0 {
+		c.DiscoveryRetryWait = defDiscoveryRetryWait
+	}
 }
 
 // NewVariablers is a factory that knows how to create variablers.
-func NewVariablers(cfg FactoryConfig) (map[string]Variabler, error) {
+// The query variables whose values can't be discovered (e.g the datasource
+// is down) start without values and their discovery is retried in the
+// background until the values are discovered or the context is done.
+func NewVariablers(ctx context.Context, cfg FactoryConfig) (map[string]Variabler, error) {
+	cfg.defaults()
+
 	variablers := map[string]Variabler{}
 	for _, v := range cfg.Dashboard.Variables {
 		switch {
@@ -51,6 +89,14 @@ func NewVariablers(cfg FactoryConfig) (map[string]Variabler, error) {
 			variablers[v.Name] = &ConstVariabler{cfg: v}
 		case v.Interval != nil:
 			variablers[v.Name] = NewIntervalVariabler(cfg.TimeRange, v)
+		case v.Query != nil:
+			qv := newQueryVariabler(cfg.Controller, v, cfg.Logger)
+			err := qv.discover(ctx)
+			if err != nil {
+				cfg.Logger.Errorf("variable %s started without values: %s", v.Name, err)
+				go qv.retryDiscovery(ctx, cfg.DiscoveryRetryWait)
+			}
+			variablers[v.Name] = qv
 		}
 	}
 
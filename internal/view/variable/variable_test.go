@@ -0,0 +1,59 @@
+package variable_test
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/mock"
+	"github.com/stretchr/testify/require"
+
+	mcontroller "github.com/slok/grafterm/internal/mocks/controller"
+	"github.com/slok/grafterm/internal/model"
+	"github.com/slok/grafterm/internal/view/variable"
+)
+
+func TestNewVariablersFailedDiscovery(t *testing.T) {
+	assert := assert.New(t)
+	require := require.New(t)
+
+	db := model.Dashboard{
+		Variables: []model.Variable{
+			{
+				Name:           "env",
+				VariableSource: model.VariableSource{Query: &model.QueryVariableSource{}},
+			},
+		},
+	}
+
+	// Mocks.
+	mc := &mcontroller.Controller{}
+	mc.On("GetDiscoveredValues", mock.Anything, mock.Anything).Once().Return(nil, errors.New("wanted error"))
+	mc.On("GetDiscoveredValues", mock.Anything, mock.Anything).Once().Return([]string{"prod", "dev"}, nil)
+
+	// A failed discovery should not fail and the discovery should be
+	// retried in the background until the values are discovered.
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	vs, err := variable.NewVariablers(ctx, variable.FactoryConfig{
+		Dashboard:          db,
+		Controller:         mc,
+		DiscoveryRetryWait: time.Millisecond,
+	})
+	require.NoError(err)
+	v := vs["env"]
+	assert.Equal(variable.ScopeSync, v.Scope())
+
+	// The refreshes of the syncs should not discover the values.
+	err = v.(variable.Refresher).Refresh(context.TODO())
+	require.NoError(err)
+
+	for i := 0; i < 100 && v.Scope() != variable.ScopeDashboard; i++ {
+		time.Sleep(10 * time.Millisecond)
+	}
+	assert.Equal("prod", v.GetValue())
+	assert.Equal(variable.ScopeDashboard, v.Scope())
+	mc.AssertExpectations(t)
+}